@@ -8,7 +8,9 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/cmd/utils"
@@ -32,6 +34,11 @@ var (
 
 	address string
 	policy  string
+
+	notBefore string
+	notAfter  string
+	contracts string
+	maxValue  string
 )
 
 var UpdateCommand = cli.Command{
@@ -110,6 +117,26 @@ var AddCommand = cli.Command{
 			Destination: &aclType,
 			Required:    true,
 		},
+		&cli.StringFlag{
+			Name:        "not-before",
+			Usage:       "Time (RFC3339) from which the policy is in effect, empty for immediately",
+			Destination: &notBefore,
+		},
+		&cli.StringFlag{
+			Name:        "not-after",
+			Usage:       "Time (RFC3339) at which the policy expires, empty for never",
+			Destination: &notAfter,
+		},
+		&cli.StringFlag{
+			Name:        "contracts",
+			Usage:       "Comma separated list of the contracts the account may call (callOnly policy)",
+			Destination: &contracts,
+		},
+		&cli.StringFlag{
+			Name:        "max-value",
+			Usage:       "Maximum value in wei the account may transfer per transaction (maxValue policy)",
+			Destination: &maxValue,
+		},
 	},
 }
 
@@ -130,22 +157,56 @@ func addRun(cliCtx *cli.Context) error {
 	}
 
 	addr := common.HexToAddress(address)
-	policy, err := txpool.ResolvePolicy(policy)
+	entry, err := parsePolicyEntry(policy, notBefore, notAfter, contracts, maxValue)
 	if err != nil {
 		log.Error("Failed to resolve policy", "err", err)
 		return err
 	}
 
-	if err := txpool.AddPolicy(cliCtx.Context, aclDB, aclType, addr, policy); err != nil {
+	if err := txpool.AddPolicyEntry(cliCtx.Context, aclDB, aclType, addr, entry); err != nil {
 		log.Error("Failed to add policy", "err", err)
 		return err
 	}
 
-	log.Info("Policy added", "address", address, "policy", policy)
+	log.Info("Policy added", "address", address, "policy", entry.String())
 
 	return nil
 }
 
+// parsePolicyEntry builds a policy entry from the add command flags
+func parsePolicyEntry(policyName, notBefore, notAfter, contracts, maxValue string) (txpool.PolicyEntry, error) {
+	policy, err := txpool.ResolvePolicy(policyName)
+	if err != nil {
+		return txpool.PolicyEntry{}, err
+	}
+
+	entry := txpool.PolicyEntry{Policy: policy}
+	if notBefore != "" {
+		if entry.NotBefore, err = time.Parse(time.RFC3339, notBefore); err != nil {
+			return txpool.PolicyEntry{}, fmt.Errorf("invalid not-before: %w", err)
+		}
+	}
+	if notAfter != "" {
+		if entry.NotAfter, err = time.Parse(time.RFC3339, notAfter); err != nil {
+			return txpool.PolicyEntry{}, fmt.Errorf("invalid not-after: %w", err)
+		}
+	}
+	for _, c := range splitPolicies(contracts) {
+		if !common.IsHexAddress(c) {
+			return txpool.PolicyEntry{}, fmt.Errorf("invalid contract address: %s", c)
+		}
+		entry.Contracts = append(entry.Contracts, common.HexToAddress(c))
+	}
+	if maxValue != "" {
+		entry.MaxValue, err = uint256.FromDecimal(maxValue)
+		if err != nil {
+			return txpool.PolicyEntry{}, fmt.Errorf("invalid max-value: %w", err)
+		}
+	}
+
+	return entry, entry.Validate()
+}
+
 // removeRun is the entry point for the remove command that removes the ACL policy for the given address
 func removeRun(cliCtx *cli.Context) error {
 	if !cliCtx.IsSet(utils.DataDirFlag.Name) {
@@ -103,3 +103,25 @@ func TestUpdatePolicies(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePolicyEntry(t *testing.T) {
+	entry, err := parsePolicyEntry("callOnly", "2025-01-01T00:00:00Z", "2025-02-01T00:00:00Z", "0x0000000000000000000000000000000000c0ffee, 0x000000000000000000000000000000000000dead", "")
+	require.NoError(t, err)
+	require.Equal(t, txpool.CallOnly, entry.Policy)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), entry.NotBefore.UTC())
+	require.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), entry.NotAfter.UTC())
+	require.Len(t, entry.Contracts, 2)
+
+	entry, err = parsePolicyEntry("maxValue", "", "", "", "1000000000000000000")
+	require.NoError(t, err)
+	require.Equal(t, "1000000000000000000", entry.MaxValue.Dec())
+
+	_, err = parsePolicyEntry("callOnly", "", "", "", "")
+	require.ErrorContains(t, err, "requires at least one contract")
+
+	_, err = parsePolicyEntry("sendTx", "tomorrow", "", "", "")
+	require.ErrorContains(t, err, "invalid not-before")
+
+	_, err = parsePolicyEntry("sendTx", "2025-02-01T00:00:00Z", "2025-01-01T00:00:00Z", "", "")
+	require.ErrorContains(t, err, "not-after must be later")
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	common2 "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcfg"
+	"github.com/ledgerwatch/erigon/cmd/hack/tool/fromdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/turbo/debug"
+	"github.com/ledgerwatch/erigon/turbo/transactions"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/spf13/cobra"
+)
+
+const backfillCommitEvery = 1000 // blocks
+
+var (
+	backfillFrom, backfillTo uint64
+)
+
+var cmdBackfillInnerTxs = &cobra.Command{
+	Use: "backfill_inner_txs",
+	Short: `Re-execute the blocks of a range to store their inner txs, for the nodes executing them before inner txs were enabled.
+The blocks which inner txs are already stored are only indexed by address, the node must be stopped and keep the history of the range.`,
+	Example: "go run ./cmd/integration backfill_inner_txs --datadir=... --from=1000 --to=2000",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := backfillInnerTxs(ctx, db, backfillFrom, backfillTo, logger); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+func init() {
+	withDataDir2(cmdBackfillInnerTxs)
+	cmdBackfillInnerTxs.Flags().Uint64Var(&backfillFrom, "from", 1, "first block to backfill")
+	cmdBackfillInnerTxs.Flags().Uint64Var(&backfillTo, "to", 0, "last block to backfill, defaults to the last executed block")
+	rootCmd.AddCommand(cmdBackfillInnerTxs)
+}
+
+func backfillInnerTxs(ctx context.Context, db kv.RwDB, from, to uint64, logger log.Logger) error {
+	sn, borSn, agg := allSnapshots(ctx, db, logger)
+	defer sn.Close()
+	defer borSn.Close()
+	defer agg.Close()
+	chainConfig, historyV3 := fromdb.ChainConfig(db), kvcfg.HistoryV3.FromDB(db)
+	br, _ := blocksIO(db, logger)
+	engine, _ := initConsensusEngine(ctx, chainConfig, datadirCli, db, br, logger)
+
+	var executed uint64
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		executed, err = stages.GetStageProgress(tx, stages.Execution)
+		return err
+	}); err != nil {
+		return err
+	}
+	if to == 0 || to > executed {
+		to = executed
+	}
+	if from == 0 {
+		from = 1
+	}
+	if from > to {
+		return fmt.Errorf("nothing to backfill from block %d to block %d, the last executed block is %d", from, to, executed)
+	}
+
+	logEvery := time.NewTicker(20 * time.Second)
+	defer logEvery.Stop()
+
+	backfilled, reindexed := 0, 0
+	for chunkFrom := from; chunkFrom <= to; chunkFrom += backfillCommitEvery {
+		chunkTo := chunkFrom + backfillCommitEvery - 1
+		if chunkTo > to {
+			chunkTo = to
+		}
+
+		if err := db.Update(ctx, func(tx kv.RwTx) error {
+			hermezDb := hermez_db.NewHermezDb(tx)
+			for blockNum := chunkFrom; blockNum <= chunkTo; blockNum++ {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-logEvery.C:
+					logger.Info("[backfill_inner_txs] Progress", "block", blockNum, "to", to, "backfilled", backfilled, "reindexed", reindexed)
+				default:
+				}
+
+				block, err := br.BlockByNumber(ctx, tx, blockNum)
+				if err != nil {
+					return err
+				}
+				if block == nil {
+					return fmt.Errorf("block %d not found", blockNum)
+				}
+				if len(hermezDb.GetInnerTxs(blockNum)) >= len(block.Transactions()) {
+					// they may have been stored before the address index existed
+					if err := hermezDb.ReindexInnerTxs(blockNum); err != nil {
+						return err
+					}
+					reindexed++
+					continue
+				}
+
+				innerTxs, err := transactions.TraceBlockInnerTxs(ctx, engine, block, chainConfig, br, tx, historyV3)
+				if err != nil {
+					return fmt.Errorf("trace the inner txs of block %d: %w", blockNum, err)
+				}
+				if err := hermezDb.BackfillInnerTxs(blockNum, innerTxs); err != nil {
+					return err
+				}
+				backfilled++
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("[backfill_inner_txs] Done", "from", from, "to", to, "backfilled", backfilled, "reindexed", reindexed)
+	return nil
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	common2 "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/turbo/debug"
+	zkSmt "github.com/ledgerwatch/erigon/zk/smt"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	smtPruneOrphans, smtRepair bool
+	smtRebuild                 string
+)
+
+var cmdSmtCheck = &cobra.Command{
+	Use: "smt_check",
+	Short: `Check the SMT against the PlainState: the hash of every node reached from the root, the leaves, their hash keys and key sources, and the nodes no root uses.
+The tree can be fixed in place by rebuilding the damaged subtrees, or a given one, out of the PlainState. The node must be stopped, with the tree computed up to the executed block.`,
+	Example: "go run ./cmd/integration smt_check --datadir=... --repair --prune-orphans",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := smtCheck(ctx, db, logger); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+func init() {
+	withDataDir2(cmdSmtCheck)
+	cmdSmtCheck.Flags().BoolVar(&smtPruneOrphans, "prune-orphans", false, "delete the nodes no root uses")
+	cmdSmtCheck.Flags().BoolVar(&smtRepair, "repair", false, "rebuild the subtrees holding the issues found")
+	cmdSmtCheck.Flags().StringVar(&smtRebuild, "rebuild", "", "rebuild the subtree at the given path, as bits from the root or \"root\" for the whole tree")
+	rootCmd.AddCommand(cmdSmtCheck)
+}
+
+func smtCheck(ctx context.Context, db kv.RwDB, logger log.Logger) error {
+	const logPrefix = "smt_check"
+
+	var rebuild []int
+	if smtRebuild != "" {
+		var err error
+		if rebuild, err = zkSmt.ParseSmtPath(smtRebuild); err != nil {
+			return err
+		}
+	}
+
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the PlainState must be the state the tree was computed from
+	block, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	executed, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if block != executed {
+		return fmt.Errorf("the tree is computed up to block %d and the state executed up to block %d, run the stages up to the same block first", block, executed)
+	}
+	header := rawdb.ReadHeaderByNumber(tx, block)
+	if header == nil {
+		return fmt.Errorf("no header found with number %d", block)
+	}
+
+	report, err := zkSmt.CheckSmt(ctx, logPrefix, tx, datadir.New(datadirCli).Tmp, smtPruneOrphans)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range report.Issues {
+		logger.Warn(fmt.Sprintf("[%s] %s", logPrefix, issue.Kind), "path", zkSmt.SmtPathString(issue.Path), "key", common2.BigToHash(issue.Key.ToBigInt()), "info", issue.Info)
+	}
+	logger.Info(fmt.Sprintf("[%s] Checked", logPrefix), "block", block, "root", report.Root, "headerRoot", header.Root,
+		"nodes", report.Nodes, "leaves", report.Leaves, "stateLeaves", report.StateLeaves, "orphans", report.Orphans,
+		"unreferencedValues", report.UnreferencedValues, "pruned", report.Pruned, "issues", report.IssueCounts)
+
+	var paths [][]int
+	if smtRepair {
+		paths = report.DamagedPaths()
+	}
+	if smtRebuild != "" {
+		paths = append(paths, rebuild)
+	}
+	if len(paths) > 0 {
+		rebuilt, err := zkSmt.RebuildSmtSubtrees(ctx, logPrefix, tx, paths)
+		if err != nil {
+			return err
+		}
+		lastRoot, err := db2.NewEriDb(tx).GetLastRoot()
+		if err != nil {
+			return err
+		}
+		root := common2.BigToHash(lastRoot)
+		// nothing is written unless the whole tree is right
+		if root != header.Root {
+			return fmt.Errorf("the rebuilt root %x doesn't match the header root %x of block %d, nothing was written", root, header.Root, block)
+		}
+		logger.Info(fmt.Sprintf("[%s] Rebuilt", logPrefix), "subtrees", len(rebuilt), "root", root)
+	} else if smtRepair && report.Root != header.Root {
+		return fmt.Errorf("the root %x doesn't match the header root %x of block %d with no issue found, rebuild the tree with --rebuild=root", report.Root, header.Root, block)
+	}
+
+	if len(paths) == 0 && report.Pruned == 0 {
+		return nil
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	common2 "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/membatchwithdb"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/turbo/debug"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	zkSmt "github.com/ledgerwatch/erigon/zk/smt"
+	zkStages "github.com/ledgerwatch/erigon/zk/stages"
+	zkUtils "github.com/ledgerwatch/erigon/zk/utils"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	smtSnapshotFile  string
+	smtSnapshotBlock uint64
+)
+
+var cmdSmtExport = &cobra.Command{
+	Use: "smt_export",
+	Short: `Export the SMT of a block into a snapshot file: the nodes, leaf values, key sources and contract codes of its tree.
+The block defaults to the one the tree is computed for, an older one needs its nodes kept by zkevm.smt-history-blocks.`,
+	Example: "go run ./cmd/integration smt_export --datadir=... --file=smt.snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := smtExport(ctx, db, logger); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+var cmdSmtImport = &cobra.Command{
+	Use: "smt_import",
+	Short: `Replace the SMT with the tree of a snapshot file, checked against the state root of its block in hermez_stateRoots.
+The node must be stopped and have synced the batches up to the block, the rest of the state of the block comes from elsewhere.`,
+	Example: "go run ./cmd/integration smt_import --datadir=... --file=smt.snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := smtImport(ctx, db, logger); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+func init() {
+	withDataDir2(cmdSmtExport)
+	cmdSmtExport.Flags().StringVar(&smtSnapshotFile, "file", "", "snapshot file to write")
+	must(cmdSmtExport.MarkFlagRequired("file"))
+	cmdSmtExport.Flags().Uint64Var(&smtSnapshotBlock, "block", 0, "block to export the tree of, defaults to the one the tree is computed for")
+	rootCmd.AddCommand(cmdSmtExport)
+
+	withDataDir2(cmdSmtImport)
+	cmdSmtImport.Flags().StringVar(&smtSnapshotFile, "file", "", "snapshot file to read")
+	must(cmdSmtImport.MarkFlagRequired("file"))
+	rootCmd.AddCommand(cmdSmtImport)
+}
+
+func smtExport(ctx context.Context, db kv.RwDB, logger log.Logger) error {
+	const logPrefix = "smt_export"
+
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	latest, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	block := smtSnapshotBlock
+	if block == 0 {
+		block = latest
+	}
+	if block > latest {
+		return fmt.Errorf("the tree is computed up to block %d", latest)
+	}
+	header := rawdb.ReadHeaderByNumber(tx, block)
+	if header == nil {
+		return fmt.Errorf("no header found with number %d", block)
+	}
+
+	var source kv.Getter = tx
+	if block < latest {
+		batch := membatchwithdb.NewMemoryBatch(tx, datadir.New(datadirCli).Tmp, logger)
+		defer batch.Rollback()
+		if err := zkUtils.PopulateMemoryMutationTables(batch); err != nil {
+			return err
+		}
+		if err := zkStages.RestoreSmtHistory(ctx, logPrefix, batch, block); err != nil {
+			return fmt.Errorf("restore smt history: %w", err)
+		}
+		source = batch
+	}
+
+	// written aside first, a partial file is never left under the final name
+	tmpFile := smtSnapshotFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+	defer f.Close()
+
+	info, err := zkSmt.ExportSmt(ctx, logPrefix, db2.NewRoEriDb(source), block, header.Root, f)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, smtSnapshotFile); err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("[%s] Exported", logPrefix), "file", smtSnapshotFile, "block", info.Block, "root", info.Root,
+		"nodes", info.Nodes, "leaves", info.Leaves, "codes", info.Codes, "depth", info.Depth)
+	return nil
+}
+
+func smtImport(ctx context.Context, db kv.RwDB, logger log.Logger) error {
+	const logPrefix = "smt_import"
+
+	f, err := os.Open(smtSnapshotFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	info, err := zkSmt.ImportSmt(ctx, logPrefix, tx, f)
+	if err != nil {
+		return err
+	}
+
+	hermezDb := hermez_db.NewHermezDb(tx)
+	if err := hermezDb.TruncateSmtDepths(info.Block); err != nil {
+		return err
+	}
+	if err := hermezDb.WriteSmtDepth(info.Block, uint64(info.Depth)); err != nil {
+		return err
+	}
+	if err := stages.SaveStageProgress(tx, stages.IntermediateHashes, info.Block); err != nil {
+		return err
+	}
+
+	executed, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if executed != info.Block {
+		logger.Warn(fmt.Sprintf("[%s] The state is executed up to another block, it must be brought to the block of the tree before the node runs", logPrefix),
+			"treeBlock", info.Block, "executed", executed)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("[%s] Imported", logPrefix), "file", smtSnapshotFile, "block", info.Block, "root", info.Root,
+		"nodes", info.Nodes, "leaves", info.Leaves, "codes", info.Codes, "depth", info.Depth)
+	return nil
+}
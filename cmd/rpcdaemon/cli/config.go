@@ -573,6 +573,11 @@ func StartRpcServerWithJwtAuthentication(ctx context.Context, cfg *httpcfg.HttpC
 	return nil
 }
 
+// isAuthenticatedOnly returns true for the namespaces that are only served on the JWT authenticated endpoint
+func isAuthenticatedOnly(namespace string) bool {
+	return namespace == "engine" || namespace == "acl"
+}
+
 func startRegularRpcServer(ctx context.Context, cfg *httpcfg.HttpCfg, rpcAPI []rpc.API, logger log.Logger) error {
 	// register apis and create handler stack
 	srv := rpc.NewServer(cfg.RpcBatchConcurrency, cfg.TraceRequests, cfg.DebugSingleRequest, cfg.RpcStreamingDisable, logger, cfg.RPCSlowLogThreshold)
@@ -593,14 +598,14 @@ func startRegularRpcServer(ctx context.Context, cfg *httpcfg.HttpCfg, rpcAPI []r
 	var defaultAPIList []rpc.API
 
 	for _, api := range rpcAPI {
-		if api.Namespace != "engine" {
+		if !isAuthenticatedOnly(api.Namespace) {
 			defaultAPIList = append(defaultAPIList, api)
 		}
 	}
 
 	var apiFlags []string
 	for _, flag := range cfg.API {
-		if flag != "engine" {
+		if !isAuthenticatedOnly(flag) {
 			apiFlags = append(apiFlags, flag)
 		}
 	}
@@ -654,7 +659,7 @@ func startRegularRpcServer(ctx context.Context, cfg *httpcfg.HttpCfg, rpcAPI []r
 
 		var wsApiFlags []string
 		for _, flag := range cfg.WebsocketApi {
-			if flag != "engine" {
+			if !isAuthenticatedOnly(flag) {
 				wsApiFlags = append(wsApiFlags, flag)
 			}
 		}
@@ -669,14 +674,14 @@ func startRegularRpcServer(ctx context.Context, cfg *httpcfg.HttpCfg, rpcAPI []r
 		var defaultAPIList []rpc.API
 
 		for _, api := range rpcAPI {
-			if api.Namespace != "engine" {
+			if !isAuthenticatedOnly(api.Namespace) {
 				defaultAPIList = append(defaultAPIList, api)
 			}
 		}
 
 		var apiFlags []string
 		for _, flag := range cfg.API {
-			if flag != "engine" {
+			if !isAuthenticatedOnly(flag) {
 				apiFlags = append(apiFlags, flag)
 			}
 		}
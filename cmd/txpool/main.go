@@ -72,6 +72,9 @@ var (
 	freeGasLimit         uint64
 	enableFreeGasList    bool
 	freeGasList          string
+	priorityLanes        string
+	senderQuotas         string
+	contractQuotas       string
 
 	commitEvery   time.Duration
 	purgeEvery    time.Duration
@@ -117,6 +120,9 @@ func init() {
 	rootCmd.PersistentFlags().Uint64Var(&freeGasLimit, utils.TxPoolFreeGasLimit.Name, ethconfig.DeprecatedDefaultTxPoolConfig.FreeGasLimit, utils.TxPoolFreeGasLimit.Usage)
 	rootCmd.Flags().BoolVar(&enableFreeGasList, utils.TxPoolEnableFreeGasList.Name, ethconfig.DeprecatedDefaultTxPoolConfig.EnableFreeGasList, utils.TxPoolEnableFreeGasList.Usage)
 	rootCmd.PersistentFlags().StringVar(&freeGasList, utils.TxPoolFreeGasList.Name, "", utils.TxPoolFreeGasList.Usage)
+	rootCmd.PersistentFlags().StringVar(&priorityLanes, utils.TxPoolPriorityLanes.Name, "", utils.TxPoolPriorityLanes.Usage)
+	rootCmd.PersistentFlags().StringVar(&senderQuotas, utils.TxPoolSenderQuotas.Name, "", utils.TxPoolSenderQuotas.Usage)
+	rootCmd.PersistentFlags().StringVar(&contractQuotas, utils.TxPoolContractQuotas.Name, "", utils.TxPoolContractQuotas.Usage)
 }
 
 var rootCmd = &cobra.Command{
@@ -229,6 +235,30 @@ func doTxpool(ctx context.Context, logger log.Logger) error {
 			panic("unable to unmarshal freeGasList:" + err.Error())
 		}
 	}
+	if len(priorityLanes) > 0 {
+		if err := jsoniter.UnmarshalFromString(priorityLanes, &ethCfg.DeprecatedTxPool.PriorityLanes); err != nil {
+			panic("unable to unmarshal priorityLanes:" + err.Error())
+		}
+		if err := ethconfig.ValidatePriorityLanes(ethCfg.DeprecatedTxPool.PriorityLanes); err != nil {
+			panic("invalid priorityLanes:" + err.Error())
+		}
+	}
+	if len(senderQuotas) > 0 {
+		if err := jsoniter.UnmarshalFromString(senderQuotas, &ethCfg.DeprecatedTxPool.SenderQuotas); err != nil {
+			panic("unable to unmarshal senderQuotas:" + err.Error())
+		}
+		if err := ethconfig.ValidateThroughputQuotas(ethCfg.DeprecatedTxPool.SenderQuotas); err != nil {
+			panic("invalid senderQuotas:" + err.Error())
+		}
+	}
+	if len(contractQuotas) > 0 {
+		if err := jsoniter.UnmarshalFromString(contractQuotas, &ethCfg.DeprecatedTxPool.ContractQuotas); err != nil {
+			panic("unable to unmarshal contractQuotas:" + err.Error())
+		}
+		if err := ethconfig.ValidateThroughputQuotas(ethCfg.DeprecatedTxPool.ContractQuotas); err != nil {
+			panic("invalid contractQuotas:" + err.Error())
+		}
+	}
 
 	newTxs := make(chan types.Announcements, 1024)
 	defer close(newTxs)
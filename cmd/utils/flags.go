@@ -420,6 +420,26 @@ var (
 		Usage: "Use TLS connection to L2 datastreamer endpoint",
 		Value: false,
 	}
+	L2DataStreamerTokenFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-token",
+		Usage: "Token sent to the L2 datastreamer endpoint when it requires token authentication",
+		Value: "",
+	}
+	L2DataStreamerTLSCertFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-cert",
+		Usage: "Client certificate presented to the L2 datastreamer endpoint when it requires mutual TLS",
+		Value: "",
+	}
+	L2DataStreamerTLSKeyFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-key",
+		Usage: "Key of the client certificate presented to the L2 datastreamer endpoint",
+		Value: "",
+	}
+	L2DataStreamerTLSCAFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-ca",
+		Usage: "CA used to verify the certificate of the L2 datastreamer endpoint instead of the system roots",
+		Value: "",
+	}
 	L2DataStreamerTimeout = cli.StringFlag{
 		Name:  "zkevm.l2-datastreamer-timeout",
 		Usage: "The time to wait for data to arrive from the stream before reporting an error (0s doesn't check)",
@@ -460,6 +480,26 @@ var (
 		Usage: "The port used for the L1 cache",
 		Value: 6969,
 	}
+	L1CacheDefaultTTLFlag = cli.DurationFlag{
+		Name:  "zkevm.l1-cache-default-ttl",
+		Usage: "How long the L1 cache keeps responses that may still change, e.g. for blocks above the L1 finalized block. Responses for finalized blocks are kept forever. 0 disables caching them",
+		Value: 12 * time.Second,
+	}
+	L1CacheMethodTTLsFlag = cli.StringFlag{
+		Name:  "zkevm.l1-cache-method-ttls",
+		Usage: "A comma separated list of method=duration pairs overriding zkevm.l1-cache-default-ttl per method, e.g. eth_getLogs=1m,eth_gasPrice=0s",
+		Value: "",
+	}
+	L1CacheMaxSizeFlag = cli.StringFlag{
+		Name:  "zkevm.l1-cache-max-size",
+		Usage: "The size the L1 cache is capped at, the least recently used responses are evicted above it. 0 disables the cap",
+		Value: "1GB",
+	}
+	L1CacheFinalityPollIntervalFlag = cli.DurationFlag{
+		Name:  "zkevm.l1-cache-finality-poll-interval",
+		Usage: "How often the L1 cache checks the L1 head and finalized block to detect reorgs",
+		Value: 12 * time.Second,
+	}
 	AddressSequencerFlag = cli.StringFlag{
 		Name:  "zkevm.address-sequencer",
 		Usage: "Sequencer address",
@@ -506,6 +546,21 @@ var (
 		Usage: "The type of the highest block in the L1 chain. latest, safe, or finalized",
 		Value: "finalized",
 	}
+	L1EndpointMaxLagFlag = cli.Uint64Flag{
+		Name:  "zkevm.l1-endpoint-max-lag",
+		Usage: "L1 endpoints whose highest block is more blocks behind the best one are excluded for a while. 0 disables the check",
+		Value: 5,
+	}
+	L1EndpointExclusionBackoffFlag = cli.DurationFlag{
+		Name:  "zkevm.l1-endpoint-exclusion-backoff",
+		Usage: "The time a failing or lagging L1 endpoint is excluded for, doubled on every exclusion in a row",
+		Value: 30 * time.Second,
+	}
+	L1CrossCheckFlag = cli.BoolFlag{
+		Name:  "zkevm.l1-cross-check",
+		Usage: "Only accept the sequence and verification events once two L1 endpoints agree on the logs and block hashes",
+		Value: false,
+	}
 	L1MaticContractAddressFlag = cli.StringFlag{
 		Name:  "zkevm.l1-matic-contract-address",
 		Usage: "Ethereum L1 Matic contract address",
@@ -546,6 +601,11 @@ var (
 		Usage: "Regenerate the SMT in memory (requires a lot of RAM for most chains)",
 		Value: false,
 	}
+	SmtHistoryBlocks = cli.Uint64Flag{
+		Name:  "zkevm.smt-history-blocks",
+		Usage: "Keep the SMT nodes of this many blocks behind the head, and of the blocks not verified yet, so that zkevm_getProof serves them without rewinding the tree (0 disables it)",
+		Value: 0,
+	}
 	SequencerBlockSealTime = cli.StringFlag{
 		Name:  "zkevm.sequencer-block-seal-time",
 		Usage: "Block seal time. Defaults to 6s",
@@ -591,6 +651,16 @@ var (
 		Usage: "Reuse the L1 info index for resequencing",
 		Value: true,
 	}
+	SequencerDryRun = cli.BoolFlag{
+		Name:  "zkevm.sequencer-dry-run",
+		Usage: "On a node that is not the sequencer, build batches from its pool and state like the sequencer would, without committing them, and report them. The transactions it forwards to the sequencer are added to its pool too",
+		Value: false,
+	}
+	SequencerDryRunOutput = cli.StringFlag{
+		Name:  "zkevm.sequencer-dry-run-output",
+		Usage: "File the dry run batches are appended to, one json object per line. They are only logged when empty",
+		Value: "",
+	}
 	ExecutorUrls = cli.StringFlag{
 		Name:  "zkevm.executor-urls",
 		Usage: "A comma separated list of grpc addresses that host executors",
@@ -601,6 +671,11 @@ var (
 		Usage: "Enables the executor. Used for testing limbo, when executor-urls are set, but we don't want to use them, only in limbo to verify limbo transactions. For this case, set it to false. Defaulted to true",
 		Value: true,
 	}
+	StatelessVerification = cli.BoolFlag{
+		Name:  "zkevm.stateless-verification",
+		Usage: "Verify the batches without an executor, by re-executing them on the SMT built from their witness. Only used when no executor is in use",
+		Value: false,
+	}
 	ExecutorStrictMode = cli.BoolFlag{
 		Name:  "zkevm.executor-strict",
 		Usage: "Defaulted to true to ensure you must set some executor URLs, bypass this restriction by setting to false",
@@ -633,6 +708,16 @@ var (
 		Usage: "The maximum number of concurrent requests to the executor",
 		Value: 1,
 	}
+	ExecutorSlowRequestThreshold = cli.DurationFlag{
+		Name:  "zkevm.executor-slow-request-threshold",
+		Usage: "Executor requests taking longer than this mark the executor as unhealthy so others are preferred. 0 disables the check",
+		Value: 0,
+	}
+	ExecutorUnhealthyBackoff = cli.DurationFlag{
+		Name:  "zkevm.executor-unhealthy-backoff",
+		Usage: "The time a failing or slow executor is avoided for, doubled on every consecutive failure",
+		Value: 10 * time.Second,
+	}
 	RpcRateLimitsFlag = cli.IntFlag{
 		Name:  "zkevm.rpc-ratelimit",
 		Usage: "RPC rate limit in requests per second.",
@@ -673,6 +758,51 @@ var (
 		Usage: "Define the inactivity check interval timeout when interacting with a data stream server",
 		Value: 5 * time.Minute,
 	}
+	DataStreamInternalPort = cli.UintFlag{
+		Name:  "zkevm.data-stream-internal-port",
+		Usage: "The port the data stream server listens on behind the authenticating gateway when any of the data stream access control flags is set, defaults to zkevm.data-stream-port + 1. The data stream server only listens on the loopback then",
+		Value: 0,
+	}
+	DataStreamTLSCert = cli.StringFlag{
+		Name:  "zkevm.data-stream-tls-cert",
+		Usage: "Certificate used to serve the data stream over TLS",
+		Value: "",
+	}
+	DataStreamTLSKey = cli.StringFlag{
+		Name:  "zkevm.data-stream-tls-key",
+		Usage: "Key of the certificate used to serve the data stream over TLS",
+		Value: "",
+	}
+	DataStreamClientCA = cli.StringFlag{
+		Name:  "zkevm.data-stream-client-ca",
+		Usage: "CA the data stream clients certificates must be signed by (mutual TLS), the certificate common name identifies the client",
+		Value: "",
+	}
+	DataStreamTokensFile = cli.StringFlag{
+		Name:  "zkevm.data-stream-tokens-file",
+		Usage: "File with a '<client identity> <token>' pair per line, if set the data stream clients must authenticate with one of the tokens",
+		Value: "",
+	}
+	DataStreamAllowlist = cli.StringFlag{
+		Name:  "zkevm.data-stream-allowlist",
+		Usage: "Comma separated list of the data stream client identities allowed to connect (token identities, certificate common names or IPs)",
+		Value: "",
+	}
+	DataStreamMaxConnectionsPerClient = cli.IntFlag{
+		Name:  "zkevm.data-stream-max-connections-per-client",
+		Usage: "The maximum number of connections to the data stream per client identity, 0 means no limit",
+		Value: 0,
+	}
+	DataStreamClientRateLimit = cli.StringFlag{
+		Name:  "zkevm.data-stream-client-rate-limit",
+		Usage: "The maximum amount of data sent per second to each data stream client identity, e.g. 10MB. 0 means no limit",
+		Value: "0",
+	}
+	DataStreamEntryFiltering = cli.BoolFlag{
+		Name:  "zkevm.data-stream-entry-filtering",
+		Usage: "Serve the data stream through the gateway so clients can subscribe to the batch entries only or to the blocks without their transactions",
+		Value: false,
+	}
 	Limbo = cli.BoolFlag{
 		Name:  "zkevm.limbo",
 		Usage: "Enable limbo processing on batches that failed verification",
@@ -749,6 +879,21 @@ var (
 		Usage: "Reject smart contract deployments",
 		Value: false,
 	}
+	TxPoolBundleLimit = cli.IntFlag{
+		Name:  "zkevm.txpool-bundle-limit",
+		Usage: "Maximum number of pending transaction bundles in the txpool, 0 disables the bundle submission",
+		Value: 1024,
+	}
+	TxPoolBundleMaxTxs = cli.IntFlag{
+		Name:  "zkevm.txpool-bundle-max-txs",
+		Usage: "Maximum number of transactions in a bundle",
+		Value: 16,
+	}
+	TxPoolBundleLifetime = cli.DurationFlag{
+		Name:  "zkevm.txpool-bundle-lifetime",
+		Usage: "Time a bundle is kept pending before it is dropped, 0 to keep it until its max block number",
+		Value: 5 * time.Minute,
+	}
 	DisableVirtualCounters = cli.BoolFlag{
 		Name:  "zkevm.disable-virtual-counters",
 		Usage: "Disable the virtual counters. This has an effect on on sequencer node and when external executor is not enabled.",
@@ -764,6 +909,31 @@ var (
 		Usage: "The URL of the data availability service",
 		Value: "",
 	}
+	DABackend = cli.StringFlag{
+		Name:  "zkevm.da-backend",
+		Usage: "The data availability backend used to fetch validium batch data: dac (zkevm.da-url), committee (zkevm.da-committee-urls) or local (zkevm.da-local-path)",
+		Value: "dac",
+	}
+	DACommitteeUrls = cli.StringFlag{
+		Name:  "zkevm.da-committee-urls",
+		Usage: "A comma separated list of the URLs of the data availability committee members, tried in order by the committee backend",
+		Value: "",
+	}
+	DAQuorum = cli.IntFlag{
+		Name:  "zkevm.da-quorum",
+		Usage: "The number of committee members that have to serve the data before it is used by the committee backend",
+		Value: 1,
+	}
+	DALocalPath = cli.StringFlag{
+		Name:  "zkevm.da-local-path",
+		Usage: "The directory the local data availability backend serves the data from, one file per hash",
+		Value: "",
+	}
+	DAMaxAttempts = cli.IntFlag{
+		Name:  "zkevm.da-max-attempts",
+		Usage: "The number of attempts made against a rate limited data availability service",
+		Value: 10,
+	}
 	VirtualCountersSmtReduction = cli.Float64Flag{
 		Name:  "zkevm.virtual-counters-smt-reduction",
 		Usage: "The multiplier to reduce the SMT depth by when calculating virtual counters",
@@ -103,10 +103,22 @@ var (
 		Name:  "txpool.freegaslist",
 		Usage: "FreeGasList Project in JSON Format",
 	}
+	TxPoolPriorityLanes = cli.StringFlag{
+		Name:  "txpool.prioritylanes",
+		Usage: "Priority lanes in JSON format, by priority, each reserving a gas_share and counter_share of the block space to the txs matching claims, whitelist, from_list or to_list. A lane with no tx pending leaves its share to the others",
+	}
+	TxPoolSenderQuotas = cli.StringFlag{
+		Name:  "txpool.senderquotas",
+		Usage: "Throughput quotas of the senders in JSON format, by address or * for every other sender, capping their max_txs_per_block, max_gas_per_batch and max_counter_share of the batch counters",
+	}
+	TxPoolContractQuotas = cli.StringFlag{
+		Name:  "txpool.contractquotas",
+		Usage: "Throughput quotas of the destination contracts in JSON format, by address or * for every other contract, capping their max_txs_per_block, max_gas_per_batch and max_counter_share of the batch counters",
+	}
 	// Gas Pricer
 	GpoTypeFlag = cli.StringFlag{
 		Name:  "gpo.type",
-		Usage: "raw gas price strategy type: default, follower, fixed",
+		Usage: "raw gas price strategy type: default, follower, fixed, congestion",
 		Value: "default",
 	}
 	GpoUpdatePeriodFlag = cli.StringFlag{
@@ -174,11 +186,56 @@ var (
 		Usage: "raw gas price usdt",
 		Value: 0,
 	}
+	GpoCoinPriceSourceFlag = cli.StringFlag{
+		Name:  "gpo.coin-price-source",
+		Usage: "Source of the coin prices of the follower and fixed gas price strategies: kafka, http, file",
+		Value: "kafka",
+	}
+	GpoCoinPriceURLFlag = cli.StringFlag{
+		Name:  "gpo.coin-price-url",
+		Usage: "Endpoint the http coin price source polls, serving the JSON of the kafka messages",
+		Value: "",
+	}
+	GpoCoinPriceFileFlag = cli.StringFlag{
+		Name:  "gpo.coin-price-file",
+		Usage: "JSON file of the file coin price source, format: {\"l1CoinPrice\":2000,\"l2CoinPrice\":50}",
+		Value: "",
+	}
+	GpoCoinPricePollPeriodFlag = cli.DurationFlag{
+		Name:  "gpo.coin-price-poll-period",
+		Usage: "Period the http coin price source polls at",
+		Value: 30 * time.Second,
+	}
+	GpoCoinPriceMaxAgeFlag = cli.DurationFlag{
+		Name:  "gpo.coin-price-max-age",
+		Usage: "Age the coin prices are stale at, the last good ones being used and an alert raised, 0 to never be",
+		Value: 10 * time.Minute,
+	}
 	GpoCongestionThresholdFlag = cli.IntFlag{
 		Name:  "gpo.congestion-threshold",
 		Usage: "Used to determine whether pending tx has reached the threshold for congestion",
 		Value: 0,
 	}
+	GpoCongestionTargetLoadFlag = cli.Float64Flag{
+		Name:  "gpo.congestion-target-load",
+		Usage: "Load of the chain, from 0 to 1, the congestion gas price strategy holds the price at, raising it above and lowering it below",
+		Value: 0.5,
+	}
+	GpoCongestionBlocksFlag = cli.IntFlag{
+		Name:  "gpo.congestion-blocks",
+		Usage: "Number of recent blocks the congestion gas price strategy measures the load over",
+		Value: 10,
+	}
+	GpoCongestionBlockGasFlag = cli.Uint64Flag{
+		Name:  "gpo.congestion-block-gas",
+		Usage: "Gas used by a block the congestion gas price strategy counts as full",
+		Value: 30_000_000,
+	}
+	GpoCongestionPendingTxsFlag = cli.IntFlag{
+		Name:  "gpo.congestion-pending-txs",
+		Usage: "Number of pending txs the congestion gas price strategy counts as a full pool",
+		Value: 1000,
+	}
 	SequencerBatchSleepDuration = cli.DurationFlag{
 		Name:  "zkevm.sequencer-batch-sleep-duration",
 		Usage: "Full batch sleep duration is the time the sequencer sleeps between each full batch iteration.",
@@ -256,9 +313,36 @@ func setGPOXLayer(ctx *cli.Context, cfg *gaspricecfg.Config) {
 	if ctx.IsSet(GpoGasPriceUsdtFlag.Name) {
 		cfg.XLayer.GasPriceUsdt = ctx.Float64(GpoGasPriceUsdtFlag.Name)
 	}
+	if ctx.IsSet(GpoCoinPriceSourceFlag.Name) {
+		cfg.XLayer.CoinPriceSource = ctx.String(GpoCoinPriceSourceFlag.Name)
+	}
+	if ctx.IsSet(GpoCoinPriceURLFlag.Name) {
+		cfg.XLayer.CoinPriceURL = ctx.String(GpoCoinPriceURLFlag.Name)
+	}
+	if ctx.IsSet(GpoCoinPriceFileFlag.Name) {
+		cfg.XLayer.CoinPriceFile = ctx.String(GpoCoinPriceFileFlag.Name)
+	}
+	if ctx.IsSet(GpoCoinPricePollPeriodFlag.Name) {
+		cfg.XLayer.CoinPricePollPeriod = ctx.Duration(GpoCoinPricePollPeriodFlag.Name)
+	}
+	if ctx.IsSet(GpoCoinPriceMaxAgeFlag.Name) {
+		cfg.XLayer.CoinPriceMaxAge = ctx.Duration(GpoCoinPriceMaxAgeFlag.Name)
+	}
 	if ctx.IsSet(GpoCongestionThresholdFlag.Name) {
 		cfg.XLayer.CongestionThreshold = ctx.Int(GpoCongestionThresholdFlag.Name)
 	}
+	if ctx.IsSet(GpoCongestionTargetLoadFlag.Name) {
+		cfg.XLayer.CongestionTargetLoad = ctx.Float64(GpoCongestionTargetLoadFlag.Name)
+	}
+	if ctx.IsSet(GpoCongestionBlocksFlag.Name) {
+		cfg.XLayer.CongestionBlocks = ctx.Int(GpoCongestionBlocksFlag.Name)
+	}
+	if ctx.IsSet(GpoCongestionBlockGasFlag.Name) {
+		cfg.XLayer.CongestionBlockGas = ctx.Uint64(GpoCongestionBlockGasFlag.Name)
+	}
+	if ctx.IsSet(GpoCongestionPendingTxsFlag.Name) {
+		cfg.XLayer.CongestionPendingTxs = ctx.Int(GpoCongestionPendingTxsFlag.Name)
+	}
 
 	// Default price check
 	if cfg.Default == nil || cfg.Default.Int64() <= 0 {
@@ -327,6 +411,44 @@ func setTxPoolXLayer(ctx *cli.Context, cfg *ethconfig.DeprecatedTxPoolConfig) {
 			}
 		}
 	}
+	if ctx.IsSet(TxPoolPriorityLanes.Name) {
+		// a new slice, the pool may still be reading the previous one
+		var lanes []ethconfig.PriorityLane
+		if lanesStr := ctx.String(TxPoolPriorityLanes.Name); len(lanesStr) > 0 {
+			if err := jsoniter.UnmarshalFromString(lanesStr, &lanes); err != nil {
+				panic("unable to unmarshal priorityLanes:" + err.Error())
+			}
+			if err := ethconfig.ValidatePriorityLanes(lanes); err != nil {
+				panic("invalid priorityLanes:" + err.Error())
+			}
+		}
+		cfg.PriorityLanes = lanes
+	}
+	setThroughputQuotas(ctx, cfg)
+}
+
+// setThroughputQuotas is shared by the pool and the sequencer apollo namespaces
+func setThroughputQuotas(ctx *cli.Context, cfg *ethconfig.DeprecatedTxPoolConfig) {
+	if ctx.IsSet(TxPoolSenderQuotas.Name) {
+		cfg.SenderQuotas = parseThroughputQuotas(ctx.String(TxPoolSenderQuotas.Name), "senderQuotas")
+	}
+	if ctx.IsSet(TxPoolContractQuotas.Name) {
+		cfg.ContractQuotas = parseThroughputQuotas(ctx.String(TxPoolContractQuotas.Name), "contractQuotas")
+	}
+}
+
+func parseThroughputQuotas(quotasStr, name string) map[string]ethconfig.ThroughputQuota {
+	// a new map, the pool may still be reading the previous one
+	var quotas map[string]ethconfig.ThroughputQuota
+	if len(quotasStr) > 0 {
+		if err := jsoniter.UnmarshalFromString(quotasStr, &quotas); err != nil {
+			panic("unable to unmarshal " + name + ":" + err.Error())
+		}
+		if err := ethconfig.ValidateThroughputQuotas(quotas); err != nil {
+			panic("invalid " + name + ":" + err.Error())
+		}
+	}
+	return quotas
 }
 
 // SetApolloGPOXLayer is a public wrapper function to internally call setGPO
@@ -338,3 +460,8 @@ func SetApolloGPOXLayer(ctx *cli.Context, cfg *gaspricecfg.Config) {
 func SetApolloPoolXLayer(ctx *cli.Context, fullCfg *ethconfig.Config) {
 	setTxPool(ctx, fullCfg)
 }
+
+// SetApolloSequencerQuotasXLayer is a public wrapper function to internally call setThroughputQuotas
+func SetApolloSequencerQuotasXLayer(ctx *cli.Context, cfg *ethconfig.DeprecatedTxPoolConfig) {
+	setThroughputQuotas(ctx, cfg)
+}
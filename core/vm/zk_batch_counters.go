@@ -65,6 +65,7 @@ func (bcc *BatchCounterCollector) Clone() *BatchCounterCollector {
 		blockCount:              bcc.blockCount,
 		forkId:                  bcc.forkId,
 		unlimitedCounters:       bcc.unlimitedCounters,
+		addonCounters:           bcc.addonCounters,
 
 		rlpCombinedCounters:        bcc.rlpCombinedCounters.Clone(),
 		executionCombinedCounters:  bcc.executionCombinedCounters.Clone(),
@@ -545,10 +545,15 @@ const (
 	TableAccountValues                = "HermezSmtAccountValues"
 	TableMetadata                     = "HermezSmtMetadata"
 	TableHashKey                      = "HermezSmtHashKey"
+	TableRetainedNodes                = "HermezSmtRetainedNodes"
+	TableRetainedNodeBlocks           = "HermezSmtRetainedNodeBlocks"
 	TablePoolLimbo                    = "PoolLimbo"
 	BATCH_ENDS                        = "batch_ends"
 	WITNESS_CACHE                     = "witness_cache"
 	BAD_TX_HASHES                     = "bad_tx_hashes"
+	VERIFICATION_FAILURES             = "verification_failures"  // batch number + timestamp -> verification failure
+	INNER_TX_ADDRESS_INDEX            = "inner_tx_address_index" // address + block number + tx index + inner tx index -> roles
+	L1_BLOCK_HASHES                   = "hermez_l1BlockHashes"   // source + l1blockno -> hash of an ingested, not yet finalized l1 block
 	//Diagnostics tables
 	DiagSystemInfo = "DiagSystemInfo"
 	DiagSyncStages = "DiagSyncStages"
@@ -791,10 +796,15 @@ var ChaindataTables = []string{
 	TableAccountValues,
 	TableMetadata,
 	TableHashKey,
+	TableRetainedNodes,
+	TableRetainedNodeBlocks,
 	TablePoolLimbo,
 	BATCH_ENDS,
 	WITNESS_CACHE,
 	BAD_TX_HASHES,
+	VERIFICATION_FAILURES,
+	INNER_TX_ADDRESS_INDEX,
+	L1_BLOCK_HASHES,
 }
 
 const (
@@ -56,7 +56,6 @@ import (
 	"net/url"
 	"path"
 
-	log2 "github.com/0xPolygonHermez/zkevm-data-streamer/log"
 	"github.com/ledgerwatch/erigon-lib/chain"
 	"github.com/ledgerwatch/erigon-lib/chain/networkname"
 	"github.com/ledgerwatch/erigon-lib/chain/snapcfg"
@@ -131,6 +130,7 @@ import (
 	"github.com/ledgerwatch/erigon/zk/contracts"
 	"github.com/ledgerwatch/erigon/zk/datastream/client"
 	"github.com/ledgerwatch/erigon/zk/datastream/server"
+	"github.com/ledgerwatch/erigon/zk/datastream/gateway"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/l1_cache"
 	"github.com/ledgerwatch/erigon/zk/l1infotree"
@@ -224,6 +224,7 @@ type Ethereum struct {
 
 	// zk
 	streamServer    server.StreamServer
+	streamGateway   *gateway.Gateway
 	l1Syncer        *syncer.L1Syncer
 	etherManClients []*etherman.Client
 	l1Cache         *l1_cache.L1Cache
@@ -976,15 +977,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		httpCfg := stack.Config().Http
 		if httpCfg.DataStreamPort > 0 && httpCfg.DataStreamHost != "" {
 			file := stack.Config().Dirs.DataDir + "/data-stream"
-			logConfig := &log2.Config{
-				Environment: "production",
-				Level:       "warn",
-				Outputs:     nil,
-			}
-
-			// todo [zkevm] read the stream version from config and figure out what system id is used for
-			backend.streamServer, err = dataStreamServerFactory.CreateStreamServer(uint16(httpCfg.DataStreamPort), uint8(backend.config.DatastreamVersion), 1, datastreamer.StreamType(1), file, httpCfg.DataStreamWriteTimeout, httpCfg.DataStreamInactivityTimeout, httpCfg.DataStreamInactivityCheckInterval, logConfig)
-			if err != nil {
+			if backend.streamServer, backend.streamGateway, err = newDataStream(backend.config.Zk, &httpCfg, file); err != nil {
 				return nil, err
 			}
 
@@ -1011,7 +1004,13 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		l1Urls := strings.Split(cfg.L1RpcUrl, ",")
 
 		if cfg.Zk.L1CacheEnabled {
-			l1Cache, err := l1_cache.NewL1Cache(ctx, path.Join(stack.DataDir(), "l1cache"), cfg.Zk.L1CachePort)
+			l1Cache, err := l1_cache.NewL1Cache(ctx, path.Join(stack.DataDir(), "l1cache"), l1_cache.Config{
+				Port:                 cfg.Zk.L1CachePort,
+				MethodTTLs:           cfg.Zk.L1CacheMethodTTLs,
+				DefaultTTL:           cfg.Zk.L1CacheDefaultTTL,
+				MaxSize:              cfg.Zk.L1CacheMaxSize,
+				FinalityPollInterval: cfg.Zk.L1CacheFinalityPollInterval,
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -1073,24 +1072,32 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			ethermanClients[i] = c.EthClient
 		}
 
-		seqVerSyncer := syncer.NewL1Syncer(
+		// the syncers share the endpoints, a failing or lagging one is avoided by all of them
+		l1Endpoints := syncer.NewL1Endpoints(ethermanClients, syncer.L1EndpointsConfig{
+			MaxLag:           cfg.L1EndpointMaxLag,
+			ExclusionBackoff: cfg.L1EndpointExclusionBackoff,
+		})
+
+		seqVerSyncer := syncer.NewL1SyncerWithEndpoints(
 			ctx,
-			ethermanClients,
+			l1Endpoints,
 			seqAndVerifL1Contracts,
 			seqAndVerifTopics,
 			cfg.L1BlockRange,
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
+			cfg.L1CrossCheck,
 		)
 
-		backend.l1Syncer = syncer.NewL1Syncer(
+		backend.l1Syncer = syncer.NewL1SyncerWithEndpoints(
 			ctx,
-			ethermanClients,
+			l1Endpoints,
 			l1Contracts,
 			l1Topics,
 			cfg.L1BlockRange,
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
+			cfg.L1CrossCheck && !isSequencer, // syncing the sequence and verification events
 		)
 
 		log.Info("Rollup ID", "rollupId", cfg.L1RollupId)
@@ -1098,14 +1105,15 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		// Check if L1 contracts addresses should be retrieved from the L1 chain
 		l1ContractAddressProcess(ctx, cfg.Zk, backend.l1Syncer)
 
-		l1InfoTreeSyncer := syncer.NewL1Syncer(
+		l1InfoTreeSyncer := syncer.NewL1SyncerWithEndpoints(
 			ctx,
-			ethermanClients,
+			l1Endpoints,
 			[]libcommon.Address{cfg.AddressGerManager},
 			[][]libcommon.Hash{{contracts.UpdateL1InfoTreeTopic}},
 			cfg.L1BlockRange,
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
+			false,
 		)
 
 		l1InfoTreeUpdater := l1infotree.NewUpdater(cfg.Zk, l1InfoTreeSyncer)
@@ -1136,6 +1144,8 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 					Timeout:               cfg.ExecutorRequestTimeout,
 					MaxConcurrentRequests: cfg.ExecutorMaxConcurrentRequests,
 					OutputLocation:        cfg.ExecutorPayloadOutput,
+					SlowRequestThreshold:  cfg.ExecutorSlowRequestThreshold,
+					UnhealthyBackoff:      cfg.ExecutorUnhealthyBackoff,
 				}
 				executors := legacy_executor_verifier.NewExecutors(levCfg)
 				for _, e := range executors {
@@ -1160,9 +1170,9 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			// we switch context from being an RPC node to a sequencer
 			backend.txPool2.ForceUpdateLatestBlock(executionProgress)
 
-			l1BlockSyncer := syncer.NewL1Syncer(
+			l1BlockSyncer := syncer.NewL1SyncerWithEndpoints(
 				ctx,
-				ethermanClients,
+				l1Endpoints,
 				[]libcommon.Address{cfg.AddressZkevm, cfg.AddressRollup},
 				[][]libcommon.Hash{{
 					contracts.SequenceBatchesTopic,
@@ -1170,6 +1180,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 				cfg.L1BlockRange,
 				cfg.L1QueryDelay,
 				cfg.L1HighestBlockType,
+				cfg.L1CrossCheck,
 			)
 
 			backend.syncStages = stages2.NewSequencerZkStages(
@@ -1210,7 +1221,10 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			if err != nil {
 				return nil, err
 			}
-			streamClient := initDataStreamClient(ctx, cfg.Zk, uint16(latestForkId))
+			streamClient, err := initDataStreamClient(ctx, cfg.Zk, uint16(latestForkId))
+			if err != nil {
+				return nil, err
+			}
 
 			backend.syncStages = stages2.NewDefaultZkStages(
 				backend.sentryCtx,
@@ -1230,6 +1244,26 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			)
 
 			backend.syncUnwindOrder = zkStages.ZkUnwindOrder
+
+			if cfg.SequencerDryRun {
+				if backend.txPool2 == nil {
+					return nil, errors.New("the sequencer dry run needs the txpool")
+				}
+				if err := stages2.StartSequencerDryRun(
+					ctx,
+					backend.chainDB,
+					config,
+					backend.sentriesClient,
+					allSnapshots,
+					backend.agg,
+					dataStreamServer,
+					backend.txPool2,
+					backend.txPool2DB,
+					l1InfoTreeUpdater,
+				); err != nil {
+					return nil, err
+				}
+			}
 		}
 		// TODO: SEQ: prune order
 
@@ -1296,8 +1330,12 @@ func newEtherMan(cfg *ethconfig.Config, l2ChainName, url string) *etherman.Clien
 }
 
 // creates a datastream client with default parameters
-func initDataStreamClient(ctx context.Context, cfg *ethconfig.Zk, latestForkId uint16) *client.StreamClient {
-	return client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestForkId)
+func initDataStreamClient(ctx context.Context, cfg *ethconfig.Zk, latestForkId uint16) (*client.StreamClient, error) {
+	c := client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestForkId)
+	if err := c.SetAuth(cfg.L2DataStreamerToken, cfg.L2DataStreamerTLSCertFile, cfg.L2DataStreamerTLSKeyFile, cfg.L2DataStreamerTLSCAFile); err != nil {
+		return nil, fmt.Errorf("datastream client authentication: %w", err)
+	}
+	return c, nil
 }
 
 func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig *chain.Config) error {
@@ -1390,15 +1428,21 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 	}
 
 	if chainConfig.Bor == nil {
-		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient)
+		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient, jsonrpc.ACLAPIList(s.apiList))
 	}
 
-	go func() {
-		if err := cli.StartDataStream(s.streamServer); err != nil {
-			log.Error(err.Error())
-			return
+	if s.streamGateway != nil {
+		// the stream is only served through the gateway
+		if err := startDataStream(s.sentryCtx, s.streamServer, s.streamGateway); err != nil {
+			return err
 		}
-	}()
+	} else {
+		go func() {
+			if err := cli.StartDataStream(s.streamServer); err != nil {
+				log.Error(err.Error())
+			}
+		}()
+	}
 
 	// Register the backend on the node
 	stack.RegisterLifecycle(s)
@@ -1973,6 +2017,10 @@ func (s *Ethereum) Stop() error {
 	}
 	s.chainDB.Close()
 
+	if s.streamGateway != nil {
+		s.streamGateway.Stop()
+	}
+
 	if s.silkwormRPCDaemonService != nil {
 		if err := s.silkwormRPCDaemonService.Stop(); err != nil {
 			s.logger.Error("silkworm.StopRpcDaemon error", "err", err)
@@ -0,0 +1,64 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	log2 "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli"
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli/httpcfg"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/zk/datastream/gateway"
+	"github.com/ledgerwatch/erigon/zk/datastream/server"
+)
+
+// newDataStream creates the data stream server of the data stream port.  When access control or filtering is
+// configured the server listens on the internal port of the loopback only, and a gateway authenticating the clients
+// serves them on the data stream port
+func newDataStream(zkCfg *ethconfig.Zk, httpCfg *httpcfg.HttpCfg, file string) (server.StreamServer, *gateway.Gateway, error) {
+	logConfig := &log2.Config{
+		Environment: "production",
+		Level:       "warn",
+		Outputs:     nil,
+	}
+
+	gatewayCfg := zkCfg.DataStreamGatewayConfig()
+	if !gatewayCfg.Enabled() {
+		// todo [zkevm] read the stream version from config and figure out what system id is used for
+		streamServer, err := dataStreamServerFactory.CreateStreamServer(uint16(httpCfg.DataStreamPort), uint8(zkCfg.DatastreamVersion), 1, datastreamer.StreamType(1), file, httpCfg.DataStreamWriteTimeout, httpCfg.DataStreamInactivityTimeout, httpCfg.DataStreamInactivityCheckInterval, logConfig)
+		return streamServer, nil, err
+	}
+
+	internalPort := zkCfg.DataStreamInternalPort
+	if internalPort == 0 {
+		internalPort = uint(httpCfg.DataStreamPort) + 1
+	}
+	if internalPort == uint(httpCfg.DataStreamPort) {
+		return nil, nil, fmt.Errorf("the data stream internal port must differ from the data stream port %d", httpCfg.DataStreamPort)
+	}
+
+	gatewayCfg.ListenAddr = fmt.Sprintf(":%d", httpCfg.DataStreamPort)
+	gatewayCfg.UpstreamAddr = fmt.Sprintf("127.0.0.1:%d", internalPort)
+	streamGateway, err := gateway.New(gatewayCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("data stream gateway: %w", err)
+	}
+
+	streamServer, err := dataStreamServerFactory.CreateLoopbackStreamServer(uint16(internalPort), uint8(zkCfg.DatastreamVersion), 1, datastreamer.StreamType(1), file, httpCfg.DataStreamWriteTimeout, httpCfg.DataStreamInactivityTimeout, httpCfg.DataStreamInactivityCheckInterval, logConfig)
+	return streamServer, streamGateway, err
+}
+
+// startDataStream starts the data stream server, then the gateway in front of it if any
+func startDataStream(ctx context.Context, streamServer server.StreamServer, streamGateway *gateway.Gateway) error {
+	if err := cli.StartDataStream(streamServer); err != nil {
+		return err
+	}
+	if streamGateway != nil {
+		if err := streamGateway.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start the data stream gateway: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package eth
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/cmd/rpcdaemon/cli/httpcfg"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/server"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+)
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+type streamEntry interface {
+	Marshal() ([]byte, error)
+	Type() types.EntryType
+}
+
+// writeTestBatch writes a batch of two blocks with a transaction each to the stream
+func writeTestBatch(t *testing.T, streamServer server.StreamServer) {
+	entries := []streamEntry{
+		types.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_BATCH),
+		&types.BatchStartProto{BatchStart: &datastream.BatchStart{Number: 1, ForkId: 9}},
+	}
+	for block := uint64(1); block <= 2; block++ {
+		entries = append(entries,
+			types.NewBookmarkProto(block, datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK),
+			&types.L2BlockProto{L2Block: &datastream.L2Block{Number: block, BatchNumber: 1}},
+			&types.TxProto{Transaction: &datastream.Transaction{L2BlockNumber: block}},
+			&types.L2BlockEndProto{Number: block},
+		)
+	}
+	entries = append(entries, &types.BatchEndProto{BatchEnd: &datastream.BatchEnd{Number: 1, StateRoot: common.HexToHash("0x1").Bytes()}})
+
+	require.NoError(t, streamServer.StartAtomicOp())
+	for _, entry := range entries {
+		data, err := entry.Marshal()
+		require.NoError(t, err)
+		if entry.Type() == types.BookmarkEntryType {
+			_, err = streamServer.AddStreamBookmark(data)
+		} else {
+			_, err = streamServer.AddStreamEntry(datastreamer.EntryType(entry.Type()), data)
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, streamServer.CommitAtomicOp())
+}
+
+func subscribe(t *testing.T, addr string, from *types.BookmarkProto, filter types.EntryFilter, count int) []interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := client.NewClient(ctx, addr, false, 0, 500*time.Millisecond, 0)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.ReadEntriesToChannelFrom(from, filter)
+	}()
+
+	entries := make([]interface{}, 0, count)
+	timeout := time.After(5 * time.Second)
+	for len(entries) < count {
+		select {
+		case entry := <-*c.GetEntryChan():
+			entries = append(entries, entry)
+		case err := <-errCh:
+			t.Fatalf("subscription ended: %v", err)
+		case <-timeout:
+			t.Fatalf("timed out with %d entries", len(entries))
+		}
+	}
+
+	c.StopReadingToChannel()
+	require.NoError(t, <-errCh)
+	return entries
+}
+
+func TestDataStreamGateway(t *testing.T) {
+	zkCfg := &ethconfig.Zk{
+		DatastreamVersion:        3,
+		DataStreamInternalPort:   uint(freePort(t)),
+		DataStreamEntryFiltering: true,
+	}
+	httpCfg := &httpcfg.HttpCfg{
+		DataStreamPort:                    freePort(t),
+		DataStreamHost:                    "localhost",
+		DataStreamWriteTimeout:            5 * time.Second,
+		DataStreamInactivityTimeout:       10 * time.Second,
+		DataStreamInactivityCheckInterval: time.Minute,
+	}
+
+	streamServer, streamGateway, err := newDataStream(zkCfg, httpCfg, filepath.Join(t.TempDir(), "data-stream"))
+	require.NoError(t, err)
+	require.NotNil(t, streamGateway)
+	require.NoError(t, startDataStream(context.Background(), streamServer, streamGateway))
+	t.Cleanup(streamGateway.Stop)
+	writeTestBatch(t, streamServer)
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(httpCfg.DataStreamPort))
+	internalPort := strconv.Itoa(int(zkCfg.DataStreamInternalPort))
+
+	t.Run("the stream server is on the loopback only", func(t *testing.T) {
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", internalPort))
+		require.NoError(t, err)
+		conn.Close()
+
+		addrs, err := net.InterfaceAddrs()
+		require.NoError(t, err)
+		for _, interfaceAddr := range addrs {
+			ipNet, ok := interfaceAddr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipNet.IP.String(), internalPort), time.Second)
+			if err == nil {
+				conn.Close()
+			}
+			require.Error(t, err, ipNet.IP.String())
+		}
+	})
+
+	t.Run("batches from a batch", func(t *testing.T) {
+		entries := subscribe(t, addr, types.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_BATCH), types.EntryFilterBatches, 2)
+		require.Equal(t, uint64(9), entries[0].(*types.BatchStart).ForkId)
+		require.Equal(t, common.HexToHash("0x1"), entries[1].(*types.BatchEnd).StateRoot)
+	})
+
+	t.Run("blocks from a block", func(t *testing.T) {
+		entries := subscribe(t, addr, types.NewBookmarkProto(2, datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK), types.EntryFilterBlocks, 2)
+		block, ok := entries[0].(*types.FullL2Block)
+		require.True(t, ok)
+		require.Equal(t, uint64(2), block.L2BlockNumber)
+		require.Empty(t, block.L2Txs)
+		require.IsType(t, &types.BatchEnd{}, entries[1])
+	})
+
+	t.Run("everything from a block", func(t *testing.T) {
+		entries := subscribe(t, addr, types.NewBookmarkProto(2, datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK), types.EntryFilterNone, 1)
+		block, ok := entries[0].(*types.FullL2Block)
+		require.True(t, ok)
+		require.Len(t, block.L2Txs, 1)
+	})
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/da"
+	"github.com/ledgerwatch/erigon/zk/datastream/gateway"
 )
 
 type Zk struct {
@@ -13,6 +15,10 @@ type Zk struct {
 	L2DataStreamerUrl                      string
 	L2DataStreamerUseTLS                   bool
 	L2DataStreamerTimeout                  time.Duration
+	L2DataStreamerToken                    string
+	L2DataStreamerTLSCertFile              string
+	L2DataStreamerTLSKeyFile               string
+	L2DataStreamerTLSCAFile                string
 	L2ShortCircuitToVerifiedBatch          bool
 	L1SyncStartBlock                       uint64
 	L1SyncStopBatch                        uint64
@@ -29,11 +35,18 @@ type Zk struct {
 	L1BlockRange                           uint64
 	L1QueryDelay                           uint64
 	L1HighestBlockType                     string
+	L1EndpointMaxLag                       uint64
+	L1EndpointExclusionBackoff             time.Duration
+	L1CrossCheck                           bool
 	L1MaticContractAddress                 common.Address
 	L1FirstBlock                           uint64
 	L1FinalizedBlockRequirement            uint64
 	L1CacheEnabled                         bool
 	L1CachePort                            uint
+	L1CacheDefaultTTL                      time.Duration
+	L1CacheMethodTTLs                      map[string]time.Duration
+	L1CacheMaxSize                         datasize.ByteSize
+	L1CacheFinalityPollInterval            time.Duration
 	RpcRateLimits                          int
 	RpcGetBatchWitnessConcurrencyLimit     int
 	DatastreamVersion                      int
@@ -46,14 +59,19 @@ type Zk struct {
 	SequencerResequence                    bool
 	SequencerResequenceStrict              bool
 	SequencerResequenceReuseL1InfoIndex    bool
+	SequencerDryRun                        bool
+	SequencerDryRunOutput                  string
 	ExecutorUrls                           []string
 	ExecutorStrictMode                     bool
 	ExecutorRequestTimeout                 time.Duration
 	ExecutorEnabled                        bool
+	StatelessVerification                  bool
 	DatastreamNewBlockTimeout              time.Duration
 	WitnessMemdbSize                       datasize.ByteSize
 	WitnessUnwindLimit                     uint64
 	ExecutorMaxConcurrentRequests          int
+	ExecutorSlowRequestThreshold           time.Duration
+	ExecutorUnhealthyBackoff               time.Duration
 	Limbo                                  bool
 	AllowFreeTransactions                  bool
 	RejectLowGasPriceTransactions          bool
@@ -66,15 +84,30 @@ type Zk struct {
 	MaxGasPrice                            uint64
 	GasPriceFactor                         float64
 	DAUrl                                  string
+	DABackend                              string
+	DACommitteeUrls                        []string
+	DAQuorum                               int
+	DALocalPath                            string
+	DAMaxAttempts                          int
 	DataStreamHost                         string
 	DataStreamPort                         uint
 	DataStreamWriteTimeout                 time.Duration
 	DataStreamInactivityTimeout            time.Duration
 	DataStreamInactivityCheckInterval      time.Duration
+	DataStreamInternalPort                 uint
+	DataStreamTLSCertFile                  string
+	DataStreamTLSKeyFile                   string
+	DataStreamClientCAFile                 string
+	DataStreamTokensFile                   string
+	DataStreamAllowlist                    []string
+	DataStreamMaxConnectionsPerClient      int
+	DataStreamClientRateLimit              datasize.ByteSize
+	DataStreamEntryFiltering               bool
 
 	RebuildTreeAfter      uint64
 	IncrementTreeAlways   bool
 	SmtRegenerateInMemory bool
+	SmtHistoryBlocks      uint64
 	WitnessFull           bool
 	SyncLimit             uint64
 	Gasless               bool
@@ -91,6 +124,9 @@ type Zk struct {
 	ExecutorPayloadOutput       string
 
 	TxPoolRejectSmartContractDeployments bool
+	TxPoolBundleLimit                    int
+	TxPoolBundleMaxTxs                   int
+	TxPoolBundleLifetime                 time.Duration
 
 	// For X Layer
 	XLayer XLayerConfig
@@ -116,6 +152,11 @@ func (c *Zk) ShouldCountersBeUnlimited(l1Recovery bool) bool {
 	return l1Recovery || (c.DisableVirtualCounters && !c.ExecutorStrictMode && !c.HasExecutors())
 }
 
+// TracksL1Reorgs returns true when the L1 data is synced past the finalized blocks, which can then be reorged out
+func (c *Zk) TracksL1Reorgs() bool {
+	return c.L1HighestBlockType == "latest" || c.L1HighestBlockType == "safe"
+}
+
 func (c *Zk) HasExecutors() bool {
 	return len(c.ExecutorUrls) > 0 && c.ExecutorUrls[0] != ""
 }
@@ -124,7 +165,39 @@ func (c *Zk) UseExecutors() bool {
 	return c.HasExecutors() && c.ExecutorEnabled
 }
 
+// UseStatelessVerification returns true when the batches are verified statelessly in place of an executor
+func (c *Zk) UseStatelessVerification() bool {
+	return c.StatelessVerification && !c.UseExecutors()
+}
+
 // ShouldImportInitialBatch returns true in case initial batch config file name is non-empty string.
 func (c *Zk) ShouldImportInitialBatch() bool {
 	return c.InitialBatchCfgFile != ""
 }
+
+// DAConfig returns the config of the data availability backend used to fetch validium batch data
+func (c *Zk) DAConfig() da.Config {
+	return da.Config{
+		Backend:     c.DABackend,
+		Url:         c.DAUrl,
+		Urls:        c.DACommitteeUrls,
+		Quorum:      c.DAQuorum,
+		LocalPath:   c.DALocalPath,
+		MaxAttempts: c.DAMaxAttempts,
+	}
+}
+
+// DataStreamGatewayConfig returns the config of the gateway authenticating the data stream clients and serving the
+// filtered subscriptions, the listen and upstream addresses are left to the caller
+func (c *Zk) DataStreamGatewayConfig() gateway.Config {
+	return gateway.Config{
+		TLSCertFile:             c.DataStreamTLSCertFile,
+		TLSKeyFile:              c.DataStreamTLSKeyFile,
+		ClientCAFile:            c.DataStreamClientCAFile,
+		TokensFile:              c.DataStreamTokensFile,
+		Allowlist:               c.DataStreamAllowlist,
+		MaxConnectionsPerClient: c.DataStreamMaxConnectionsPerClient,
+		ClientRateLimit:         c.DataStreamClientRateLimit,
+		EntryFiltering:          c.DataStreamEntryFiltering,
+	}
+}
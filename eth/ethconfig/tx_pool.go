@@ -17,6 +17,7 @@
 package ethconfig
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/common"
@@ -67,6 +68,11 @@ type DeprecatedTxPoolConfig struct {
 	EnableFreeGasList bool
 	// FreeGasList project name to FreeGasInfo
 	FreeGasList []FreeGasInfo
+	// PriorityLanes are the lanes block space is reserved for, by priority. Transactions of no lane go to the public one
+	PriorityLanes []PriorityLane
+	// SenderQuotas and ContractQuotas cap the throughput of a sender or destination contract, by address
+	SenderQuotas   map[string]ThroughputQuota
+	ContractQuotas map[string]ThroughputQuota
 }
 
 // FreeGasInfo contains the details for what tx should be free
@@ -78,6 +84,71 @@ type FreeGasInfo struct {
 	GasPriceMultiple uint64   `json:"gas_price_multiple"`
 }
 
+// PublicLane is the name of the lane of the transactions matching no priority lane
+const PublicLane = "public"
+
+// PriorityLane reserves a share of the block gas and of the batch zk counters to the transactions it matches
+type PriorityLane struct {
+	Name string `json:"name"`
+	// GasShare and CounterShare are the reserved fractions, between 0 and 1, of the block gas and batch counters
+	GasShare     float64 `json:"gas_share"`
+	CounterShare float64 `json:"counter_share"`
+	// Claims matches the transactions of the free claim gas addresses, the bridge claims
+	Claims bool `json:"claims"`
+	// Whitelist matches the transactions of the txpool whitelist
+	Whitelist bool     `json:"whitelist"`
+	FromList  []string `json:"from_list"`
+	ToList    []string `json:"to_list"`
+}
+
+// ValidatePriorityLanes checks the lane names are unique and the shares add up to at most the whole block
+func ValidatePriorityLanes(lanes []PriorityLane) error {
+	names := make(map[string]struct{}, len(lanes))
+	var gasShares, counterShares float64
+	for _, lane := range lanes {
+		if lane.Name == "" || lane.Name == PublicLane {
+			return fmt.Errorf("invalid lane name %q", lane.Name)
+		}
+		if _, ok := names[lane.Name]; ok {
+			return fmt.Errorf("duplicate lane %q", lane.Name)
+		}
+		names[lane.Name] = struct{}{}
+		if lane.GasShare < 0 || lane.GasShare > 1 || lane.CounterShare < 0 || lane.CounterShare > 1 {
+			return fmt.Errorf("lane %q shares must be between 0 and 1", lane.Name)
+		}
+		gasShares += lane.GasShare
+		counterShares += lane.CounterShare
+	}
+	if gasShares > 1 || counterShares > 1 {
+		return fmt.Errorf("lane shares add up to more than 1: gas %v, counters %v", gasShares, counterShares)
+	}
+	return nil
+}
+
+// AnyAddressQuota is the key of the quota each address without one of its own gets
+const AnyAddressQuota = "*"
+
+// ThroughputQuota caps what a sender or destination contract gets of the sequencer throughput, a zero cap being none
+type ThroughputQuota struct {
+	MaxTxsPerBlock uint64 `json:"max_txs_per_block"`
+	MaxGasPerBatch uint64 `json:"max_gas_per_batch"`
+	// MaxCounterShare is the fraction, between 0 and 1, of each batch zk counter
+	MaxCounterShare float64 `json:"max_counter_share"`
+}
+
+// ValidateThroughputQuotas checks the quotas are keyed by address and their counter shares are fractions
+func ValidateThroughputQuotas(quotas map[string]ThroughputQuota) error {
+	for addr, quota := range quotas {
+		if addr != AnyAddressQuota && !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid quota address %q", addr)
+		}
+		if quota.MaxCounterShare < 0 || quota.MaxCounterShare > 1 {
+			return fmt.Errorf("quota of %s counter share must be between 0 and 1", addr)
+		}
+	}
+	return nil
+}
+
 // DeprecatedDefaultTxPoolConfig contains the default configurations for the transaction
 // pool.
 var DeprecatedDefaultTxPoolConfig = DeprecatedTxPoolConfig{
@@ -0,0 +1,359 @@
+package gasprice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
+	"github.com/ledgerwatch/erigon/zk/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// CoinPriceSource feeds the L1 and L2 coin prices in USDT to the gas pricers.
+type CoinPriceSource interface {
+	// GetL2CoinPrice gets the L2 coin price in USDT
+	GetL2CoinPrice() float64
+	// GetL1L2CoinPrice gets both l1 and L2 coin prices in USDT
+	GetL1L2CoinPrice() (float64, float64)
+	// LastUpdate returns when the prices were last read, zero before the first read
+	LastUpdate() time.Time
+}
+
+// newCoinPriceSource inits the coin price source configured, guarded against stale prices.
+func newCoinPriceSource(cfg gaspricecfg.XLayerConfig, ctx context.Context) CoinPriceSource {
+	var source CoinPriceSource
+	switch cfg.CoinPriceSource {
+	case gaspricecfg.HTTPSourceType:
+		source = newHTTPCoinPriceSource(cfg, ctx)
+	case gaspricecfg.FileSourceType:
+		source = newFileCoinPriceSource(cfg)
+	default:
+		if cfg.CoinPriceSource != gaspricecfg.KafkaSourceType && cfg.CoinPriceSource != "" {
+			log.Error(fmt.Sprintf("unknown coin price source %v. Please specify a valid one: 'kafka', 'http' or 'file'. Using 'kafka'", cfg.CoinPriceSource))
+		}
+		source = newKafkaProcessor(cfg, ctx)
+	}
+	return newCoinPriceGuard(cfg, source)
+}
+
+// L1L2PriceRecord l1 l2 coin price record
+type L1L2PriceRecord struct {
+	l1Price  float64
+	l2Price  float64
+	l1Update bool
+	l2Update bool
+}
+
+// coinPrices keeps the coin prices a source reads, starting from the default ones.
+type coinPrices struct {
+	cfg       gaspricecfg.XLayerConfig
+	rwLock    sync.RWMutex
+	l1CoinId  int
+	l2CoinId  int
+	l1Price   float64
+	l2Price   float64
+	tmpPrices L1L2PriceRecord
+	updated   time.Time
+}
+
+func newCoinPrices(cfg gaspricecfg.XLayerConfig) *coinPrices {
+	rp := &coinPrices{
+		cfg:      cfg,
+		l1Price:  cfg.DefaultL1CoinPrice,
+		l2Price:  cfg.DefaultL2CoinPrice,
+		l2CoinId: okbcoinId,
+		l1CoinId: ethcoinId,
+	}
+	if cfg.L2CoinId != 0 {
+		rp.l2CoinId = cfg.L2CoinId
+	}
+	if cfg.L1CoinId != 0 {
+		rp.l1CoinId = cfg.L1CoinId
+	}
+	return rp
+}
+
+// Update update the coin price
+func (rp *coinPrices) Update(data []byte) error {
+	if rp.cfg.Type == gaspricecfg.FixedType {
+		price, err := rp.parseCoinPrice(data, []int{rp.l2CoinId})
+		if err == nil {
+			rp.updateL2CoinPrice(price[rp.l2CoinId])
+		}
+		return err
+	} else if rp.cfg.Type == gaspricecfg.FollowerType {
+		prices, err := rp.parseCoinPrice(data, []int{rp.l1CoinId, rp.l2CoinId})
+		if err == nil {
+			rp.updateL1L2CoinPrice(prices)
+		}
+		return err
+	}
+	return nil
+}
+
+func (rp *coinPrices) updateL2CoinPrice(price float64) {
+	rp.rwLock.Lock()
+	defer rp.rwLock.Unlock()
+	rp.l2Price = price
+	rp.updated = time.Now()
+}
+
+func (rp *coinPrices) updateL1L2CoinPrice(prices map[int]float64) {
+	if len(prices) == 0 {
+		return
+	}
+	rp.rwLock.Lock()
+	defer rp.rwLock.Unlock()
+	if v, ok := prices[rp.l1CoinId]; ok {
+		rp.tmpPrices.l1Price = v
+		rp.tmpPrices.l1Update = true
+	}
+	if v, ok := prices[rp.l2CoinId]; ok {
+		rp.tmpPrices.l2Price = v
+		rp.tmpPrices.l2Update = true
+	}
+	if rp.tmpPrices.l1Update && rp.tmpPrices.l2Update {
+		rp.l1Price = rp.tmpPrices.l1Price
+		rp.l2Price = rp.tmpPrices.l2Price
+		rp.updated = time.Now()
+		rp.tmpPrices.l1Update = false
+		rp.tmpPrices.l2Update = false
+		return
+	}
+}
+
+func (rp *coinPrices) parseCoinPrice(value []byte, coinIds []int) (map[int]float64, error) {
+	if len(coinIds) == 0 {
+		return nil, fmt.Errorf("the params coinIds is empty")
+	}
+	msgI := &MsgInfo{}
+	err := json.Unmarshal(value, &msgI)
+	if err != nil {
+		return nil, err
+	}
+	if msgI.Data == nil || len(msgI.Data.PriceList) == 0 {
+		return nil, fmt.Errorf("the data PriceList is empty")
+	}
+	mp := make(map[int]*Price)
+	for _, price := range msgI.Data.PriceList {
+		mp[price.CoinId] = price
+	}
+
+	results := make(map[int]float64)
+	for _, coinId := range coinIds {
+		if coin, ok := mp[coinId]; ok {
+			results[coinId] = coin.Price
+		} else {
+			log.Debug("not find a correct coin price coin id is =", coinId)
+		}
+	}
+	if len(results) == 0 {
+		return results, ErrNotFindCoinPrice
+	}
+	return results, nil
+}
+
+// GetL2CoinPrice gets the L2 coin price in USDT
+func (rp *coinPrices) GetL2CoinPrice() float64 {
+	rp.rwLock.RLock()
+	defer rp.rwLock.RUnlock()
+	return rp.l2Price
+}
+
+// GetL1L2CoinPrice gets both l1 and L2 coin prices in USDT
+func (rp *coinPrices) GetL1L2CoinPrice() (float64, float64) {
+	rp.rwLock.RLock()
+	defer rp.rwLock.RUnlock()
+	return rp.l1Price, rp.l2Price
+}
+
+// LastUpdate returns when the prices were last updated
+func (rp *coinPrices) LastUpdate() time.Time {
+	rp.rwLock.RLock()
+	defer rp.rwLock.RUnlock()
+	return rp.updated
+}
+
+// HTTPCoinPriceSource polls an HTTP endpoint serving the prices in the JSON of the kafka messages.
+type HTTPCoinPriceSource struct {
+	*coinPrices
+	client *http.Client
+	ctx    context.Context
+}
+
+func newHTTPCoinPriceSource(cfg gaspricecfg.XLayerConfig, ctx context.Context) *HTTPCoinPriceSource {
+	hs := &HTTPCoinPriceSource{
+		coinPrices: newCoinPrices(cfg),
+		client:     &http.Client{Timeout: defaultTime * time.Second},
+		ctx:        ctx,
+	}
+
+	go hs.poller()
+	return hs
+}
+
+func (hs *HTTPCoinPriceSource) poller() {
+	period := hs.cfg.CoinPricePollPeriod
+	if period <= 0 {
+		period = gaspricecfg.DefaultXLayerConfig.CoinPricePollPeriod
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := hs.ReadAndUpdate(hs.ctx); err != nil && hs.ctx.Err() == nil {
+			log.Warn(fmt.Sprintf("coin price polling from %s failed: %v", hs.cfg.CoinPriceURL, err))
+		}
+		select {
+		case <-hs.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReadAndUpdate read and update
+func (hs *HTTPCoinPriceSource) ReadAndUpdate(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hs.cfg.CoinPriceURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := hs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, defaultMaxData))
+	if err != nil {
+		return err
+	}
+	return hs.Update(data)
+}
+
+// FileCoinPriceSource serves the prices of a JSON file, for tests and as a fallback when the live sources are down.
+type FileCoinPriceSource struct {
+	*coinPrices
+}
+
+// CoinPriceFile is the content of the file of a file coin price source
+type CoinPriceFile struct {
+	L1CoinPrice float64 `json:"l1CoinPrice"`
+	L2CoinPrice float64 `json:"l2CoinPrice"`
+}
+
+func newFileCoinPriceSource(cfg gaspricecfg.XLayerConfig) *FileCoinPriceSource {
+	fs := &FileCoinPriceSource{coinPrices: newCoinPrices(cfg)}
+	if err := fs.load(); err != nil {
+		log.Error(fmt.Sprintf("loading the coin prices of %s failed, using the default ones: %v", cfg.CoinPriceFile, err))
+	}
+	return fs
+}
+
+func (fs *FileCoinPriceSource) load() error {
+	data, err := os.ReadFile(fs.cfg.CoinPriceFile)
+	if err != nil {
+		return err
+	}
+	var prices CoinPriceFile
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return err
+	}
+	fs.rwLock.Lock()
+	defer fs.rwLock.Unlock()
+	fs.l1Price, fs.l2Price = prices.L1CoinPrice, prices.L2CoinPrice
+	fs.updated = time.Now()
+	return nil
+}
+
+// LastUpdate returns now once the file is loaded, its prices never going stale
+func (fs *FileCoinPriceSource) LastUpdate() time.Time {
+	if fs.coinPrices.LastUpdate().IsZero() {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// coinPriceGuard serves the last good prices of a source, raising an alert while the source is stale: not updated for
+// the max age configured, or serving prices too small to be real.
+type coinPriceGuard struct {
+	source  CoinPriceSource
+	maxAge  time.Duration
+	started time.Time
+	lock    sync.Mutex
+	l1Price float64
+	l2Price float64
+	stale   bool
+}
+
+func newCoinPriceGuard(cfg gaspricecfg.XLayerConfig, source CoinPriceSource) *coinPriceGuard {
+	metrics.RpcCoinPriceStale.Set(0)
+	return &coinPriceGuard{
+		source:  source,
+		maxAge:  cfg.CoinPriceMaxAge,
+		started: time.Now(),
+		l1Price: cfg.DefaultL1CoinPrice,
+		l2Price: cfg.DefaultL2CoinPrice,
+	}
+}
+
+// GetL2CoinPrice gets the L2 coin price in USDT
+func (g *coinPriceGuard) GetL2CoinPrice() float64 {
+	_, l2Price := g.get(false)
+	return l2Price
+}
+
+// GetL1L2CoinPrice gets both l1 and L2 coin prices in USDT
+func (g *coinPriceGuard) GetL1L2CoinPrice() (float64, float64) {
+	return g.get(true)
+}
+
+func (g *coinPriceGuard) get(withL1 bool) (float64, float64) {
+	l1Price, l2Price := g.source.GetL1L2CoinPrice()
+	updated := g.source.LastUpdate()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	// the source has the max age from the start to read the first prices
+	if updated.IsZero() {
+		updated = g.started
+	}
+	var reason string
+	if age := time.Since(updated); g.maxAge > 0 && age > g.maxAge {
+		reason = fmt.Sprintf("not updated for %v", age.Truncate(time.Second))
+	} else if l2Price < minUSDTPrice || (withL1 && l1Price < minUSDTPrice) {
+		reason = fmt.Sprintf("invalid prices, l1: %g, l2: %g", l1Price, l2Price)
+	}
+
+	if reason != "" {
+		if !g.stale {
+			log.Error(fmt.Sprintf("coin price source stale, %s, keeping the last good prices, l1: %g, l2: %g", reason, g.l1Price, g.l2Price))
+			metrics.RpcCoinPriceStale.Set(1)
+			g.stale = true
+		}
+		return g.l1Price, g.l2Price
+	}
+	if g.stale {
+		log.Info(fmt.Sprintf("coin price source recovered, l1: %g, l2: %g", l1Price, l2Price))
+		metrics.RpcCoinPriceStale.Set(0)
+		g.stale = false
+	}
+	if withL1 {
+		g.l1Price = l1Price
+	}
+	g.l2Price = l2Price
+	return l1Price, l2Price
+}
+
+// LastUpdate returns when the prices were last read from the source
+func (g *coinPriceGuard) LastUpdate() time.Time {
+	return g.source.LastUpdate()
+}
@@ -0,0 +1,114 @@
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPCoinPriceSource(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "{\"topic\":\"middle_coinPrice_push\",\"source\":null,\"type\":null,\"data\":{\"priceList\":[{\"coinId\":%d,\"price\":0.04}, {\"coinId\":%d,\"price\":0.002}],\"id\":\"98a797ce-f61b-4e90-87ac-445e77ad3599\"}}", ethcoinId, okbcoinId)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := newHTTPCoinPriceSource(XLayerConfig{Type: FollowerType, CoinPriceURL: server.URL, CoinPricePollPeriod: time.Hour}, ctx)
+	require.Eventually(t, func() bool { return !source.LastUpdate().IsZero() }, 5*time.Second, 10*time.Millisecond)
+	l1, l2 := source.GetL1L2CoinPrice()
+	require.Equal(t, 0.04, l1)
+	require.Equal(t, 0.002, l2)
+
+	// a failed poll keeps the prices
+	failing.Store(true)
+	updated := source.LastUpdate()
+	require.Error(t, source.ReadAndUpdate(ctx))
+	require.Equal(t, updated, source.LastUpdate())
+	require.Equal(t, 0.002, source.GetL2CoinPrice())
+}
+
+func TestFileCoinPriceSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prices.json")
+	cfg := XLayerConfig{Type: FixedType, CoinPriceFile: file, DefaultL1CoinPrice: 2000, DefaultL2CoinPrice: 50}
+
+	// a missing file leaves the default prices, never updated
+	source := newFileCoinPriceSource(cfg)
+	require.True(t, source.LastUpdate().IsZero())
+	require.Equal(t, 50.0, source.GetL2CoinPrice())
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"l1CoinPrice":2500,"l2CoinPrice":40}`), 0600))
+	source = newFileCoinPriceSource(cfg)
+	require.WithinDuration(t, time.Now(), source.LastUpdate(), time.Minute)
+	l1, l2 := source.GetL1L2CoinPrice()
+	require.Equal(t, 2500.0, l1)
+	require.Equal(t, 40.0, l2)
+}
+
+type testCoinPriceSource struct {
+	l1Price, l2Price float64
+	updated          time.Time
+}
+
+func (s *testCoinPriceSource) GetL2CoinPrice() float64 { return s.l2Price }
+
+func (s *testCoinPriceSource) GetL1L2CoinPrice() (float64, float64) { return s.l1Price, s.l2Price }
+
+func (s *testCoinPriceSource) LastUpdate() time.Time { return s.updated }
+
+func TestCoinPriceGuard(t *testing.T) {
+	source := &testCoinPriceSource{}
+	guard := newCoinPriceGuard(XLayerConfig{CoinPriceMaxAge: time.Hour, DefaultL1CoinPrice: 2000, DefaultL2CoinPrice: 50}, source)
+
+	// the default prices while the source serves none
+	l1, l2 := guard.GetL1L2CoinPrice()
+	require.Equal(t, 2000.0, l1)
+	require.Equal(t, 50.0, l2)
+	require.True(t, guard.stale)
+
+	source.l1Price, source.l2Price, source.updated = 2500, 40, time.Now()
+	l1, l2 = guard.GetL1L2CoinPrice()
+	require.Equal(t, 2500.0, l1)
+	require.Equal(t, 40.0, l2)
+	require.False(t, guard.stale)
+
+	// prices too small to be real
+	source.l2Price = 0
+	require.Equal(t, 40.0, guard.GetL2CoinPrice())
+	require.True(t, guard.stale)
+
+	// the l1 price only checked when asked for
+	source.l1Price, source.l2Price = 0, 45
+	require.Equal(t, 45.0, guard.GetL2CoinPrice())
+	require.False(t, guard.stale)
+	l1, l2 = guard.GetL1L2CoinPrice()
+	require.Equal(t, 2500.0, l1)
+	require.Equal(t, 45.0, l2)
+	require.True(t, guard.stale)
+
+	// prices not updated for longer than the max age
+	source.l1Price, source.updated = 2600, time.Now().Add(-2*time.Hour)
+	l1, l2 = guard.GetL1L2CoinPrice()
+	require.Equal(t, 2500.0, l1)
+	require.Equal(t, 45.0, l2)
+	require.True(t, guard.stale)
+
+	source.updated = time.Now()
+	l1, _ = guard.GetL1L2CoinPrice()
+	require.Equal(t, 2600.0, l1)
+	require.False(t, guard.stale)
+}
@@ -0,0 +1,133 @@
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// CongestionSignals is the load of the chain, each part from 0 when idle to 1 when full.
+type CongestionSignals struct {
+	// BlockFullness is the gas used by the recent blocks against the one of full blocks
+	BlockFullness float64
+	// PendingDepth is the number of pending txs against the one of a full pool
+	PendingDepth float64
+	// CounterUsage is the highest share of a zk counter limit the recent batches used. The batch counters are only
+	// written by the sequencer, on the other nodes it stays 0 and the load comes from the other signals.
+	CounterUsage float64
+}
+
+// Load returns the part of the chain the fullest, capped to 1.
+func (s CongestionSignals) Load() float64 {
+	load := math.Max(s.BlockFullness, math.Max(s.PendingDepth, s.CounterUsage))
+	return math.Min(math.Max(load, 0), 1)
+}
+
+// L2CongestionPricer is a strategy pricing the load of the chain, fed its signals every update period.
+type L2CongestionPricer interface {
+	UpdateCongestion(signals CongestionSignals)
+	// IsCongested tells whether the chain is congested, in place of the pending tx threshold
+	IsCongested() bool
+}
+
+// CongestionGasPrice moves the gas price by the load of the chain the way EIP-1559 moves the base fee by the gas used:
+// by up to 1/8 every update, up above the target load and down below it, between the default and the max price.
+// It is updated by the gas price suggester loop and read by the RPC calls.
+type CongestionGasPrice struct {
+	lock      sync.RWMutex
+	cfg       gaspricecfg.Config
+	ctx       context.Context
+	lastRawGP *big.Int
+	price     *big.Int // the price not truncated, changes smaller than the truncation adding up
+	load      float64
+}
+
+// newCongestionGasPriceSuggester inits l2 congestion gas price suggester, starting from the default price.
+func newCongestionGasPriceSuggester(ctx context.Context, cfg gaspricecfg.Config) *CongestionGasPrice {
+	return &CongestionGasPrice{
+		cfg:       cfg,
+		ctx:       ctx,
+		lastRawGP: new(big.Int).Set(cfg.Default),
+		price:     new(big.Int).Set(cfg.Default),
+	}
+}
+
+// UpdateGasPriceAvg not needed for congestion strategy, the price follows the congestion signals.
+func (f *CongestionGasPrice) UpdateGasPriceAvg(l1GasPrice *big.Int) {}
+
+// UpdateCongestion moves the gas price by the load of the signals.
+func (f *CongestionGasPrice) UpdateCongestion(signals CongestionSignals) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	target := f.targetLoad()
+	f.load = signals.Load()
+
+	// the distance to the target, from -1 when idle to 1 when full
+	var change float64
+	if f.load > target {
+		change = (f.load - target) / (1 - target)
+	} else {
+		change = (f.load - target) / target
+	}
+	change /= params.BaseFeeChangeDenominator
+
+	price := new(big.Float).Mul(new(big.Float).SetInt(f.price), big.NewFloat(1+change))
+	result, _ := price.Int(nil)
+
+	// Check for min/max L2 gasPrice
+	if f.cfg.Default.Cmp(result) == 1 { // minGasPrice > result
+		result = new(big.Int).Set(f.cfg.Default)
+	}
+	if f.cfg.MaxPrice != nil && f.cfg.MaxPrice.Sign() > 0 && result.Cmp(f.cfg.MaxPrice) == 1 { // result > maxGasPrice
+		result = new(big.Int).Set(f.cfg.MaxPrice)
+	}
+	f.price = result
+	f.lastRawGP = truncateGasPrice(result)
+
+	log.Info(fmt.Sprintf("Set l2 raw gas price: %d, load: %.3f (block fullness: %.3f, pending depth: %.3f, counter usage: %.3f)",
+		f.lastRawGP.Uint64(), f.load, signals.BlockFullness, signals.PendingDepth, signals.CounterUsage))
+}
+
+// IsCongested tells whether the load of the last signals is above the target.
+func (f *CongestionGasPrice) IsCongested() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.load > f.targetLoad()
+}
+
+func (f *CongestionGasPrice) targetLoad() float64 {
+	target := f.cfg.XLayer.CongestionTargetLoad
+	if target <= 0 || target >= 1 {
+		return gaspricecfg.DefaultXLayerConfig.CongestionTargetLoad
+	}
+	return target
+}
+
+func (f *CongestionGasPrice) UpdateConfig(c gaspricecfg.Config) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.cfg = c
+}
+
+func (f *CongestionGasPrice) GetLastRawGP() *big.Int {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.lastRawGP
+}
+
+func (f *CongestionGasPrice) GetConfig() gaspricecfg.Config {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.cfg
+}
+
+func (f *CongestionGasPrice) GetCtx() context.Context {
+	return f.ctx
+}
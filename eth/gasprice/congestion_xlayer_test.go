@@ -0,0 +1,115 @@
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCongestionUpdateGasPrice(t *testing.T) {
+	cfg := Config{
+		Default:  new(big.Int).SetUint64(1000000000),
+		MaxPrice: new(big.Int).SetUint64(1500000000),
+		XLayer: XLayerConfig{
+			Type:                 CongestionType,
+			UpdatePeriod:         time.Second,
+			CongestionTargetLoad: 0.5,
+		},
+	}
+	f := newCongestionGasPriceSuggester(context.Background(), cfg)
+
+	// a full counter raises the price by 1/8 whatever the other signals
+	f.UpdateCongestion(CongestionSignals{BlockFullness: 0.1, CounterUsage: 1})
+	require.True(t, f.IsCongested())
+	require.Equal(t, uint64(1120000000), f.GetLastRawGP().Uint64(), "truncated to 3 digits")
+
+	// the load half way to full raises it by 1/16, from the price not truncated
+	f.UpdateCongestion(CongestionSignals{PendingDepth: 0.75})
+	require.Equal(t, uint64(1190000000), f.GetLastRawGP().Uint64())
+
+	// up to the max price
+	for i := 0; i < 10; i++ {
+		f.UpdateCongestion(CongestionSignals{BlockFullness: 2})
+	}
+	require.Equal(t, cfg.MaxPrice.Uint64(), f.GetLastRawGP().Uint64())
+
+	// the target load holds it
+	f.UpdateCongestion(CongestionSignals{BlockFullness: 0.5})
+	require.False(t, f.IsCongested())
+	require.Equal(t, cfg.MaxPrice.Uint64(), f.GetLastRawGP().Uint64())
+
+	// idle lowers it by 1/8, down to the default price
+	f.UpdateCongestion(CongestionSignals{})
+	require.Equal(t, uint64(1310000000), f.GetLastRawGP().Uint64())
+	for i := 0; i < 10; i++ {
+		f.UpdateCongestion(CongestionSignals{})
+	}
+	require.Equal(t, cfg.Default.Uint64(), f.GetLastRawGP().Uint64())
+}
+
+// the suggester loop updates the price while the RPC calls read it, run with -race
+func TestCongestionConcurrentUpdates(t *testing.T) {
+	cfg := Config{
+		Default: new(big.Int).SetUint64(1000000000),
+		XLayer:  XLayerConfig{Type: CongestionType, CongestionTargetLoad: 0.5},
+	}
+	f := newCongestionGasPriceSuggester(context.Background(), cfg)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			f.UpdateCongestion(CongestionSignals{BlockFullness: float64(i%3) / 2})
+			f.UpdateConfig(cfg)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		f.IsCongested()
+		require.NotNil(t, f.GetLastRawGP())
+		require.Equal(t, CongestionType, f.GetConfig().XLayer.Type)
+	}
+	<-done
+}
+
+func TestL2GasPricerSwitch(t *testing.T) {
+	cfg := Config{
+		Default:  new(big.Int).SetUint64(1000000000),
+		MaxPrice: new(big.Int).SetUint64(0),
+		XLayer: XLayerConfig{
+			Type:         "unknown",
+			UpdatePeriod: time.Second,
+		},
+	}
+	require.Equal(t, []string{CongestionType, DefaultType, FixedType, FollowerType}, L2GasPricerTypes())
+
+	// an unknown type falls back to the default one
+	p := NewL2GasPriceSuggester(context.Background(), cfg)
+	require.IsType(t, &DefaultGasPricer{}, p.(*L2GasPricerSwitch).Active())
+	_, ok := AsL2CongestionPricer(p)
+	require.False(t, ok)
+
+	// a config of another type swaps the strategy, ending the context of the one replaced
+	defaultCtx := p.(*L2GasPricerSwitch).Active().GetCtx()
+	cfg.XLayer.Type = CongestionType
+	p.UpdateConfig(cfg)
+	require.IsType(t, &CongestionGasPrice{}, p.(*L2GasPricerSwitch).Active())
+	require.Error(t, defaultCtx.Err())
+	require.NoError(t, p.GetCtx().Err())
+	congestion, ok := AsL2CongestionPricer(p)
+	require.True(t, ok)
+	congestion.UpdateCongestion(CongestionSignals{BlockFullness: 1})
+	require.Equal(t, uint64(1120000000), p.GetLastRawGP().Uint64())
+
+	// a config of the same type updates it, of an unknown one keeps it
+	cfg.XLayer.CongestionTargetLoad = 0.8
+	p.UpdateConfig(cfg)
+	require.Equal(t, 0.8, p.GetConfig().XLayer.CongestionTargetLoad)
+	cfg.XLayer.Type = "unknown"
+	p.UpdateConfig(cfg)
+	require.Equal(t, CongestionType, p.GetConfig().XLayer.Type)
+	require.Equal(t, uint64(1120000000), p.GetLastRawGP().Uint64())
+}
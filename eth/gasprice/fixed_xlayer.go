@@ -15,7 +15,7 @@ type FixedGasPrice struct {
 	cfg       gaspricecfg.Config
 	ctx       context.Context
 	lastRawGP *big.Int
-	ratePrc   *KafkaProcessor
+	ratePrc   CoinPriceSource
 }
 
 // newFixedGasPriceSuggester inits l2 fixed price suggester.
@@ -24,7 +24,7 @@ func newFixedGasPriceSuggester(ctx context.Context, cfg gaspricecfg.Config) *Fix
 		cfg:       cfg,
 		ctx:       ctx,
 		lastRawGP: new(big.Int).Set(cfg.Default),
-		ratePrc:   newKafkaProcessor(cfg.XLayer, ctx),
+		ratePrc:   newCoinPriceSource(cfg.XLayer, ctx),
 	}
 	return gps
 }
@@ -15,7 +15,7 @@ type FollowerGasPrice struct {
 	cfg       gaspricecfg.Config
 	ctx       context.Context
 	lastRawGP *big.Int
-	kafkaPrc  *KafkaProcessor
+	kafkaPrc  CoinPriceSource
 }
 
 // newFollowerGasPriceSuggester inits l2 follower gas price suggester which is based on the l1 gas price.
@@ -24,7 +24,7 @@ func newFollowerGasPriceSuggester(ctx context.Context, cfg gaspricecfg.Config) *
 		cfg:       cfg,
 		ctx:       ctx,
 		lastRawGP: new(big.Int).Set(cfg.Default),
-		kafkaPrc:  newKafkaProcessor(cfg.XLayer, ctx),
+		kafkaPrc:  newCoinPriceSource(cfg.XLayer, ctx),
 	}
 }
 
@@ -15,3 +15,13 @@ func OKBToOKBWei(eth *big.Float) *big.Int {
 	val.Int(wei)
 	return wei
 }
+
+// truncateGasPrice keeps the 3 most significant digits of a gas price, zeroing the others
+func truncateGasPrice(gp *big.Int) *big.Int {
+	digits := len(gp.String())
+	if digits <= 3 { //nolint:gomnd
+		return new(big.Int).Set(gp)
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits-3)), nil) //nolint:gomnd
+	return new(big.Int).Mul(new(big.Int).Quo(gp, unit), unit)
+}
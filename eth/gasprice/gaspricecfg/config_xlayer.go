@@ -25,8 +25,27 @@ type XLayerConfig struct {
 	// DefaultL2CoinPrice is the native token's coin price
 	DefaultL2CoinPrice float64 `toml:",omitempty"`
 	GasPriceUsdt       float64 `toml:",omitempty"`
+	// CoinPriceSource is where the coin prices are read from: kafka, http or file
+	CoinPriceSource string `toml:",omitempty"`
+	// CoinPriceURL is the endpoint the http source polls, serving the JSON of the kafka messages
+	CoinPriceURL string `toml:",omitempty"`
+	// CoinPriceFile is the JSON file of the file source
+	CoinPriceFile string `toml:",omitempty"`
+	// CoinPricePollPeriod is the period the http source polls at
+	CoinPricePollPeriod time.Duration `toml:",omitempty"`
+	// CoinPriceMaxAge is the age the coin prices are stale at, the last good ones being used, 0 to never be
+	CoinPriceMaxAge time.Duration `toml:",omitempty"`
 
 	CongestionThreshold int `toml:",omitempty"`
+
+	// CongestionTargetLoad is the load of the chain, from 0 to 1, the congestion type holds the gas price at
+	CongestionTargetLoad float64 `toml:",omitempty"`
+	// CongestionBlocks is the number of recent blocks the congestion type measures the load over
+	CongestionBlocks int `toml:",omitempty"`
+	// CongestionBlockGas is the gas used by a block the congestion type counts as full
+	CongestionBlockGas uint64 `toml:",omitempty"`
+	// CongestionPendingTxs is the number of pending txs the congestion type counts as a full pool
+	CongestionPendingTxs int `toml:",omitempty"`
 }
 
 var (
@@ -40,7 +59,15 @@ var (
 		DefaultL1CoinPrice:  2000,
 		DefaultL2CoinPrice:  50,
 		GasPriceUsdt:        0.000000476190476,
+		CoinPriceSource:     KafkaSourceType,
+		CoinPricePollPeriod: 30 * time.Second,
+		CoinPriceMaxAge:     10 * time.Minute,
 		CongestionThreshold: 0,
+
+		CongestionTargetLoad: 0.5,
+		CongestionBlocks:     10,
+		CongestionBlockGas:   30_000_000,
+		CongestionPendingTxs: 1000,
 	}
 	DefaultXLayerPrice = big.NewInt(1 * params.GWei)
 )
@@ -54,4 +81,18 @@ const (
 
 	// FixedType the gas price from config that the unit is usdt, XLayer config
 	FixedType string = "fixed"
+
+	// CongestionType moves the gas price EIP-1559 like by the block fullness, the pending txs and the zk counters used.
+	CongestionType string = "congestion"
+)
+
+const (
+	// KafkaSourceType reads the coin prices pushed to a kafka topic.
+	KafkaSourceType string = "kafka"
+
+	// HTTPSourceType polls the coin prices from an http endpoint.
+	HTTPSourceType string = "http"
+
+	// FileSourceType reads the coin prices from a JSON file.
+	FileSourceType string = "file"
 )
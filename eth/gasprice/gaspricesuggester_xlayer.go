@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
 	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
@@ -20,24 +23,140 @@ type L2GasPricer interface {
 	GetCtx() context.Context
 }
 
-// NewL2GasPriceSuggester init.
+// L2GasPricerFactory builds an L2 gas pricer strategy, the context given ends when the strategy is swapped out.
+type L2GasPricerFactory func(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer
+
+var (
+	l2GasPricersLock sync.RWMutex
+	l2GasPricers     = map[string]L2GasPricerFactory{}
+)
+
+func init() {
+	RegisterL2GasPricer(gaspricecfg.DefaultType, func(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer {
+		return newDefaultGasPriceSuggester(ctx, cfg)
+	})
+	RegisterL2GasPricer(gaspricecfg.FollowerType, func(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer {
+		return newFollowerGasPriceSuggester(ctx, cfg)
+	})
+	RegisterL2GasPricer(gaspricecfg.FixedType, func(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer {
+		return newFixedGasPriceSuggester(ctx, cfg)
+	})
+	RegisterL2GasPricer(gaspricecfg.CongestionType, func(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer {
+		return newCongestionGasPriceSuggester(ctx, cfg)
+	})
+}
+
+// RegisterL2GasPricer makes a strategy selectable by its type name, in the config and through Apollo.
+func RegisterL2GasPricer(typ string, factory L2GasPricerFactory) {
+	l2GasPricersLock.Lock()
+	defer l2GasPricersLock.Unlock()
+	if _, ok := l2GasPricers[typ]; ok {
+		panic(fmt.Sprintf("l2 gas price suggester type %v registered twice", typ))
+	}
+	l2GasPricers[typ] = factory
+}
+
+// L2GasPricerTypes returns the type names of the strategies registered.
+func L2GasPricerTypes() []string {
+	l2GasPricersLock.RLock()
+	defer l2GasPricersLock.RUnlock()
+	types := make([]string, 0, len(l2GasPricers))
+	for typ := range l2GasPricers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func getL2GasPricerFactory(typ string) (L2GasPricerFactory, bool) {
+	l2GasPricersLock.RLock()
+	defer l2GasPricersLock.RUnlock()
+	factory, ok := l2GasPricers[typ]
+	return factory, ok
+}
+
+// NewL2GasPriceSuggester init, the strategy of the type configured being swapped for another when a config update
+// changes the type.
 func NewL2GasPriceSuggester(ctx context.Context, cfg gaspricecfg.Config) L2GasPricer {
-	var gpricer L2GasPricer
-	switch cfg.XLayer.Type {
-	case gaspricecfg.FollowerType:
-		log.Info("Follower type selected")
-		gpricer = newFollowerGasPriceSuggester(ctx, cfg)
-	case gaspricecfg.DefaultType:
-		log.Info("Default type selected")
-		gpricer = newDefaultGasPriceSuggester(ctx, cfg)
-	case gaspricecfg.FixedType:
-		log.Info("Fixed type selected")
-		gpricer = newFixedGasPriceSuggester(ctx, cfg)
-	default:
-		log.Error(fmt.Sprintf("unknown l2 gas price suggester type %v. Please specify a valid one: 'follower', 'fixed' or 'default'", cfg.XLayer.Type))
+	s := &L2GasPricerSwitch{ctx: ctx}
+	if _, ok := getL2GasPricerFactory(cfg.XLayer.Type); !ok {
+		log.Error(fmt.Sprintf("unknown l2 gas price suggester type %v. Please specify a valid one: %s. Using 'default'", cfg.XLayer.Type, strings.Join(L2GasPricerTypes(), ", ")))
+		cfg.XLayer.Type = gaspricecfg.DefaultType
+	}
+	s.start(cfg)
+	return s
+}
+
+// L2GasPricerSwitch runs the strategy of the type configured, swapping it when the type changes.
+type L2GasPricerSwitch struct {
+	ctx    context.Context
+	lock   sync.RWMutex
+	active L2GasPricer
+	typ    string
+	cancel context.CancelFunc
+}
+
+// start swaps in the strategy of the type of the config, ending the one running.
+func (s *L2GasPricerSwitch) start(cfg gaspricecfg.Config) {
+	factory, _ := getL2GasPricerFactory(cfg.XLayer.Type)
+	if s.cancel != nil {
+		s.cancel()
 	}
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(s.ctx)
+	s.active = factory(ctx, cfg)
+	s.typ = cfg.XLayer.Type
+	log.Info(fmt.Sprintf("L2 gas price suggester type %v selected", s.typ))
+}
 
-	return gpricer
+// Active returns the strategy running.
+func (s *L2GasPricerSwitch) Active() L2GasPricer {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.active
+}
+
+func (s *L2GasPricerSwitch) UpdateGasPriceAvg(l1GasPrice *big.Int) {
+	s.Active().UpdateGasPriceAvg(l1GasPrice)
+}
+
+// UpdateConfig updates the config of the strategy running, or swaps it for the one of another type.
+func (s *L2GasPricerSwitch) UpdateConfig(c gaspricecfg.Config) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c.XLayer.Type == s.typ {
+		s.active.UpdateConfig(c)
+		return
+	}
+	if _, ok := getL2GasPricerFactory(c.XLayer.Type); !ok {
+		log.Error(fmt.Sprintf("unknown l2 gas price suggester type %v, keeping %v", c.XLayer.Type, s.typ))
+		c.XLayer.Type = s.typ
+		s.active.UpdateConfig(c)
+		return
+	}
+	s.start(c)
+}
+
+func (s *L2GasPricerSwitch) GetLastRawGP() *big.Int {
+	return s.Active().GetLastRawGP()
+}
+
+func (s *L2GasPricerSwitch) GetConfig() gaspricecfg.Config {
+	return s.Active().GetConfig()
+}
+
+// GetCtx returns the context of the suggester, the one of the strategy running ending when it is swapped out.
+func (s *L2GasPricerSwitch) GetCtx() context.Context {
+	return s.ctx
+}
+
+// AsL2CongestionPricer returns the strategy running as a congestion aware one, if it is.
+func AsL2CongestionPricer(p L2GasPricer) (L2CongestionPricer, bool) {
+	if s, ok := p.(*L2GasPricerSwitch); ok {
+		p = s.Active()
+	}
+	c, ok := p.(L2CongestionPricer)
+	return c, ok
 }
 
 func GetL1GasPrice(l1RpcUrl string) (*big.Int, error) {
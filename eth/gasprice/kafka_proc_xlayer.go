@@ -4,16 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/ledgerwatch/erigon/eth/gasprice/gaspricecfg"
-	"github.com/ledgerwatch/log/v3"
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl/plain"
 )
@@ -72,42 +68,18 @@ type Price struct {
 	Id                       string  `json:"id"`
 }
 
-// L1L2PriceRecord l1 l2 coin price record
-type L1L2PriceRecord struct {
-	l1Price  float64
-	l2Price  float64
-	l1Update bool
-	l2Update bool
-}
-
-// KafkaProcessor kafka processor
+// KafkaProcessor kafka processor, the coin price source reading the prices pushed to a kafka topic
 type KafkaProcessor struct {
-	cfg       gaspricecfg.XLayerConfig
-	kreader   *kafka.Reader
-	ctx       context.Context
-	rwLock    sync.RWMutex
-	l1CoinId  int
-	l2CoinId  int
-	l1Price   float64
-	l2Price   float64
-	tmpPrices L1L2PriceRecord
+	*coinPrices
+	kreader *kafka.Reader
+	ctx     context.Context
 }
 
 func newKafkaProcessor(cfg gaspricecfg.XLayerConfig, ctx context.Context) *KafkaProcessor {
 	rp := &KafkaProcessor{
-		cfg:      cfg,
-		kreader:  getKafkaReader(cfg),
-		l1Price:  cfg.DefaultL1CoinPrice,
-		l2Price:  cfg.DefaultL2CoinPrice,
-		ctx:      ctx,
-		l2CoinId: okbcoinId,
-		l1CoinId: ethcoinId,
-	}
-	if cfg.L2CoinId != 0 {
-		rp.l2CoinId = cfg.L2CoinId
-	}
-	if cfg.L1CoinId != 0 {
-		rp.l1CoinId = cfg.L1CoinId
+		coinPrices: newCoinPrices(cfg),
+		kreader:    getKafkaReader(cfg),
+		ctx:        ctx,
 	}
 
 	go rp.processor()
@@ -176,95 +148,3 @@ func (rp *KafkaProcessor) ReadAndUpdate(ctx context.Context) error {
 	}
 	return rp.Update(m.Value)
 }
-
-// Update update the coin price
-func (rp *KafkaProcessor) Update(data []byte) error {
-	if rp.cfg.Type == gaspricecfg.FixedType {
-		price, err := rp.parseCoinPrice(data, []int{rp.l2CoinId})
-		if err == nil {
-			rp.updateL2CoinPrice(price[rp.l2CoinId])
-		}
-		return err
-	} else if rp.cfg.Type == gaspricecfg.FollowerType {
-		prices, err := rp.parseCoinPrice(data, []int{rp.l1CoinId, rp.l2CoinId})
-		if err == nil {
-			rp.updateL1L2CoinPrice(prices)
-		}
-		return err
-	}
-	return nil
-}
-
-func (rp *KafkaProcessor) updateL2CoinPrice(price float64) {
-	rp.rwLock.Lock()
-	defer rp.rwLock.Unlock()
-	rp.l2Price = price
-}
-
-func (rp *KafkaProcessor) updateL1L2CoinPrice(prices map[int]float64) {
-	if len(prices) == 0 {
-		return
-	}
-	rp.rwLock.Lock()
-	defer rp.rwLock.Unlock()
-	if v, ok := prices[rp.l1CoinId]; ok {
-		rp.tmpPrices.l1Price = v
-		rp.tmpPrices.l1Update = true
-	}
-	if v, ok := prices[rp.l2CoinId]; ok {
-		rp.tmpPrices.l2Price = v
-		rp.tmpPrices.l2Update = true
-	}
-	if rp.tmpPrices.l1Update && rp.tmpPrices.l2Update {
-		rp.l1Price = rp.tmpPrices.l1Price
-		rp.l2Price = rp.tmpPrices.l2Price
-		rp.tmpPrices.l1Update = false
-		rp.tmpPrices.l2Update = false
-		return
-	}
-}
-
-func (rp *KafkaProcessor) parseCoinPrice(value []byte, coinIds []int) (map[int]float64, error) {
-	if len(coinIds) == 0 {
-		return nil, fmt.Errorf("the params coinIds is empty")
-	}
-	msgI := &MsgInfo{}
-	err := json.Unmarshal(value, &msgI)
-	if err != nil {
-		return nil, err
-	}
-	if msgI.Data == nil || len(msgI.Data.PriceList) == 0 {
-		return nil, fmt.Errorf("the data PriceList is empty")
-	}
-	mp := make(map[int]*Price)
-	for _, price := range msgI.Data.PriceList {
-		mp[price.CoinId] = price
-	}
-
-	results := make(map[int]float64)
-	for _, coinId := range coinIds {
-		if coin, ok := mp[coinId]; ok {
-			results[coinId] = coin.Price
-		} else {
-			log.Debug("not find a correct coin price coin id is =", coinId)
-		}
-	}
-	if len(results) == 0 {
-		return results, ErrNotFindCoinPrice
-	}
-	return results, nil
-}
-
-// GetL2CoinPrice gets the L2 coin price in USDT
-func (rp *KafkaProcessor) GetL2CoinPrice() float64 {
-	rp.rwLock.RLock()
-	defer rp.rwLock.RUnlock()
-	return rp.l2Price
-}
-
-// GetL1L2CoinPrice gets both l1 and L2 coin prices in USDT
-func (rp *KafkaProcessor) GetL1L2CoinPrice() (float64, float64) {
-	rp.rwLock.RLock()
-	defer rp.rwLock.RUnlock()
-	return rp.l1Price, rp.l2Price
-}
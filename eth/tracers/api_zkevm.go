@@ -24,3 +24,20 @@ type TraceConfig_ZkEvm struct {
 	CounterCollector *vm.TransactionCounter
 	SmtDepth         *int
 }
+
+// ZkCountersTracerName is the native tracer rolling the zk counters of a transaction up per contract, call frame
+// and opcode
+const ZkCountersTracerName = "zkCountersTracer"
+
+// ZkCountersTracer is a tracer reading the zk counters a transaction uses as it runs, the collector the
+// interpreter deducts them from is given to it before the run
+type ZkCountersTracer interface {
+	Tracer
+	SetCounterCollector(cc *vm.CounterCollector)
+}
+
+// TracesZkCounters tells whether the tracer asked for is the zk counters one, the transactions it traces needing
+// counters to run with
+func (c *TraceConfig_ZkEvm) TracesZkCounters() bool {
+	return c != nil && c.Tracer != nil && *c.Tracer == ZkCountersTracerName
+}
@@ -0,0 +1,255 @@
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"github.com/holiman/uint256"
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+)
+
+func init() {
+	register(tracers.ZkCountersTracerName, newZkCountersTracer)
+}
+
+var errZkCountersTracerNoCounters = errors.New("the zk counters of the transaction are not known")
+
+// zkCounterUsage holds the zk counters used, indexed by vm.CounterKey
+type zkCounterUsage [8]int
+
+func (u *zkCounterUsage) add(o *zkCounterUsage) {
+	for i := range u {
+		u[i] += o[i]
+	}
+}
+
+func (u *zkCounterUsage) isZero() bool {
+	return *u == zkCounterUsage{}
+}
+
+// MarshalJSON names the counters the way zkevm_estimateCounters does
+func (u zkCounterUsage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{
+		"steps":            u[vm.S],
+		"arithmetics":      u[vm.A],
+		"binaries":         u[vm.B],
+		"memAligns":        u[vm.M],
+		"keccakHashes":     u[vm.K],
+		"poseidonPaddings": u[vm.D],
+		"poseidonhashes":   u[vm.P],
+		"SHA256hashes":     u[vm.SHA],
+	})
+}
+
+type zkCountersOpcode struct {
+	Count    int            `json:"count"`
+	Counters zkCounterUsage `json:"counters"`
+}
+
+type zkCountersFrame struct {
+	Type     string             `json:"type"`
+	From     libcommon.Address  `json:"from"`
+	To       libcommon.Address  `json:"to"`
+	Error    string             `json:"error,omitempty"`
+	Counters zkCounterUsage     `json:"counters"`   // used by the frame itself
+	Total    zkCounterUsage     `json:"cumulative"` // used by the frame and its subcalls
+	Calls    []*zkCountersFrame `json:"calls,omitempty"`
+
+	op     vm.OpCode // the opcode the counters used from now on go to
+	inCode bool      // whether an opcode of the frame ran already
+}
+
+type zkCountersResult struct {
+	SmtLevels  int                                   `json:"smtLevels"`
+	Counters   zkCounterUsage                        `json:"counters"`
+	Limits     zkCounterUsage                        `json:"limits"`
+	ByContract map[libcommon.Address]*zkCounterUsage `json:"byContract"`
+	ByOpcode   map[string]*zkCountersOpcode          `json:"byOpcode"`
+	Call       *zkCountersFrame                      `json:"call"`
+}
+
+// zkCountersTracer rolls the zk counters a transaction uses up per contract, per call frame and per opcode.
+// The counters are read from the collector the interpreter runs with: every event the usage since the last one
+// goes to the opcode that ran in between, the counters of an opcode being deducted before it executes.
+//
+// Example:
+//
+//	> debug.traceCall({...}, "latest", {tracer: "zkCountersTracer"})
+//	{
+//	  "smtLevels": 48,
+//	  "counters": {"steps": 5130, "keccakHashes": 3, ...},
+//	  "limits": {"steps": 7570538, ...},
+//	  "byContract": {"0x...": {"steps": 4210, ...}},
+//	  "byOpcode": {"SSTORE": {"count": 2, "counters": {"steps": 1364, ...}}},
+//	  "call": {"type": "CALL", "from": "0x...", "to": "0x...", "counters": {...}, "cumulative": {...}, "calls": [...]}
+//	}
+type zkCountersTracer struct {
+	noopTracer
+	collector *vm.CounterCollector
+	last      zkCounterUsage
+	result    zkCountersResult
+	callstack []*zkCountersFrame
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newZkCountersTracer returns a native go tracer rolling up the zk counters of a tx, its counters being given
+// before it runs by SetCounterCollector.
+func newZkCountersTracer(ctx *tracers.Context, _ json.RawMessage) (tracers.Tracer, error) {
+	return &zkCountersTracer{
+		result: zkCountersResult{
+			ByContract: make(map[libcommon.Address]*zkCounterUsage),
+			ByOpcode:   make(map[string]*zkCountersOpcode),
+		},
+	}, nil
+}
+
+// SetCounterCollector implements tracers.ZkCountersTracer.
+func (t *zkCountersTracer) SetCounterCollector(cc *vm.CounterCollector) {
+	t.collector = cc
+	t.result.SmtLevels = cc.GetSmtLevels()
+	for i, c := range cc.Counters() {
+		t.result.Limits[i] = c.Limit()
+	}
+	t.last = t.used()
+}
+
+func (t *zkCountersTracer) used() zkCounterUsage {
+	var used zkCounterUsage
+	for i, c := range t.collector.Counters() {
+		used[i] = c.Used()
+	}
+	return used
+}
+
+// collect attributes the counters used since the last event to the running frame and its pending opcode
+func (t *zkCountersTracer) collect() {
+	if t.collector == nil || len(t.callstack) == 0 {
+		return
+	}
+	used := t.used()
+	var delta zkCounterUsage
+	for i := range delta {
+		delta[i] = used[i] - t.last[i]
+	}
+	t.last = used
+	if delta.isZero() {
+		return
+	}
+
+	frame := t.callstack[len(t.callstack)-1]
+	frame.Counters.add(&delta)
+	t.result.Counters.add(&delta)
+	contract, ok := t.result.ByContract[frame.To]
+	if !ok {
+		contract = new(zkCounterUsage)
+		t.result.ByContract[frame.To] = contract
+	}
+	contract.add(&delta)
+	// a precompile or a call to an account without code runs no opcode
+	if frame.inCode {
+		t.result.ByOpcode[frame.op.String()].Counters.add(&delta)
+	}
+}
+
+func (t *zkCountersTracer) enter(typ vm.OpCode, from, to libcommon.Address) {
+	frame := &zkCountersFrame{Type: typ.String(), From: from, To: to}
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	} else {
+		t.result.Call = frame
+	}
+	t.callstack = append(t.callstack, frame)
+}
+
+func (t *zkCountersTracer) exit(err error) {
+	frame := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	if err != nil {
+		frame.Error = err.Error()
+	}
+	frame.Total = frame.Counters
+	for _, call := range frame.Calls {
+		frame.Total.add(&call.Total)
+	}
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *zkCountersTracer) CaptureStart(env *vm.EVM, from libcommon.Address, to libcommon.Address, precompile bool, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	t.enter(typ, from, to)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *zkCountersTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if len(t.callstack) != 1 {
+		return
+	}
+	t.collect()
+	t.exit(err)
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *zkCountersTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 || len(t.callstack) == 0 {
+		return
+	}
+	t.collect()
+
+	frame := t.callstack[len(t.callstack)-1]
+	frame.op, frame.inCode = op, true
+	opcode, ok := t.result.ByOpcode[op.String()]
+	if !ok {
+		opcode = &zkCountersOpcode{}
+		t.result.ByOpcode[op.String()] = opcode
+	}
+	opcode.Count++
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *zkCountersTracer) CaptureEnter(typ vm.OpCode, from libcommon.Address, to libcommon.Address, precompile, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	if atomic.LoadUint32(&t.interrupt) > 0 || len(t.callstack) == 0 {
+		return
+	}
+	// the counters of the calling opcode go to the caller
+	t.collect()
+	t.enter(typ, from, to)
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *zkCountersTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if atomic.LoadUint32(&t.interrupt) > 0 || len(t.callstack) <= 1 {
+		return
+	}
+	t.collect()
+	t.exit(err)
+}
+
+// GetResult returns the json-encoded counters rolled up, and any error arising from the encoding or forceful
+// termination (via `Stop`).
+func (t *zkCountersTracer) GetResult() (json.RawMessage, error) {
+	if t.collector == nil {
+		return nil, errZkCountersTracerNoCounters
+	}
+	res, err := json.Marshal(t.result)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *zkCountersTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
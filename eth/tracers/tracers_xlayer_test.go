@@ -0,0 +1,132 @@
+package tracers_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/core/vm/evmtypes"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/tests"
+	"github.com/ledgerwatch/erigon/turbo/stages/mock"
+)
+
+type zkCountersTrace struct {
+	Counters   map[string]int                       `json:"counters"`
+	ByContract map[libcommon.Address]map[string]int `json:"byContract"`
+	ByOpcode   map[string]struct {
+		Count    int            `json:"count"`
+		Counters map[string]int `json:"counters"`
+	} `json:"byOpcode"`
+	Call zkCountersTraceFrame `json:"call"`
+}
+
+type zkCountersTraceFrame struct {
+	Type       string                 `json:"type"`
+	To         libcommon.Address      `json:"to"`
+	Counters   map[string]int         `json:"counters"`
+	Cumulative map[string]int         `json:"cumulative"`
+	Calls      []zkCountersTraceFrame `json:"calls"`
+}
+
+func TestZkCountersTracer(t *testing.T) {
+	caller, callee := libcommon.HexToAddress("0xaa"), libcommon.HexToAddress("0xbb")
+
+	chainConfig := params.ChainConfigByChainName("hermez-dev")
+	chainConfig.ForkID4Block = big.NewInt(0)
+	chainConfig.ForkID5DragonfruitBlock = big.NewInt(0)
+	chainConfig.ForkID6IncaBerryBlock = big.NewInt(0)
+	chainConfig.ForkID7EtrogBlock = big.NewInt(0)
+	chainConfig.ForkID88ElderberryBlock = big.NewInt(0)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := types.LatestSignerForChainID(chainConfig.ChainID)
+	txn, err := types.SignTx(types.NewTransaction(0, caller, uint256.NewInt(0), 1_000_000, uint256.NewInt(0), nil), *signer, key)
+	require.NoError(t, err)
+	origin, err := signer.Sender(txn)
+	require.NoError(t, err)
+
+	alloc := types.GenesisAlloc{
+		origin: {Balance: big.NewInt(1_000_000_000)},
+		// CALL 0xbb
+		caller: {Nonce: 1, Code: hexutil.MustDecode("0x600060006000600060007300000000000000000000000000000000000000bb5af15000")},
+		// SSTORE(0, 42), KECCAK256(0, 32)
+		callee: {Nonce: 1, Code: hexutil.MustDecode("0x602a6000556020600020500000")},
+	}
+	blockCtx := evmtypes.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: 1,
+		Time:        1,
+		Difficulty:  big.NewInt(0),
+		GasLimit:    10_000_000,
+		BaseFee:     uint256.NewInt(0),
+	}
+	rules := chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Time)
+
+	m := mock.Mock(t)
+	tx, err := m.DB.BeginRw(m.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	statedb, err := tests.MakePreState(rules, tx, alloc, blockCtx.BlockNumber)
+	require.NoError(t, err)
+
+	// the tracer can't run without the counters
+	tracer, err := tracers.New(tracers.ZkCountersTracerName, new(tracers.Context), json.RawMessage("{}"))
+	require.NoError(t, err)
+	_, err = tracer.GetResult()
+	require.Error(t, err)
+
+	tracer, err = tracers.New(tracers.ZkCountersTracerName, new(tracers.Context), json.RawMessage("{}"))
+	require.NoError(t, err)
+	counters := vm.NewTransactionCounter(txn, 32, uint16(chainConfig.ForkID88ElderberryBlock.Uint64()), 0.6, false).ExecutionCounters()
+	tracer.(tracers.ZkCountersTracer).SetCounterCollector(counters)
+
+	msg, err := txn.AsMessage(*signer, nil, rules)
+	require.NoError(t, err)
+	evm := vm.NewZkEVM(blockCtx, core.NewEVMTxContext(msg), statedb, chainConfig, vm.NewZkConfig(vm.Config{Debug: true, Tracer: tracer}, counters))
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(msg.Gas()), true, false)
+	require.NoError(t, err)
+	require.NoError(t, result.Err)
+
+	res, err := tracer.GetResult()
+	require.NoError(t, err)
+	var trace zkCountersTrace
+	require.NoError(t, json.Unmarshal(res, &trace))
+
+	// everything the execution used is rolled up
+	for i, name := range []string{"steps", "arithmetics", "binaries", "memAligns", "keccakHashes", "poseidonPaddings", "poseidonhashes", "SHA256hashes"} {
+		require.Equal(t, counters.Counters()[i].Used(), trace.Counters[name], name)
+		require.Equal(t, trace.Counters[name], trace.ByContract[caller][name]+trace.ByContract[callee][name], name)
+		require.Equal(t, trace.Counters[name], trace.Call.Cumulative[name], name)
+		require.Equal(t, trace.Call.Counters[name]+trace.Call.Calls[0].Cumulative[name], trace.Call.Cumulative[name], name)
+	}
+	require.Positive(t, trace.Counters["steps"])
+
+	// the frames
+	require.Equal(t, caller, trace.Call.To)
+	require.Len(t, trace.Call.Calls, 1)
+	require.Equal(t, "CALL", trace.Call.Calls[0].Type)
+	require.Equal(t, callee, trace.Call.Calls[0].To)
+	require.Equal(t, trace.ByContract[callee], trace.Call.Calls[0].Counters)
+
+	// the opcodes, the keccak hashes of the callee being the ones of KECCAK256
+	require.Equal(t, 1, trace.ByOpcode["SSTORE"].Count)
+	require.Positive(t, trace.ByOpcode["SSTORE"].Counters["poseidonhashes"])
+	require.Equal(t, 1, trace.ByOpcode["KECCAK256"].Count)
+	require.Positive(t, trace.ByOpcode["KECCAK256"].Counters["keccakHashes"])
+	require.Equal(t, trace.ByContract[callee]["keccakHashes"], trace.ByOpcode["KECCAK256"].Counters["keccakHashes"])
+	require.Equal(t, 1, trace.ByOpcode["CALL"].Count)
+	require.Positive(t, trace.ByOpcode["CALL"].Counters["steps"])
+}
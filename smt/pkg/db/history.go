@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+)
+
+// The nodes an updated tree no longer uses are deleted straight away, which leaves the roots of the older blocks
+// dangling. When the history is retained they stay in TableSmt instead, recorded as dropped by the tree of the block
+// being computed, until PruneHistory deletes them.
+const TableRetainedNodes = "HermezSmtRetainedNodes"           // block number + node key -> true
+const TableRetainedNodeBlocks = "HermezSmtRetainedNodeBlocks" // node key -> block number
+
+// key of the stats table holding the oldest block which tree can be traversed from its root
+var historyFromKey = []byte("historyFrom")
+
+var errStopPruning = errors.New("stop pruning")
+
+// RetainHistory makes the nodes deleted from now on stay in the db, dropped by the tree of the given block
+func (m *EriDb) RetainHistory(block uint64) {
+	m.retainHistory = true
+	m.historyBlock = block
+}
+
+func (m *EriRoDb) GetHistoryFrom() (uint64, bool, error) {
+	data, err := m.kvTxRo.GetOne(TableStats, historyFromKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+func (m *EriDb) SetHistoryFrom(block uint64) error {
+	return m.tx.Put(TableStats, historyFromKey, blockKey(block))
+}
+
+func (m *EriDb) ClearHistoryFrom() error {
+	return m.tx.Delete(TableStats, historyFromKey)
+}
+
+// retainNode records the node as dropped by the tree of the current block rather than deleting it
+func (m *EriDb) retainNode(k []byte) error {
+	// the node may have been dropped before and brought back since
+	if err := m.releaseNode(k); err != nil {
+		return err
+	}
+	if err := m.tx.Put(TableRetainedNodes, append(blockKey(m.historyBlock), k...), []byte{1}); err != nil {
+		return err
+	}
+	return m.tx.Put(TableRetainedNodeBlocks, k, blockKey(m.historyBlock))
+}
+
+// releaseNode forgets that the node was dropped, for the nodes inserted again
+func (m *EriDb) releaseNode(k []byte) error {
+	block, err := m.kvTxRo.GetOne(TableRetainedNodeBlocks, k)
+	if err != nil || len(block) == 0 {
+		return err
+	}
+	if err := m.tx.Delete(TableRetainedNodes, append(blockKey(binary.BigEndian.Uint64(block)), k...)); err != nil {
+		return err
+	}
+	return m.tx.Delete(TableRetainedNodeBlocks, k)
+}
+
+// PruneHistory deletes at most limit (0 for all) of the nodes dropped by the trees of the blocks up to toBlock. It
+// returns the oldest block which tree stays whole, toBlock once everything up to it is pruned
+func (m *EriDb) PruneHistory(toBlock uint64, limit int) (prunedTo uint64, done bool, err error) {
+	keys := make([][]byte, 0)
+	done = true
+	if err = m.tx.ForEach(TableRetainedNodes, nil, func(k, _ []byte) error {
+		if binary.BigEndian.Uint64(k[:8]) > toBlock {
+			return errStopPruning
+		}
+		if limit > 0 && len(keys) == limit {
+			done = false
+			return errStopPruning
+		}
+		keys = append(keys, append([]byte{}, k...))
+		return nil
+	}); err != nil && !errors.Is(err, errStopPruning) {
+		return 0, false, err
+	}
+
+	for _, k := range keys {
+		if err = m.tx.Delete(TableSmt, k[8:]); err != nil {
+			return 0, false, err
+		}
+		if err = m.tx.Delete(TableRetainedNodeBlocks, k[8:]); err != nil {
+			return 0, false, err
+		}
+		if err = m.tx.Delete(TableRetainedNodes, k); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if done {
+		return toBlock, true, nil
+	}
+	// the trees of the blocks before the last one pruned may miss some of the nodes it dropped
+	return binary.BigEndian.Uint64(keys[len(keys)-1][:8]), false, nil
+}
+
+func (m *EriDb) deleteNode(key utils.NodeKey) error {
+	k := []byte(utils.ConvertBigIntToHex(utils.ArrayToScalar(key[:])))
+	if m.retainHistory {
+		return m.retainNode(k)
+	}
+	return m.tx.Delete(TableSmt, k)
+}
+
+func blockKey(block uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, block)
+	return k
+}
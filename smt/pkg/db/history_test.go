@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEriDbHistory(t *testing.T) {
+	dbi, _ := mdbx.NewTemporaryMdbx(context.Background(), t.TempDir())
+	tx, err := dbi.BeginRw(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, CreateEriDbBuckets(tx))
+	db := NewEriDb(tx)
+
+	node := func(i int64) (utils.NodeKey, utils.NodeValue12) {
+		return utils.NodeKey{uint64(i), 0, 0, 0}, utils.NodeValue12{big.NewInt(i), big.NewInt(0), big.NewInt(0), big.NewInt(0),
+			big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+	}
+	stored := func(key utils.NodeKey) bool {
+		value, err := db.Get(key)
+		require.NoError(t, err)
+		return value[0] != nil
+	}
+
+	key1, value1 := node(1)
+	key2, value2 := node(2)
+	key3, value3 := node(3)
+	require.NoError(t, db.Insert(key1, value1))
+	require.NoError(t, db.Insert(key2, value2))
+	require.NoError(t, db.Insert(key3, value3))
+
+	_, found, err := db.GetHistoryFrom()
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, db.SetHistoryFrom(4))
+
+	// the nodes dropped stay until they are pruned
+	db.RetainHistory(5)
+	require.NoError(t, db.DeleteByNodeKey(key1))
+	require.NoError(t, db.DeleteByNodeKey(key2))
+	db.RetainHistory(6)
+	require.NoError(t, db.DeleteByNodeKey(key3))
+	require.True(t, stored(key1))
+	require.True(t, stored(key2))
+	require.True(t, stored(key3))
+
+	// the nodes brought back are no longer pruned
+	require.NoError(t, db.Insert(key2, value2))
+
+	prunedTo, done, err := db.PruneHistory(4, 0)
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, uint64(4), prunedTo)
+	require.True(t, stored(key1))
+
+	prunedTo, done, err = db.PruneHistory(10, 1)
+	require.NoError(t, err)
+	require.False(t, done)
+	require.Equal(t, uint64(5), prunedTo)
+	require.False(t, stored(key1))
+	require.True(t, stored(key2))
+	require.True(t, stored(key3))
+
+	prunedTo, done, err = db.PruneHistory(10, 0)
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, uint64(10), prunedTo)
+	require.True(t, stored(key2))
+	require.False(t, stored(key3))
+
+	historyFrom, found, err := db.GetHistoryFrom()
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(4), historyFrom)
+	require.NoError(t, db.ClearHistoryFrom())
+	_, found, err = db.GetHistoryFrom()
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -31,12 +31,15 @@ const TableAccountValues = "HermezSmtAccountValues"
 const TableMetadata = "HermezSmtMetadata"
 const TableHashKey = "HermezSmtHashKey"
 
-var HermezSmtTables = []string{TableSmt, TableStats, TableAccountValues, TableMetadata, TableHashKey}
+var HermezSmtTables = []string{TableSmt, TableStats, TableAccountValues, TableMetadata, TableHashKey, TableRetainedNodes, TableRetainedNodeBlocks}
 
 type EriDb struct {
 	kvTx kv.RwTx
 	tx   SmtDbTx
 	*EriRoDb
+
+	retainHistory bool
+	historyBlock  uint64
 }
 
 type EriRoDb struct {
@@ -69,6 +72,16 @@ func CreateEriDbBuckets(tx kv.RwTx) error {
 		return err
 	}
 
+	err = tx.CreateBucket(TableRetainedNodes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.CreateBucket(TableRetainedNodeBlocks)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -186,6 +199,12 @@ func (m *EriDb) Insert(key utils.NodeKey, value utils.NodeValue12) error {
 	vConc := utils.ArrayToScalarBig(vals)
 	v := utils.ConvertBigIntToHex(vConc)
 
+	if m.retainHistory {
+		if err := m.releaseNode([]byte(k)); err != nil {
+			return err
+		}
+	}
+
 	return m.tx.Put(TableSmt, []byte(k), []byte(v))
 }
 
@@ -194,9 +213,7 @@ func (m *EriDb) Delete(key string) error {
 }
 
 func (m *EriDb) DeleteByNodeKey(key utils.NodeKey) error {
-	keyConc := utils.ArrayToScalar(key[:])
-	k := utils.ConvertBigIntToHex(keyConc)
-	return m.tx.Delete(TableSmt, []byte(k))
+	return m.deleteNode(key)
 }
 
 func (m *EriRoDb) GetAccountValue(key utils.NodeKey) (utils.NodeValue8, error) {
@@ -257,8 +257,9 @@ func (m *MemDb) AddCode(code []byte) error {
 	m.lock.Lock()         // Lock for writing
 	defer m.lock.Unlock() // Make sure to unlock when done
 
-	codeHash := utils.HashContractBytecode(hex.EncodeToString(code))
-	m.DbCode[codeHash] = code
+	// keyed the way GetCode looks the code up, by the hash padded to 32 bytes
+	codeHash := utils.ResizeHashTo32BytesByPrefixingWithZeroes(utils.HashContractBytecodeBigInt(hex.EncodeToString(code)).Bytes())
+	m.DbCode["0x"+hex.EncodeToString(codeHash)] = code
 	return nil
 }
 
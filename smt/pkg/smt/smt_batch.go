@@ -10,6 +10,9 @@ import (
 	"github.com/ledgerwatch/erigon/zk"
 )
 
+// parallelInsertBatchMinKeys is the batch size from which the subtrees are updated and hashed in parallel
+var parallelInsertBatchMinKeys = 1 << 12
+
 type InsertBatchConfig struct {
 	ctx                 context.Context
 	logPrefix           string
@@ -69,28 +72,107 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 	progressChan, stopProgressPrinter := getProgressPrinterPre(cfg.logPrefix, "process", uint64(size), cfg.shouldPrintProgress)
 	defer stopProgressPrinter()
 
-	for i := 0; i < size; i++ {
+	inParallel := size >= parallelInsertBatchMinKeys && parallel.DefaultNumGoroutines() > 1
+	if inParallel {
+		maxInsertingNodePathLevel, err = s.insertBatchInParallel(cfg, nodeKeys, nodeValues, nodeValuesHashes, rootNodeHash, &smtBatchNodeRoot, nodeHashesForDelete, *progressChan)
+	} else {
+		maxInsertingNodePathLevel, err = s.insertBatchIntoSubtree(cfg, nodeKeys, nodeValues, nodeValuesHashes, rootNodeHash, &smtBatchNodeRoot, 0, s.fetchNodeDataFromDb, nodeHashesForDelete, *progressChan)
+	}
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case *progressChan <- uint64(1):
+	default:
+	}
+	stopProgressPrinter()
+
+	if err := s.updateDepth(maxInsertingNodePathLevel); err != nil {
+		return nil, fmt.Errorf("updateDepth: %w", err)
+	}
+
+	if err := s.deleteBatchedNodeValues(cfg.logPrefix, nodeHashesForDelete); err != nil {
+		return nil, fmt.Errorf("deleteBatchedNodeValues: %w", err)
+	}
+
+	if err := s.saveBatchedNodeValues(cfg.logPrefix, nodeValues, nodeValuesHashes); err != nil {
+		return nil, fmt.Errorf("saveBatchedNodeValues: %w", err)
+	}
+
+	if smtBatchNodeRoot == nil {
+		rootNodeHash = &utils.NodeKey{0, 0, 0, 0}
+	} else {
+		sdh := newSmtDfsHelper(s)
+
+		go func() {
+			defer sdh.destroy()
+
+			if inParallel {
+				calculateAndSaveSubtreesHashesDfs(sdh, smtBatchNodeRoot, parallelInsertBatchSplitLevel())
+			}
+			calculateAndSaveHashesDfs(sdh, smtBatchNodeRoot, make([]int, 256), 0)
+			rootNodeHash = (*utils.NodeKey)(smtBatchNodeRoot.hash)
+		}()
+
+		if !s.noSaveOnInsert {
+			if err = sdh.startConsumersLoop(s); err != nil {
+				return nil, fmt.Errorf("saving smt hashes dfs: %w", err)
+			}
+		}
+		sdh.wg.Wait()
+	}
+	if err := s.setLastRoot(*rootNodeHash); err != nil {
+		return nil, err
+	}
+
+	return &SMTResponse{
+		Mode:          "batch insert",
+		NewRootScalar: rootNodeHash,
+	}, nil
+}
+
+// insertBatchIntoSubtree inserts the keys into the subtree at the given level, whose root has no parent: a root
+// deleted or collapsed there leaves the levels above as they are
+func (s *SMT) insertBatchIntoSubtree(
+	cfg InsertBatchConfig,
+	nodeKeys []*utils.NodeKey,
+	nodeValues []*utils.NodeValue8,
+	nodeValuesHashes []*[4]uint64,
+	subtreeRootHash *utils.NodeKey,
+	subtreeRoot **smtBatchNode,
+	subtreeLevel int,
+	fetch smtBatchNodeFetcher,
+	nodeHashesForDelete map[uint64]map[uint64]map[uint64]map[uint64]*utils.NodeKey,
+	progressChan chan uint64,
+) (maxLevel int, err error) {
+	for i := 0; i < len(nodeKeys); i++ {
 		select {
 		case <-cfg.ctx.Done():
-			return nil, fmt.Errorf("context done")
-		case *progressChan <- uint64(1):
+			return 0, fmt.Errorf("context done")
+		case progressChan <- uint64(1):
 		default:
 		}
 
+		// the root was loaded by the first key, missing afterwards it has been deleted and must not be loaded again
+		if i > 0 && *subtreeRoot == nil {
+			subtreeRootHash = &utils.NodeKey{0, 0, 0, 0}
+		}
+
 		insertingNodeKey := nodeKeys[i]
 		insertingNodeValue := nodeValues[i]
 		insertingNodeValueHash := nodeValuesHashes[i]
 		insertingNodePath := insertingNodeKey.GetPath()
-		insertingNodePathLevel, insertingPointerToSmtBatchNode, visitedNodeHashes, err := s.findInsertingPoint(insertingNodePath, rootNodeHash, &smtBatchNodeRoot, insertingNodeValue.IsZero())
+		insertingNodePathLevel, insertingPointerToSmtBatchNode, visitedNodeHashes, err := s.findInsertingPoint(insertingNodePath, subtreeRootHash, subtreeRoot, subtreeLevel, insertingNodeValue.IsZero(), fetch)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 		updateNodeHashesForDelete(nodeHashesForDelete, visitedNodeHashes)
 
 		// special case if root does not exists yet
 		if (*insertingPointerToSmtBatchNode) == nil {
 			if !insertingNodeValue.IsZero() {
-				*insertingPointerToSmtBatchNode = newSmtBatchNodeLeaf(insertingNodeKey, (*utils.NodeKey)(insertingNodeValueHash), nil)
+				remainingKey := utils.RemoveKeyBits(*insertingNodeKey, subtreeLevel)
+				*insertingPointerToSmtBatchNode = newSmtBatchNodeLeaf(&remainingKey, (*utils.NodeKey)(insertingNodeValueHash), nil)
 			}
 			// else branch would be for deleting a value but the root does not exists => there is nothing to delete
 			continue
@@ -100,7 +182,7 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 		if !insertingNodeValue.IsZero() {
 			if !((*insertingPointerToSmtBatchNode).isLeaf()) {
 				if insertingPointerToSmtBatchNode, err = (*insertingPointerToSmtBatchNode).createALeafInEmptyDirection(insertingNodePath, insertingNodePathLevel, insertingNodeKey); err != nil {
-					return nil, err
+					return 0, err
 				}
 				insertingRemainingKey = *((*insertingPointerToSmtBatchNode).nodeLeftHashOrRemainingKey)
 				insertingNodePathLevel++
@@ -116,7 +198,7 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 				(*insertingPointerToSmtBatchNode).expandLeafByAddingALeafInDirection(currentTreeNodePath, insertingNodePathLevel)
 
 				if insertingPointerToSmtBatchNode, err = (*insertingPointerToSmtBatchNode).createALeafInEmptyDirection(insertingNodePath, insertingNodePathLevel, insertingNodeKey); err != nil {
-					return nil, err
+					return 0, err
 				}
 				// EXPLAIN THE LINE BELOW: there is no need to update insertingRemainingKey because it is not needed anymore therefore its value is incorrect if used after this line
 				// insertingRemainingKey = *((*insertingPointerToSmtBatchNode).nodeLeftKeyOrRemainingKey)
@@ -164,55 +246,217 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 			}
 		}
 
-		if maxInsertingNodePathLevel < insertingNodePathLevel {
-			maxInsertingNodePathLevel = insertingNodePathLevel
+		if maxLevel < insertingNodePathLevel {
+			maxLevel = insertingNodePathLevel
 		}
 	}
-	select {
-	case *progressChan <- uint64(1):
-	default:
-	}
-	stopProgressPrinter()
 
-	if err := s.updateDepth(maxInsertingNodePathLevel); err != nil {
-		return nil, fmt.Errorf("updateDepth: %w", err)
+	return maxLevel, nil
+}
+
+type smtBatchNodeFetcher func(nodeHash *utils.NodeKey, parentNode *smtBatchNode) (*smtBatchNode, error)
+
+// smtBatchSubtree is a subtree updated on its own goroutine, detached from its parent in the meantime
+type smtBatchSubtree struct {
+	root                *smtBatchNode
+	parent              *smtBatchNode
+	path                []int
+	indices             []int
+	maxLevel            int
+	nodeHashesForDelete map[uint64]map[uint64]map[uint64]map[uint64]*utils.NodeKey
+	err                 error
+}
+
+// parallelInsertBatchSplitLevel returns the level the tree is split at, leaving a few subtrees to each goroutine
+func parallelInsertBatchSplitLevel() int {
+	level := 1
+	for 1<<level < parallel.DefaultNumGoroutines()*4 && level < 8 {
+		level++
 	}
+	return level
+}
 
-	if err := s.deleteBatchedNodeValues(cfg.logPrefix, nodeHashesForDelete); err != nil {
-		return nil, fmt.Errorf("deleteBatchedNodeValues: %w", err)
+// insertBatchInParallel splits the keys by their path down to the split level and updates the branches found there
+// concurrently. The db can only be read from the goroutine owning the transaction, so every node the inserts need
+// is loaded first, and the keys reaching no branch at the split level are inserted on this goroutine afterwards.
+// The tree being canonical, the result is the same as inserting the keys one by one
+func (s *SMT) insertBatchInParallel(
+	cfg InsertBatchConfig,
+	nodeKeys []*utils.NodeKey,
+	nodeValues []*utils.NodeValue8,
+	nodeValuesHashes []*[4]uint64,
+	rootNodeHash *utils.NodeKey,
+	smtBatchNodeRoot **smtBatchNode,
+	nodeHashesForDelete map[uint64]map[uint64]map[uint64]map[uint64]*utils.NodeKey,
+	progressChan chan uint64,
+) (int, error) {
+	splitLevel := parallelInsertBatchSplitLevel()
+
+	for i, nodeKey := range nodeKeys {
+		if i&0xfff == 0 {
+			select {
+			case <-cfg.ctx.Done():
+				return 0, fmt.Errorf("context done")
+			default:
+			}
+		}
+		_, _, visitedNodeHashes, err := s.findInsertingPoint(nodeKey.GetPath(), rootNodeHash, smtBatchNodeRoot, 0, nodeValues[i].IsZero(), s.fetchNodeDataFromDb)
+		if err != nil {
+			return 0, err
+		}
+		updateNodeHashesForDelete(nodeHashesForDelete, visitedNodeHashes)
 	}
 
-	if err := s.saveBatchedNodeValues(cfg.logPrefix, nodeValues, nodeValuesHashes); err != nil {
-		return nil, fmt.Errorf("saveBatchedNodeValues: %w", err)
+	var (
+		subtrees      []*smtBatchSubtree
+		subtreeByRoot = make(map[*smtBatchNode]*smtBatchSubtree)
+		shallow       []int
+	)
+	for i, nodeKey := range nodeKeys {
+		path := nodeKey.GetPath()
+		node, level := *smtBatchNodeRoot, 0
+		for ; level < splitLevel && node != nil && !node.isLeaf(); level++ {
+			node = *node.getChildInDirection(path[level])
+		}
+		if level < splitLevel || node == nil || node.isLeaf() {
+			shallow = append(shallow, i)
+			continue
+		}
+
+		subtree, ok := subtreeByRoot[node]
+		if !ok {
+			subtree = &smtBatchSubtree{
+				root:                node,
+				parent:              node.parentNode,
+				path:                append([]int{}, path[:splitLevel]...),
+				nodeHashesForDelete: make(map[uint64]map[uint64]map[uint64]map[uint64]*utils.NodeKey),
+			}
+			subtrees = append(subtrees, subtree)
+			subtreeByRoot[node] = subtree
+		}
+		subtree.indices = append(subtree.indices, i)
 	}
 
-	if smtBatchNodeRoot == nil {
-		rootNodeHash = &utils.NodeKey{0, 0, 0, 0}
-	} else {
-		sdh := newSmtDfsHelper(s)
+	for _, subtree := range subtrees {
+		subtree.root.parentNode = nil
+	}
 
+	var wg sync.WaitGroup
+	jobs := make(chan *smtBatchSubtree, len(subtrees))
+	for _, subtree := range subtrees {
+		jobs <- subtree
+	}
+	close(jobs)
+	for cpuIndex := 0; cpuIndex < parallel.DefaultNumGoroutines(); cpuIndex++ {
+		wg.Add(1)
 		go func() {
-			defer sdh.destroy()
-
-			calculateAndSaveHashesDfs(sdh, smtBatchNodeRoot, make([]int, 256), 0)
-			rootNodeHash = (*utils.NodeKey)(smtBatchNodeRoot.hash)
+			defer wg.Done()
+			for subtree := range jobs {
+				keys := make([]*utils.NodeKey, len(subtree.indices))
+				values := make([]*utils.NodeValue8, len(subtree.indices))
+				valuesHashes := make([]*[4]uint64, len(subtree.indices))
+				for j, i := range subtree.indices {
+					keys[j], values[j], valuesHashes[j] = nodeKeys[i], nodeValues[i], nodeValuesHashes[i]
+				}
+				subtree.maxLevel, subtree.err = s.insertBatchIntoSubtree(cfg, keys, values, valuesHashes, &utils.NodeKey{0, 0, 0, 0}, &subtree.root, splitLevel, refuseNodeFetch, subtree.nodeHashesForDelete, progressChan)
+			}
 		}()
+	}
+	wg.Wait()
 
-		if !s.noSaveOnInsert {
-			if err = sdh.startConsumersLoop(s); err != nil {
-				return nil, fmt.Errorf("saving smt hashes dfs: %w", err)
+	maxLevel := 0
+	for _, subtree := range subtrees {
+		if subtree.err != nil {
+			return 0, subtree.err
+		}
+		if maxLevel < subtree.maxLevel {
+			maxLevel = subtree.maxLevel
+		}
+		for _, mapLevel0 := range subtree.nodeHashesForDelete {
+			for _, mapLevel1 := range mapLevel0 {
+				for _, mapLevel2 := range mapLevel1 {
+					for _, nodeHash := range mapLevel2 {
+						setNodeKeyMapValue(nodeHashesForDelete, nodeHash, nodeHash)
+					}
+				}
 			}
 		}
-		sdh.wg.Wait()
+
+		*subtree.parent.getChildInDirection(subtree.path[splitLevel-1]) = subtree.root
+		if subtree.root != nil {
+			subtree.root.parentNode = subtree.parent
+		} else {
+			subtree.parent.updateHashesAfterDelete()
+		}
 	}
-	if err := s.setLastRoot(*rootNodeHash); err != nil {
-		return nil, err
+
+	// a subtree left empty or with a single leaf changes the levels above it
+	for _, subtree := range subtrees {
+		path := make([]int, 256)
+		copy(path, subtree.path)
+		collapseSmtBatchNodesUpwards(smtBatchNodeRoot, subtree.parent, path, splitLevel-1)
 	}
 
-	return &SMTResponse{
-		Mode:          "batch insert",
-		NewRootScalar: rootNodeHash,
-	}, nil
+	if len(shallow) > 0 {
+		keys := make([]*utils.NodeKey, len(shallow))
+		values := make([]*utils.NodeValue8, len(shallow))
+		valuesHashes := make([]*[4]uint64, len(shallow))
+		for j, i := range shallow {
+			keys[j], values[j], valuesHashes[j] = nodeKeys[i], nodeValues[i], nodeValuesHashes[i]
+		}
+		if *smtBatchNodeRoot == nil {
+			rootNodeHash = &utils.NodeKey{0, 0, 0, 0}
+		}
+		shallowMaxLevel, err := s.insertBatchIntoSubtree(cfg, keys, values, valuesHashes, rootNodeHash, smtBatchNodeRoot, 0, s.fetchNodeDataFromDb, nodeHashesForDelete, progressChan)
+		if err != nil {
+			return 0, err
+		}
+		if maxLevel < shallowMaxLevel {
+			maxLevel = shallowMaxLevel
+		}
+	}
+
+	return maxLevel, nil
+}
+
+// refuseNodeFetch stands for the db on the goroutines updating the subtrees, which must find every node loaded
+func refuseNodeFetch(nodeHash *utils.NodeKey, _ *smtBatchNode) (*smtBatchNode, error) {
+	if nodeHash.IsZero() {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("node %v was not loaded before the parallel insert", *nodeHash)
+}
+
+// collapseSmtBatchNodesUpwards removes the branches left empty and moves the single leaves up from the node at the
+// given level, as the deletes do on their way up
+func collapseSmtBatchNodesUpwards(smtBatchNodeRoot **smtBatchNode, node *smtBatchNode, path []int, level int) {
+	pointer := &node
+	for *pointer != nil && !(*pointer).isLeaf() {
+		current := *pointer
+		// a node removed or collapsed on the way up from an earlier subtree
+		if (current.parentNode == nil && current != *smtBatchNodeRoot) || (current.parentNode != nil && *current.parentNode.getChildInDirection(path[level-1]) != current) {
+			return
+		}
+
+		if current.leftNode == nil && current.rightNode == nil && current.nodeLeftHashOrRemainingKey.IsZero() && current.nodeRightHashOrValueHash.IsZero() {
+			if current.parentNode == nil {
+				*smtBatchNodeRoot = nil
+				return
+			}
+			*current.parentNode.getChildInDirection(path[level-1]) = nil
+			current.parentNode.updateHashesAfterDelete()
+			pointer = &current.parentNode
+			level--
+			continue
+		}
+
+		theSingleNodeLeaf, theSingleNodeLeafDirection := current.getTheSingleLeafAndDirectionIfAny()
+		if theSingleNodeLeaf == nil {
+			return
+		}
+		pointer = current.collapseLeafByRemovingTheSingleLeaf(path, level, theSingleNodeLeaf, theSingleNodeLeafDirection)
+		level--
+	}
 }
 
 // returns the new size of the values batch after removing duplicate entries
@@ -424,18 +668,21 @@ func calculateRootNodeHashIfNil(s *SMT, root **utils.NodeKey) error {
 	return nil
 }
 
+// findInsertingPoint walks down the path from the node at the start level, loading the missing nodes with fetch
 func (s *SMT) findInsertingPoint(
 	insertingNodePath []int,
 	insertingPointerNodeHash *utils.NodeKey,
 	insertingPointerToSmtBatchNode **smtBatchNode,
+	startLevel int,
 	fetchDirectSiblings bool,
+	fetch smtBatchNodeFetcher,
 ) (
 	insertingNodePathLevel int,
 	nextInsertingPointerToSmtBatchNode **smtBatchNode,
 	visitedNodeHashes []*utils.NodeKey,
 	err error,
 ) {
-	insertingNodePathLevel = -1
+	insertingNodePathLevel = startLevel - 1
 	visitedNodeHashes = make([]*utils.NodeKey, 0, 256)
 
 	var (
@@ -446,20 +693,20 @@ func (s *SMT) findInsertingPoint(
 	for {
 		if (*insertingPointerToSmtBatchNode) == nil { // update in-memory structure from db
 			if !insertingPointerNodeHash.IsZero() {
-				*insertingPointerToSmtBatchNode, err = s.fetchNodeDataFromDb(insertingPointerNodeHash, insertingPointerToSmtBatchNodeParent)
+				*insertingPointerToSmtBatchNode, err = fetch(insertingPointerNodeHash, insertingPointerToSmtBatchNodeParent)
 				if err != nil {
 					return -2, insertingPointerToSmtBatchNode, visitedNodeHashes, err
 				}
 				visitedNodeHashes = append(visitedNodeHashes, insertingPointerNodeHash)
 			} else {
-				if insertingNodePathLevel != -1 {
+				if insertingNodePathLevel != startLevel-1 {
 					return -2, insertingPointerToSmtBatchNode, visitedNodeHashes, fmt.Errorf("nodekey is zero at non-root level")
 				}
 			}
 		}
 
 		if (*insertingPointerToSmtBatchNode) == nil {
-			if insertingNodePathLevel != -1 {
+			if insertingNodePathLevel != startLevel-1 {
 				return -2, insertingPointerToSmtBatchNode, visitedNodeHashes, fmt.Errorf("working smt pointer is nil at non-root level")
 			}
 			break
@@ -474,14 +721,14 @@ func (s *SMT) findInsertingPoint(
 		if fetchDirectSiblings {
 			// load direct siblings of a non-leaf from the DB
 			if (*insertingPointerToSmtBatchNode).leftNode == nil {
-				(*insertingPointerToSmtBatchNode).leftNode, err = s.fetchNodeDataFromDb((*insertingPointerToSmtBatchNode).nodeLeftHashOrRemainingKey, (*insertingPointerToSmtBatchNode))
+				(*insertingPointerToSmtBatchNode).leftNode, err = fetch((*insertingPointerToSmtBatchNode).nodeLeftHashOrRemainingKey, (*insertingPointerToSmtBatchNode))
 				if err != nil {
 					return -2, insertingPointerToSmtBatchNode, visitedNodeHashes, err
 				}
 				visitedNodeHashes = append(visitedNodeHashes, (*insertingPointerToSmtBatchNode).nodeLeftHashOrRemainingKey)
 			}
 			if (*insertingPointerToSmtBatchNode).rightNode == nil {
-				(*insertingPointerToSmtBatchNode).rightNode, err = s.fetchNodeDataFromDb((*insertingPointerToSmtBatchNode).nodeRightHashOrValueHash, (*insertingPointerToSmtBatchNode))
+				(*insertingPointerToSmtBatchNode).rightNode, err = fetch((*insertingPointerToSmtBatchNode).nodeRightHashOrValueHash, (*insertingPointerToSmtBatchNode))
 				if err != nil {
 					return -2, insertingPointerToSmtBatchNode, visitedNodeHashes, err
 				}
@@ -517,13 +764,63 @@ func updateNodeHashesForDelete(
 	}
 }
 
-// no point to parallelize this function because db consumer is slower than this producer
+// calculateAndSaveSubtreesHashesDfs hashes the subtrees at the split level in parallel, their nodes going to the same
+// consumer, so that only the levels above are left to hash
+func calculateAndSaveSubtreesHashesDfs(
+	sdh *smtDfsHelper,
+	smtBatchNodeRoot *smtBatchNode,
+	splitLevel int,
+) {
+	var subtrees []*smtBatchNode
+	var paths [][]int
+	var collect func(node *smtBatchNode, path []int)
+	collect = func(node *smtBatchNode, path []int) {
+		if len(path) == splitLevel {
+			subtrees, paths = append(subtrees, node), append(paths, path)
+			return
+		}
+		if node.isLeaf() {
+			return
+		}
+		for direction, child := range []*smtBatchNode{node.leftNode, node.rightNode} {
+			if child != nil {
+				collect(child, append(append(make([]int, 0, len(path)+1), path...), direction))
+			}
+		}
+	}
+	collect(smtBatchNodeRoot, nil)
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, len(subtrees))
+	for i := range subtrees {
+		jobs <- i
+	}
+	close(jobs)
+	for cpuIndex := 0; cpuIndex < parallel.DefaultNumGoroutines(); cpuIndex++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				path := make([]int, 256)
+				copy(path, paths[i])
+				calculateAndSaveHashesDfs(sdh, subtrees[i], path, splitLevel)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// the nodes hashed already belong to subtrees hashed in parallel
 func calculateAndSaveHashesDfs(
 	sdh *smtDfsHelper,
 	smtBatchNode *smtBatchNode,
 	path []int,
 	level int,
 ) {
+	if smtBatchNode.hash != nil {
+		return
+	}
+
 	if smtBatchNode.isLeaf() {
 		hashObj, hashValue := utils.HashKeyAndValueByPointers(utils.ConcatArrays4ByPointers(smtBatchNode.nodeLeftHashOrRemainingKey.AsUint64Pointer(), smtBatchNode.nodeRightHashOrValueHash.AsUint64Pointer()), &utils.LeafCapacity)
 		smtBatchNode.hash = hashObj
@@ -2,12 +2,16 @@ package smt_test
 
 import (
 	"context"
+	"math/rand"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/dgravesa/go-parallel/parallel"
+	"github.com/holiman/uint256"
 	libcommon "github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/smt/pkg/db"
 	"github.com/ledgerwatch/erigon/smt/pkg/smt"
 	"github.com/ledgerwatch/erigon/smt/pkg/utils"
 	"gotest.tools/v3/assert"
@@ -112,3 +116,80 @@ func compareAllTreesInsertTimesAndFinalHashes(t *testing.T, smtIncremental, smtB
 
 	assertSmtDbStructure(t, smtBatch, true)
 }
+
+// the batches are large enough to be inserted in parallel, they are inserted with one goroutine into the first tree
+// and with several into the second one
+func TestCompareParallelAndSequentialBatchInsertsOnRandomData(t *testing.T) {
+	defer parallel.SetDefaultNumGoroutines(parallel.DefaultNumGoroutines())
+
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	random := rand.New(rand.NewSource(seed))
+	ctx := context.Background()
+
+	smtSequential := smt.NewSMT(nil, false)
+	smtParallel := smt.NewSMT(nil, false)
+
+	// the first round creates the accounts and contracts, the next ones update and delete some of them
+	var contracts []libcommon.Address
+	storageKeys := make(map[libcommon.Address][]string)
+	for round := 0; round < 4; round++ {
+		accChanges := make(map[libcommon.Address]*accounts.Account)
+		codeChanges := make(map[libcommon.Address]string)
+		storageChanges := make(map[libcommon.Address]map[string]string)
+
+		accountsCount := 50 + random.Intn(100)
+		for i := 0; i < accountsCount; i++ {
+			acc := accounts.NewAccount()
+			acc.Balance = *uint256.NewInt(random.Uint64())
+			acc.Nonce = random.Uint64()
+			accChanges[randomAddress(random)] = &acc
+		}
+		if round == 0 {
+			contractsCount := 30 + random.Intn(20)
+			for i := 0; i < contractsCount; i++ {
+				contract := randomAddress(random)
+				contracts = append(contracts, contract)
+				codeChanges[contract] = "0x6080604052"
+			}
+		}
+		for _, contract := range contracts {
+			storage := make(map[string]string)
+			for _, key := range storageKeys[contract] {
+				switch random.Intn(3) {
+				case 0:
+					storage[key] = "0x0"
+				case 1:
+					storage[key] = randomAddress(random).Hex()
+				}
+			}
+			newKeysCount := 150 + random.Intn(150)
+			for i := 0; i < newKeysCount; i++ {
+				key := randomAddress(random).Hex()
+				storageKeys[contract] = append(storageKeys[contract], key)
+				storage[key] = randomAddress(random).Hex()
+			}
+			storageChanges[contract] = storage
+		}
+
+		parallel.SetDefaultNumGoroutines(1)
+		_, _, err := smtSequential.SetStorage(ctx, "", accChanges, codeChanges, storageChanges)
+		assert.NilError(t, err)
+		parallel.SetDefaultNumGoroutines(4)
+		_, _, err = smtParallel.SetStorage(ctx, "", accChanges, codeChanges, storageChanges)
+		assert.NilError(t, err)
+
+		assert.Equal(t, utils.ConvertBigIntToHex(smtSequential.LastRoot()), utils.ConvertBigIntToHex(smtParallel.LastRoot()), "round %d", round)
+		sequentialDb, parallelDb := smtSequential.Db.(*db.MemDb), smtParallel.Db.(*db.MemDb)
+		assert.DeepEqual(t, sequentialDb.Db, parallelDb.Db)
+		assert.DeepEqual(t, sequentialDb.DbHashKey, parallelDb.DbHashKey)
+	}
+
+	assertSmtDbStructure(t, smtParallel, true)
+}
+
+func randomAddress(random *rand.Rand) libcommon.Address {
+	var addr libcommon.Address
+	random.Read(addr[:])
+	return addr
+}
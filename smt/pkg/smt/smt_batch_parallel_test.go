@@ -0,0 +1,85 @@
+package smt
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/dgravesa/go-parallel/parallel"
+	"github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertBatchInParallel(t *testing.T) {
+	defer func(minKeys int) { parallelInsertBatchMinKeys = minKeys }(parallelInsertBatchMinKeys)
+	// the batches only go parallel with more than one goroutine
+	defer parallel.SetDefaultNumGoroutines(parallel.DefaultNumGoroutines())
+	parallel.SetDefaultNumGoroutines(4)
+
+	cfg := NewInsertBatchConfig(context.Background(), "test", false)
+	random := rand.New(rand.NewSource(1))
+
+	randomValue := func() *utils.NodeValue8 {
+		value := utils.NodeValue8{}
+		for i := range value {
+			value[i] = big.NewInt(0)
+		}
+		value[0].SetUint64(uint64(random.Uint32()))
+		return &value
+	}
+	zero := &utils.NodeValue8{big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+
+	// each round runs the same batches one after the other and in parallel, with sizes from a few keys, leaving most
+	// subtrees with a leaf or none, to a few thousands, with deletes emptying them
+	for _, round := range []struct {
+		size, batches, minKeys int
+	}{
+		{size: 20, batches: 20, minKeys: 1},
+		{size: 200, batches: 10, minKeys: 1},
+		{size: 10_000, batches: 3, minKeys: 1 << 12},
+	} {
+		sequential, inParallel := NewSMT(nil, false), NewSMT(nil, false)
+
+		var keys []*utils.NodeKey
+		for batch := 0; batch < round.batches; batch++ {
+			var batchKeys []*utils.NodeKey
+			var batchValues []*utils.NodeValue8
+			for _, key := range keys {
+				switch random.Intn(4) {
+				case 0:
+					batchKeys, batchValues = append(batchKeys, key), append(batchValues, zero)
+				case 1:
+					batchKeys, batchValues = append(batchKeys, key), append(batchValues, randomValue())
+				}
+			}
+			// the last batch deletes nearly everything
+			if batch == round.batches-1 {
+				batchKeys, batchValues = batchKeys[:0], batchValues[:0]
+				for _, key := range keys[1:] {
+					batchKeys, batchValues = append(batchKeys, key), append(batchValues, zero)
+				}
+			} else {
+				for i := 0; i < round.size; i++ {
+					key := &utils.NodeKey{random.Uint64(), random.Uint64(), random.Uint64(), random.Uint64()}
+					keys = append(keys, key)
+					batchKeys, batchValues = append(batchKeys, key), append(batchValues, randomValue())
+				}
+			}
+
+			parallelInsertBatchMinKeys = math.MaxInt
+			_, err := sequential.InsertBatch(cfg, batchKeys, batchValues, nil, nil)
+			require.NoError(t, err)
+			parallelInsertBatchMinKeys = round.minKeys
+			_, err = inParallel.InsertBatch(cfg, batchKeys, batchValues, nil, nil)
+			require.NoError(t, err)
+
+			require.Equal(t, sequential.LastRoot(), inParallel.LastRoot(), "round of %d, batch %d", round.size, batch)
+			sequentialDb, parallelDb := sequential.Db.(*db.MemDb), inParallel.Db.(*db.MemDb)
+			require.Equal(t, sequentialDb.Db, parallelDb.Db)
+			require.Equal(t, sequentialDb.DbHashKey, parallelDb.DbHashKey)
+		}
+	}
+}
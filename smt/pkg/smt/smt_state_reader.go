@@ -10,6 +10,7 @@ import (
 	libcommon "github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon/core/state"
 	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/crypto"
 	"github.com/ledgerwatch/erigon/smt/pkg/utils"
 	"github.com/ledgerwatch/erigon/zkevm/log"
 )
@@ -28,16 +29,23 @@ func (s *SMT) ReadAccountData(address libcommon.Address) (*accounts.Account, err
 		return nil, err
 	}
 
-	codeHash, err := s.GetAccountCodeHash(address)
+	code, err := s.ReadAccountCode(address, 0, libcommon.Hash{})
 	if err != nil {
 		return nil, err
 	}
 
+	// the account carries the keccak code hash as the plain state does, the smt one is only used to look the code up
+	codeHash := libcommon.Hash{}
+	if len(code) > 0 {
+		codeHash = crypto.Keccak256Hash(code)
+	}
+
 	account := &accounts.Account{
-		Balance:  *balance,
-		Nonce:    nonce.Uint64(),
-		CodeHash: codeHash,
-		Root:     libcommon.Hash{},
+		Initialised: true,
+		Balance:     *balance,
+		Nonce:       nonce.Uint64(),
+		CodeHash:    codeHash,
+		Root:        libcommon.Hash{},
 	}
 
 	return account, nil
@@ -53,8 +61,16 @@ func (s *SMT) ReadAccountStorage(address libcommon.Address, incarnation uint64,
 	return value, nil
 }
 
-// ReadAccountCode reads account code from the SMT
-func (s *SMT) ReadAccountCode(address libcommon.Address, incarnation uint64, codeHash libcommon.Hash) ([]byte, error) {
+// ReadAccountCode reads account code from the SMT, by the smt code hash of the account rather than the given one
+func (s *SMT) ReadAccountCode(address libcommon.Address, incarnation uint64, _ libcommon.Hash) ([]byte, error) {
+	codeHash, err := s.GetAccountCodeHash(address)
+	if err != nil {
+		return []byte{}, err
+	}
+	if codeHash == (libcommon.Hash{}) {
+		return nil, nil
+	}
+
 	code, err := s.Db.GetCode(codeHash.Bytes())
 	if err != nil {
 		return []byte{}, err
@@ -87,9 +103,9 @@ func (s *SMT) ReadAccountCodeSize(address libcommon.Address, _ uint64, _ libcomm
 	return int(sizeInt64), nil
 }
 
-// ReadAccountIncarnation reads account incarnation from the SMT (not implemented for SMT)
+// ReadAccountIncarnation reads account incarnation from the SMT, the zkevm state has no incarnations
 func (s *SMT) ReadAccountIncarnation(_ libcommon.Address) (uint64, error) {
-	return 0, errors.New("ReadAccountIncarnation not implemented for SMT")
+	return 0, nil
 }
 
 // GetAccountBalance returns the balance of an account from the SMT
@@ -165,6 +181,15 @@ func (s *SMT) getValueInBytes(nodeKey utils.NodeKey) ([]byte, error) {
 		}
 
 		if v.IsFinalNode() {
+			// the leaf on the path of a key missing from the tree is the one of another key
+			usedKey := make([]int, len(prefix))
+			for i, b := range prefix {
+				usedKey[i] = int(b)
+			}
+			if *utils.JoinKey(usedKey, *v.Get0to4()) != nodeKey {
+				return false, nil
+			}
+
 			valHash := v.Get4to8()
 			v, err := s.Db.Get(*valHash)
 			if err != nil {
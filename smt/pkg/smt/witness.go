@@ -182,6 +182,10 @@ func AddWitnessToSMT(s *SMT, w *trie.Witness) error {
 
 				storageMap[addr.String()][stKey] = valScaler.String()
 			}
+			// the only leaf of a tree is its root
+			if len(path) == 0 {
+				continue
+			}
 			path = path[:len(path)-1]
 			NodeChildCountMap[intArrayToString(path)] += 1
 
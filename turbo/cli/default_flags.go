@@ -179,6 +179,10 @@ var DefaultFlags = []cli.Flag{
 	&utils.L2DataStreamerUrlFlag,
 	&utils.L2DataStreamerUseTLSFlag,
 	&utils.L2DataStreamerTimeout,
+	&utils.L2DataStreamerTokenFlag,
+	&utils.L2DataStreamerTLSCertFlag,
+	&utils.L2DataStreamerTLSKeyFlag,
+	&utils.L2DataStreamerTLSCAFlag,
 	&utils.L2ShortCircuitToVerifiedBatchFlag,
 	&utils.L1SyncStartBlock,
 	&utils.L1SyncStopBatch,
@@ -186,6 +190,10 @@ var DefaultFlags = []cli.Flag{
 	&utils.L1RpcUrlFlag,
 	&utils.L1CacheEnabledFlag,
 	&utils.L1CachePortFlag,
+	&utils.L1CacheDefaultTTLFlag,
+	&utils.L1CacheMethodTTLsFlag,
+	&utils.L1CacheMaxSizeFlag,
+	&utils.L1CacheFinalityPollIntervalFlag,
 	&utils.AddressSequencerFlag,
 	&utils.AddressAdminFlag,
 	&utils.AddressRollupFlag,
@@ -195,6 +203,9 @@ var DefaultFlags = []cli.Flag{
 	&utils.L1BlockRangeFlag,
 	&utils.L1QueryDelayFlag,
 	&utils.L1HighestBlockTypeFlag,
+	&utils.L1EndpointMaxLagFlag,
+	&utils.L1EndpointExclusionBackoffFlag,
+	&utils.L1CrossCheckFlag,
 	&utils.L1MaticContractAddressFlag,
 	&utils.L1FirstBlockFlag,
 	&utils.L1FinalizedBlockRequirementFlag,
@@ -206,6 +217,7 @@ var DefaultFlags = []cli.Flag{
 	&utils.RebuildTreeAfterFlag,
 	&utils.IncrementTreeAlways,
 	&utils.SmtRegenerateInMemory,
+	&utils.SmtHistoryBlocks,
 	&utils.SequencerBlockSealTime,
 	&utils.SequencerBatchSealTime,
 	&utils.SequencerBatchVerificationTimeout,
@@ -215,14 +227,19 @@ var DefaultFlags = []cli.Flag{
 	&utils.SequencerResequence,
 	&utils.SequencerResequenceStrict,
 	&utils.SequencerResequenceReuseL1InfoIndex,
+	&utils.SequencerDryRun,
+	&utils.SequencerDryRunOutput,
 	&utils.ExecutorUrls,
 	&utils.ExecutorStrictMode,
 	&utils.ExecutorRequestTimeout,
 	&utils.ExecutorEnabled,
+	&utils.StatelessVerification,
 	&utils.DatastreamNewBlockTimeout,
 	&utils.WitnessMemdbSize,
 	&utils.WitnessUnwindLimit,
 	&utils.ExecutorMaxConcurrentRequests,
+	&utils.ExecutorSlowRequestThreshold,
+	&utils.ExecutorUnhealthyBackoff,
 	&utils.Limbo,
 	&utils.AllowFreeTransactions,
 	&utils.RejectLowGasPriceTransactions,
@@ -239,6 +256,15 @@ var DefaultFlags = []cli.Flag{
 	&utils.DataStreamWriteTimeout,
 	&utils.DataStreamInactivityTimeout,
 	&utils.DataStreamInactivityCheckInterval,
+	&utils.DataStreamInternalPort,
+	&utils.DataStreamTLSCert,
+	&utils.DataStreamTLSKey,
+	&utils.DataStreamClientCA,
+	&utils.DataStreamTokensFile,
+	&utils.DataStreamAllowlist,
+	&utils.DataStreamMaxConnectionsPerClient,
+	&utils.DataStreamClientRateLimit,
+	&utils.DataStreamEntryFiltering,
 	&utils.WitnessFullFlag,
 	&utils.SyncLimit,
 	&utils.ExecutorPayloadOutput,
@@ -286,8 +312,16 @@ var DefaultFlags = []cli.Flag{
 	&SyncLoopPruneLimitFlag,
 	&utils.PoolManagerUrl,
 	&utils.TxPoolRejectSmartContractDeployments,
+	&utils.TxPoolBundleLimit,
+	&utils.TxPoolBundleMaxTxs,
+	&utils.TxPoolBundleLifetime,
 	&utils.DisableVirtualCounters,
 	&utils.DAUrl,
+	&utils.DABackend,
+	&utils.DACommitteeUrls,
+	&utils.DAQuorum,
+	&utils.DALocalPath,
+	&utils.DAMaxAttempts,
 	&utils.VirtualCountersSmtReduction,
 	&utils.BadBatches,
 	&utils.InitialBatchCfgFile,
@@ -312,7 +346,16 @@ var DefaultFlags = []cli.Flag{
 	&utils.GpoDefaultL1CoinPriceFlag,
 	&utils.GpoDefaultL2CoinPriceFlag,
 	&utils.GpoGasPriceUsdtFlag,
+	&utils.GpoCoinPriceSourceFlag,
+	&utils.GpoCoinPriceURLFlag,
+	&utils.GpoCoinPriceFileFlag,
+	&utils.GpoCoinPricePollPeriodFlag,
+	&utils.GpoCoinPriceMaxAgeFlag,
 	&utils.GpoCongestionThresholdFlag,
+	&utils.GpoCongestionTargetLoadFlag,
+	&utils.GpoCongestionBlocksFlag,
+	&utils.GpoCongestionBlockGasFlag,
+	&utils.GpoCongestionPendingTxsFlag,
 	&utils.ApolloEnableFlag,
 	&utils.ApolloIPAddr,
 	&utils.ApolloAppId,
@@ -331,6 +374,9 @@ var DefaultFlags = []cli.Flag{
 	&utils.TxPoolFreeGasLimit,
 	&utils.TxPoolEnableFreeGasList,
 	&utils.TxPoolFreeGasList,
+	&utils.TxPoolPriorityLanes,
+	&utils.TxPoolSenderQuotas,
+	&utils.TxPoolContractQuotas,
 	&utils.HTTPApiKeysFlag,
 	&utils.MethodRateLimitFlag,
 
@@ -10,9 +10,12 @@ import (
 
 	"strconv"
 
+	"github.com/c2h5oh/datasize"
 	libcommon "github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon/cmd/utils"
 	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/zk/da"
+	"github.com/ledgerwatch/erigon/zk/l1_cache"
 	"github.com/ledgerwatch/erigon/zk/sequencer"
 	utils2 "github.com/ledgerwatch/erigon/zk/utils"
 	"github.com/urfave/cli/v2"
@@ -148,12 +151,47 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		witnessInclusion = append(witnessInclusion, libcommon.HexToAddress(s))
 	}
 
+	l1CacheMethodTTLs, err := l1_cache.ParseMethodTTLs(ctx.String(utils.L1CacheMethodTTLsFlag.Name))
+	if err != nil {
+		panic(fmt.Sprintf("could not parse l1 cache method ttls: %v", err))
+	}
+	var l1CacheMaxSize datasize.ByteSize
+	if err := l1CacheMaxSize.UnmarshalText([]byte(ctx.String(utils.L1CacheMaxSizeFlag.Name))); err != nil {
+		panic(fmt.Sprintf("could not parse l1 cache max size %s: %v", ctx.String(utils.L1CacheMaxSizeFlag.Name), err))
+	}
+
+	var daCommitteeUrls []string
+	for _, s := range strings.Split(strings.ReplaceAll(ctx.String(utils.DACommitteeUrls.Name), " ", ""), ",") {
+		if s != "" {
+			daCommitteeUrls = append(daCommitteeUrls, s)
+		}
+	}
+
+	if (ctx.String(utils.L2DataStreamerTLSCertFlag.Name) == "") != (ctx.String(utils.L2DataStreamerTLSKeyFlag.Name) == "") {
+		panic(fmt.Sprintf("%s and %s must be set together", utils.L2DataStreamerTLSCertFlag.Name, utils.L2DataStreamerTLSKeyFlag.Name))
+	}
+
+	var dataStreamAllowlist []string
+	for _, s := range strings.Split(strings.ReplaceAll(ctx.String(utils.DataStreamAllowlist.Name), " ", ""), ",") {
+		if s != "" {
+			dataStreamAllowlist = append(dataStreamAllowlist, s)
+		}
+	}
+	var dataStreamClientRateLimit datasize.ByteSize
+	if err := dataStreamClientRateLimit.UnmarshalText([]byte(ctx.String(utils.DataStreamClientRateLimit.Name))); err != nil {
+		panic(fmt.Sprintf("could not parse data stream client rate limit %s: %v", ctx.String(utils.DataStreamClientRateLimit.Name), err))
+	}
+
 	cfg.Zk = &ethconfig.Zk{
 		L2ChainId:                              ctx.Uint64(utils.L2ChainIdFlag.Name),
 		L2RpcUrl:                               ctx.String(utils.L2RpcUrlFlag.Name),
 		L2DataStreamerUrl:                      ctx.String(utils.L2DataStreamerUrlFlag.Name),
 		L2DataStreamerUseTLS:                   ctx.Bool(utils.L2DataStreamerUseTLSFlag.Name),
 		L2DataStreamerTimeout:                  l2DataStreamTimeout,
+		L2DataStreamerToken:                    ctx.String(utils.L2DataStreamerTokenFlag.Name),
+		L2DataStreamerTLSCertFile:              ctx.String(utils.L2DataStreamerTLSCertFlag.Name),
+		L2DataStreamerTLSKeyFile:               ctx.String(utils.L2DataStreamerTLSKeyFlag.Name),
+		L2DataStreamerTLSCAFile:                ctx.String(utils.L2DataStreamerTLSCAFlag.Name),
 		L2ShortCircuitToVerifiedBatch:          l2ShortCircuitToVerifiedBatchVal,
 		L1SyncStartBlock:                       ctx.Uint64(utils.L1SyncStartBlock.Name),
 		L1SyncStopBatch:                        ctx.Uint64(utils.L1SyncStopBatch.Name),
@@ -161,6 +199,10 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		L1RpcUrl:                               ctx.String(utils.L1RpcUrlFlag.Name),
 		L1CacheEnabled:                         ctx.Bool(utils.L1CacheEnabledFlag.Name),
 		L1CachePort:                            ctx.Uint(utils.L1CachePortFlag.Name),
+		L1CacheDefaultTTL:                      ctx.Duration(utils.L1CacheDefaultTTLFlag.Name),
+		L1CacheMethodTTLs:                      l1CacheMethodTTLs,
+		L1CacheMaxSize:                         l1CacheMaxSize,
+		L1CacheFinalityPollInterval:            ctx.Duration(utils.L1CacheFinalityPollIntervalFlag.Name),
 		AddressSequencer:                       libcommon.HexToAddress(ctx.String(utils.AddressSequencerFlag.Name)),
 		AddressAdmin:                           libcommon.HexToAddress(ctx.String(utils.AddressAdminFlag.Name)),
 		AddressRollup:                          libcommon.HexToAddress(ctx.String(utils.AddressRollupFlag.Name)),
@@ -170,6 +212,9 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		L1BlockRange:                           ctx.Uint64(utils.L1BlockRangeFlag.Name),
 		L1QueryDelay:                           ctx.Uint64(utils.L1QueryDelayFlag.Name),
 		L1HighestBlockType:                     ctx.String(utils.L1HighestBlockTypeFlag.Name),
+		L1EndpointMaxLag:                       ctx.Uint64(utils.L1EndpointMaxLagFlag.Name),
+		L1EndpointExclusionBackoff:             ctx.Duration(utils.L1EndpointExclusionBackoffFlag.Name),
+		L1CrossCheck:                           ctx.Bool(utils.L1CrossCheckFlag.Name),
 		L1MaticContractAddress:                 libcommon.HexToAddress(ctx.String(utils.L1MaticContractAddressFlag.Name)),
 		L1FirstBlock:                           ctx.Uint64(utils.L1FirstBlockFlag.Name),
 		RpcRateLimits:                          ctx.Int(utils.RpcRateLimitsFlag.Name),
@@ -181,6 +226,7 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		RebuildTreeAfter:                       ctx.Uint64(utils.RebuildTreeAfterFlag.Name),
 		IncrementTreeAlways:                    ctx.Bool(utils.IncrementTreeAlways.Name),
 		SmtRegenerateInMemory:                  ctx.Bool(utils.SmtRegenerateInMemory.Name),
+		SmtHistoryBlocks:                       ctx.Uint64(utils.SmtHistoryBlocks.Name),
 		SequencerBlockSealTime:                 sequencerBlockSealTime,
 		SequencerBatchSealTime:                 sequencerBatchSealTime,
 		SequencerBatchVerificationTimeout:      sequencerBatchVerificationTimeout,
@@ -190,14 +236,19 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		SequencerResequence:                    ctx.Bool(utils.SequencerResequence.Name),
 		SequencerResequenceStrict:              ctx.Bool(utils.SequencerResequenceStrict.Name),
 		SequencerResequenceReuseL1InfoIndex:    ctx.Bool(utils.SequencerResequenceReuseL1InfoIndex.Name),
+		SequencerDryRun:                        ctx.Bool(utils.SequencerDryRun.Name),
+		SequencerDryRunOutput:                  ctx.String(utils.SequencerDryRunOutput.Name),
 		ExecutorUrls:                           strings.Split(strings.ReplaceAll(ctx.String(utils.ExecutorUrls.Name), " ", ""), ","),
 		ExecutorStrictMode:                     ctx.Bool(utils.ExecutorStrictMode.Name),
 		ExecutorRequestTimeout:                 ctx.Duration(utils.ExecutorRequestTimeout.Name),
 		ExecutorEnabled:                        ctx.Bool(utils.ExecutorEnabled.Name),
+		StatelessVerification:                  ctx.Bool(utils.StatelessVerification.Name),
 		DatastreamNewBlockTimeout:              ctx.Duration(utils.DatastreamNewBlockTimeout.Name),
 		WitnessMemdbSize:                       *witnessMemSize,
 		WitnessUnwindLimit:                     witnessUnwindLimit,
 		ExecutorMaxConcurrentRequests:          ctx.Int(utils.ExecutorMaxConcurrentRequests.Name),
+		ExecutorSlowRequestThreshold:           ctx.Duration(utils.ExecutorSlowRequestThreshold.Name),
+		ExecutorUnhealthyBackoff:               ctx.Duration(utils.ExecutorUnhealthyBackoff.Name),
 		Limbo:                                  ctx.Bool(utils.Limbo.Name),
 		AllowFreeTransactions:                  ctx.Bool(utils.AllowFreeTransactions.Name),
 		RejectLowGasPriceTransactions:          ctx.Bool(utils.RejectLowGasPriceTransactions.Name),
@@ -218,13 +269,30 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		DebugStepAfter:                         ctx.Uint64(utils.DebugStepAfter.Name),
 		PoolManagerUrl:                         ctx.String(utils.PoolManagerUrl.Name),
 		TxPoolRejectSmartContractDeployments:   ctx.Bool(utils.TxPoolRejectSmartContractDeployments.Name),
+		TxPoolBundleLimit:                      ctx.Int(utils.TxPoolBundleLimit.Name),
+		TxPoolBundleMaxTxs:                     ctx.Int(utils.TxPoolBundleMaxTxs.Name),
+		TxPoolBundleLifetime:                   ctx.Duration(utils.TxPoolBundleLifetime.Name),
 		DisableVirtualCounters:                 ctx.Bool(utils.DisableVirtualCounters.Name),
 		ExecutorPayloadOutput:                  ctx.String(utils.ExecutorPayloadOutput.Name),
 		DAUrl:                                  ctx.String(utils.DAUrl.Name),
+		DABackend:                              ctx.String(utils.DABackend.Name),
+		DACommitteeUrls:                        daCommitteeUrls,
+		DAQuorum:                               ctx.Int(utils.DAQuorum.Name),
+		DALocalPath:                            ctx.String(utils.DALocalPath.Name),
+		DAMaxAttempts:                          ctx.Int(utils.DAMaxAttempts.Name),
 		DataStreamHost:                         ctx.String(utils.DataStreamHost.Name),
 		DataStreamPort:                         ctx.Uint(utils.DataStreamPort.Name),
 		DataStreamWriteTimeout:                 ctx.Duration(utils.DataStreamWriteTimeout.Name),
 		DataStreamInactivityTimeout:            ctx.Duration(utils.DataStreamInactivityTimeout.Name),
+		DataStreamInternalPort:                 ctx.Uint(utils.DataStreamInternalPort.Name),
+		DataStreamTLSCertFile:                  ctx.String(utils.DataStreamTLSCert.Name),
+		DataStreamTLSKeyFile:                   ctx.String(utils.DataStreamTLSKey.Name),
+		DataStreamClientCAFile:                 ctx.String(utils.DataStreamClientCA.Name),
+		DataStreamTokensFile:                   ctx.String(utils.DataStreamTokensFile.Name),
+		DataStreamAllowlist:                    dataStreamAllowlist,
+		DataStreamMaxConnectionsPerClient:      ctx.Int(utils.DataStreamMaxConnectionsPerClient.Name),
+		DataStreamClientRateLimit:              dataStreamClientRateLimit,
+		DataStreamEntryFiltering:               ctx.Bool(utils.DataStreamEntryFiltering.Name),
 		VirtualCountersSmtReduction:            ctx.Float64(utils.VirtualCountersSmtReduction.Name),
 		BadBatches:                             badBatches,
 		InitialBatchCfgFile:                    ctx.String(utils.InitialBatchCfgFile.Name),
@@ -273,6 +341,10 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 
 	checkFlag(utils.AddressZkevmFlag.Name, cfg.AddressZkevm)
 
+	if _, err := da.NewBackend(cfg.DAConfig()); err != nil {
+		panic(fmt.Sprintf("invalid data availability config: %v", err))
+	}
+
 	checkFlag(utils.L1ChainIdFlag.Name, cfg.L1ChainId)
 	checkFlag(utils.L1RpcUrlFlag.Name, cfg.L1RpcUrl)
 	checkFlag(utils.L1MaticContractAddressFlag.Name, cfg.L1MaticContractAddress.Hex())
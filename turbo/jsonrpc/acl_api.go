@@ -0,0 +1,218 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+// maxACLPolicyTransactionsPageSize caps the number of policy transactions returned by a single acl_policyTransactions call
+const maxACLPolicyTransactionsPageSize = 1000
+
+// ACLAPI the interface for the acl_ RPC commands. It changes the ACLs of the txpool on a running node, so it is only
+// served on the JWT authenticated endpoint.
+type ACLAPI interface {
+	AddPolicy(ctx context.Context, aclType string, addr libcommon.Address, entry ACLPolicyEntry) error
+	RemovePolicy(ctx context.Context, aclType string, addr libcommon.Address, policy string) error
+	ListPolicies(ctx context.Context, aclType string) (map[libcommon.Address][]ACLPolicyEntry, error)
+	ImportPolicies(ctx context.Context, aclType string, policies map[libcommon.Address][]ACLPolicyEntry) error
+	GetMode(ctx context.Context) (string, error)
+	SetMode(ctx context.Context, mode string) error
+	PolicyTransactions(ctx context.Context, offset, count uint64) (*ACLPolicyTransactionsPage, error)
+}
+
+// ACLPolicyEntry is the JSON representation of a policy assigned to an address
+type ACLPolicyEntry struct {
+	Policy    string              `json:"policy"`
+	NotBefore *hexutil.Uint64     `json:"notBefore,omitempty"` // unix seconds
+	NotAfter  *hexutil.Uint64     `json:"notAfter,omitempty"`  // unix seconds
+	Contracts []libcommon.Address `json:"contracts,omitempty"`
+	MaxValue  *hexutil.Big        `json:"maxValue,omitempty"`
+}
+
+// ACLPolicyTransaction is the JSON representation of a change made to the ACLs
+type ACLPolicyTransaction struct {
+	ACLType   string             `json:"aclType"`
+	Operation string             `json:"operation"`
+	Time      hexutil.Uint64     `json:"time"` // unix seconds
+	Address   *libcommon.Address `json:"address,omitempty"`
+	Entry     *ACLPolicyEntry    `json:"entry,omitempty"`
+}
+
+// ACLPolicyTransactionsPage is a page of policy transactions, newest first
+type ACLPolicyTransactionsPage struct {
+	Total        hexutil.Uint64         `json:"total"`
+	Transactions []ACLPolicyTransaction `json:"transactions"`
+}
+
+// ACLAPIImpl data structure to store things needed for acl_ commands
+type ACLAPIImpl struct {
+	aclDB kv.RwDB
+}
+
+// NewACLAPI returns ACLAPIImpl instance
+func NewACLAPI(aclDB kv.RwDB) *ACLAPIImpl {
+	return &ACLAPIImpl{
+		aclDB: aclDB,
+	}
+}
+
+func (api *ACLAPIImpl) AddPolicy(ctx context.Context, aclType string, addr libcommon.Address, entry ACLPolicyEntry) error {
+	e, err := entry.toPolicyEntry()
+	if err != nil {
+		return err
+	}
+	return txpool.AddPolicyEntry(ctx, api.aclDB, aclType, addr, e)
+}
+
+func (api *ACLAPIImpl) RemovePolicy(ctx context.Context, aclType string, addr libcommon.Address, policy string) error {
+	p, err := txpool.ResolvePolicy(policy)
+	if err != nil {
+		return err
+	}
+	return txpool.RemovePolicy(ctx, api.aclDB, aclType, addr, p)
+}
+
+func (api *ACLAPIImpl) ListPolicies(ctx context.Context, aclType string) (map[libcommon.Address][]ACLPolicyEntry, error) {
+	entries, err := txpool.ListPolicyEntries(ctx, api.aclDB, aclType)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[libcommon.Address][]ACLPolicyEntry, len(entries))
+	for addr, addrEntries := range entries {
+		result[addr] = make([]ACLPolicyEntry, 0, len(addrEntries))
+		for _, e := range addrEntries {
+			result[addr] = append(result[addr], newACLPolicyEntry(e))
+		}
+	}
+	return result, nil
+}
+
+// ImportPolicies replaces the policies of every given address in one go. An address with an empty list of
+// policies is removed from the ACL.
+func (api *ACLAPIImpl) ImportPolicies(ctx context.Context, aclType string, policies map[libcommon.Address][]ACLPolicyEntry) error {
+	addrs := make([]libcommon.Address, 0, len(policies))
+	entries := make([][]txpool.PolicyEntry, 0, len(policies))
+	for addr, addrPolicies := range policies {
+		addrEntries := make([]txpool.PolicyEntry, 0, len(addrPolicies))
+		for _, p := range addrPolicies {
+			e, err := p.toPolicyEntry()
+			if err != nil {
+				return fmt.Errorf("address %s: %w", addr.Hex(), err)
+			}
+			addrEntries = append(addrEntries, e)
+		}
+		addrs = append(addrs, addr)
+		entries = append(entries, addrEntries)
+	}
+	return txpool.UpdatePolicyEntries(ctx, api.aclDB, aclType, addrs, entries)
+}
+
+func (api *ACLAPIImpl) GetMode(ctx context.Context) (string, error) {
+	mode, err := txpool.GetMode(ctx, api.aclDB)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return txpool.DisabledMode, nil
+	}
+	return string(mode), nil
+}
+
+func (api *ACLAPIImpl) SetMode(ctx context.Context, mode string) error {
+	return txpool.SetMode(ctx, api.aclDB, mode)
+}
+
+// PolicyTransactions returns up to count changes made to the ACLs, newest first, skipping the first offset ones
+func (api *ACLAPIImpl) PolicyTransactions(ctx context.Context, offset, count uint64) (*ACLPolicyTransactionsPage, error) {
+	if count > maxACLPolicyTransactionsPageSize {
+		return nil, fmt.Errorf("count %d exceeds the maximum page size of %d", count, maxACLPolicyTransactionsPageSize)
+	}
+
+	pts, total, err := txpool.PolicyTransactionsPage(ctx, api.aclDB, int(offset), int(count))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ACLPolicyTransactionsPage{
+		Total:        hexutil.Uint64(total),
+		Transactions: make([]ACLPolicyTransaction, 0, len(pts)),
+	}
+	for _, pt := range pts {
+		t := ACLPolicyTransaction{
+			ACLType:   pt.ACLType().String(),
+			Operation: pt.Operation().String(),
+			Time:      hexutil.Uint64(pt.Time().Unix()),
+		}
+		if pt.Operation() != txpool.ModeChange {
+			addr := pt.Address()
+			t.Address = &addr
+		}
+		// updates record the address only, the new policies are not part of the transaction
+		if pt.Operation() == txpool.Add || pt.Operation() == txpool.Remove {
+			entry := newACLPolicyEntry(pt.Entry())
+			t.Entry = &entry
+		}
+		page.Transactions = append(page.Transactions, t)
+	}
+	return page, nil
+}
+
+func newACLPolicyEntry(e txpool.PolicyEntry) ACLPolicyEntry {
+	entry := ACLPolicyEntry{
+		Policy:    e.Policy.String(),
+		Contracts: e.Contracts,
+	}
+	if !e.NotBefore.IsZero() {
+		notBefore := hexutil.Uint64(e.NotBefore.Unix())
+		entry.NotBefore = &notBefore
+	}
+	if !e.NotAfter.IsZero() {
+		notAfter := hexutil.Uint64(e.NotAfter.Unix())
+		entry.NotAfter = &notAfter
+	}
+	if e.MaxValue != nil {
+		entry.MaxValue = (*hexutil.Big)(e.MaxValue.ToBig())
+	}
+	return entry
+}
+
+func (e ACLPolicyEntry) toPolicyEntry() (txpool.PolicyEntry, error) {
+	policy, err := txpool.ResolvePolicy(e.Policy)
+	if err != nil {
+		return txpool.PolicyEntry{}, err
+	}
+
+	entry := txpool.PolicyEntry{
+		Policy:    policy,
+		Contracts: e.Contracts,
+	}
+	if e.NotBefore != nil {
+		entry.NotBefore = time.Unix(int64(*e.NotBefore), 0)
+	}
+	if e.NotAfter != nil {
+		entry.NotAfter = time.Unix(int64(*e.NotAfter), 0)
+	}
+	if e.MaxValue != nil {
+		maxValue, overflow := uint256.FromBig((*big.Int)(e.MaxValue))
+		if overflow || (*big.Int)(e.MaxValue).Sign() < 0 {
+			return txpool.PolicyEntry{}, errors.New("maxValue out of range")
+		}
+		entry.MaxValue = maxValue
+	}
+
+	if err := entry.Validate(); err != nil {
+		return txpool.PolicyEntry{}, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,149 @@
+package jsonrpc
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+func newTestACLAPI(t *testing.T) *ACLAPIImpl {
+	aclDB, err := txpool.OpenACLDB(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(aclDB.Close)
+	return NewACLAPI(aclDB)
+}
+
+func TestACLAPI_Policies(t *testing.T) {
+	api := newTestACLAPI(t)
+	ctx := context.Background()
+
+	sender := libcommon.HexToAddress("0x1234")
+	contract := libcommon.HexToAddress("0xc0ffee")
+	notAfter := hexutil.Uint64(1800000000)
+
+	require.NoError(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "sendTx"}))
+	require.NoError(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "callOnly", NotAfter: &notAfter, Contracts: []libcommon.Address{contract}}))
+	require.NoError(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "maxValue", MaxValue: (*hexutil.Big)(big.NewInt(100))}))
+
+	require.Error(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "unknown"}))
+	require.Error(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "callOnly"}))
+	require.Error(t, api.AddPolicy(ctx, "allowlist", sender, ACLPolicyEntry{Policy: "maxValue", MaxValue: (*hexutil.Big)(big.NewInt(-1))}))
+	require.Error(t, api.AddPolicy(ctx, "greylist", sender, ACLPolicyEntry{Policy: "sendTx"}))
+
+	policies, err := api.ListPolicies(ctx, "allowlist")
+	require.NoError(t, err)
+	require.Equal(t, map[libcommon.Address][]ACLPolicyEntry{
+		sender: {
+			{Policy: "sendTx"},
+			{Policy: "callOnly", NotAfter: &notAfter, Contracts: []libcommon.Address{contract}},
+			{Policy: "maxValue", MaxValue: (*hexutil.Big)(big.NewInt(100))},
+		},
+	}, policies)
+
+	require.NoError(t, api.RemovePolicy(ctx, "allowlist", sender, "callOnly"))
+	policies, err = api.ListPolicies(ctx, "allowlist")
+	require.NoError(t, err)
+	require.Len(t, policies[sender], 2)
+
+	policies, err = api.ListPolicies(ctx, "blocklist")
+	require.NoError(t, err)
+	require.Empty(t, policies)
+}
+
+func TestACLAPI_ImportPolicies(t *testing.T) {
+	api := newTestACLAPI(t)
+	ctx := context.Background()
+
+	kept := libcommon.HexToAddress("0x01")
+	replaced := libcommon.HexToAddress("0x02")
+	removed := libcommon.HexToAddress("0x03")
+
+	require.NoError(t, api.AddPolicy(ctx, "blocklist", kept, ACLPolicyEntry{Policy: "deploy"}))
+	require.NoError(t, api.AddPolicy(ctx, "blocklist", replaced, ACLPolicyEntry{Policy: "deploy"}))
+	require.NoError(t, api.AddPolicy(ctx, "blocklist", removed, ACLPolicyEntry{Policy: "deploy"}))
+
+	require.NoError(t, api.ImportPolicies(ctx, "blocklist", map[libcommon.Address][]ACLPolicyEntry{
+		replaced: {{Policy: "sendTx"}},
+		removed:  {},
+	}))
+
+	policies, err := api.ListPolicies(ctx, "blocklist")
+	require.NoError(t, err)
+	require.Equal(t, map[libcommon.Address][]ACLPolicyEntry{
+		kept:     {{Policy: "deploy"}},
+		replaced: {{Policy: "sendTx"}},
+	}, policies)
+
+	// an invalid entry fails the whole import
+	require.Error(t, api.ImportPolicies(ctx, "blocklist", map[libcommon.Address][]ACLPolicyEntry{
+		kept:     {},
+		replaced: {{Policy: "callOnly"}},
+	}))
+	policies, err = api.ListPolicies(ctx, "blocklist")
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+}
+
+func TestACLAPI_Mode(t *testing.T) {
+	api := newTestACLAPI(t)
+	ctx := context.Background()
+
+	mode, err := api.GetMode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, txpool.DisabledMode, mode)
+
+	require.NoError(t, api.SetMode(ctx, "allowlist"))
+	mode, err = api.GetMode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, txpool.AllowlistMode, mode)
+
+	require.Error(t, api.SetMode(ctx, "everything"))
+}
+
+func TestACLAPI_PolicyTransactions(t *testing.T) {
+	api := newTestACLAPI(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, api.AddPolicy(ctx, "allowlist", libcommon.BigToAddress(big.NewInt(int64(i))), ACLPolicyEntry{Policy: "sendTx"}))
+	}
+	require.NoError(t, api.SetMode(ctx, "allowlist"))
+
+	page, err := api.PolicyTransactions(ctx, 0, 4)
+	require.NoError(t, err)
+	require.Equal(t, hexutil.Uint64(6), page.Total)
+	require.Len(t, page.Transactions, 4)
+
+	rest, err := api.PolicyTransactions(ctx, 4, 4)
+	require.NoError(t, err)
+	require.Len(t, rest.Transactions, 2)
+
+	seen := make(map[libcommon.Address]bool)
+	modeChanges := 0
+	for _, pt := range append(page.Transactions, rest.Transactions...) {
+		if pt.Operation == txpool.ModeChange.String() {
+			modeChanges++
+			require.Nil(t, pt.Address)
+			continue
+		}
+		require.Equal(t, "add", pt.Operation)
+		require.Equal(t, "allowlist", pt.ACLType)
+		require.Equal(t, &ACLPolicyEntry{Policy: "sendTx"}, pt.Entry)
+		seen[*pt.Address] = true
+	}
+	require.Equal(t, 1, modeChanges)
+	require.Len(t, seen, 5)
+
+	empty, err := api.PolicyTransactions(ctx, 10, 4)
+	require.NoError(t, err)
+	require.Empty(t, empty.Transactions)
+
+	_, err = api.PolicyTransactions(ctx, 0, maxACLPolicyTransactionsPageSize+1)
+	require.Error(t, err)
+}
@@ -174,6 +174,16 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, r
 				Service:   ZkEvmAPI(zkEvmImpl),
 				Version:   "1.0",
 			})
+		case "acl":
+			// the ACLs live in the txpool, so they can only be managed on the node running it
+			if rawPool != nil {
+				list = append(list, rpc.API{
+					Namespace: "acl",
+					Public:    false,
+					Service:   ACLAPI(NewACLAPI(rawPool.ACLDB())),
+					Version:   "1.0",
+				})
+			}
 		case "clique":
 			list = append(list, clique.NewCliqueAPI(db, engine, blockReader))
 		case "overlay":
@@ -188,6 +198,17 @@ func APIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, r
 	return list, ethImpl.GetGPCache()
 }
 
+// ACLAPIList returns the acl api of the list, if enabled. It is left out of the regular endpoint and served on the
+// JWT authenticated one instead.
+func ACLAPIList(list []rpc.API) (aclList []rpc.API) {
+	for _, api := range list {
+		if api.Namespace == "acl" {
+			aclList = append(aclList, api)
+		}
+	}
+	return aclList
+}
+
 // func AuthAPIList(db kv.RoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient,
 // 	filters *rpchelper.Filters, stateCache kvcache.Cache, blockReader services.FullBlockReader,
 // 	agg *libstate.AggregatorV3,
@@ -381,6 +381,7 @@ type APIImpl struct {
 	BadTxAllowance                uint64
 	SenderLocks                   *SenderLock
 	LogsMaxRange                  uint64
+	SequencerDryRun               bool
 
 	// For X Layer
 	L2GasPricer   gasprice.L2GasPricer
@@ -421,6 +422,7 @@ func NewEthAPI(base *BaseAPI, db kv.RoDB, eth rpchelper.ApiBackend, txPool txpoo
 		BadTxAllowance:                ethCfg.BadTxAllowance,
 		SenderLocks:                   NewSenderLock(),
 		LogsMaxRange:                  LogsMaxRange,
+		SequencerDryRun:               ethCfg.SequencerDryRun,
 		// For X Layer
 		L2GasPricer:   gasprice.NewL2GasPriceSuggester(context.Background(), ethCfg.GPO),
 		EnableInnerTx: ethCfg.XLayer.EnableInnerTx,
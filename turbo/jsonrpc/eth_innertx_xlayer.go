@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
+	"strings"
 
 	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/turbo/rpchelper"
+	"github.com/ledgerwatch/erigon/turbo/transactions"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/metrics"
 	zktypes "github.com/ledgerwatch/erigon/zk/types"
@@ -50,12 +56,9 @@ func (api *APIImpl) GetInternalTransactions(ctx context.Context, txnHash libcomm
 		}
 	}
 
-	hermezReader := hermez_db.NewHermezDbReader(tx)
-	blockInnerTxs := hermezReader.GetInnerTxs(blockNum)
-	if len(blockInnerTxs) < len(block.Transactions()) {
-		return nil, fmt.Errorf("block inner tx count %d is less than block tx count %d", len(blockInnerTxs), len(block.Transactions()))
-	} else if len(blockInnerTxs) > len(block.Transactions()) {
-		log.Warn(fmt.Sprintf("block inner tx count %d is greater than block tx count %d", len(blockInnerTxs), len(block.Transactions())))
+	blockInnerTxs, err := api.blockInnerTxs(ctx, tx, block)
+	if err != nil {
+		return nil, err
 	}
 
 	return blockInnerTxs[txnIndex], nil
@@ -102,12 +105,9 @@ func (api *APIImpl) GetBlockInternalTransactions(ctx context.Context, number rpc
 		return nil, fmt.Errorf("can't get the matching block")
 	}
 
-	hermezReader := hermez_db.NewHermezDbReader(tx)
-	blockInnerTxs := hermezReader.GetInnerTxs(n)
-	if len(blockInnerTxs) < len(block.Transactions()) {
-		return nil, fmt.Errorf("block inner tx count %d is less than block tx count %d", len(blockInnerTxs), len(block.Transactions()))
-	} else if len(blockInnerTxs) > len(block.Transactions()) {
-		log.Warn(fmt.Sprintf("block inner tx count %d is greater than block tx count %d", len(blockInnerTxs), len(block.Transactions())))
+	blockInnerTxs, err := api.blockInnerTxs(ctx, tx, block)
+	if err != nil {
+		return nil, err
 	}
 	metrics.RpcInnerTxExecuted.Add(float64(len(blockInnerTxs)))
 
@@ -118,3 +118,219 @@ func (api *APIImpl) GetBlockInternalTransactions(ctx context.Context, number rpc
 
 	return res, nil
 }
+
+// blockInnerTxs returns the inner txs stored for the block, re-executing it when they have not been stored, e.g. for
+// the blocks executed before inner txs were enabled
+func (api *APIImpl) blockInnerTxs(ctx context.Context, tx kv.Tx, block *types.Block) ([][]*zktypes.InnerTx, error) {
+	blockInnerTxs := hermez_db.NewHermezDbReader(tx).GetInnerTxs(block.NumberU64())
+	if len(blockInnerTxs) >= len(block.Transactions()) {
+		if len(blockInnerTxs) > len(block.Transactions()) {
+			log.Warn(fmt.Sprintf("block inner tx count %d is greater than block tx count %d", len(blockInnerTxs), len(block.Transactions())))
+		}
+		return blockInnerTxs, nil
+	}
+	if len(blockInnerTxs) > 0 {
+		log.Warn(fmt.Sprintf("block inner tx count %d is less than block tx count %d, re-executing the block", len(blockInnerTxs), len(block.Transactions())))
+	}
+
+	chainConfig, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	blockInnerTxs, err = transactions.TraceBlockInnerTxs(ctx, api.engine(), block, chainConfig, api._blockReader, tx, api.historyV3(tx))
+	if err != nil {
+		return nil, fmt.Errorf("trace the inner txs of block %d: %w", block.NumberU64(), err)
+	}
+	metrics.RpcInnerTxTracedBlocks.Inc()
+
+	return blockInnerTxs, nil
+}
+
+const (
+	defaultInternalTransactionsLimit = 100
+	maxInternalTransactionsLimit     = 1000
+	// index entries looked at by a single query, bounding the cost of the queries of the busiest addresses
+	maxInternalTransactionsScan = 100_000
+)
+
+// InternalTransactionsFilter selects the inner txs returned by GetInternalTransactionsByAddress, all the fields are
+// optional
+type InternalTransactionsFilter struct {
+	FromBlock    *rpc.BlockNumber `json:"fromBlock"`    // defaults to the genesis
+	ToBlock      *rpc.BlockNumber `json:"toBlock"`      // defaults to the latest block
+	Direction    string           `json:"direction"`    // "from" or "to" the address, both if empty
+	CallTypes    []string         `json:"callTypes"`    // e.g. "call", "create", "delegatecall"
+	MinValue     *hexutil.Big     `json:"minValue"`     // minimum value transferred in wei
+	InternalOnly bool             `json:"internalOnly"` // skip the top level calls of the transactions
+	Limit        *hexutil.Uint64  `json:"limit"`
+}
+
+// AddressInternalTransaction is an inner tx along with the transaction it belongs to
+type AddressInternalTransaction struct {
+	BlockNumber      hexutil.Uint64 `json:"block_number"`
+	TransactionHash  libcommon.Hash `json:"transaction_hash"`
+	TransactionIndex hexutil.Uint64 `json:"transaction_index"`
+	*zktypes.InnerTx
+}
+
+// innerTxMatcher checks the inner txs against an InternalTransactionsFilter
+type innerTxMatcher struct {
+	from, to     bool
+	callTypes    map[string]struct{}
+	minValue     *big.Int
+	internalOnly bool
+}
+
+func newInnerTxMatcher(filter InternalTransactionsFilter) (*innerTxMatcher, error) {
+	m := &innerTxMatcher{internalOnly: filter.InternalOnly}
+
+	switch filter.Direction {
+	case "":
+		m.from, m.to = true, true
+	case "from":
+		m.from = true
+	case "to":
+		m.to = true
+	default:
+		return nil, fmt.Errorf("invalid direction %q, expected \"from\" or \"to\"", filter.Direction)
+	}
+
+	if len(filter.CallTypes) > 0 {
+		m.callTypes = make(map[string]struct{}, len(filter.CallTypes))
+		for _, callType := range filter.CallTypes {
+			m.callTypes[strings.ToLower(callType)] = struct{}{}
+		}
+	}
+	if filter.MinValue != nil {
+		m.minValue = filter.MinValue.ToInt()
+	}
+
+	return m, nil
+}
+
+func (m *innerTxMatcher) matchesEntry(entry hermez_db.InnerTxIndexEntry) bool {
+	return m.from && entry.IsFrom() || m.to && entry.IsTo()
+}
+
+func (m *innerTxMatcher) matches(innerTx *zktypes.InnerTx) bool {
+	if m.internalOnly && innerTx.Dept.Sign() == 0 {
+		return false
+	}
+	if m.callTypes != nil {
+		if _, found := m.callTypes[innerTx.CallType]; !found {
+			return false
+		}
+	}
+	if m.minValue != nil && m.minValue.Sign() > 0 {
+		value, ok := new(big.Int).SetString(innerTx.ValueWei, 10)
+		if !ok || value.Cmp(m.minValue) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetInternalTransactionsByAddress returns the inner txs sent from or to the address, in the order they were executed
+func (api *APIImpl) GetInternalTransactionsByAddress(ctx context.Context, address libcommon.Address, filter InternalTransactionsFilter) ([]*AddressInternalTransaction, error) {
+	if !api.EnableInnerTx {
+		return nil, errors.New("unsupported internal transaction method")
+	}
+
+	matcher, err := newInnerTxMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+	limit := defaultInternalTransactionsLimit
+	if filter.Limit != nil {
+		if *filter.Limit == 0 || *filter.Limit > maxInternalTransactionsLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxInternalTransactionsLimit)
+		}
+		limit = int(*filter.Limit)
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	fromBlock, toBlock := uint64(0), uint64(0)
+	if filter.FromBlock != nil {
+		if fromBlock, err = api.innerTxsBlockNumber(tx, *filter.FromBlock); err != nil {
+			return nil, err
+		}
+	}
+	if toBlock, err = api.innerTxsBlockNumber(tx, rpc.LatestBlockNumber); err != nil {
+		return nil, err
+	}
+	if filter.ToBlock != nil {
+		if toBlock, err = api.innerTxsBlockNumber(tx, *filter.ToBlock); err != nil {
+			return nil, err
+		}
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("fromBlock %d is greater than toBlock %d", fromBlock, toBlock)
+	}
+
+	hermezReader := hermez_db.NewHermezDbReader(tx)
+	results := make([]*AddressInternalTransaction, 0)
+	scanned := 0
+
+	// the inner txs of a transaction are usually indexed next to each other
+	var lastBlock uint64
+	var lastTxIndex uint32
+	var lastInnerTxs []*zktypes.InnerTx
+	var lastTxHash libcommon.Hash
+
+	err = hermezReader.GetInnerTxIndexEntries(address, fromBlock, toBlock, func(entry hermez_db.InnerTxIndexEntry) (bool, error) {
+		if scanned++; scanned > maxInternalTransactionsScan {
+			return false, fmt.Errorf("the address has more than %d internal transactions in the block range, narrow it", maxInternalTransactionsScan)
+		}
+		if !matcher.matchesEntry(entry) {
+			return true, nil
+		}
+
+		if lastInnerTxs == nil || entry.BlockNumber != lastBlock || entry.TxIndex != lastTxIndex {
+			innerTxs, err := hermezReader.GetTxInnerTxs(entry.BlockNumber, entry.TxIndex)
+			if err != nil {
+				return false, err
+			}
+			txn, err := api._blockReader.TxnByIdxInBlock(ctx, tx, entry.BlockNumber, int(entry.TxIndex))
+			if err != nil {
+				return false, err
+			}
+			if txn == nil {
+				return false, fmt.Errorf("transaction %d of block %d not found", entry.TxIndex, entry.BlockNumber)
+			}
+			lastBlock, lastTxIndex, lastInnerTxs, lastTxHash = entry.BlockNumber, entry.TxIndex, innerTxs, txn.Hash()
+		}
+		if int(entry.Index) >= len(lastInnerTxs) {
+			return true, nil
+		}
+
+		innerTx := lastInnerTxs[entry.Index]
+		if !matcher.matches(innerTx) {
+			return true, nil
+		}
+		results = append(results, &AddressInternalTransaction{
+			BlockNumber:      hexutil.Uint64(entry.BlockNumber),
+			TransactionHash:  lastTxHash,
+			TransactionIndex: hexutil.Uint64(entry.TxIndex),
+			InnerTx:          innerTx,
+		})
+		return len(results) < limit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (api *APIImpl) innerTxsBlockNumber(tx kv.Tx, number rpc.BlockNumber) (uint64, error) {
+	if number == rpc.PendingBlockNumber {
+		return 0, fmt.Errorf("not supported pending block number")
+	}
+	n, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(number), tx, api.filters)
+	return n, err
+}
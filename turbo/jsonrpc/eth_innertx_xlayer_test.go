@@ -0,0 +1,72 @@
+package jsonrpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInnerTxMatcher(t *testing.T) {
+	topLevel := &zktypes.InnerTx{CallType: "call", ValueWei: "0"}
+	transfer := &zktypes.InnerTx{Dept: *big.NewInt(1), CallType: "call", ValueWei: "1000"}
+	creation := &zktypes.InnerTx{Dept: *big.NewInt(1), CallType: "create", ValueWei: "0"}
+
+	scenarios := map[string]struct {
+		filter   InternalTransactionsFilter
+		expected []bool // topLevel, transfer, creation
+	}{
+		"no filter": {
+			expected: []bool{true, true, true},
+		},
+		"internal only": {
+			filter:   InternalTransactionsFilter{InternalOnly: true},
+			expected: []bool{false, true, true},
+		},
+		"call types": {
+			filter:   InternalTransactionsFilter{CallTypes: []string{"CREATE", "create2"}},
+			expected: []bool{false, false, true},
+		},
+		"min value": {
+			filter:   InternalTransactionsFilter{MinValue: (*hexutil.Big)(big.NewInt(1))},
+			expected: []bool{false, true, false},
+		},
+		"incoming native transfers made by contracts": {
+			filter:   InternalTransactionsFilter{Direction: "to", InternalOnly: true, MinValue: (*hexutil.Big)(big.NewInt(1))},
+			expected: []bool{false, true, false},
+		},
+	}
+
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			matcher, err := newInnerTxMatcher(scenario.filter)
+			require.NoError(t, err)
+			for i, innerTx := range []*zktypes.InnerTx{topLevel, transfer, creation} {
+				require.Equal(t, scenario.expected[i], matcher.matches(innerTx), "inner tx %d", i)
+			}
+		})
+	}
+}
+
+func TestInnerTxMatcher_Direction(t *testing.T) {
+	from := hermez_db.InnerTxIndexEntry{Roles: hermez_db.InnerTxRoleFrom}
+	to := hermez_db.InnerTxIndexEntry{Roles: hermez_db.InnerTxRoleTo}
+	self := hermez_db.InnerTxIndexEntry{Roles: hermez_db.InnerTxRoleFrom | hermez_db.InnerTxRoleTo}
+
+	matcher, err := newInnerTxMatcher(InternalTransactionsFilter{Direction: "to"})
+	require.NoError(t, err)
+	require.False(t, matcher.matchesEntry(from))
+	require.True(t, matcher.matchesEntry(to))
+	require.True(t, matcher.matchesEntry(self))
+
+	matcher, err = newInnerTxMatcher(InternalTransactionsFilter{})
+	require.NoError(t, err)
+	require.True(t, matcher.matchesEntry(from))
+	require.True(t, matcher.matchesEntry(to))
+
+	_, err = newInnerTxMatcher(InternalTransactionsFilter{Direction: "sideways"})
+	require.Error(t, err)
+}
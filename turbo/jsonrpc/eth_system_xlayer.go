@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"strconv"
 	"time"
@@ -12,9 +13,12 @@ import (
 	"github.com/ledgerwatch/erigon-lib/common/hexutil"
 	proto_txpool "github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
 	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/vm"
 	"github.com/ledgerwatch/erigon/eth/gasprice"
 	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
 	"github.com/ledgerwatch/erigon/zk/apollo"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/metrics"
 	"github.com/ledgerwatch/erigon/zk/sequencer"
 	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
@@ -41,6 +45,9 @@ func (api *APIImpl) gasPriceNonRedirectedXL(ctx context.Context) (*hexutil.Big,
 }
 
 func (api *APIImpl) isCongested(ctx context.Context) bool {
+	if pricer, ok := gasprice.AsL2CongestionPricer(api.L2GasPricer); ok {
+		return pricer.IsCongested()
+	}
 
 	latestBlockTxNum, err := api.getLatestBlockTxNum(ctx)
 	if err != nil {
@@ -58,6 +65,84 @@ func (api *APIImpl) isCongested(ctx context.Context) bool {
 	return !isLatestBlockEmpty && isPendingTxCongested
 }
 
+// updateCongestion feeds the congestion signals to the strategy running if it prices them, returning whether it did
+func (api *APIImpl) updateCongestion(ctx context.Context) bool {
+	pricer, ok := gasprice.AsL2CongestionPricer(api.L2GasPricer)
+	if !ok {
+		return false
+	}
+	signals, err := api.getCongestionSignals(ctx)
+	if err != nil {
+		log.Error(fmt.Sprintf("error getting congestion signals: %v", err))
+		return false
+	}
+	pricer.UpdateCongestion(signals)
+	return true
+}
+
+// getCongestionSignals measures the load of the chain over the recent blocks: the gas they used, the pending txs and
+// the zk counters used by their batches. Only the sequencer stores the batch counters, the counter usage is 0 elsewhere.
+func (api *APIImpl) getCongestionSignals(ctx context.Context) (gasprice.CongestionSignals, error) {
+	var signals gasprice.CongestionSignals
+	cfg := api.L2GasPricer.GetConfig().XLayer
+
+	poolStatus, err := api.txPool.Status(ctx, &proto_txpool.StatusRequest{})
+	if err != nil {
+		return signals, err
+	}
+	if cfg.CongestionPendingTxs > 0 {
+		signals.PendingDepth = float64(poolStatus.PendingCount) / float64(cfg.CongestionPendingTxs)
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return signals, err
+	}
+	defer tx.Rollback()
+
+	latest, err := rpchelper.GetLatestFinishedBlockNumber(tx)
+	if err != nil {
+		return signals, err
+	}
+	blocks := uint64(max(cfg.CongestionBlocks, 1))
+	if blocks > latest {
+		blocks = latest
+	}
+
+	hermezDb := hermez_db.NewHermezDbReader(tx)
+	var gasUsed uint64
+	for number := latest - blocks + 1; number <= latest; number++ {
+		header := rawdb.ReadHeaderByNumber(tx, number)
+		if header == nil {
+			return signals, fmt.Errorf("header %d not found", number)
+		}
+		gasUsed += header.GasUsed
+
+		counters, found, err := hermezDb.GetBatchCountersByBlock(number)
+		if err != nil {
+			return signals, err
+		}
+		if !found {
+			continue
+		}
+		forkId, err := hermezDb.GetForkIdByBlockNum(number)
+		if err != nil {
+			return signals, err
+		}
+		limits := vm.NewCounterCollector(0, uint16(forkId)).Counters()
+		for i, used := range counters {
+			if i < len(limits) && limits[i].Limit() > 0 {
+				signals.CounterUsage = math.Max(signals.CounterUsage, float64(used)/float64(limits[i].Limit()))
+			}
+		}
+	}
+	if cfg.CongestionBlockGas > 0 && blocks > 0 {
+		signals.BlockFullness = float64(gasUsed) / float64(blocks*cfg.CongestionBlockGas)
+	}
+
+	return signals, nil
+}
+
 func (api *APIImpl) getLatestBlockTxNum(ctx context.Context) (int, error) {
 	tx, err := api.db.BeginRo(ctx)
 	if err != nil {
@@ -118,9 +203,12 @@ func (api *APIImpl) runL2GasPriceSuggester() {
 			if apollo.IsApolloConfigL2GasPricerEnabled() {
 				api.L2GasPricer.UpdateConfig(apollo.GetApolloGasPricerConfig())
 			}
+			updated := api.updateCongestion(ctx)
 			l1gp, err := gasprice.GetL1GasPrice(api.L1RpcUrl)
 			if err == nil {
 				api.L2GasPricer.UpdateGasPriceAvg(l1gp)
+			}
+			if err == nil || updated {
 				api.gasCache.SetLatestRawGP(api.L2GasPricer.GetLastRawGP())
 			}
 			api.updateDynamicGP(ctx)
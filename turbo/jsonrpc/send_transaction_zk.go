@@ -1,6 +1,7 @@
 package jsonrpc
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,6 +10,9 @@ import (
 	zkchainconfig "github.com/ledgerwatch/erigon-lib/chain"
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	txPoolProto "github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/ledgerwatch/log/v3"
+
 	"github.com/ledgerwatch/erigon/zk/sequencer"
 	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
 )
@@ -37,3 +41,31 @@ func (api *APIImpl) sendTxZk(rpcUrl string, encodedTx hexutility.Bytes, chainId
 
 	return common.HexToHash(hashHex), nil
 }
+
+// forwardTxZk proxies a transaction to the pool manager if set, to the sequencer otherwise.  The sequencer dry run
+// gets it in the local pool too.
+func (api *APIImpl) forwardTxZk(ctx context.Context, encodedTx hexutility.Bytes, chainId uint64) (common.Hash, error) {
+	rpcUrl := api.l2RpcUrl
+	if api.isPoolManagerAddressSet() {
+		rpcUrl = api.PoolManagerUrl
+	}
+
+	hash, err := api.sendTxZk(rpcUrl, encodedTx, chainId)
+	if err == nil && api.SequencerDryRun {
+		api.addToDryRunPool(ctx, hash, encodedTx)
+	}
+	return hash, err
+}
+
+// addToDryRunPool adds a transaction forwarded to the sequencer to the local pool too, which the sequencer dry run
+// yields from.  The transaction is already accepted by the sequencer so a failure here is only logged.
+func (api *APIImpl) addToDryRunPool(ctx context.Context, hash common.Hash, encodedTx hexutility.Bytes) {
+	res, err := api.txPool.Add(ctx, &txPoolProto.AddRequest{RlpTxs: [][]byte{encodedTx}})
+	if err != nil {
+		log.Warn("[SequencerDryRun] Failed to add a forwarded transaction to the pool", "hash", hash, "err", err)
+		return
+	}
+	if res.Imported[0] != txPoolProto.ImportResult_SUCCESS {
+		log.Debug("[SequencerDryRun] Forwarded transaction not added to the pool", "hash", hash, "result", txPoolProto.ImportResult_name[int32(res.Imported[0])], "err", res.Errors[0])
+	}
+}
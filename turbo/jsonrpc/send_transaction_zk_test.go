@@ -0,0 +1,60 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	txPoolProto "github.com/ledgerwatch/erigon-lib/gointerfaces/txpool"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type addRecordingTxPool struct {
+	txPoolProto.TxpoolClient
+	added [][]byte
+}
+
+func (p *addRecordingTxPool) Add(_ context.Context, in *txPoolProto.AddRequest, _ ...grpc.CallOption) (*txPoolProto.AddReply, error) {
+	p.added = append(p.added, in.RlpTxs...)
+	return &txPoolProto.AddReply{Imported: []txPoolProto.ImportResult{txPoolProto.ImportResult_SUCCESS}, Errors: []string{""}}, nil
+}
+
+func TestForwardTxZkDryRun(t *testing.T) {
+	hash := common.HexToHash("0x1234")
+	encodedTx := hexutility.Bytes{0x01, 0x02}
+	accept := true
+	sequencer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, hash.Hex())
+		} else {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"nonce too low"}}`)
+		}
+	}))
+	defer sequencer.Close()
+
+	txPool := &addRecordingTxPool{}
+	api := &APIImpl{BaseAPI: &BaseAPI{l2RpcUrl: sequencer.URL}, txPool: txPool}
+
+	// the transaction only goes to the sequencer
+	got, err := api.forwardTxZk(context.Background(), encodedTx, 1)
+	require.NoError(t, err)
+	require.Equal(t, hash, got)
+	require.Empty(t, txPool.added)
+
+	// and to the local pool too for the dry run
+	api.SequencerDryRun = true
+	_, err = api.forwardTxZk(context.Background(), encodedTx, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{encodedTx}, txPool.added)
+
+	// unless the sequencer rejected it
+	accept = false
+	_, err = api.forwardTxZk(context.Background(), encodedTx, 1)
+	require.Error(t, err)
+	require.Len(t, txPool.added, 1)
+}
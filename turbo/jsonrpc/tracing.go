@@ -290,6 +290,10 @@ func (api *PrivateDebugAPIImpl) TraceTransaction(ctx context.Context, hash commo
 		stream.WriteNil()
 		return err
 	}
+	if config, err = zkCountersTraceConfig(hermez_db.NewHermezDbReader(tx), blockNum, txn, config, api.config.Zk.VirtualCountersSmtReduction); err != nil {
+		stream.WriteNil()
+		return err
+	}
 	// Trace the transaction and return
 	return transactions.TraceTx(ctx, txEnv.Msg, txEnv.BlockContext, txEnv.TxContext, txEnv.Ibs, config, chainConfig, stream, api.evmCallTimeout)
 }
@@ -354,6 +358,10 @@ func (api *PrivateDebugAPIImpl) TraceCall(ctx context.Context, args ethapi.CallA
 		return fmt.Errorf("convert args to msg: %v", err)
 	}
 
+	if config, err = zkCountersTraceConfig(hermez_db.NewHermezDbReader(dbtx), blockNumber, callTransaction(msg), config, api.config.Zk.VirtualCountersSmtReduction); err != nil {
+		return fmt.Errorf("zk counters: %v", err)
+	}
+
 	blockCtx := transactions.NewEVMBlockContext(engine, header, blockNrOrHash.RequireCanonical, dbtx, api._blockReader)
 	txCtx := core.NewEVMTxContext(msg)
 	// Trace the transaction and return
@@ -31,6 +31,7 @@ type blockTracer struct {
 	_blockReader   services.FullBlockReader
 	historyV3      bool
 	evmCallTimeout time.Duration
+	smtReduction   float64 // of the counters the zk counters tracer runs the transactions with
 }
 
 func (bt *blockTracer) TraceBlock(block *types.Block) error {
@@ -99,13 +100,20 @@ func (bt *blockTracer) traceTransactionUnflushed(txTracerEnv txTracerEnv, txn ty
 		return err
 	}
 
+	// each transaction runs with counters of its own
+	config, err := zkCountersTraceConfig(hermez_db.NewHermezDbReader(bt.tx), txTracerEnv.block.NumberU64(), txn, bt.config, bt.smtReduction)
+	if err != nil {
+		bt.stream.WriteNil()
+		return err
+	}
+
 	if err = transactions.TraceTx(
 		bt.ctx,
 		msg,
 		txTracerEnv.txEnv.BlockContext,
 		txCtx,
 		txTracerEnv.txEnv.Ibs,
-		bt.config,
+		config,
 		bt.chainConfig,
 		bt.stream,
 		bt.evmCallTimeout,
@@ -0,0 +1,38 @@
+package jsonrpc
+
+import (
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+)
+
+// zkCountersTraceConfig returns the config to trace a transaction run on top of the block with. The zk counters
+// tracer reads the counters the transaction uses, it gets a copy of the config with fresh counters for the fork and
+// the smt depth of the block, the counters given already being kept.
+func zkCountersTraceConfig(hermezDb *hermez_db.HermezDbReader, blockNum uint64, txn types.Transaction, config *tracers.TraceConfig_ZkEvm, smtReduction float64) (*tracers.TraceConfig_ZkEvm, error) {
+	if !config.TracesZkCounters() || config.CounterCollector != nil {
+		return config, nil
+	}
+
+	forkId, err := hermezDb.GetForkIdByBlockNum(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	smtDepth, err := getSmtDepth(hermezDb, blockNum, config)
+	if err != nil {
+		return nil, err
+	}
+
+	withCounters := *config
+	withCounters.CounterCollector = vm.NewTransactionCounter(txn, smtDepth, uint16(forkId), smtReduction, false)
+	return &withCounters, nil
+}
+
+// callTransaction builds the transaction of a call for its counters, which tell the contract deployments apart
+func callTransaction(msg types.Message) types.Transaction {
+	if msg.To() == nil {
+		return types.NewContractCreation(msg.Nonce(), msg.Value(), msg.Gas(), msg.GasPrice(), msg.Data())
+	}
+	return types.NewTransaction(msg.Nonce(), *msg.To(), msg.Value(), msg.Gas(), msg.GasPrice(), msg.Data())
+}
@@ -0,0 +1,68 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ledgerwatch/erigon-lib/chain"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcache"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/accounts/abi/bind/backends"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/eth/tracers"
+	_ "github.com/ledgerwatch/erigon/eth/tracers/native"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/rpc/rpccfg"
+	"github.com/ledgerwatch/erigon/turbo/adapter/ethapi"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+)
+
+func TestTraceCallZkCounters(t *testing.T) {
+	contractBackend := backends.NewTestSimulatedBackendWithConfig(t, gspec.Alloc, gspec.Config, gspec.GasLimit)
+	defer contractBackend.Close()
+	contractBackend.Commit()
+
+	// the counters are those of the fork of the block
+	tx, err := contractBackend.DB().BeginRw(ctx)
+	require.NoError(t, err)
+	hermezDb := hermez_db.NewHermezDb(tx)
+	require.NoError(t, hermezDb.WriteBlockBatch(1, 1))
+	require.NoError(t, hermezDb.WriteForkId(1, uint64(chain.ForkID12Banana)))
+	require.NoError(t, tx.Commit())
+
+	baseApi := NewBaseApi(nil, kvcache.New(kvcache.DefaultCoherentConfig), contractBackend.BlockReader(), contractBackend.Agg(), false, rpccfg.DefaultEvmCallTimeout, contractBackend.Engine(), datadir.New(t.TempDir()))
+	api := NewPrivateDebugAPI(baseApi, contractBackend.DB(), 0, &ethconfig.Defaults)
+
+	tracer, smtDepth := tracers.ZkCountersTracerName, 32
+	gas := hexutil.Uint64(1_000_000)
+	// SSTORE(0, 42)
+	code := hexutility.Bytes(hexutil.MustDecode("0x602a60005500"))
+
+	for name, args := range map[string]ethapi.CallArgs{
+		"call":     {From: &address, To: &address1, Gas: &gas},
+		"creation": {From: &address, Gas: &gas, Data: &code},
+	} {
+		var buf bytes.Buffer
+		stream := jsoniter.NewStream(jsoniter.ConfigDefault, &buf, 4096)
+		err := api.TraceCall(ctx, args, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), &tracers.TraceConfig_ZkEvm{Tracer: &tracer, SmtDepth: &smtDepth}, stream)
+		require.NoError(t, err, name)
+		require.NoError(t, stream.Flush(), name)
+
+		var trace struct {
+			Counters   map[string]int                    `json:"counters"`
+			ByContract map[common.Address]map[string]int `json:"byContract"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &trace), name)
+		if name == "creation" {
+			require.Positive(t, trace.Counters["steps"], name)
+			require.Positive(t, trace.Counters["poseidonhashes"], name)
+		}
+	}
+}
@@ -93,6 +93,7 @@ func (api *PrivateDebugAPIImpl) traceBlock(ctx context.Context, blockNrOrHash rp
 		_blockReader:   api._blockReader,
 		historyV3:      api.historyV3(tx),
 		evmCallTimeout: api.evmCallTimeout,
+		smtReduction:   api.config.Zk.VirtualCountersSmtReduction,
 	}
 
 	return blockTracer.TraceBlock(block)
@@ -463,6 +464,7 @@ func (api *PrivateDebugAPIImpl) TraceBatchByNumber(ctx context.Context, batchNum
 		_blockReader:   api._blockReader,
 		historyV3:      api.historyV3(tx),
 		evmCallTimeout: api.evmCallTimeout,
+		smtReduction:   api.config.Zk.VirtualCountersSmtReduction,
 	}
 
 	for _, blockNum := range blockNumbers {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
 
 	libcommon "github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/gointerfaces"
@@ -22,6 +23,8 @@ type TxPoolAPI interface {
 	Content(ctx context.Context) (interface{}, error)
 	ContentFrom(ctx context.Context, addr libcommon.Address) (map[string]map[string]*RPCTransaction, error)
 	Limbo(ctx context.Context) (interface{}, error)
+	SendBundle(ctx context.Context, txs []hexutility.Bytes, maxBlockNumber *hexutil.Uint64) (libcommon.Hash, error)
+	BundleStatus(ctx context.Context, hash libcommon.Hash) (*txpool.BundleStatus, error)
 }
 
 // TxPoolAPIImpl data structure to store things needed for net_ commands
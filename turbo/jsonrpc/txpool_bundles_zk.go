@@ -0,0 +1,78 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
+)
+
+var errNoBundlePool = errors.New("bundles are only accepted by the node running the txpool")
+
+// SendBundle submits transactions the sequencer includes all together, in order and contiguously in a single block, or
+// not at all. The bundle is dropped after maxBlockNumber when set.
+func (api *TxPoolAPIImpl) SendBundle(ctx context.Context, txs []hexutility.Bytes, maxBlockNumber *hexutil.Uint64) (libcommon.Hash, error) {
+	if api.l2RPCUrl != "" {
+		res, err := client.JSONRPCCall(api.l2RPCUrl, "txpool_sendBundle", txs, maxBlockNumber)
+		if err != nil {
+			return libcommon.Hash{}, err
+		}
+		if res.Error != nil {
+			return libcommon.Hash{}, fmt.Errorf("RPC error response: %s", res.Error.Message)
+		}
+		var hash libcommon.Hash
+		if err = json.Unmarshal(res.Result, &hash); err != nil {
+			return libcommon.Hash{}, err
+		}
+		return hash, nil
+	}
+
+	if api.rawPool == nil {
+		return libcommon.Hash{}, errNoBundlePool
+	}
+
+	rlpTxs := make([][]byte, len(txs))
+	for i, tx := range txs {
+		rlpTxs[i] = tx
+	}
+	var maxBlock uint64
+	if maxBlockNumber != nil {
+		maxBlock = uint64(*maxBlockNumber)
+	}
+
+	return api.rawPool.AddBundle(ctx, rlpTxs, maxBlock)
+}
+
+// BundleStatus returns whether a bundle is pending, was included in a block, failed or expired
+func (api *TxPoolAPIImpl) BundleStatus(ctx context.Context, hash libcommon.Hash) (*txpool.BundleStatus, error) {
+	if api.l2RPCUrl != "" {
+		res, err := client.JSONRPCCall(api.l2RPCUrl, "txpool_bundleStatus", hash)
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, fmt.Errorf("RPC error response: %s", res.Error.Message)
+		}
+		var status *txpool.BundleStatus
+		if err = json.Unmarshal(res.Result, &status); err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+
+	if api.rawPool == nil {
+		return nil, errNoBundlePool
+	}
+
+	status, ok := api.rawPool.BundleStatus(hash)
+	if !ok {
+		return nil, nil
+	}
+	return status, nil
+}
@@ -11,8 +11,8 @@ import (
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/common/hexutility"
 	"github.com/ledgerwatch/erigon-lib/kv"
-	"github.com/ledgerwatch/log/v3"
 	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/log/v3"
 
 	"math"
 
@@ -74,6 +74,7 @@ type ZkEvmAPI interface {
 	GetBatchCountersByNumber(ctx context.Context, batchNumRpc rpc.BlockNumber) (res json.RawMessage, err error)
 	GetExitRootTable(ctx context.Context) ([]l1InfoTreeData, error)
 	GetVersionHistory(ctx context.Context) (json.RawMessage, error)
+	GetVerificationFailures(ctx context.Context, fromBatch, toBatch *hexutil.Uint64, limit *hexutil.Uint64) ([]*zktypes.VerificationFailure, error)
 	GetForkId(ctx context.Context) (hexutil.Uint64, error)
 	GetForkById(ctx context.Context, forkId hexutil.Uint64) (res json.RawMessage, err error)
 	GetForkIdByBatchNumber(ctx context.Context, batchNumber rpc.BlockNumber) (hexutil.Uint64, error)
@@ -1283,6 +1284,42 @@ func (api *ZkEvmAPIImpl) GetVersionHistory(ctx context.Context) (json.RawMessage
 	return versionsJson, nil
 }
 
+const (
+	defaultVerificationFailuresLimit = 100
+	maxVerificationFailuresLimit     = 1000
+)
+
+// GetVerificationFailures returns the executor verification failures recorded for the batches in [fromBatch, toBatch],
+// both optional, ordered by batch and then by time
+func (api *ZkEvmAPIImpl) GetVerificationFailures(ctx context.Context, fromBatch, toBatch *hexutil.Uint64, limit *hexutil.Uint64) ([]*zktypes.VerificationFailure, error) {
+	from, to := uint64(0), uint64(math.MaxUint64)
+	if fromBatch != nil {
+		from = uint64(*fromBatch)
+	}
+	if toBatch != nil {
+		to = uint64(*toBatch)
+	}
+	if from > to {
+		return nil, fmt.Errorf("fromBatch %d is greater than toBatch %d", from, to)
+	}
+
+	count := defaultVerificationFailuresLimit
+	if limit != nil {
+		if *limit == 0 || *limit > maxVerificationFailuresLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxVerificationFailuresLimit)
+		}
+		count = int(*limit)
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return hermez_db.NewHermezDbReader(tx).GetVerificationFailures(from, to, count)
+}
+
 type l1InfoTreeData struct {
 	Index           uint64      `json:"index"`
 	Ger             common.Hash `json:"ger"`
@@ -1654,20 +1691,22 @@ func (zkapi *ZkEvmAPIImpl) GetProof(ctx context.Context, address common.Address,
 	}
 
 	if blockNr < latestBlock {
-		if latestBlock-blockNr > uint64(api.MaxGetProofRewindBlockCount) {
-			return nil, fmt.Errorf("requested block is too old, block must be within %d blocks of the head block number (currently %d)", api.MaxGetProofRewindBlockCount, latestBlock)
-		}
-		unwindState := &stagedsync.UnwindState{UnwindPoint: blockNr}
-		stageState := &stagedsync.StageState{BlockNumber: latestBlock}
-
-		interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, api.dirs.Tmp, api._blockReader, nil, api.historyV3(tx), api._agg, nil)
+		// the nodes kept by zkevm.smt-history-blocks spare rewinding the whole tree from the head
+		err = zkStages.RestoreSmtHistory(ctx, "zkevm_getProof", batch, blockNr)
+		if errors.Is(err, zkStages.ErrSmtHistoryNotRetained) {
+			if latestBlock-blockNr > uint64(api.MaxGetProofRewindBlockCount) {
+				return nil, fmt.Errorf("requested block is too old, block must be within %d blocks of the head block number (currently %d)", api.MaxGetProofRewindBlockCount, latestBlock)
+			}
+			unwindState := &stagedsync.UnwindState{UnwindPoint: blockNr}
+			stageState := &stagedsync.StageState{BlockNumber: latestBlock}
 
-		if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
-			return nil, fmt.Errorf("unwind intermediate hashes: %w", err)
-		}
+			interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, api.dirs.Tmp, api._blockReader, nil, api.historyV3(tx), api._agg, nil)
 
-		if err != nil {
-			return nil, err
+			if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
+				return nil, fmt.Errorf("unwind intermediate hashes: %w", err)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("restore smt history: %w", err)
 		}
 		tx = batch
 	}
@@ -817,6 +817,62 @@ func TestGetVersionHistory(t *testing.T) {
 	}
 }
 
+func TestGetVerificationFailures(t *testing.T) {
+	assert := assert.New(t)
+	////////////////
+	contractBackend := backends.NewTestSimulatedBackendWithConfig(t, gspec.Alloc, gspec.Config, gspec.GasLimit)
+	defer contractBackend.Close()
+	stateCache := kvcache.New(kvcache.DefaultCoherentConfig)
+	contractBackend.Commit()
+	///////////
+
+	db := contractBackend.DB()
+	agg := contractBackend.Agg()
+
+	baseApi := NewBaseApi(nil, stateCache, contractBackend.BlockReader(), agg, false, rpccfg.DefaultEvmCallTimeout, contractBackend.Engine(), datadir.New(t.TempDir()))
+	ethImpl := NewEthAPI(baseApi, db, nil, nil, nil, 5000000, 100_000, 100_000, &ethconfig.Defaults, false, 100, 100, log.New(), 1000)
+	var l1Syncer *syncer.L1Syncer
+	zkEvmImpl := NewZkEvmAPI(ethImpl, db, 100_000, &ethconfig.Defaults, l1Syncer, "", nil)
+
+	tx, err := db.BeginRw(ctx)
+	assert.NoError(err)
+	hDB := hermez_db.NewHermezDb(tx)
+	ti := time.Now()
+	for i := 1; i <= 10; i++ {
+		err := hDB.WriteVerificationFailure(&zktypes.VerificationFailure{
+			BatchNumber:       hexutil.Uint64(i),
+			BlockNumbers:      []hexutil.Uint64{hexutil.Uint64(i)},
+			ExpectedStateRoot: common.HexToHash("0x1"),
+			ExecutorStateRoot: common.HexToHash("0x2"),
+			ExecutorUrl:       "executor:50071",
+			Error:             "executor state root mismatches",
+			Timestamp:         ti,
+		})
+		assert.NoError(err)
+	}
+	tx.Commit()
+
+	failures, err := zkEvmImpl.GetVerificationFailures(ctx, nil, nil, nil)
+	assert.NoError(err)
+	assert.Len(failures, 10)
+	assert.Equal(hexutil.Uint64(1), failures[0].BatchNumber)
+	assert.Equal("executor:50071", failures[0].ExecutorUrl)
+	assert.Equal(common.HexToHash("0x2"), failures[0].ExecutorStateRoot)
+	failureJson, err := json.Marshal(failures[0])
+	assert.NoError(err)
+	assert.Contains(string(failureJson), `"batchNumber":"0x1","blockNumbers":["0x1"]`)
+
+	from, to, limit := hexutil.Uint64(3), hexutil.Uint64(8), hexutil.Uint64(2)
+	failures, err = zkEvmImpl.GetVerificationFailures(ctx, &from, &to, &limit)
+	assert.NoError(err)
+	assert.Len(failures, 2)
+	assert.Equal(hexutil.Uint64(3), failures[0].BatchNumber)
+	assert.Equal(hexutil.Uint64(4), failures[1].BatchNumber)
+
+	_, err = zkEvmImpl.GetVerificationFailures(ctx, &to, &from, nil)
+	assert.Error(err)
+}
+
 func TestGetExitRootTable(t *testing.T) {
 	assert := assert.New(t)
 	////////////////
@@ -2,6 +2,8 @@ package stages
 
 import (
 	"context"
+	"io"
+	"os"
 
 	proto_downloader "github.com/ledgerwatch/erigon-lib/gointerfaces/downloader"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -157,3 +159,64 @@ func NewSequencerZkStages(ctx context.Context,
 		stagedsync.StageFinishCfg(db, dirs.Tmp, forkValidator),
 		runInTestMode)
 }
+
+// StartSequencerDryRun runs the batch loop of the sequencer on an RPC node without committing anything, reporting the
+// batches it would have built to the log and to the output file when one is set
+func StartSequencerDryRun(ctx context.Context,
+	db kv.RwDB,
+	cfg *ethconfig.Config,
+	controlServer *sentry_multi_client.MultiClient,
+	snapshots *freezeblocks.RoSnapshots,
+	agg *state.Aggregator,
+	dataStreamServer server.DataStreamServer,
+	txPool *txpool.TxPool,
+	txPoolDb kv.RwDB,
+	infoTreeUpdater *l1infotree.Updater,
+) error {
+	dirs := cfg.Dirs
+	blockReader := freezeblocks.NewBlockReader(snapshots, nil)
+
+	var out io.Writer
+	if cfg.SequencerDryRunOutput != "" {
+		f, err := os.OpenFile(cfg.SequencerDryRunOutput, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+
+	sequenceCfg := zkStages.StageSequenceBlocksCfg(
+		db,
+		cfg.Prune,
+		cfg.BatchSize,
+		nil,
+		controlServer.ChainConfig,
+		controlServer.Engine,
+		&vm.ZkConfig{},
+		nil,
+		cfg.StateStream,
+		/*stateStream=*/ false,
+		cfg.HistoryV3,
+		dirs,
+		blockReader,
+		cfg.Genesis,
+		cfg.Sync,
+		agg,
+		dataStreamServer,
+		cfg.Zk,
+		&cfg.Miner,
+		txPool,
+		txPoolDb,
+		nil,
+		uint16(cfg.YieldSize),
+		infoTreeUpdater,
+	)
+
+	go func() {
+		zkStages.RunSequencerDryRun(ctx, sequenceCfg, stagedsync.StageHistoryCfg(db, cfg.Prune, dirs.Tmp), out)
+		if f, ok := out.(*os.File); ok {
+			f.Close()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,62 @@
+package transactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/chain"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/consensus"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/turbo/services"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+)
+
+// TraceBlockInnerTxs re-executes the block on top of the state of its parent to get the inner txs of each of its
+// transactions, the same ones the execution stage stores when inner txs are enabled
+func TraceBlockInnerTxs(ctx context.Context, engine consensus.EngineReader, block *types.Block, cfg *chain.Config, headerReader services.HeaderReader, dbtx kv.Tx, historyV3 bool) ([][]*zktypes.InnerTx, error) {
+	if len(block.Transactions()) == 0 {
+		return nil, nil
+	}
+
+	txEnv, err := ComputeTxEnv_ZkEvm(ctx, engine, block, cfg, headerReader, dbtx, 0, historyV3)
+	if err != nil {
+		return nil, err
+	}
+
+	vmConfig := vm.NewTraceVmConfig()
+	vmConfig.Debug = false
+	vmConfig.NoReceipts = false
+	vmConfig.NoInnerTxs = false
+
+	usedGas := new(uint64)
+	gp := new(core.GasPool).AddGas(block.GasLimit())
+	hermezReader := hermez_db.NewHermezDbReader(dbtx)
+
+	blockInnerTxs := make([][]*zktypes.InnerTx, 0, len(block.Transactions()))
+	for txIndex, txn := range block.Transactions() {
+		select {
+		default:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		txHash := txn.Hash()
+		evm, effectiveGasPricePercentage, err := core.PrepareForTxExecution(cfg, &vmConfig, &txEnv.BlockContext, hermezReader, txEnv.Ibs, block, &txHash, txIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		_, _, innerTxs, err := core.ApplyTransaction_zkevm(cfg, engine, evm, gp, txEnv.Ibs, state.NewNoopWriter(), block.Header(), txn, usedGas, effectiveGasPricePercentage, true)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d of block %d: %w", txIndex, block.NumberU64(), err)
+		}
+		blockInnerTxs = append(blockInnerTxs, innerTxs)
+	}
+
+	return blockInnerTxs, nil
+}
@@ -180,6 +180,13 @@ func TraceTx(
 			stream.WriteNil()
 			return err
 		}
+		if countersTracer, ok := tracer.(tracers.ZkCountersTracer); ok {
+			if executionCounters == nil {
+				stream.WriteNil()
+				return fmt.Errorf("tracer %s needs the zk counters of the transaction", *config.Tracer)
+			}
+			countersTracer.SetCounterCollector(executionCounters)
+		}
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
@@ -20,6 +20,8 @@ type ApolloConfig struct {
 	EnableFlag uint32
 	NodeCfg    nodecfg.Config
 	EthCfg     ethconfig.Config
+	// SeqQuotasCfg holds the throughput quotas of the sequencer namespace, apart from the ones of the pool namespace
+	SeqQuotasCfg ethconfig.DeprecatedTxPoolConfig
 	sync.RWMutex
 }
 
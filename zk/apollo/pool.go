@@ -136,3 +136,65 @@ func (cfg *ApolloConfig) GetEnableFreeGasList(localEnableFreeGasList bool) bool
 	}
 	return localEnableFreeGasList
 }
+
+func (cfg *ApolloConfig) GetPriorityLanes(localPriorityLanes []ethconfig.PriorityLane) []ethconfig.PriorityLane {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
+	if cfg.isPoolEnabled() {
+		return cfg.EthCfg.DeprecatedTxPool.PriorityLanes
+	}
+	return localPriorityLanes
+}
+
+func (cfg *ApolloConfig) GetSenderQuotas(localSenderQuotas map[string]ethconfig.ThroughputQuota) map[string]ethconfig.ThroughputQuota {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
+	if cfg.isPoolEnabled() {
+		return cfg.EthCfg.DeprecatedTxPool.SenderQuotas
+	}
+	if cfg.isSeqEnabled() {
+		return mergeQuotas(localSenderQuotas, cfg.SeqQuotasCfg.SenderQuotas)
+	}
+	return localSenderQuotas
+}
+
+func (cfg *ApolloConfig) GetContractQuotas(localContractQuotas map[string]ethconfig.ThroughputQuota) map[string]ethconfig.ThroughputQuota {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
+	if cfg.isPoolEnabled() {
+		return cfg.EthCfg.DeprecatedTxPool.ContractQuotas
+	}
+	if cfg.isSeqEnabled() {
+		return mergeQuotas(localContractQuotas, cfg.SeqQuotasCfg.ContractQuotas)
+	}
+	return localContractQuotas
+}
+
+// mergeQuotas adds the quotas of the sequencer namespace to the local ones, which it does not overwrite
+func mergeQuotas(local, seq map[string]ethconfig.ThroughputQuota) map[string]ethconfig.ThroughputQuota {
+	if len(seq) == 0 {
+		return local
+	}
+	merged := make(map[string]ethconfig.ThroughputQuota, len(local)+len(seq))
+	for addr, quota := range local {
+		merged[quotaKey(addr)] = quota
+	}
+	for addr, quota := range seq {
+		if _, ok := merged[quotaKey(addr)]; !ok {
+			merged[quotaKey(addr)] = quota
+		}
+	}
+	return merged
+}
+
+// quotaKey returns the address of a quota in a single case, so that a local and a sequencer quota of the same address
+// are found the same
+func quotaKey(addr string) string {
+	if addr == ethconfig.AnyAddressQuota {
+		return addr
+	}
+	return libcommon.HexToAddress(addr).Hex()
+}
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
 )
 
 var testAddresses = make([]common.Address, 64)
@@ -85,3 +88,31 @@ func BenchmarkContainsAddressOld(b *testing.B) {
 		containsAddressOldImpl(localAddrStr, addr)
 	}
 }
+
+func TestSequencerQuotas(t *testing.T) {
+	local := map[string]ethconfig.ThroughputQuota{
+		"0x00000000000000000000000000000000000000aa": {MaxTxsPerBlock: 1},
+	}
+	cfg := &ApolloConfig{}
+
+	// the sequencer namespace off or setting no quota leaves the local ones
+	require.Equal(t, local, cfg.GetSenderQuotas(local))
+	cfg.EnableFlag = SequencerFlag
+	require.Equal(t, local, cfg.GetSenderQuotas(local))
+
+	// its quotas are added to the local ones without overwriting them
+	cfg.SeqQuotasCfg.SenderQuotas = map[string]ethconfig.ThroughputQuota{
+		"0x00000000000000000000000000000000000000AA": {MaxTxsPerBlock: 5},
+		ethconfig.AnyAddressQuota:                    {MaxTxsPerBlock: 10},
+	}
+	require.Equal(t, map[string]ethconfig.ThroughputQuota{
+		common.HexToAddress("0xaa").Hex(): {MaxTxsPerBlock: 1},
+		ethconfig.AnyAddressQuota:         {MaxTxsPerBlock: 10},
+	}, cfg.GetSenderQuotas(local))
+	require.Nil(t, cfg.GetContractQuotas(nil))
+
+	// the pool namespace takes over both
+	cfg.EnableFlag |= PoolFlag
+	cfg.EthCfg.DeprecatedTxPool.SenderQuotas = map[string]ethconfig.ThroughputQuota{ethconfig.AnyAddressQuota: {MaxTxsPerBlock: 3}}
+	require.Equal(t, cfg.EthCfg.DeprecatedTxPool.SenderQuotas, cfg.GetSenderQuotas(local))
+}
@@ -47,6 +47,7 @@ func loadSequencerConfig(ctx *cli.Context) {
 
 	loadNodeSequencerConfig(ctx, &UnsafeGetApolloConfig().NodeCfg)
 	loadEthSequencerConfig(ctx, &UnsafeGetApolloConfig().EthCfg)
+	loadSequencerQuotasConfig(ctx, &UnsafeGetApolloConfig().SeqQuotasCfg)
 }
 
 // loadNodeSequencerConfig loads the dynamic sequencer apollo node configurations
@@ -74,6 +75,13 @@ func loadEthSequencerConfig(ctx *cli.Context, ethCfg *ethconfig.Config) {
 	}
 }
 
+// loadSequencerQuotasConfig loads the throughput quotas of the sequencer apollo configurations, the ones it does not
+// set are left to the local configuration
+func loadSequencerQuotasConfig(ctx *cli.Context, cfg *ethconfig.DeprecatedTxPoolConfig) {
+	cfg.SenderQuotas, cfg.ContractQuotas = nil, nil
+	utils.SetApolloSequencerQuotasXLayer(ctx, cfg)
+}
+
 // setSequencerFlag sets the dynamic sequencer apollo flag
 func setSequencerFlag() {
 	UnsafeGetApolloConfig().Lock()
@@ -75,6 +75,15 @@ func loadZkConfig(ctx *cli.Context, ethCfg *ethconfig.Config) {
 	if ctx.IsSet(utils.L1QueryDelayFlag.Name) {
 		ethCfg.Zk.L1QueryDelay = ctx.Uint64(utils.L1QueryDelayFlag.Name)
 	}
+	if ctx.IsSet(utils.L1EndpointMaxLagFlag.Name) {
+		ethCfg.Zk.L1EndpointMaxLag = ctx.Uint64(utils.L1EndpointMaxLagFlag.Name)
+	}
+	if ctx.IsSet(utils.L1EndpointExclusionBackoffFlag.Name) {
+		ethCfg.Zk.L1EndpointExclusionBackoff = ctx.Duration(utils.L1EndpointExclusionBackoffFlag.Name)
+	}
+	if ctx.IsSet(utils.L1CrossCheckFlag.Name) {
+		ethCfg.Zk.L1CrossCheck = ctx.Bool(utils.L1CrossCheckFlag.Name)
+	}
 	if ctx.IsSet(utils.L1MaticContractAddressFlag.Name) {
 		ethCfg.Zk.L1MaticContractAddress = libcommon.HexToAddress(ctx.String(utils.L1MaticContractAddressFlag.Name))
 	}
@@ -93,6 +102,9 @@ func loadZkConfig(ctx *cli.Context, ethCfg *ethconfig.Config) {
 	if ctx.IsSet(utils.ExecutorUrls.Name) {
 		ethCfg.Zk.ExecutorUrls = strings.Split(ctx.String(utils.ExecutorUrls.Name), ",")
 	}
+	if ctx.IsSet(utils.StatelessVerification.Name) {
+		ethCfg.Zk.StatelessVerification = ctx.Bool(utils.StatelessVerification.Name)
+	}
 	if ctx.IsSet(utils.ExecutorStrictMode.Name) {
 		ethCfg.Zk.ExecutorStrictMode = ctx.Bool(utils.ExecutorStrictMode.Name)
 	}
@@ -102,6 +114,12 @@ func loadZkConfig(ctx *cli.Context, ethCfg *ethconfig.Config) {
 	if ctx.IsSet(utils.ExecutorMaxConcurrentRequests.Name) {
 		ethCfg.Zk.ExecutorMaxConcurrentRequests = ctx.Int(utils.ExecutorMaxConcurrentRequests.Name)
 	}
+	if ctx.IsSet(utils.ExecutorSlowRequestThreshold.Name) {
+		ethCfg.Zk.ExecutorSlowRequestThreshold = ctx.Duration(utils.ExecutorSlowRequestThreshold.Name)
+	}
+	if ctx.IsSet(utils.ExecutorUnhealthyBackoff.Name) {
+		ethCfg.Zk.ExecutorUnhealthyBackoff = ctx.Duration(utils.ExecutorUnhealthyBackoff.Name)
+	}
 	if ctx.IsSet(utils.AllowFreeTransactions.Name) {
 		ethCfg.Zk.AllowFreeTransactions = ctx.Bool(utils.AllowFreeTransactions.Name)
 	}
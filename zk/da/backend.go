@@ -0,0 +1,76 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+const (
+	BackendDAC       = "dac"
+	BackendCommittee = "committee"
+	BackendLocal     = "local"
+)
+
+var (
+	ErrHashMismatch    = errors.New("off chain data does not match the expected hash")
+	ErrUnknownBackend  = errors.New("unknown data availability backend")
+	ErrMissingUrl      = errors.New("data availability url is required")
+	ErrInvalidQuorum   = errors.New("data availability quorum must be between 1 and the number of committee members")
+	ErrMissingLocalDir = errors.New("data availability local path is required")
+)
+
+// DABackend fetches the off chain data of validium batches. Implementations must only return data whose keccak256
+// hash matches the requested hash.
+type DABackend interface {
+	GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error)
+}
+
+// Config selects and configures the DABackend used by the node
+type Config struct {
+	Backend     string   // one of BackendDAC, BackendCommittee or BackendLocal, defaults to BackendDAC
+	Url         string   // BackendDAC: the url of the data availability committee
+	Urls        []string // BackendCommittee: the urls of the committee members, tried in order
+	Quorum      int      // BackendCommittee: the number of members that have to serve the data
+	LocalPath   string   // BackendLocal: the directory holding the data
+	MaxAttempts int      // the number of attempts made against a rate limited member
+}
+
+// NewBackend creates the backend selected by the config. It returns nil without an error if no data availability
+// backend has been configured, which is fine for rollups.
+func NewBackend(cfg Config) (DABackend, error) {
+	switch cfg.Backend {
+	case "", BackendDAC:
+		if cfg.Url == "" {
+			return nil, nil
+		}
+		return NewJSONRPCBackend(cfg.Url, cfg.MaxAttempts), nil
+	case BackendCommittee:
+		if len(cfg.Urls) == 0 {
+			return nil, ErrMissingUrl
+		}
+		members := make([]DABackend, len(cfg.Urls))
+		for i, url := range cfg.Urls {
+			members[i] = NewJSONRPCBackend(url, cfg.MaxAttempts)
+		}
+		return NewCommitteeBackend(members, cfg.Quorum)
+	case BackendLocal:
+		if cfg.LocalPath == "" {
+			return nil, ErrMissingLocalDir
+		}
+		return NewLocalBackend(cfg.LocalPath), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, cfg.Backend)
+	}
+}
+
+// verifyOffChainData checks the data hashes to the expected hash
+func verifyOffChainData(hash common.Hash, data []byte) error {
+	if actual := crypto.Keccak256Hash(data); actual != hash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, hash.String(), actual.String())
+	}
+	return nil
+}
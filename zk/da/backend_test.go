@@ -0,0 +1,145 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBackend struct {
+	data  map[common.Hash][]byte
+	err   error
+	calls int
+}
+
+func (m *mockBackend) GetOffChainData(_ context.Context, hash common.Hash) ([]byte, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	data, ok := m.data[hash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func newDACServer(t *testing.T, data []byte) *httptest.Server {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"result":"0x%x"}`, data)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestJSONRPCBackend_VerifiesHash(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+
+	got, err := NewJSONRPCBackend(newDACServer(t, data).URL, 0).GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	_, err = NewJSONRPCBackend(newDACServer(t, []byte("tampered")).URL, 0).GetOffChainData(context.Background(), hash)
+	require.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestCommitteeBackend(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+
+	newMember := func() *mockBackend {
+		return &mockBackend{data: map[common.Hash][]byte{hash: data}}
+	}
+	down := func() *mockBackend {
+		return &mockBackend{err: errors.New("connection refused")}
+	}
+
+	t.Run("first member serving the data is enough", func(t *testing.T) {
+		first, second := newMember(), newMember()
+		b, err := NewCommitteeBackend([]DABackend{first, second}, 0)
+		require.NoError(t, err)
+
+		got, err := b.GetOffChainData(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+		require.Equal(t, 0, second.calls)
+	})
+
+	t.Run("survives a member being down", func(t *testing.T) {
+		b, err := NewCommitteeBackend([]DABackend{down(), newMember(), newMember()}, 2)
+		require.NoError(t, err)
+
+		got, err := b.GetOffChainData(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	})
+
+	t.Run("fails without quorum", func(t *testing.T) {
+		last := newMember()
+		b, err := NewCommitteeBackend([]DABackend{down(), down(), last}, 2)
+		require.NoError(t, err)
+
+		_, err = b.GetOffChainData(context.Background(), hash)
+		require.ErrorContains(t, err, "served by 0 of 2 required committee members")
+		require.ErrorContains(t, err, "connection refused")
+		// once the quorum can no longer be reached the remaining members are not asked
+		require.Equal(t, 0, last.calls)
+	})
+
+	t.Run("invalid quorum", func(t *testing.T) {
+		_, err := NewCommitteeBackend([]DABackend{newMember()}, 2)
+		require.ErrorIs(t, err, ErrInvalidQuorum)
+	})
+}
+
+func TestLocalBackend(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+	b := NewLocalBackend(t.TempDir())
+
+	_, err := b.GetOffChainData(context.Background(), hash)
+	require.ErrorContains(t, err, "no off chain data")
+
+	require.ErrorIs(t, b.Store(hash, []byte("tampered")), ErrHashMismatch)
+	require.NoError(t, b.Store(hash, data))
+
+	got, err := b.GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+}
+
+func TestNewBackend(t *testing.T) {
+	b, err := NewBackend(Config{})
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	b, err = NewBackend(Config{Url: "http://localhost:8444"})
+	require.NoError(t, err)
+	require.IsType(t, &JSONRPCBackend{}, b)
+
+	b, err = NewBackend(Config{Backend: BackendCommittee, Urls: []string{"http://a", "http://b"}, Quorum: 2})
+	require.NoError(t, err)
+	require.IsType(t, &CommitteeBackend{}, b)
+
+	b, err = NewBackend(Config{Backend: BackendLocal, LocalPath: t.TempDir()})
+	require.NoError(t, err)
+	require.IsType(t, &LocalBackend{}, b)
+
+	_, err = NewBackend(Config{Backend: BackendCommittee})
+	require.ErrorIs(t, err, ErrMissingUrl)
+	_, err = NewBackend(Config{Backend: BackendCommittee, Urls: []string{"http://a"}, Quorum: 2})
+	require.ErrorIs(t, err, ErrInvalidQuorum)
+	_, err = NewBackend(Config{Backend: BackendLocal})
+	require.ErrorIs(t, err, ErrMissingLocalDir)
+	_, err = NewBackend(Config{Backend: "ipfs"})
+	require.ErrorIs(t, err, ErrUnknownBackend)
+}
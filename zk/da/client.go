@@ -15,14 +15,53 @@ import (
 const maxAttempts = 10
 const retryDelay = 500 * time.Millisecond
 
+// JSONRPCBackend fetches off chain data from a data availability committee using sync_getOffChainData
+type JSONRPCBackend struct {
+	url         string
+	maxAttempts int
+}
+
+// NewJSONRPCBackend creates a backend for the committee at url. A rate limited request is retried up to maxAttempts
+// times, zero means the default number of attempts.
+func NewJSONRPCBackend(url string, maxAttempts int) *JSONRPCBackend {
+	return &JSONRPCBackend{
+		url:         url,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (b *JSONRPCBackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
+	data, err := getOffChainData(ctx, b.url, hash, b.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyOffChainData(hash, data); err != nil {
+		return nil, fmt.Errorf("DA url %s: %w", b.url, err)
+	}
+	return data, nil
+}
+
+// GetOffChainData fetches the off chain data for hash from the committee at url without checking it against the hash
 func GetOffChainData(ctx context.Context, url string, hash common.Hash) ([]byte, error) {
+	return getOffChainData(ctx, url, hash, maxAttempts)
+}
+
+func getOffChainData(ctx context.Context, url string, hash common.Hash, attempts int) ([]byte, error) {
+	if attempts <= 0 {
+		attempts = maxAttempts
+	}
+
 	attemp := 0
 
-	for attemp < maxAttempts {
+	for attemp < attempts {
 		response, err := client.JSONRPCCall(url, "sync_getOffChainData", hash)
 
 		if httpErr, ok := err.(*client.HTTPError); ok && httpErr.StatusCode == http.StatusTooManyRequests {
-			time.Sleep(retryDelay)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(retryDelay):
+			}
 			attemp += 1
 			continue
 		}
@@ -38,5 +77,5 @@ func GetOffChainData(ctx context.Context, url string, hash common.Hash) ([]byte,
 		return hexutil.Decode(strings.Trim(string(response.Result), "\""))
 	}
 
-	return nil, fmt.Errorf("max attempts of data fetching reached, attempts: %v, DA url: %s", maxAttempts, url)
+	return nil, fmt.Errorf("max attempts of data fetching reached, attempts: %v, DA url: %s", attempts, url)
 }
@@ -0,0 +1,67 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// CommitteeBackend asks the members of a data availability committee in order until quorum of them have served
+// data matching the hash, so that the node keeps syncing while some members are down
+type CommitteeBackend struct {
+	members []DABackend
+	quorum  int
+}
+
+// NewCommitteeBackend creates a backend over the given members. A quorum of zero means a single member is enough.
+func NewCommitteeBackend(members []DABackend, quorum int) (*CommitteeBackend, error) {
+	if quorum == 0 {
+		quorum = 1
+	}
+	if quorum < 0 || quorum > len(members) {
+		return nil, ErrInvalidQuorum
+	}
+	return &CommitteeBackend{
+		members: members,
+		quorum:  quorum,
+	}, nil
+}
+
+func (b *CommitteeBackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
+	var data []byte
+	var errs []error
+	served := 0
+
+	for i, member := range b.members {
+		if served == b.quorum {
+			break
+		}
+		if len(b.members)-i < b.quorum-served {
+			// not enough members left to reach the quorum
+			break
+		}
+
+		memberData, err := member.GetOffChainData(ctx, hash)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn("Data availability committee member failed to serve off chain data", "member", i, "hash", hash, "err", err)
+			errs = append(errs, fmt.Errorf("member %d: %w", i, err))
+			continue
+		}
+
+		// members verify the data against the hash, so all the data served is the same
+		data = memberData
+		served++
+	}
+
+	if served < b.quorum {
+		return nil, fmt.Errorf("off chain data for hash %s served by %d of %d required committee members: %w", hash.String(), served, b.quorum, errors.Join(errs...))
+	}
+
+	return data, nil
+}
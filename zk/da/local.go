@@ -0,0 +1,68 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+// LocalBackend serves off chain data from a directory holding one file per hash, named by the hex of the hash.
+// It is meant for replaying validium batches and for tests, where no committee is reachable.
+type LocalBackend struct {
+	dir string
+}
+
+func NewLocalBackend(dir string) *LocalBackend {
+	return &LocalBackend{
+		dir: dir,
+	}
+}
+
+func (b *LocalBackend) path(hash common.Hash) string {
+	return filepath.Join(b.dir, hash.Hex())
+}
+
+func (b *LocalBackend) GetOffChainData(_ context.Context, hash common.Hash) ([]byte, error) {
+	data, err := os.ReadFile(b.path(hash))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no off chain data for hash %s in %s", hash.String(), b.dir)
+		}
+		return nil, err
+	}
+	if err := verifyOffChainData(hash, data); err != nil {
+		return nil, fmt.Errorf("DA path %s: %w", b.path(hash), err)
+	}
+	return data, nil
+}
+
+// Store writes the off chain data for hash, e.g. to prepare the directory for a replay
+func (b *LocalBackend) Store(hash common.Hash, data []byte) error {
+	if err := verifyOffChainData(hash, data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that a reader never sees partial data
+	tmp, err := os.CreateTemp(b.dir, hash.Hex()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.path(hash))
+}
@@ -1,5 +1,7 @@
 package client
 
+import "github.com/ledgerwatch/erigon/zk/datastream/types"
+
 const (
 	// Commands
 	CmdUnknown Command = iota
@@ -9,6 +11,12 @@ const (
 	CmdStartBookmark // CmdStartBookmark for the start from bookmark TCP client command
 	CmdEntry         // CmdEntry for the get entry TCP client command
 	CmdBookmark      // CmdBookmark for the get bookmark TCP client command
+
+	// CmdAuth authenticates the client with a token, it must be the first command sent to a server requiring it
+	CmdAuth Command = 0xa0
+	// CmdStartBookmarkFiltered starts streaming from a bookmark like CmdStartBookmark, only sending the entries kept by
+	// the filter. It is served by the data stream gateway
+	CmdStartBookmarkFiltered Command = 0xa1
 )
 
 // sendHeaderCmd sends the header command to the server.
@@ -39,6 +47,26 @@ func (c *StreamClient) sendBookmarkCmd(bookmark []byte, streaming bool) error {
 	return c.writeToConn(bookmark)
 }
 
+// sendFilteredBookmarkCmd sends CmdStartBookmarkFiltered for the provided bookmark value and filter.
+func (c *StreamClient) sendFilteredBookmarkCmd(bookmark []byte, filter types.EntryFilter) error {
+	if err := c.sendCommand(CmdStartBookmarkFiltered); err != nil {
+		return err
+	}
+
+	// Send the filter
+	if err := c.writeToConn(uint64(filter)); err != nil {
+		return err
+	}
+
+	// Send bookmark length
+	if err := c.writeToConn(uint32(len(bookmark))); err != nil {
+		return err
+	}
+
+	// Send the bookmark to start from
+	return c.writeToConn(bookmark)
+}
+
 // sendStartCmd sends a start command to the server, indicating
 // that the client wishes to start streaming from the given entry number.
 func (c *StreamClient) sendStartCmd(from uint64) error {
@@ -76,3 +104,18 @@ func (c *StreamClient) sendCommand(cmd Command) error {
 	// Send stream type
 	return c.writeToConn(uint64(c.streamType))
 }
+
+// sendAuthCmd sends the auth command along with the token to the server.
+func (c *StreamClient) sendAuthCmd(token string) error {
+	if err := c.sendCommand(CmdAuth); err != nil {
+		return err
+	}
+
+	// Send token length
+	if err := c.writeToConn(uint32(len(token))); err != nil {
+		return err
+	}
+
+	// Send the token
+	return c.writeToConn([]byte(token))
+}
@@ -3,10 +3,13 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"net"
+	"os"
 	"reflect"
 	"sync/atomic"
 	"time"
@@ -73,6 +76,9 @@ type StreamClient struct {
 
 	useTLS    bool
 	tlsConfig *tls.Config
+
+	// sent right after connecting to servers requiring token authentication
+	authToken string
 }
 
 const (
@@ -113,6 +119,35 @@ func NewClient(ctx context.Context, server string, useTLS bool, version int, che
 	return c
 }
 
+// SetAuth configures how the client authenticates against a server requiring it. The token, if not empty, is sent
+// right after connecting. The certificate and key files, if set, are presented to servers requiring mutual TLS and
+// the CA file, if set, is used instead of the system roots to verify the server certificate.
+func (c *StreamClient) SetAuth(token, certFile, keyFile, caFile string) error {
+	c.authToken = token
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("load client certificate: %w", err)
+		}
+		c.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		c.tlsConfig.RootCAs = pool
+	}
+
+	return nil
+}
+
 func (c *StreamClient) IsVersion3() bool {
 	return c.version >= versionAddedBlockEnd
 }
@@ -306,6 +341,26 @@ func (c *StreamClient) Start() error {
 		return fmt.Errorf("connecting to server %s: %w", c.server, err)
 	}
 
+	if c.authToken != "" {
+		if err := c.authenticate(); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("authenticating to server %s: %w", c.server, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *StreamClient) authenticate() error {
+	if err := c.sendAuthCmd(c.authToken); err != nil {
+		return fmt.Errorf("sendAuthCmd: %w", err)
+	}
+
+	if _, err := c.readPacketAndDecodeResultEntry(); err != nil {
+		return fmt.Errorf("readPacketAndDecodeResultEntry: %w", err)
+	}
+
 	return nil
 }
 
@@ -617,6 +672,132 @@ LOOP:
 	return nil
 }
 
+// ReadEntriesToChannelFrom streams the entries from the batch or block of the bookmark on to the entry channel, so
+// clients can resume from a batch or block number rather than an entry number. Unless the filter is EntryFilterNone
+// the server, which must be a data stream gateway, only sends the entries kept by the filter: the blocks are then
+// sent without their transactions. Unlike ReadAllEntriesToChannel it keeps following the stream once at its end,
+// never sending the nil end of stream entry, until StopReadingToChannel is called or an error occurs.
+func (c *StreamClient) ReadEntriesToChannelFrom(from *types.BookmarkProto, filter types.EntryFilter) (err error) {
+	if !filter.IsValid() {
+		return fmt.Errorf("invalid entry filter %s", filter)
+	}
+
+	defer func() {
+		if err != nil {
+			c.setStreaming(false)
+			c.lastError = err
+		}
+	}()
+	select {
+	case <-c.ctx.Done():
+		return fmt.Errorf("context done - stopping")
+	default:
+	}
+	if err := c.stopStreamingIfStarted(); err != nil {
+		return fmt.Errorf("stopStreamingIfStarted: %w", err)
+	}
+
+	protoBookmark, err := from.Marshal()
+	if err != nil {
+		return fmt.Errorf("bookmark.Marshal: %w", err)
+	}
+
+	c.stopReadingToChannel.Store(false)
+	if filter == types.EntryFilterNone {
+		err = c.sendBookmarkCmd(protoBookmark, true)
+	} else {
+		err = c.sendFilteredBookmarkCmd(protoBookmark, filter)
+	}
+	if err != nil {
+		return fmt.Errorf("send start command: %w", err)
+	}
+	c.setStreaming(true)
+	if _, err := c.afterStartCommand(); err != nil {
+		return fmt.Errorf("afterStartCommand: %w", err)
+	}
+
+	iterator := &liveEntryIterator{c: c}
+	for {
+		parsedProto, _, err := ReadParsedProto(iterator)
+		if err != nil {
+			if errors.Is(err, errStoppedReading) {
+				return nil
+			}
+			return err
+		}
+		c.lastWrittenTime.Store(time.Now().UnixNano())
+
+		switch parsedProto := parsedProto.(type) {
+		case nil, *types.BookmarkProto:
+			continue
+		case *types.BatchStart:
+			c.currentFork = parsedProto.ForkId
+		case *types.GerUpdate:
+		case *types.BatchEnd:
+		case *types.FullL2Block:
+			parsedProto.ForkId = c.currentFork
+		default:
+			return fmt.Errorf("unexpected entry type: %v", parsedProto)
+		}
+
+		select {
+		case c.entryChan <- parsedProto:
+		case <-c.ctx.Done():
+			return fmt.Errorf("context done - stopping")
+		}
+	}
+}
+
+var errStoppedReading = errors.New("stopped reading")
+
+// liveEntryIterator reads the entries of a stream followed live, waiting for as long as it takes for the next one
+type liveEntryIterator struct {
+	c *StreamClient
+}
+
+func (it *liveEntryIterator) NextFileEntry() (*types.FileEntry, error) {
+	packet, err := it.c.waitForPacket()
+	if err != nil {
+		return nil, err
+	}
+	return it.c.readFileEntry(packet)
+}
+
+// GetEntryNumberLimit is never reached as the stream is followed past its current end
+func (it *liveEntryIterator) GetEntryNumberLimit() uint64 {
+	return math.MaxUint64
+}
+
+// waitForPacket reads the packet type of the next packet, read timeouts only meaning nothing was sent meanwhile
+func (c *StreamClient) waitForPacket() ([]byte, error) {
+	packet := make([]byte, 1)
+	for {
+		if c.stopReadingToChannel.Load() {
+			return nil, errStoppedReading
+		}
+		select {
+		case <-c.ctx.Done():
+			return nil, fmt.Errorf("context done - stopping")
+		default:
+		}
+
+		if err := c.resetReadTimeout(); err != nil {
+			return nil, fmt.Errorf("resetReadTimeout: %w", err)
+		}
+		n, err := c.conn.Read(packet)
+		if n == 1 {
+			return packet, nil
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return nil, fmt.Errorf("%w: conn.Read: %w", ErrSocket, err)
+		}
+	}
+}
+
 func (c *StreamClient) HandleStart() error {
 	if !c.started {
 		log.Info("[Datastream client] Starting datastream client from cold")
@@ -782,6 +963,11 @@ func (c *StreamClient) NextFileEntry() (file *types.FileEntry, err error) {
 		return file, fmt.Errorf("readBuffer: %w", err)
 	}
 
+	return c.readFileEntry(packet)
+}
+
+// reads the rest of the file entry which packet type has already been read
+func (c *StreamClient) readFileEntry(packet []byte) (file *types.FileEntry, err error) {
 	packetType := packet[0]
 	// Check packet type
 	if packetType == PtResult {
@@ -902,6 +1088,8 @@ func (c *StreamClient) readResultEntry(packet []byte) (re *types.ResultEntry, er
 			return re, fmt.Errorf("%w: %s", types.ErrBadFromBookmark, re.ErrorStr)
 		case types.CmdErrInvalidCommand:
 			return re, fmt.Errorf("%w: %s", types.ErrInvalidCommand, re.ErrorStr)
+		case types.CmdErrUnauthorized:
+			return re, fmt.Errorf("%w: %s", types.ErrUnauthorized, re.ErrorStr)
 		default:
 			return re, fmt.Errorf("unknown error code: %s", re.ErrorStr)
 		}
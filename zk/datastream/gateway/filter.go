@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	maxBookmarkLength = 1024
+	packetHeaderSize  = 5 // packet type and length, common to all the packets sent by the data stream server
+)
+
+var (
+	filteredEntries = metrics.GetOrCreateCounter(`datastream_gateway_filtered_entries_total`)
+
+	errPacketTooShort = errors.New("packet shorter than its header")
+)
+
+// filteringPipe is the pipe of gateways serving filtered subscriptions. It follows the commands of the client to turn
+// CmdStartBookmarkFiltered into CmdStartBookmark for the data stream server, and drops the data entries the current
+// subscription filtered out on their way back
+func (g *Gateway) filteringPipe(ctx context.Context, conn, upstream net.Conn, state *clientState) {
+	var filter atomic.Uint64
+	var writeMtx sync.Mutex // answers from us and packets from the data stream server both go to the client
+	done := make(chan struct{}, 2)
+
+	go func() {
+		if err := forwardCommands(conn, upstream, &filter, func(result *types.ResultEntry) error {
+			writeMtx.Lock()
+			defer writeMtx.Unlock()
+			return g.send(ctx, conn, state, result.Encode())
+		}); err != nil && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+			log.Debug("[datastream-gateway] Stopped forwarding commands", "remote", conn.RemoteAddr(), "err", err)
+		}
+		done <- struct{}{}
+	}()
+
+	go func() {
+		for {
+			packet, err := readPacket(upstream)
+			if err != nil {
+				break
+			}
+			if packet[0] == client.PtData && len(packet) >= int(types.FileEntryMinSize) {
+				entryType := types.EntryType(binary.BigEndian.Uint32(packet[packetHeaderSize:]))
+				if !types.EntryFilter(filter.Load()).Keeps(entryType) {
+					filteredEntries.Inc()
+					continue
+				}
+			}
+			writeMtx.Lock()
+			err = g.send(ctx, conn, state, packet)
+			writeMtx.Unlock()
+			if err != nil {
+				break
+			}
+		}
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	conn.Close()
+	upstream.Close()
+	<-done
+}
+
+// forwardCommands forwards the commands of the client to the data stream server, setting the filter of the entries
+// streamed from then on. Unknown commands are forwarded as they are along with everything after them, nothing
+// being filtered anymore
+func forwardCommands(conn, upstream net.Conn, filter *atomic.Uint64, answer func(*types.ResultEntry) error) error {
+	for {
+		header := make([]byte, 16) // command and stream type
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		}
+
+		var args []byte
+		var err error
+		starts, entryFilter := false, types.EntryFilterNone
+		switch cmd := client.Command(binary.BigEndian.Uint64(header[:8])); cmd {
+		case client.CmdStop, client.CmdHeader:
+		case client.CmdEntry:
+			args, err = readN(conn, 8)
+		case client.CmdStart:
+			args, err = readN(conn, 8)
+			starts = true
+		case client.CmdBookmark:
+			args, err = readBookmark(conn)
+		case client.CmdStartBookmark:
+			args, err = readBookmark(conn)
+			starts = true
+		case client.CmdStartBookmarkFiltered:
+			var rawFilter []byte
+			if rawFilter, err = readN(conn, 8); err != nil {
+				return err
+			}
+			if args, err = readBookmark(conn); err != nil {
+				return err
+			}
+			entryFilter = types.EntryFilter(binary.BigEndian.Uint64(rawFilter))
+			if !entryFilter.IsValid() {
+				errStr := []byte(fmt.Sprintf("invalid entry filter %d", uint64(entryFilter)))
+				if err := answer(&types.ResultEntry{
+					PacketType: client.PtResult,
+					Length:     types.ResultEntryMinSize + uint32(len(errStr)),
+					ErrorNum:   types.CmdErrInvalidCommand,
+					ErrorStr:   errStr,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			binary.BigEndian.PutUint64(header[:8], uint64(client.CmdStartBookmark))
+			starts = true
+		default:
+			filter.Store(uint64(types.EntryFilterNone))
+			if _, err := upstream.Write(header); err != nil {
+				return err
+			}
+			_, err := io.Copy(upstream, conn)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		// the filter applies to the entries of the stream started by the command
+		if starts {
+			filter.Store(uint64(entryFilter))
+		}
+
+		if _, err := upstream.Write(append(header, args...)); err != nil {
+			return err
+		}
+	}
+}
+
+func readN(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readBookmark reads a bookmark along with its length
+func readBookmark(r io.Reader) ([]byte, error) {
+	rawLength, err := readN(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(rawLength)
+	if length > maxBookmarkLength {
+		return nil, fmt.Errorf("bookmark longer than %d bytes", maxBookmarkLength)
+	}
+	bookmark, err := readN(r, int(length))
+	if err != nil {
+		return nil, err
+	}
+	return append(rawLength, bookmark...), nil
+}
+
+// readPacket reads a whole packet from the data stream server, all of them starting with their type and length
+func readPacket(r io.Reader) ([]byte, error) {
+	header, err := readN(r, packetHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < packetHeaderSize {
+		return nil, errPacketTooShort
+	}
+	rest, err := readN(r, int(length-packetHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, rest...), nil
+}
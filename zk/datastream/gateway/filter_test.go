@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/stretchr/testify/require"
+)
+
+type marshaller interface {
+	Marshal() ([]byte, error)
+	Type() types.EntryType
+}
+
+// testBatchEntries returns the entries of a batch with a block of two transactions
+func testBatchEntries(t *testing.T) [][]byte {
+	protos := []marshaller{
+		&types.BatchStartProto{BatchStart: &datastream.BatchStart{Number: 1, ForkId: 9}},
+		&types.L2BlockProto{L2Block: &datastream.L2Block{Number: 1, BatchNumber: 1}},
+		&types.TxProto{Transaction: &datastream.Transaction{L2BlockNumber: 1, Index: 0}},
+		&types.TxProto{Transaction: &datastream.Transaction{L2BlockNumber: 1, Index: 1}},
+		&types.L2BlockEndProto{Number: 1},
+		&types.BatchEndProto{BatchEnd: &datastream.BatchEnd{Number: 1, StateRoot: common.HexToHash("0x1").Bytes()}},
+	}
+
+	entries := make([][]byte, len(protos))
+	for i, p := range protos {
+		data, err := p.Marshal()
+		require.NoError(t, err)
+		entry := &types.FileEntry{
+			PacketType: client.PtData,
+			Length:     types.FileEntryMinSize + uint32(len(data)),
+			EntryType:  p.Type(),
+			EntryNum:   uint64(i + 1),
+			Data:       data,
+		}
+		entries[i] = entry.Encode()
+	}
+	return entries
+}
+
+func okResult() []byte {
+	result := &types.ResultEntry{PacketType: client.PtResult, Length: types.ResultEntryMinSize, ErrorNum: types.CmdErrOK}
+	return result.Encode()
+}
+
+// startStreamUpstream starts a data stream server sending the entries on every start bookmark command
+func startStreamUpstream(t *testing.T, entries [][]byte) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					header := make([]byte, 16)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					switch client.Command(binary.BigEndian.Uint64(header)) {
+					case client.CmdStartBookmark:
+						if _, err := readBookmark(conn); err != nil {
+							return
+						}
+						conn.Write(okResult())
+						for _, entry := range entries {
+							conn.Write(entry)
+						}
+					case client.CmdStop:
+						conn.Write(okResult())
+					default:
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func subscribe(t *testing.T, addr string, filter types.EntryFilter, count int) []interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := client.NewClient(ctx, addr, false, 0, 500*time.Millisecond, 0)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.ReadEntriesToChannelFrom(types.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_BATCH), filter)
+	}()
+
+	entries := make([]interface{}, 0, count)
+	timeout := time.After(5 * time.Second)
+	for len(entries) < count {
+		select {
+		case entry := <-*c.GetEntryChan():
+			entries = append(entries, entry)
+		case err := <-errCh:
+			t.Fatalf("subscription ended: %v", err)
+		case <-timeout:
+			t.Fatalf("timed out with %d entries", len(entries))
+		}
+	}
+
+	c.StopReadingToChannel()
+	require.NoError(t, <-errCh)
+	return entries
+}
+
+func TestGateway_EntryFiltering(t *testing.T) {
+	g, err := New(Config{
+		ListenAddr:     "127.0.0.1:0",
+		UpstreamAddr:   startStreamUpstream(t, testBatchEntries(t)),
+		EntryFiltering: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, g.Start(context.Background()))
+	t.Cleanup(g.Stop)
+	addr := g.Addr().String()
+
+	t.Run("batches", func(t *testing.T) {
+		entries := subscribe(t, addr, types.EntryFilterBatches, 2)
+		require.IsType(t, &types.BatchStart{}, entries[0])
+		require.Equal(t, uint64(9), entries[0].(*types.BatchStart).ForkId)
+		require.IsType(t, &types.BatchEnd{}, entries[1])
+		require.Equal(t, common.HexToHash("0x1"), entries[1].(*types.BatchEnd).StateRoot)
+	})
+
+	t.Run("blocks", func(t *testing.T) {
+		entries := subscribe(t, addr, types.EntryFilterBlocks, 3)
+		require.IsType(t, &types.BatchStart{}, entries[0])
+		block, ok := entries[1].(*types.FullL2Block)
+		require.True(t, ok)
+		require.Equal(t, uint64(1), block.L2BlockNumber)
+		require.Equal(t, uint64(9), block.ForkId)
+		require.Empty(t, block.L2Txs)
+		require.IsType(t, &types.BatchEnd{}, entries[2])
+	})
+
+	t.Run("none", func(t *testing.T) {
+		entries := subscribe(t, addr, types.EntryFilterNone, 3)
+		block, ok := entries[1].(*types.FullL2Block)
+		require.True(t, ok)
+		require.Len(t, block.L2Txs, 2)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		cmd := binary.BigEndian.AppendUint64(nil, uint64(client.CmdStartBookmarkFiltered))
+		cmd = binary.BigEndian.AppendUint64(cmd, uint64(client.StSequencer))
+		cmd = binary.BigEndian.AppendUint64(cmd, 42)
+		cmd = binary.BigEndian.AppendUint32(cmd, 0)
+		_, err = conn.Write(cmd)
+		require.NoError(t, err)
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		packet, err := readPacket(conn)
+		require.NoError(t, err)
+		result, err := types.DecodeResultEntry(packet)
+		require.NoError(t, err)
+		require.Equal(t, uint32(types.CmdErrInvalidCommand), result.ErrorNum)
+	})
+}
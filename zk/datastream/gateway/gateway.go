@@ -0,0 +1,589 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/log/v3"
+	"golang.org/x/time/rate"
+)
+
+const (
+	authTimeout    = 10 * time.Second
+	exposedTimeout = time.Second
+	maxTokenLength = 1024
+	copyBufferSize = 32 * 1024
+)
+
+var (
+	ErrMissingUpstream   = errors.New("missing upstream address")
+	ErrMissingTLSKeyPair = errors.New("both a TLS certificate and key are required")
+	ErrClientCANeedsTLS  = errors.New("a client CA requires a TLS certificate and key")
+	ErrUpstreamExposed   = errors.New("the upstream is reachable on a non-loopback address, clients could get around the gateway")
+
+	errUnauthorized    = errors.New("invalid token")
+	errNotAllowed      = errors.New("client not allowed")
+	errConnectionLimit = errors.New("too many connections")
+
+	rejectedUnauthorized    = metrics.GetOrCreateCounter(`datastream_gateway_rejected_total{reason="unauthorized"}`)
+	rejectedNotAllowed      = metrics.GetOrCreateCounter(`datastream_gateway_rejected_total{reason="not_allowed"}`)
+	rejectedConnectionLimit = metrics.GetOrCreateCounter(`datastream_gateway_rejected_total{reason="connection_limit"}`)
+)
+
+// Config of the gateway put in front of the data stream server to authenticate its clients and limit their usage
+type Config struct {
+	ListenAddr   string // address the clients connect to
+	UpstreamAddr string // address of the data stream server behind the gateway
+
+	// serve the clients over TLS, requiring them to present a certificate signed by the client CA if set
+	TLSCertFile  string
+	TLSKeyFile   string
+	ClientCAFile string
+
+	// file with a "<client identity> <token>" pair per line. If set clients must authenticate with one of the tokens
+	TokensFile string
+
+	// identities allowed to connect, any authenticated client is allowed if empty. A client is identified by its
+	// token if tokens are used, else by the common name of its certificate if a client CA is set, else by its IP
+	Allowlist []string
+
+	MaxConnectionsPerClient int               // 0 means no limit
+	ClientRateLimit         datasize.ByteSize // bytes per second sent to each client, 0 means no limit
+
+	// serve the filtered subscriptions started with CmdStartBookmarkFiltered
+	EntryFiltering bool
+}
+
+// Enabled reports whether any of the gateway features are configured
+func (c Config) Enabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != "" || c.ClientCAFile != "" || c.TokensFile != "" ||
+		len(c.Allowlist) > 0 || c.MaxConnectionsPerClient > 0 || c.ClientRateLimit > 0 || c.EntryFiltering
+}
+
+// ClientInfo describes a client connected through the gateway
+type ClientInfo struct {
+	Identity    string
+	Connections int
+	BytesSent   uint64
+}
+
+type clientState struct {
+	connections int
+	conns       map[net.Conn]struct{}
+	limiter     *rate.Limiter
+
+	connectionsGauge metrics.Gauge
+	bytesSent        metrics.Counter
+}
+
+// Gateway is a TCP proxy authenticating the data stream clients before piping them to the data stream server
+type Gateway struct {
+	cfg       Config
+	tlsConfig *tls.Config
+	tokens    map[string]string // token -> identity
+	allowlist map[string]struct{}
+
+	mtx     sync.Mutex
+	clients map[string]*clientState
+
+	ln     net.Listener
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cfg Config) (*Gateway, error) {
+	if cfg.UpstreamAddr == "" {
+		return nil, ErrMissingUpstream
+	}
+
+	g := &Gateway{
+		cfg:     cfg,
+		clients: make(map[string]*clientState),
+	}
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, ErrMissingTLSKeyPair
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		g.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	if cfg.ClientCAFile != "" {
+		if g.tlsConfig == nil {
+			return nil, ErrClientCANeedsTLS
+		}
+		caPem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+		g.tlsConfig.ClientCAs = pool
+		g.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if cfg.TokensFile != "" {
+		tokens, err := LoadTokens(cfg.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		g.tokens = tokens
+	}
+
+	if len(cfg.Allowlist) > 0 {
+		g.allowlist = make(map[string]struct{}, len(cfg.Allowlist))
+		for _, identity := range cfg.Allowlist {
+			g.allowlist[identity] = struct{}{}
+		}
+	}
+
+	return g, nil
+}
+
+// LoadTokens reads a file with a "<client identity> <token>" pair per line, empty lines and lines starting with #
+// are skipped
+func LoadTokens(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read tokens file: %w", err)
+	}
+
+	tokens := make(map[string]string)
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("tokens file %s line %d: expected \"<client identity> <token>\"", file, i+1)
+		}
+		if len(fields[1]) > maxTokenLength {
+			return nil, fmt.Errorf("tokens file %s line %d: token longer than %d bytes", file, i+1, maxTokenLength)
+		}
+		if _, found := tokens[fields[1]]; found {
+			return nil, fmt.Errorf("tokens file %s line %d: duplicated token", file, i+1)
+		}
+		tokens[fields[1]] = fields[0]
+	}
+
+	return tokens, nil
+}
+
+// Start listens for clients and serves them in the background until Stop is called
+func (g *Gateway) Start(ctx context.Context) error {
+	// the gateway is only worth anything if the upstream cannot be reached without going through it
+	if err := checkUpstreamLoopbackOnly(g.cfg.UpstreamAddr); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", g.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", g.cfg.ListenAddr, err)
+	}
+	if g.tlsConfig != nil {
+		ln = tls.NewListener(ln, g.tlsConfig)
+	}
+	g.ln = ln
+
+	ctx, g.cancel = context.WithCancel(ctx)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		g.acceptLoop(ctx)
+	}()
+
+	log.Info("[datastream-gateway] Listening", "addr", ln.Addr(), "tls", g.tlsConfig != nil, "mtls", g.cfg.ClientCAFile != "", "tokens", len(g.tokens), "allowlist", len(g.allowlist), "filtering", g.cfg.EntryFiltering)
+	return nil
+}
+
+// checkUpstreamLoopbackOnly returns ErrUpstreamExposed if the upstream port accepts connections on any of the
+// non-loopback addresses of the host
+func checkUpstreamLoopbackOnly(upstreamAddr string) error {
+	_, port, err := net.SplitHostPort(upstreamAddr)
+	if err != nil {
+		return fmt.Errorf("upstream address %s: %w", upstreamAddr, err)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("list the interface addresses: %w", err)
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		// link local addresses cannot be dialled without their zone
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		exposedAddr := net.JoinHostPort(ipNet.IP.String(), port)
+		conn, err := net.DialTimeout("tcp", exposedAddr, exposedTimeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrUpstreamExposed, exposedAddr)
+	}
+
+	return nil
+}
+
+// Addr returns the address the gateway listens on, nil if not started
+func (g *Gateway) Addr() net.Addr {
+	if g.ln == nil {
+		return nil
+	}
+	return g.ln.Addr()
+}
+
+// Stop closes the listener and all the client connections
+func (g *Gateway) Stop() {
+	if g.cancel == nil {
+		return
+	}
+	g.cancel()
+	g.ln.Close()
+
+	g.mtx.Lock()
+	for _, state := range g.clients {
+		for conn := range state.conns {
+			conn.Close()
+		}
+	}
+	g.mtx.Unlock()
+
+	g.wg.Wait()
+}
+
+// Clients returns the clients currently connected
+func (g *Gateway) Clients() []ClientInfo {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	clients := make([]ClientInfo, 0, len(g.clients))
+	for identity, state := range g.clients {
+		if state.connections == 0 {
+			continue
+		}
+		clients = append(clients, ClientInfo{
+			Identity:    identity,
+			Connections: state.connections,
+			BytesSent:   state.bytesSent.GetValueUint64(),
+		})
+	}
+	return clients
+}
+
+// Disconnect closes all the connections of a client, returning how many were closed
+func (g *Gateway) Disconnect(identity string) int {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	state, found := g.clients[identity]
+	if !found {
+		return 0
+	}
+	for conn := range state.conns {
+		conn.Close()
+	}
+	return len(state.conns)
+}
+
+func (g *Gateway) acceptLoop(ctx context.Context) {
+	for {
+		conn, err := g.ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("[datastream-gateway] Failed to accept connection", "err", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			g.handle(ctx, conn)
+		}()
+	}
+}
+
+func (g *Gateway) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	identity, err := g.authenticate(conn)
+	if err != nil {
+		log.Debug("[datastream-gateway] Rejected client", "remote", conn.RemoteAddr(), "identity", identity, "err", err)
+		return
+	}
+
+	state, err := g.register(identity, conn)
+	if err != nil {
+		log.Debug("[datastream-gateway] Rejected client", "remote", conn.RemoteAddr(), "identity", identity, "err", err)
+		if g.tokens != nil {
+			g.writeResult(conn, err)
+		}
+		return
+	}
+	defer g.unregister(identity, conn)
+
+	upstream, err := net.Dial("tcp", g.cfg.UpstreamAddr)
+	if err != nil {
+		log.Warn("[datastream-gateway] Failed to connect to the data stream server", "upstream", g.cfg.UpstreamAddr, "err", err)
+		return
+	}
+	defer upstream.Close()
+
+	if g.tokens != nil {
+		// the auth command is answered by us, everything after goes to the data stream server
+		if err := g.writeResult(conn, nil); err != nil {
+			return
+		}
+	}
+
+	log.Info("[datastream-gateway] Client connected", "remote", conn.RemoteAddr(), "identity", identity)
+	if g.cfg.EntryFiltering {
+		g.filteringPipe(ctx, conn, upstream, state)
+	} else {
+		g.pipe(ctx, conn, upstream, state)
+	}
+	log.Info("[datastream-gateway] Client disconnected", "remote", conn.RemoteAddr(), "identity", identity)
+}
+
+// authenticate resolves the identity of the client, reading its token if tokens are used, and checks it is allowed
+func (g *Gateway) authenticate(conn net.Conn) (string, error) {
+	if err := conn.SetDeadline(time.Now().Add(authTimeout)); err != nil {
+		return "", err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	var identity string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return "", fmt.Errorf("TLS handshake: %w", err)
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			identity = certs[0].Subject.CommonName
+		}
+	}
+
+	if g.tokens != nil {
+		token, err := readAuthCommand(conn)
+		if err != nil {
+			rejectedUnauthorized.Inc()
+			return "", err
+		}
+		tokenIdentity, found := g.lookupToken(token)
+		if !found {
+			rejectedUnauthorized.Inc()
+			g.writeResult(conn, errUnauthorized)
+			return "", errUnauthorized
+		}
+		identity = tokenIdentity
+	} else if identity == "" {
+		host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			host = conn.RemoteAddr().String()
+		}
+		identity = host
+	}
+
+	if g.allowlist != nil {
+		if _, allowed := g.allowlist[identity]; !allowed {
+			rejectedNotAllowed.Inc()
+			if g.tokens != nil {
+				g.writeResult(conn, errNotAllowed)
+			}
+			return identity, errNotAllowed
+		}
+	}
+
+	return identity, nil
+}
+
+// lookupToken compares the token against all the known ones in constant time
+func (g *Gateway) lookupToken(token string) (string, bool) {
+	var identity string
+	found := false
+	for known, knownIdentity := range g.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			identity, found = knownIdentity, true
+		}
+	}
+	return identity, found
+}
+
+// readAuthCommand reads the auth command the client must send first: the command, the stream type, the token length
+// and the token
+func readAuthCommand(conn net.Conn) (string, error) {
+	header := make([]byte, 8+8+4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("read auth command: %w", err)
+	}
+	if cmd := client.Command(binary.BigEndian.Uint64(header[:8])); cmd != client.CmdAuth {
+		return "", fmt.Errorf("expected the auth command, got %d", cmd)
+	}
+	length := binary.BigEndian.Uint32(header[16:])
+	if length > maxTokenLength {
+		return "", fmt.Errorf("token longer than %d bytes", maxTokenLength)
+	}
+	token := make([]byte, length)
+	if _, err := io.ReadFull(conn, token); err != nil {
+		return "", fmt.Errorf("read token: %w", err)
+	}
+	return string(token), nil
+}
+
+// writeResult answers the auth command, a nil error meaning success
+func (g *Gateway) writeResult(conn net.Conn, err error) error {
+	result := &types.ResultEntry{
+		PacketType: client.PtResult,
+		ErrorNum:   types.CmdErrOK,
+	}
+	if err != nil {
+		result.ErrorNum = types.CmdErrUnauthorized
+		result.ErrorStr = []byte(err.Error())
+	}
+	result.Length = types.ResultEntryMinSize + uint32(len(result.ErrorStr))
+
+	if err := conn.SetWriteDeadline(time.Now().Add(authTimeout)); err != nil {
+		return err
+	}
+	defer conn.SetWriteDeadline(time.Time{})
+	_, err = conn.Write(result.Encode())
+	return err
+}
+
+func (g *Gateway) register(identity string, conn net.Conn) (*clientState, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	state, found := g.clients[identity]
+	if !found {
+		label := metricLabel(identity)
+		state = &clientState{
+			conns:            make(map[net.Conn]struct{}),
+			connectionsGauge: metrics.GetOrCreateGauge(fmt.Sprintf(`datastream_gateway_client_connections{client="%s"}`, label)),
+			bytesSent:        metrics.GetOrCreateCounter(fmt.Sprintf(`datastream_gateway_client_bytes_sent_total{client="%s"}`, label)),
+		}
+		if g.cfg.ClientRateLimit > 0 {
+			burst := int(g.cfg.ClientRateLimit.Bytes())
+			if burst < copyBufferSize {
+				burst = copyBufferSize
+			}
+			state.limiter = rate.NewLimiter(rate.Limit(g.cfg.ClientRateLimit.Bytes()), burst)
+		}
+		g.clients[identity] = state
+	}
+
+	if g.cfg.MaxConnectionsPerClient > 0 && state.connections >= g.cfg.MaxConnectionsPerClient {
+		rejectedConnectionLimit.Inc()
+		return nil, errConnectionLimit
+	}
+
+	state.connections++
+	state.conns[conn] = struct{}{}
+	state.connectionsGauge.SetInt(state.connections)
+	return state, nil
+}
+
+func (g *Gateway) unregister(identity string, conn net.Conn) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	state := g.clients[identity]
+	state.connections--
+	delete(state.conns, conn)
+	state.connectionsGauge.SetInt(state.connections)
+}
+
+// pipe copies the data both ways until either side closes, rate limiting what is sent to the client
+func (g *Gateway) pipe(ctx context.Context, conn, upstream net.Conn, state *clientState) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		buf := make([]byte, copyBufferSize)
+		for {
+			n, err := upstream.Read(buf)
+			if n > 0 {
+				if werr := g.send(ctx, conn, state, buf[:n]); werr != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	// closing both ends unblocks the other copy
+	conn.Close()
+	upstream.Close()
+	<-done
+}
+
+// send writes the data to the client once the rate limiter allows it
+func (g *Gateway) send(ctx context.Context, conn net.Conn, state *clientState, data []byte) error {
+	for len(data) > 0 {
+		// the burst of the limiter is at least the copy buffer size
+		chunk := data
+		if len(chunk) > copyBufferSize {
+			chunk = chunk[:copyBufferSize]
+		}
+		if state.limiter != nil {
+			if err := state.limiter.WaitN(ctx, len(chunk)); err != nil {
+				return err
+			}
+		}
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+		state.bytesSent.AddInt(len(chunk))
+		data = data[len(chunk):]
+	}
+	return nil
+}
+
+// metricLabel keeps the identity safe to use as a metric label value
+func metricLabel(identity string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r == '\n' {
+			return '_'
+		}
+		return r
+	}, identity)
+}
@@ -0,0 +1,366 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/stretchr/testify/require"
+)
+
+// startUpstream starts a server on addr answering "pong" to every 8 bytes it receives
+func startUpstream(t *testing.T, addr string) string {
+	ln, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 8)
+				for {
+					if _, err := io.ReadFull(conn, buf); err != nil {
+						return
+					}
+					if _, err := conn.Write([]byte("pong")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func startGateway(t *testing.T, cfg Config) *Gateway {
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.UpstreamAddr = startUpstream(t, "127.0.0.1:0")
+	g, err := New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, g.Start(context.Background()))
+	t.Cleanup(g.Stop)
+	return g
+}
+
+func writeTokensFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func authenticate(t *testing.T, conn net.Conn, token string) *types.ResultEntry {
+	cmd := binary.BigEndian.AppendUint64(nil, uint64(client.CmdAuth))
+	cmd = binary.BigEndian.AppendUint64(cmd, uint64(client.StSequencer))
+	cmd = binary.BigEndian.AppendUint32(cmd, uint32(len(token)))
+	cmd = append(cmd, token...)
+	_, err := conn.Write(cmd)
+	require.NoError(t, err)
+
+	header := make([]byte, types.ResultEntryMinSize)
+	_, err = io.ReadFull(conn, header)
+	require.NoError(t, err)
+	rest := make([]byte, binary.BigEndian.Uint32(header[1:5])-types.ResultEntryMinSize)
+	_, err = io.ReadFull(conn, rest)
+	require.NoError(t, err)
+
+	result, err := types.DecodeResultEntry(append(header, rest...))
+	require.NoError(t, err)
+	return result
+}
+
+func ping(conn net.Conn) (string, error) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(make([]byte, 8)); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func TestGateway_Tokens(t *testing.T) {
+	g := startGateway(t, Config{
+		TokensFile: writeTokensFile(t, "# partners\npartner-a secret-a\npartner-b secret-b\n"),
+		Allowlist:  []string{"partner-a"},
+	})
+
+	conn, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.True(t, authenticate(t, conn, "secret-a").IsOk())
+	pong, err := ping(conn)
+	require.NoError(t, err)
+	require.Equal(t, "pong", pong)
+
+	require.Eventually(t, func() bool {
+		clients := g.Clients()
+		return len(clients) == 1 && clients[0].Identity == "partner-a" && clients[0].BytesSent > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a valid token of a client that is not in the allowlist
+	conn2, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn2.Close()
+	result := authenticate(t, conn2, "secret-b")
+	require.Equal(t, uint32(types.CmdErrUnauthorized), result.ErrorNum)
+
+	// an unknown token
+	conn3, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn3.Close()
+	result = authenticate(t, conn3, "nope")
+	require.Equal(t, uint32(types.CmdErrUnauthorized), result.ErrorNum)
+
+	// the stream client authenticates on start
+	c := client.NewClient(context.Background(), g.Addr().String(), false, 3, time.Second, 0)
+	require.NoError(t, c.SetAuth("secret-a", "", "", ""))
+	require.NoError(t, c.Start())
+
+	c = client.NewClient(context.Background(), g.Addr().String(), false, 3, time.Second, 0)
+	require.NoError(t, c.SetAuth("nope", "", "", ""))
+	require.ErrorIs(t, c.Start(), types.ErrUnauthorized)
+
+	// disconnecting the client closes its connections
+	require.Equal(t, 2, g.Disconnect("partner-a"))
+	_, err = ping(conn)
+	require.Error(t, err)
+}
+
+func TestGateway_ConnectionLimitAndAllowlist(t *testing.T) {
+	g := startGateway(t, Config{
+		Allowlist:               []string{"127.0.0.1"},
+		MaxConnectionsPerClient: 1,
+	})
+
+	conn, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = ping(conn)
+	require.NoError(t, err)
+
+	conn2, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn2.Close()
+	_, err = ping(conn2)
+	require.Error(t, err)
+
+	clients := g.Clients()
+	require.Len(t, clients, 1)
+	require.Equal(t, "127.0.0.1", clients[0].Identity)
+	require.Equal(t, 1, clients[0].Connections)
+
+	// once the first connection is gone a new one is accepted
+	conn.Close()
+	require.Eventually(t, func() bool { return len(g.Clients()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	conn3, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn3.Close()
+	_, err = ping(conn3)
+	require.NoError(t, err)
+
+	notAllowed := startGateway(t, Config{Allowlist: []string{"10.0.0.1"}})
+	conn4, err := net.Dial("tcp", notAllowed.Addr().String())
+	require.NoError(t, err)
+	defer conn4.Close()
+	_, err = ping(conn4)
+	require.Error(t, err)
+}
+
+func TestGateway_RateLimit(t *testing.T) {
+	g := startGateway(t, Config{ClientRateLimit: 64 * 1024})
+
+	conn, err := net.Dial("tcp", g.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the burst covers the first responses so this only checks the data still flows through the limiter
+	for i := 0; i < 10; i++ {
+		pong, err := ping(conn)
+		require.NoError(t, err)
+		require.Equal(t, "pong", pong)
+	}
+}
+
+type testPKI struct {
+	caFile, serverCertFile, serverKeyFile, clientCertFile, clientKeyFile string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	dir := t.TempDir()
+
+	writePem := func(name, typ string, der []byte) string {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+		return file
+	}
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		return key
+	}
+	keyDer := func(key *ecdsa.PrivateKey) []byte {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return der
+	}
+
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDer)
+	require.NoError(t, err)
+
+	issue := func(serial int64, cn string, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key := newKey()
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		return der, key
+	}
+
+	serverDer, serverKey := issue(2, "127.0.0.1", x509.ExtKeyUsageServerAuth)
+	clientDer, clientKey := issue(3, "partner-a", x509.ExtKeyUsageClientAuth)
+
+	return testPKI{
+		caFile:         writePem("ca.pem", "CERTIFICATE", caDer),
+		serverCertFile: writePem("server.pem", "CERTIFICATE", serverDer),
+		serverKeyFile:  writePem("server.key", "EC PRIVATE KEY", keyDer(serverKey)),
+		clientCertFile: writePem("client.pem", "CERTIFICATE", clientDer),
+		clientKeyFile:  writePem("client.key", "EC PRIVATE KEY", keyDer(clientKey)),
+	}
+}
+
+func TestGateway_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	g := startGateway(t, Config{
+		TLSCertFile:  pki.serverCertFile,
+		TLSKeyFile:   pki.serverKeyFile,
+		ClientCAFile: pki.caFile,
+		Allowlist:    []string{"partner-a"},
+	})
+
+	rootCAs := x509.NewCertPool()
+	caPem, err := os.ReadFile(pki.caFile)
+	require.NoError(t, err)
+	rootCAs.AppendCertsFromPEM(caPem)
+
+	cert, err := tls.LoadX509KeyPair(pki.clientCertFile, pki.clientKeyFile)
+	require.NoError(t, err)
+	conn, err := tls.Dial("tcp", g.Addr().String(), &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer conn.Close()
+	pong, err := ping(conn)
+	require.NoError(t, err)
+	require.Equal(t, "pong", pong)
+
+	clients := g.Clients()
+	require.Len(t, clients, 1)
+	require.Equal(t, "partner-a", clients[0].Identity)
+
+	// without a client certificate the handshake fails
+	conn2, err := tls.Dial("tcp", g.Addr().String(), &tls.Config{RootCAs: rootCAs})
+	if err == nil {
+		defer conn2.Close()
+		_, err = ping(conn2)
+	}
+	require.Error(t, err)
+
+	// the stream client presents its certificate
+	c := client.NewClient(context.Background(), g.Addr().String(), true, 3, time.Second, 0)
+	require.NoError(t, c.SetAuth("", pki.clientCertFile, pki.clientKeyFile, pki.caFile))
+	require.NoError(t, c.Start())
+}
+
+func TestGateway_UpstreamLoopbackOnly(t *testing.T) {
+	var exposedIP net.IP
+	addrs, err := net.InterfaceAddrs()
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() {
+			exposedIP = ipNet.IP
+			break
+		}
+	}
+	if exposedIP == nil {
+		t.Skip("no non-loopback address to check the upstream against")
+	}
+
+	// an upstream on the loopback is not reachable on the other addresses of the host
+	g := startGateway(t, Config{})
+	_, port, err := net.SplitHostPort(g.cfg.UpstreamAddr)
+	require.NoError(t, err)
+	_, err = net.DialTimeout("tcp", net.JoinHostPort(exposedIP.String(), port), time.Second)
+	require.Error(t, err)
+
+	// an upstream listening on every interface is, so the gateway refuses to start
+	upstreamAddr := startUpstream(t, ":0")
+	_, port, err = net.SplitHostPort(upstreamAddr)
+	require.NoError(t, err)
+	g, err = New(Config{ListenAddr: "127.0.0.1:0", UpstreamAddr: net.JoinHostPort("127.0.0.1", port)})
+	require.NoError(t, err)
+	require.ErrorIs(t, g.Start(context.Background()), ErrUpstreamExposed)
+	require.Nil(t, g.Addr())
+}
+
+func TestLoadTokens(t *testing.T) {
+	tokens, err := LoadTokens(writeTokensFile(t, "\n# comment\npartner-a  secret-a\n\npartner-b\tsecret-b\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"secret-a": "partner-a", "secret-b": "partner-b"}, tokens)
+
+	_, err = LoadTokens(writeTokensFile(t, "partner-a\n"))
+	require.Error(t, err)
+
+	_, err = LoadTokens(writeTokensFile(t, "partner-a secret\npartner-b secret\n"))
+	require.Error(t, err)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(Config{})
+	require.ErrorIs(t, err, ErrMissingUpstream)
+
+	_, err = New(Config{UpstreamAddr: "127.0.0.1:1", TLSCertFile: "cert.pem"})
+	require.ErrorIs(t, err, ErrMissingTLSKeyPair)
+
+	_, err = New(Config{UpstreamAddr: "127.0.0.1:1", ClientCAFile: "ca.pem"})
+	require.ErrorIs(t, err, ErrClientCANeedsTLS)
+}
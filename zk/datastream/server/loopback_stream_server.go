@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+	"github.com/ledgerwatch/log/v3"
+)
+
+/*
+zkevm-data-streamer always listens on every interface.  Behind the data stream gateway the stream server must only be
+reached through the gateway, so LoopbackStreamServer starts it the way datastreamer.StreamServer.Start does but on
+the loopback.  This reaches into the unexported fields and methods of the stream server of v0.2.8, an upgrade of the
+library that changes them fails the build or TestLoopbackStreamServer.
+*/
+
+//go:linkname streamServerBroadcastAtomicOp github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer.(*StreamServer).broadcastAtomicOp
+func streamServerBroadcastAtomicOp(s *datastreamer.StreamServer)
+
+//go:linkname streamServerCheckClientInactivity github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer.(*StreamServer).checkClientInactivity
+func streamServerCheckClientInactivity(s *datastreamer.StreamServer)
+
+//go:linkname streamServerWaitConnections github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer.(*StreamServer).waitConnections
+func streamServerWaitConnections(s *datastreamer.StreamServer)
+
+// LoopbackStreamServer is a stream server listening on the loopback only
+type LoopbackStreamServer struct {
+	*datastreamer.StreamServer
+	port uint16
+}
+
+func (f *ZkEVMDataStreamServerFactory) CreateLoopbackStreamServer(port uint16, version uint8, systemID uint64, streamType datastreamer.StreamType, fileName string, writeTimeout time.Duration, inactivityTimeout time.Duration, inactivityCheckInterval time.Duration, cfg *dslog.Config) (StreamServer, error) {
+	streamServer, err := datastreamer.NewServer(port, version, systemID, streamType, fileName, writeTimeout, inactivityTimeout, inactivityCheckInterval, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &LoopbackStreamServer{StreamServer: streamServer, port: port}, nil
+}
+
+// Start opens access to the clients on the loopback and starts broadcasting
+func (s *LoopbackStreamServer) Start() error {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(s.port))))
+	if err != nil {
+		return fmt.Errorf("listen on the loopback: %w", err)
+	}
+	if err := s.setField("ln", ln); err != nil {
+		ln.Close()
+		return err
+	}
+	if err := s.setField("started", true); err != nil {
+		ln.Close()
+		return err
+	}
+
+	go streamServerBroadcastAtomicOp(s.StreamServer)
+	go streamServerCheckClientInactivity(s.StreamServer)
+	go streamServerWaitConnections(s.StreamServer)
+
+	log.Info("[dataStream] Listening on the loopback", "addr", ln.Addr())
+	return nil
+}
+
+// Addr returns the address the stream server listens on, nil if not started
+func (s *LoopbackStreamServer) Addr() net.Addr {
+	field := reflect.ValueOf(s.StreamServer).Elem().FieldByName("ln")
+	if !field.IsValid() || field.IsNil() {
+		return nil
+	}
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface().(net.Listener).Addr()
+}
+
+func (s *LoopbackStreamServer) setField(name string, value interface{}) error {
+	field := reflect.ValueOf(s.StreamServer).Elem().FieldByName(name)
+	if !field.IsValid() || !reflect.TypeOf(value).AssignableTo(field.Type()) {
+		return fmt.Errorf("the data stream server has no %s field of type %T, the library changed", name, value)
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(value))
+	return nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+)
+
+func TestLoopbackStreamServer(t *testing.T) {
+	streamServer, err := NewZkEVMDataStreamServerFactory().CreateLoopbackStreamServer(0, 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), 5*time.Second, 10*time.Second, 60*time.Second, &dslog.Config{Environment: "production", Level: "warn"})
+	require.NoError(t, err)
+	require.Nil(t, streamServer.(*LoopbackStreamServer).Addr())
+	require.NoError(t, streamServer.Start())
+	addr := streamServer.(*LoopbackStreamServer).Addr().(*net.TCPAddr)
+	require.True(t, addr.IP.IsLoopback())
+
+	// the entries committed are broadcast as usual
+	bookmark := types.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_BATCH)
+	bookmarkData, err := bookmark.Marshal()
+	require.NoError(t, err)
+	batchStart, err := (&types.BatchStartProto{BatchStart: &datastream.BatchStart{Number: 1, ForkId: 9}}).Marshal()
+	require.NoError(t, err)
+	require.NoError(t, streamServer.StartAtomicOp())
+	_, err = streamServer.AddStreamBookmark(bookmarkData)
+	require.NoError(t, err)
+	_, err = streamServer.AddStreamEntry(datastreamer.EntryType(types.EntryTypeBatchStart), batchStart)
+	require.NoError(t, err)
+	require.NoError(t, streamServer.CommitAtomicOp())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := client.NewClient(ctx, addr.String(), false, 0, 500*time.Millisecond, 0)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.ReadEntriesToChannelFrom(bookmark, types.EntryFilterNone)
+	}()
+	select {
+	case entry := <-*c.GetEntryChan():
+		require.Equal(t, uint64(9), entry.(*types.BatchStart).ForkId)
+	case err := <-errCh:
+		t.Fatalf("subscription ended: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	c.StopReadingToChannel()
+	require.NoError(t, <-errCh)
+
+	// and no other address than the loopback reaches the server
+	addrs, err := net.InterfaceAddrs()
+	require.NoError(t, err)
+	for _, interfaceAddr := range addrs {
+		ipNet, ok := interfaceAddr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipNet.IP.String(), strconv.Itoa(addr.Port)), time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		require.Error(t, err, ipNet.IP.String())
+	}
+}
@@ -0,0 +1,42 @@
+package types
+
+import "fmt"
+
+// EntryFilter selects the entries streamed to a filtered subscription, sparing the clients that only need the batch
+// boundaries or the blocks from downloading every transaction
+type EntryFilter uint64
+
+const (
+	EntryFilterNone    EntryFilter = iota // every entry
+	EntryFilterBatches                    // only the batch start and end entries, which carry the roots of the batch
+	EntryFilterBlocks                     // every entry but the transactions
+)
+
+func (f EntryFilter) IsValid() bool {
+	return f <= EntryFilterBlocks
+}
+
+// Keeps reports whether the entries of the given type pass the filter
+func (f EntryFilter) Keeps(entryType EntryType) bool {
+	switch f {
+	case EntryFilterBatches:
+		return entryType == EntryTypeBatchStart || entryType == EntryTypeBatchEnd
+	case EntryFilterBlocks:
+		return entryType != EntryTypeL2Tx
+	default:
+		return true
+	}
+}
+
+func (f EntryFilter) String() string {
+	switch f {
+	case EntryFilterNone:
+		return "none"
+	case EntryFilterBatches:
+		return "batches"
+	case EntryFilterBlocks:
+		return "blocks"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint64(f))
+	}
+}
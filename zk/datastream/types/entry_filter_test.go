@@ -0,0 +1,26 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryFilterKeeps(t *testing.T) {
+	entryTypes := []EntryType{EntryTypeBatchStart, EntryTypeL2Block, EntryTypeL2Tx, EntryTypeL2BlockEnd, EntryTypeGerUpdate, BookmarkEntryType, EntryTypeBatchEnd}
+
+	kept := func(filter EntryFilter) []EntryType {
+		var kept []EntryType
+		for _, entryType := range entryTypes {
+			if filter.Keeps(entryType) {
+				kept = append(kept, entryType)
+			}
+		}
+		return kept
+	}
+
+	require.Equal(t, entryTypes, kept(EntryFilterNone))
+	require.Equal(t, []EntryType{EntryTypeBatchStart, EntryTypeBatchEnd}, kept(EntryFilterBatches))
+	require.Equal(t, []EntryType{EntryTypeBatchStart, EntryTypeL2Block, EntryTypeL2BlockEnd, EntryTypeGerUpdate, BookmarkEntryType, EntryTypeBatchEnd}, kept(EntryFilterBlocks))
+	require.False(t, EntryFilter(3).IsValid())
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/common/length"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcfg"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/consensus/ethash"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/turbo/snapshotsync/freezeblocks"
+	"github.com/ledgerwatch/erigon/zk/datastream/server"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/erigon/zk/stateless"
+	zktx "github.com/ledgerwatch/erigon/zk/tx"
+	"github.com/ledgerwatch/erigon/zk/utils"
+	"github.com/ledgerwatch/erigon/zk/witness"
+)
+
+const (
+	sourceL1         = "l1"
+	sourceDatastream = "datastream"
+)
+
+var (
+	chaindata        string
+	tmpDir           string
+	batchNumber      uint64
+	source           string
+	datastreamFile   string
+	smtReduction     float64
+	witnessFull      bool
+	witnessMemdbSize uint64
+)
+
+// re-executes a single batch of a node on the state it had at the end of the previous batch and diffs the state
+// roots, receipts and counters it gets against the ones the node stored.  The blocks of the batch are read from the
+// l1 batch data the node synced or from a datastream file.  The state is rolled back in memory from the head of the
+// node, the further the batch is behind it the more memory it takes.
+func main() {
+	flag.StringVar(&chaindata, "chaindata", "", "chaindata directory of the node")
+	flag.StringVar(&tmpDir, "tmpdir", os.TempDir(), "directory of the in memory databases the state is rolled back in")
+	flag.Uint64Var(&batchNumber, "batch", 0, "batch number")
+	flag.StringVar(&source, "source", sourceL1, "where the blocks of the batch come from: l1 for the l1_batch_data table of the node, datastream for a datastream file")
+	flag.StringVar(&datastreamFile, "datastream-file", "", "datastream file the blocks are read from with the datastream source")
+	flag.Float64Var(&smtReduction, "smt-reduction", 0.6, "virtual counters smt reduction")
+	flag.BoolVar(&witnessFull, "witness-full", false, "roll back the whole state rather than the part the stored blocks touched, for batches touching other accounts than the stored ones")
+	flag.Uint64Var(&witnessMemdbSize, "witness-memdb-size", uint64(2*datasize.GB), "size in bytes of the in memory database the state is rolled back in")
+	flag.Parse()
+
+	if chaindata == "" || batchNumber == 0 {
+		fmt.Println("chaindata and batch are required")
+		os.Exit(1)
+	}
+	if source != sourceL1 && source != sourceDatastream {
+		fmt.Printf("unknown source %s\n", source)
+		os.Exit(1)
+	}
+	if source == sourceDatastream && datastreamFile == "" {
+		fmt.Println("datastream-file is required with the datastream source")
+		os.Exit(1)
+	}
+
+	db := mdbx.NewMDBX(log.New()).Path(chaindata).Readonly().MustOpen()
+	defer db.Close()
+
+	diffs, err := replay(context.Background(), db)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(diffs) > 0 {
+		fmt.Printf("batch %d differs from the stored one:\n", batchNumber)
+		for _, diff := range diffs {
+			fmt.Printf("  %s\n", diff)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("batch %d matches the stored one\n", batchNumber)
+}
+
+func replay(ctx context.Context, db kv.RoDB) ([]string, error) {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if historyV3, err := kvcfg.HistoryV3.Enabled(tx); err != nil {
+		return nil, err
+	} else if historyV3 {
+		return nil, errors.New("nodes running with history v3 are not supported")
+	}
+
+	hermezDb := hermez_db.NewHermezDbReader(tx)
+	blockNumbers, err := hermezDb.GetL2BlockNosByBatch(batchNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockNumbers) == 0 {
+		return nil, fmt.Errorf("no blocks stored for batch %d", batchNumber)
+	}
+	forkId, err := hermezDb.GetForkId(batchNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	genesisHash, err := rawdb.ReadCanonicalHash(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+	chainConfig, err := rawdb.ReadChainConfig(tx, genesisHash)
+	if err != nil {
+		return nil, err
+	}
+	if chainConfig == nil {
+		return nil, fmt.Errorf("no chain config for genesis %s", genesisHash)
+	}
+	if err = utils.UpdateZkEVMBlockCfg(chainConfig, hermezDb, "batch-replay"); err != nil {
+		return nil, err
+	}
+
+	// the state at the end of the previous batch is what the witness of the batch holds
+	start := time.Now()
+	blockReader := freezeblocks.NewBlockReader(freezeblocks.NewRoSnapshots(ethconfig.BlocksFreezing{Enabled: false}, "", 0, log.New()), nil)
+	generator := witness.NewGenerator(
+		datadir.Dirs{Tmp: tmpDir},
+		false,
+		nil,
+		blockReader,
+		chainConfig,
+		&ethconfig.Zk{WitnessMemdbSize: datasize.ByteSize(witnessMemdbSize)},
+		ethash.NewFaker(),
+		nil,
+		math.MaxUint64,
+	)
+	batchWitness, err := generator.GetWitnessByBlockRange(tx, ctx, blockNumbers[0], blockNumbers[len(blockNumbers)-1], false, witnessFull)
+	if err != nil {
+		return nil, fmt.Errorf("GetWitnessByBlockRange: %w", err)
+	}
+	fmt.Printf("rolled back the state to block %d in %s\n", blockNumbers[0]-1, time.Since(start))
+
+	var batch *stateless.Batch
+	switch source {
+	case sourceL1:
+		batch, err = batchFromL1(hermezDb, forkId, batchWitness)
+	case sourceDatastream:
+		batch, err = batchFromDatastream(chainConfig.ChainID.Uint64(), forkId, batchWitness)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, smtDepth, err := hermezDb.GetClosestSmtDepth(blockNumbers[0] - 1)
+	if err != nil {
+		return nil, err
+	}
+	cfg := stateless.Config{ChainConfig: chainConfig, SmtReduction: smtReduction, SmtDepth: int(smtDepth)}
+
+	start = time.Now()
+	result, err := stateless.Execute(ctx, cfg, batch)
+	if err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+	fmt.Printf("executed %d blocks in %s, old root %s, new root %s, counters %v, overflow %v\n", len(batch.Blocks), time.Since(start), result.OldStateRoot, result.NewStateRoot, result.Counters, result.Overflow)
+
+	return diffResult(tx, hermezDb, blockNumbers, result)
+}
+
+// batchFromL1 decodes the batch from the l1_batch_data table: coinbase, l1 info root and limit timestamp ahead of the
+// batch L2 data
+func batchFromL1(hermezDb *hermez_db.HermezDbReader, forkId uint64, batchWitness []byte) (*stateless.Batch, error) {
+	data, err := hermezDb.GetL1BatchData(batchNumber)
+	if err != nil {
+		return nil, err
+	}
+	headerLength := length.Addr + length.Hash + 8
+	if len(data) < headerLength {
+		return nil, fmt.Errorf("no l1 batch data for batch %d", batchNumber)
+	}
+	coinbase := common.BytesToAddress(data[:length.Addr])
+
+	return stateless.DecodeBatch(batchNumber, forkId, coinbase, batchWitness, data[headerLength:], hermezDb.GetL1InfoTreeUpdate)
+}
+
+func batchFromDatastream(chainId, forkId uint64, batchWitness []byte) (*stateless.Batch, error) {
+	logConfig := &dslog.Config{
+		Environment: "production",
+		Level:       "warn",
+		Outputs:     []string{"stdout"},
+	}
+	factory := server.NewZkEVMDataStreamServerFactory()
+	stream, err := factory.CreateStreamServer(0, uint8(3), 1, datastreamer.StreamType(1), datastreamFile, 5*time.Second, 10*time.Second, 60*time.Second, logConfig)
+	if err != nil {
+		return nil, err
+	}
+	batches, err := factory.CreateDataStreamServer(stream, chainId).ReadBatches(batchNumber, batchNumber)
+	if err != nil {
+		return nil, fmt.Errorf("ReadBatches: %w", err)
+	}
+	if len(batches) == 0 || len(batches[0]) == 0 {
+		return nil, fmt.Errorf("no blocks in the datastream for batch %d", batchNumber)
+	}
+
+	batch := &stateless.Batch{
+		Number:   batchNumber,
+		ForkId:   forkId,
+		Coinbase: batches[0][0].Coinbase,
+		Witness:  batchWitness,
+		Blocks:   make([]stateless.Block, 0, len(batches[0])),
+	}
+	for _, l2Block := range batches[0] {
+		block := stateless.Block{
+			DeltaTimestamp:               l2Block.DeltaTimestamp,
+			L1InfoTreeIndex:              l2Block.L1InfoTreeIndex,
+			GlobalExitRoot:               l2Block.GlobalExitRoot,
+			L1BlockHash:                  l2Block.L1BlockHash,
+			Transactions:                 make([]types.Transaction, 0, len(l2Block.L2Txs)),
+			EffectiveGasPricePercentages: make([]uint8, 0, len(l2Block.L2Txs)),
+		}
+		for _, l2Tx := range l2Block.L2Txs {
+			transaction, _, err := zktx.DecodeTx(l2Tx.Encoded, l2Tx.EffectiveGasPricePercentage, l2Block.ForkId)
+			if err != nil {
+				return nil, fmt.Errorf("block %d: decode tx: %w", l2Block.L2BlockNumber, err)
+			}
+			block.Transactions = append(block.Transactions, transaction)
+			block.EffectiveGasPricePercentages = append(block.EffectiveGasPricePercentages, l2Tx.EffectiveGasPricePercentage)
+		}
+		batch.Blocks = append(batch.Blocks, block)
+	}
+
+	return batch, nil
+}
+
+// diffResult compares the re-executed batch with the state roots, receipts and counters stored for its blocks
+func diffResult(tx kv.Tx, hermezDb *hermez_db.HermezDbReader, blockNumbers []uint64, result *stateless.Result) ([]string, error) {
+	var diffs []string
+	if len(result.BlockRoots) != len(blockNumbers) {
+		diffs = append(diffs, fmt.Sprintf("blocks: stored %d, replayed %d", len(blockNumbers), len(result.BlockRoots)))
+	}
+
+	for i, blockNumber := range blockNumbers[:min(len(blockNumbers), len(result.BlockRoots))] {
+		header := rawdb.ReadHeaderByNumber(tx, blockNumber)
+		if header == nil {
+			return nil, fmt.Errorf("no header for block %d", blockNumber)
+		}
+		if header.Root != result.BlockRoots[i] {
+			diffs = append(diffs, fmt.Sprintf("block %d state root: stored %s, replayed %s", blockNumber, header.Root, result.BlockRoots[i]))
+		}
+
+		stored := rawdb.ReadRawReceipts(tx, blockNumber)
+		if stored == nil && len(result.Receipts[i]) > 0 {
+			fmt.Printf("no receipts stored for block %d, they are not compared\n", blockNumber)
+			continue
+		}
+		diffs = append(diffs, diffReceipts(blockNumber, stored, result.Receipts[i])...)
+	}
+
+	storedCounters, found, err := hermezDb.GetBatchCountersByBlock(blockNumbers[len(blockNumbers)-1])
+	if err != nil {
+		return nil, err
+	}
+	if !found || len(storedCounters) == 0 {
+		// only the sequencer writes them
+		fmt.Println("no counters stored for the batch, they are not compared")
+		return diffs, nil
+	}
+	for key, used := range storedCounters {
+		if key >= len(vm.CounterKeyNames) {
+			break
+		}
+		name := string(vm.CounterKeyNames[key])
+		if replayed := result.Counters[name]; replayed != used {
+			diffs = append(diffs, fmt.Sprintf("counter %s: stored %d, replayed %d", name, used, replayed))
+		}
+	}
+
+	return diffs, nil
+}
+
+// diffReceipts compares the consensus fields of the receipts, the other ones are derived from the block
+func diffReceipts(blockNumber uint64, stored, replayed types.Receipts) []string {
+	if len(stored) != len(replayed) {
+		return []string{fmt.Sprintf("block %d receipts: stored %d, replayed %d", blockNumber, len(stored), len(replayed))}
+	}
+
+	var diffs []string
+	for i := range stored {
+		prefix := fmt.Sprintf("block %d tx %d", blockNumber, i)
+		if replayed[i].TxHash != (common.Hash{}) {
+			prefix = fmt.Sprintf("%s (%s)", prefix, replayed[i].TxHash)
+		}
+		if stored[i].Status != replayed[i].Status {
+			diffs = append(diffs, fmt.Sprintf("%s status: stored %d, replayed %d", prefix, stored[i].Status, replayed[i].Status))
+		}
+		if stored[i].CumulativeGasUsed != replayed[i].CumulativeGasUsed {
+			diffs = append(diffs, fmt.Sprintf("%s cumulative gas used: stored %d, replayed %d", prefix, stored[i].CumulativeGasUsed, replayed[i].CumulativeGasUsed))
+		}
+		if len(stored[i].Logs) != len(replayed[i].Logs) {
+			diffs = append(diffs, fmt.Sprintf("%s logs: stored %d, replayed %d", prefix, len(stored[i].Logs), len(replayed[i].Logs)))
+			continue
+		}
+		for j := range stored[i].Logs {
+			if !equalLogs(stored[i].Logs[j], replayed[i].Logs[j]) {
+				diffs = append(diffs, fmt.Sprintf("%s log %d: stored %+v, replayed %+v", prefix, j, *stored[i].Logs[j], *replayed[i].Logs[j]))
+			}
+		}
+	}
+	return diffs
+}
+
+func equalLogs(a, b *types.Log) bool {
+	if a.Address != b.Address || len(a.Topics) != len(b.Topics) || !bytes.Equal(a.Data, b.Data) {
+		return false
+	}
+	for i := range a.Topics {
+		if a.Topics[i] != b.Topics[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/zk/stateless"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/erigon/zk/utils"
+)
+
+var (
+	witnessFile    string
+	batchDataFile  string
+	l1InfoTreeFile string
+	chainName      string
+	chainId        uint64
+	forkId         uint64
+	batchNumber    uint64
+	coinbase       string
+	expectedRoot   string
+	smtReduction   float64
+	smtDepth       int
+)
+
+// verifies a batch without an executor from the hex encoded witness and batch L2 data, the ones of the
+// zkevm_getBatchWitness and zkevm_getBatchByNumber endpoints will do
+func main() {
+	flag.StringVar(&witnessFile, "witness", "", "file holding the hex encoded witness of the batch")
+	flag.StringVar(&batchDataFile, "batch-data", "", "file holding the hex encoded batch L2 data")
+	flag.StringVar(&l1InfoTreeFile, "l1-info-tree", "", "json file mapping the l1 info tree indexes the batch uses to their updates, of which GER and ParentHash are used")
+	flag.StringVar(&chainName, "chain", "hermez-dev", "chain the config is taken from")
+	flag.Uint64Var(&chainId, "chain-id", 0, "chain id, the one of the chain config when 0")
+	flag.Uint64Var(&forkId, "fork-id", 0, "fork id of the batch")
+	flag.Uint64Var(&batchNumber, "batch", 0, "batch number")
+	flag.StringVar(&coinbase, "coinbase", "", "sequencer address")
+	flag.StringVar(&expectedRoot, "expected-root", "", "state root the batch is expected to end on, the batch is only executed when empty")
+	flag.Float64Var(&smtReduction, "smt-reduction", 0.6, "virtual counters smt reduction")
+	flag.IntVar(&smtDepth, "smt-depth", 0, "smt depth the counters are estimated with, the one of the witness tree when 0")
+	flag.Parse()
+
+	witness, err := readHexFile(witnessFile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	batchL2Data, err := readHexFile(batchDataFile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	l1InfoTree := make(map[uint64]*zktypes.L1InfoTreeUpdate)
+	if l1InfoTreeFile != "" {
+		contents, err := os.ReadFile(l1InfoTreeFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err = json.Unmarshal(contents, &l1InfoTree); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	chainConfig := params.ChainConfigByChainName(chainName)
+	if chainConfig == nil {
+		fmt.Printf("unknown chain %s\n", chainName)
+		return
+	}
+	if chainId != 0 {
+		chainConfig.ChainID = new(big.Int).SetUint64(chainId)
+	}
+	// every block of the batch runs on its fork
+	if err = utils.RecoverySetBlockConfigForks(0, forkId, chainConfig, "stateless-verifier"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	batch, err := stateless.DecodeBatch(batchNumber, forkId, common.HexToAddress(coinbase), witness, batchL2Data, func(index uint64) (*zktypes.L1InfoTreeUpdate, error) {
+		return l1InfoTree[index], nil
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	cfg := stateless.Config{ChainConfig: chainConfig, SmtReduction: smtReduction, SmtDepth: smtDepth}
+	var result *stateless.Result
+	if expectedRoot == "" {
+		result, err = stateless.Execute(context.Background(), cfg, batch)
+	} else {
+		result, err = stateless.Verify(context.Background(), cfg, batch, common.HexToHash(expectedRoot))
+	}
+
+	if result != nil {
+		countersJson, _ := json.MarshalIndent(result.Counters, "", "  ")
+		fmt.Printf("old root: %s\n", result.OldStateRoot)
+		for i, root := range result.BlockRoots {
+			fmt.Printf("block %d root: %s\n", i, root)
+		}
+		fmt.Printf("new root: %s\n", result.NewStateRoot)
+		fmt.Printf("counters: %s\n", string(countersJson))
+		fmt.Printf("overflow: %v\n", result.Overflow)
+	}
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	if expectedRoot != "" {
+		fmt.Println("batch verified")
+	}
+}
+
+func readHexFile(file string) ([]byte, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return common.FromHex(strings.Trim(strings.TrimSpace(string(contents)), `"`)), nil
+}
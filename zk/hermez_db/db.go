@@ -1,6 +1,7 @@
 package hermez_db
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -54,6 +55,8 @@ const ERIGON_VERSIONS = "erigon_versions"                               // erigo
 const BATCH_ENDS = "batch_ends"                                         // batch number -> true
 const WITNESS_CACHE = "witness_cache"                                   // block number -> witness for 1 block
 const BAD_TX_HASHES = "bad_tx_hashes"                                   // tx hash -> integer counter
+const VERIFICATION_FAILURES = "verification_failures"                   // batch number + timestamp -> verification failure
+const L1_BLOCK_HASHES = "hermez_l1BlockHashes"                          // source + l1blockno -> hash of an ingested, not yet finalized l1 block
 
 var HermezDbTables = []string{
 	L1VERIFICATIONS,
@@ -90,9 +93,12 @@ var HermezDbTables = []string{
 	PLAIN_STATE_VERSION,
 	ERIGON_VERSIONS,
 	INNER_TX,
+	INNER_TX_ADDRESS_INDEX,
 	BATCH_ENDS,
 	BAD_TX_HASHES,
 	WITNESS_CACHE,
+	VERIFICATION_FAILURES,
+	L1_BLOCK_HASHES,
 }
 
 type HermezDb struct {
@@ -676,6 +682,106 @@ func (db *HermezDb) TruncateVerifications(l2BlockNo uint64) error {
 	return nil
 }
 
+// TruncateSequencesByL1Block deletes the sequences of the l1 blocks after the given one
+func (db *HermezDb) TruncateSequencesByL1Block(l1BlockNo uint64) error {
+	return db.truncateByL1Block(L1SEQUENCES, l1BlockNo)
+}
+
+// TruncateVerificationsByL1Block deletes the verifications of the l1 blocks after the given one
+func (db *HermezDb) TruncateVerificationsByL1Block(l1BlockNo uint64) error {
+	return db.truncateByL1Block(L1VERIFICATIONS, l1BlockNo)
+}
+
+func (db *HermezDb) truncateByL1Block(table string, l1BlockNo uint64) error {
+	c, err := db.tx.RwCursor(table)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for k, _, err := c.Seek(ConcatKey(l1BlockNo+1, 0)); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err = c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func l1BlockHashKey(source string, l1BlockNo uint64) []byte {
+	return append([]byte(source), Uint64ToBytes(l1BlockNo)...)
+}
+
+// WriteL1BlockHash keeps the hash of an l1 block the source ingested data from, to notice when it's reorged out
+func (db *HermezDb) WriteL1BlockHash(source string, l1BlockNo uint64, hash common.Hash) error {
+	return db.tx.Put(L1_BLOCK_HASHES, l1BlockHashKey(source, l1BlockNo), hash.Bytes())
+}
+
+// GetL1BlockHashes returns the hashes of the l1 blocks kept for the source by block number
+func (db *HermezDbReader) GetL1BlockHashes(source string) (map[uint64]common.Hash, error) {
+	c, err := db.tx.Cursor(L1_BLOCK_HASHES)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	hashes := make(map[uint64]common.Hash)
+	prefix := []byte(source)
+	for k, v, err := c.Seek(prefix); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if len(k) != len(prefix)+8 || !bytes.HasPrefix(k, prefix) {
+			break
+		}
+		hashes[BytesToUint64(k[len(prefix):])] = common.BytesToHash(v)
+	}
+
+	return hashes, nil
+}
+
+// TruncateL1BlockHashes deletes the hashes kept for the source of the l1 blocks after the given one
+func (db *HermezDb) TruncateL1BlockHashes(source string, l1BlockNo uint64) error {
+	hashes, err := db.GetL1BlockHashes(source)
+	if err != nil {
+		return err
+	}
+	for blockNo := range hashes {
+		if blockNo > l1BlockNo {
+			if err = db.tx.Delete(L1_BLOCK_HASHES, l1BlockHashKey(source, blockNo)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneL1BlockHashes deletes the hashes kept for the source of the finalized l1 blocks, they can't be reorged out.
+// The highest one is kept as the block every reorg goes back to at most.
+func (db *HermezDb) PruneL1BlockHashes(source string, finalizedL1BlockNo uint64) error {
+	hashes, err := db.GetL1BlockHashes(source)
+	if err != nil {
+		return err
+	}
+	var anchor uint64
+	for blockNo := range hashes {
+		if blockNo <= finalizedL1BlockNo {
+			anchor = max(anchor, blockNo)
+		}
+	}
+	for blockNo := range hashes {
+		if blockNo < anchor {
+			if err = db.tx.Delete(L1_BLOCK_HASHES, l1BlockHashKey(source, blockNo)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (db *HermezDb) WriteBlockBatch(l2BlockNo, batchNo uint64) error {
 	// first store the block -> batch record
 	err := db.tx.Put(BLOCKBATCHES, Uint64ToBytes(l2BlockNo), Uint64ToBytes(batchNo))
@@ -1461,6 +1567,23 @@ func (db *HermezDbReader) GetLatestBatchCounters(batchNumber uint64) (countersAr
 	return countersArray, found, nil
 }
 
+// GetBatchCountersByBlock returns the counters the batch of a block used up to the block, written by the sequencer only
+func (db *HermezDbReader) GetBatchCountersByBlock(blockNumber uint64) (countersArray []int, found bool, err error) {
+	v, err := db.tx.GetOne(BATCH_COUNTERS, Uint64ToBytes(blockNumber))
+	if err != nil {
+		return nil, false, err
+	}
+	found = len(v) > 0
+
+	if found {
+		if err = json.Unmarshal(v, &countersArray); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return countersArray, found, nil
+}
+
 func (db *HermezDb) DeleteBatchCounters(fromBlockNum, toBlockNum uint64) error {
 	return db.deleteFromBucketWithUintKeysRange(BATCH_COUNTERS, fromBlockNum, toBlockNum)
 }
@@ -1565,6 +1688,30 @@ func (db *HermezDbReader) GetClosestSmtDepth(l2BlockNo uint64) (closestBlock uin
 	return closestBlock, depth, nil
 }
 
+// get the highest block up to the given one the smt root was computed for
+func (db *HermezDbReader) GetLastSmtDepthBlock(l2BlockNo uint64) (uint64, bool, error) {
+	c, err := db.tx.Cursor(SMT_DEPTHS)
+	if err != nil {
+		return 0, false, err
+	}
+	defer c.Close()
+
+	k, _, err := c.Seek(Uint64ToBytes(l2BlockNo + 1))
+	if err != nil {
+		return 0, false, err
+	}
+	if k == nil {
+		k, _, err = c.Last()
+	} else {
+		k, _, err = c.Prev()
+	}
+	if err != nil || k == nil {
+		return 0, false, err
+	}
+
+	return BytesToUint64(k), true, nil
+}
+
 // truncate smt depths from the given block onwards
 func (db *HermezDb) TruncateSmtDepths(fromBlock uint64) error {
 	c, err := db.tx.Cursor(SMT_DEPTHS)
@@ -1642,6 +1789,53 @@ func (db *HermezDbReader) GetL1InfoTreeIndexToRoots() (map[uint64]common.Hash, e
 	return indexToRoot, nil
 }
 
+// TruncateL1InfoTreeUpdatesByL1Block deletes the l1 info tree updates of the l1 blocks after the given one, along with
+// their leaves and roots, and returns how many were deleted. The updates are indexed in the order of their l1 blocks
+// so only the latest ones go.
+func (db *HermezDb) TruncateL1InfoTreeUpdatesByL1Block(l1BlockNo uint64) (int, error) {
+	fromIndex := uint64(math.MaxUint64)
+	deleted := 0
+	for {
+		latest, err := db.GetLatestL1InfoTreeUpdate()
+		if err != nil {
+			return 0, err
+		}
+		if latest == nil || latest.BlockNumber <= l1BlockNo {
+			break
+		}
+
+		if err = db.tx.Delete(L1_INFO_TREE_UPDATES, Uint64ToBytes(latest.Index)); err != nil {
+			return 0, err
+		}
+		if err = db.tx.Delete(L1_INFO_TREE_UPDATES_BY_GER, latest.GER.Bytes()); err != nil {
+			return 0, err
+		}
+		if err = db.tx.Delete(L1_INFO_LEAVES, Uint64ToBytes(latest.Index)); err != nil {
+			return 0, err
+		}
+		fromIndex = latest.Index
+		deleted++
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	indexToRoots, err := db.GetL1InfoTreeIndexToRoots()
+	if err != nil {
+		return 0, err
+	}
+	for index, root := range indexToRoots {
+		if index >= fromIndex {
+			if err = db.tx.Delete(L1_INFO_ROOTS, root.Bytes()); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
 func (db *HermezDbReader) GetForkIdByBlockNum(blockNum uint64) (uint64, error) {
 	blockbatch, err := db.GetBatchNoByL2Block(blockNum)
 	if err != nil {
@@ -1924,3 +2118,66 @@ func (db *HermezDbReader) GetWitnessCache(blockNo uint64) ([]byte, error) {
 func (db *HermezDb) DeleteWitnessCaches(from, to uint64) error {
 	return db.deleteFromBucketWithUintKeysRange(WITNESS_CACHE, from, to)
 }
+
+func (db *HermezDb) WriteVerificationFailure(failure *types.VerificationFailure) error {
+	v, err := json.Marshal(failure)
+	if err != nil {
+		return err
+	}
+	key := append(Uint64ToBytes(uint64(failure.BatchNumber)), Uint64ToBytes(uint64(failure.Timestamp.UnixNano()))...)
+	return db.tx.Put(VERIFICATION_FAILURES, key, v)
+}
+
+// DeleteVerificationFailures deletes the verification failures of the batches in [fromBatch, toBatch]
+func (db *HermezDb) DeleteVerificationFailures(fromBatch, toBatch uint64) error {
+	c, err := db.tx.RwCursor(VERIFICATION_FAILURES)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for k, _, err := c.Seek(Uint64ToBytes(fromBatch)); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if BytesToUint64(k[:8]) > toBatch {
+			break
+		}
+		if err = c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetVerificationFailures returns the verification failures of the batches in [fromBatch, toBatch] ordered by batch
+// and then by time, returning at most limit of them if limit is positive
+func (db *HermezDbReader) GetVerificationFailures(fromBatch, toBatch uint64, limit int) ([]*types.VerificationFailure, error) {
+	c, err := db.tx.Cursor(VERIFICATION_FAILURES)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	failures := make([]*types.VerificationFailure, 0)
+	for k, v, err := c.Seek(Uint64ToBytes(fromBatch)); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if BytesToUint64(k[:8]) > toBatch {
+			break
+		}
+		if limit > 0 && len(failures) >= limit {
+			break
+		}
+
+		failure := &types.VerificationFailure{}
+		if err := json.Unmarshal(v, failure); err != nil {
+			return nil, err
+		}
+		failures = append(failures, failure)
+	}
+
+	return failures, nil
+}
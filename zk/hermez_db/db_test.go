@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/zk/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -521,6 +524,84 @@ func TestBatchBlocks(t *testing.T) {
 	}
 }
 
+func TestTruncateByL1Block(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	for l1BlockNo := uint64(10); l1BlockNo <= 13; l1BlockNo++ {
+		require.NoError(t, db.WriteSequence(l1BlockNo, l1BlockNo*10, common.Hash{1}, common.Hash{2}, common.Hash{3}))
+		require.NoError(t, db.WriteVerification(l1BlockNo, l1BlockNo*10, common.Hash{1}, common.Hash{2}))
+
+		update := &types.L1InfoTreeUpdate{Index: l1BlockNo - 10, GER: common.Hash{byte(l1BlockNo)}, BlockNumber: l1BlockNo}
+		require.NoError(t, db.WriteL1InfoTreeUpdate(update))
+		require.NoError(t, db.WriteL1InfoTreeUpdateToGer(update))
+		require.NoError(t, db.WriteL1InfoTreeLeaf(update.Index, common.Hash{byte(l1BlockNo)}))
+		require.NoError(t, db.WriteL1InfoTreeRoot(common.Hash{0xff, byte(l1BlockNo)}, update.Index))
+	}
+
+	require.NoError(t, db.TruncateSequencesByL1Block(11))
+	require.NoError(t, db.TruncateVerificationsByL1Block(11))
+	latestSequence, err := db.GetLatestSequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(110), latestSequence.BatchNo)
+	latestVerification, err := db.GetLatestVerification()
+	require.NoError(t, err)
+	require.Equal(t, uint64(110), latestVerification.BatchNo)
+
+	deleted, err := db.TruncateL1InfoTreeUpdatesByL1Block(11)
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+	latestUpdate, err := db.GetLatestL1InfoTreeUpdate()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), latestUpdate.Index)
+	byGer, err := db.GetL1InfoTreeUpdateByGer(common.Hash{12})
+	require.NoError(t, err)
+	require.Nil(t, byGer)
+	byGer, err = db.GetL1InfoTreeUpdateByGer(common.Hash{11})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), byGer.Index)
+	leaves, err := db.GetAllL1InfoTreeLeaves()
+	require.NoError(t, err)
+	require.Len(t, leaves, 2)
+	roots, err := db.GetL1InfoTreeIndexToRoots()
+	require.NoError(t, err)
+	require.Len(t, roots, 2)
+
+	// nothing after the block
+	deleted, err = db.TruncateL1InfoTreeUpdatesByL1Block(11)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+}
+
+func TestL1BlockHashes(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	for l1BlockNo := uint64(10); l1BlockNo <= 15; l1BlockNo++ {
+		require.NoError(t, db.WriteL1BlockHash("first", l1BlockNo, common.Hash{byte(l1BlockNo)}))
+	}
+	require.NoError(t, db.WriteL1BlockHash("second", 12, common.Hash{1}))
+
+	hashes, err := db.GetL1BlockHashes("first")
+	require.NoError(t, err)
+	require.Len(t, hashes, 6)
+	require.Equal(t, common.Hash{12}, hashes[12])
+
+	require.NoError(t, db.TruncateL1BlockHashes("first", 13))
+	// the highest finalized block is kept
+	require.NoError(t, db.PruneL1BlockHashes("first", 11))
+	hashes, err = db.GetL1BlockHashes("first")
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]common.Hash{11: {11}, 12: {12}, 13: {13}}, hashes)
+
+	// the other source keeps its hashes
+	hashes, err = db.GetL1BlockHashes("second")
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]common.Hash{12: {1}}, hashes)
+}
+
 func TestDeleteForkId(t *testing.T) {
 	type forkInterval struct {
 		ForkId          uint64
@@ -622,3 +703,131 @@ func TestDeleteForkId(t *testing.T) {
 		})
 	}
 }
+
+func TestVerificationFailures(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	now := time.Now()
+	for i, batch := range []uint64{5, 3, 5, 8} {
+		err := db.WriteVerificationFailure(&types.VerificationFailure{
+			BatchNumber:       hexutil.Uint64(batch),
+			BlockNumbers:      []hexutil.Uint64{hexutil.Uint64(batch * 10), hexutil.Uint64(batch*10 + 1)},
+			ExpectedStateRoot: common.HexToHash("0x1"),
+			ExecutorStateRoot: common.HexToHash("0x2"),
+			Counters:          map[string]int{"S": 10},
+			ExecutorCounters:  map[string]int{"S": 20},
+			ExecutorUrl:       "localhost:50071",
+			Timestamp:         now.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+
+	failures, err := db.GetVerificationFailures(0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, failures, 4)
+	require.Equal(t, hexutil.Uint64(3), failures[0].BatchNumber)
+	require.Equal(t, hexutil.Uint64(5), failures[1].BatchNumber)
+	require.Equal(t, hexutil.Uint64(5), failures[2].BatchNumber)
+	require.True(t, failures[1].Timestamp.Before(failures[2].Timestamp))
+	require.Equal(t, hexutil.Uint64(8), failures[3].BatchNumber)
+	require.Equal(t, []hexutil.Uint64{30, 31}, failures[0].BlockNumbers)
+	require.Equal(t, 20, failures[0].ExecutorCounters["S"])
+
+	failures, err = db.GetVerificationFailures(4, 5, 0)
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+
+	failures, err = db.GetVerificationFailures(4, 10, 1)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, hexutil.Uint64(5), failures[0].BatchNumber)
+
+	// unwinding the batches from 5 deletes all their failures but keeps the ones before
+	require.NoError(t, db.DeleteVerificationFailures(5, math.MaxUint64))
+	failures, err = db.GetVerificationFailures(0, math.MaxUint64, 0)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, hexutil.Uint64(3), failures[0].BatchNumber)
+}
+
+func TestBackfillInnerTxs(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	innerTxs := func(from string) [][]*types.InnerTx {
+		return [][]*types.InnerTx{
+			{{CallType: "call", From: from, To: "0x2"}},
+			{{CallType: "create", From: from}, {CallType: "call", Name: "call_0", From: "0x3"}},
+		}
+	}
+
+	require.NoError(t, db.WriteInnerTxs(10, innerTxs("0x10")))
+	// appending an older block fails, backfilling it doesn't
+	require.Error(t, db.WriteInnerTxs(5, innerTxs("0x5")))
+	require.NoError(t, db.BackfillInnerTxs(5, innerTxs("0x5")))
+	require.NoError(t, db.BackfillInnerTxs(5, innerTxs("0x6")))
+
+	stored := db.GetInnerTxs(5)
+	require.Len(t, stored, 2)
+	require.Equal(t, "0x6", stored[0][0].From)
+	require.Len(t, stored[1], 2)
+	require.Equal(t, "call_0", stored[1][1].Name)
+	require.Equal(t, "0x10", db.GetInnerTxs(10)[0][0].From)
+}
+
+func TestInnerTxAddressIndex(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	contract := common.HexToAddress("0xc0")
+	wallet := common.HexToAddress("0xa1")
+	other := common.HexToAddress("0xb2")
+
+	require.NoError(t, db.WriteInnerTxs(5, [][]*types.InnerTx{
+		{{CallType: "call", From: other.String(), To: contract.String()}, {CallType: "call", From: contract.String(), To: wallet.String()}},
+	}))
+	require.NoError(t, db.WriteInnerTxs(6, [][]*types.InnerTx{
+		{},
+		{{CallType: "call", From: wallet.String(), To: wallet.String()}},
+	}))
+
+	entries := func(address common.Address, from, to uint64) []InnerTxIndexEntry {
+		var found []InnerTxIndexEntry
+		require.NoError(t, db.GetInnerTxIndexEntries(address, from, to, func(entry InnerTxIndexEntry) (bool, error) {
+			found = append(found, entry)
+			return true, nil
+		}))
+		return found
+	}
+
+	require.Equal(t, []InnerTxIndexEntry{
+		{BlockNumber: 5, TxIndex: 0, Index: 1, Roles: InnerTxRoleTo},
+		{BlockNumber: 6, TxIndex: 1, Index: 0, Roles: InnerTxRoleFrom | InnerTxRoleTo},
+	}, entries(wallet, 0, 10))
+	require.Len(t, entries(wallet, 6, 6), 1)
+	require.Len(t, entries(contract, 0, 10), 2)
+	require.Empty(t, entries(common.HexToAddress("0xdead"), 0, 10))
+
+	innerTxs, err := db.GetTxInnerTxs(6, 1)
+	require.NoError(t, err)
+	require.Len(t, innerTxs, 1)
+
+	// unwinding a block drops its entries
+	require.NoError(t, db.TruncateInnerTx(6))
+	require.Len(t, entries(wallet, 0, 10), 1)
+
+	// reindexing the stored inner txs is idempotent
+	require.NoError(t, db.ReindexInnerTxs(5))
+	require.Len(t, entries(contract, 0, 10), 2)
+
+	// backfilling a block replaces its entries
+	require.NoError(t, db.BackfillInnerTxs(5, [][]*types.InnerTx{
+		{{CallType: "call", From: other.String(), To: wallet.String()}},
+	}))
+	require.Empty(t, entries(contract, 0, 10))
+	require.Len(t, entries(wallet, 0, 10), 1)
+}
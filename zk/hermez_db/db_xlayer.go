@@ -1,9 +1,12 @@
 package hermez_db
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/length"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/dbutils"
 	"github.com/ledgerwatch/erigon/rlp"
@@ -11,9 +14,47 @@ import (
 	"github.com/ledgerwatch/log/v3"
 )
 
-const INNER_TX = "InnerTx" // block_num_u64 + txId -> inner txs of transaction
+const (
+	INNER_TX               = "InnerTx"                // block_num_u64 + txId -> inner txs of transaction
+	INNER_TX_ADDRESS_INDEX = "inner_tx_address_index" // address + block_num_u64 + txId + inner tx index -> roles of the address
+)
+
+// roles of an address in an indexed inner tx
+const (
+	InnerTxRoleFrom byte = 1 << iota
+	InnerTxRoleTo
+)
+
+// InnerTxIndexEntry locates an inner tx an address takes part in
+type InnerTxIndexEntry struct {
+	BlockNumber uint64
+	TxIndex     uint32
+	Index       uint32 // position of the inner tx among the ones of the transaction
+	Roles       byte
+}
+
+func (e InnerTxIndexEntry) IsFrom() bool {
+	return e.Roles&InnerTxRoleFrom != 0
+}
+
+func (e InnerTxIndexEntry) IsTo() bool {
+	return e.Roles&InnerTxRoleTo != 0
+}
 
 func (db *HermezDb) WriteInnerTxs(number uint64, innerTxs [][]*types.InnerTx) error {
+	return db.writeInnerTxs(number, innerTxs, db.tx.Append)
+}
+
+// BackfillInnerTxs writes the inner txs of a block older than the last one written, overwriting the ones stored if any
+func (db *HermezDb) BackfillInnerTxs(number uint64, innerTxs [][]*types.InnerTx) error {
+	// the stored ones go first so that their index entries don't outlive them
+	if err := db.TruncateInnerTx(number); err != nil {
+		return err
+	}
+	return db.writeInnerTxs(number, innerTxs, db.tx.Put)
+}
+
+func (db *HermezDb) writeInnerTxs(number uint64, innerTxs [][]*types.InnerTx, write func(table string, k, v []byte) error) error {
 	for txId, its := range innerTxs {
 		if len(its) == 0 {
 			continue
@@ -24,9 +65,142 @@ func (db *HermezDb) WriteInnerTxs(number uint64, innerTxs [][]*types.InnerTx) er
 			return fmt.Errorf("encode inner tx for block %d: %w", number, err)
 		}
 
-		if err = db.tx.Append(INNER_TX, dbutils.LogKey(number, uint32(txId)), data); err != nil {
+		if err = write(INNER_TX, dbutils.LogKey(number, uint32(txId)), data); err != nil {
 			return fmt.Errorf("writing logs for block %d: %w", number, err)
 		}
+		if err = db.indexInnerTxs(number, uint32(txId), its); err != nil {
+			return fmt.Errorf("indexing inner txs for block %d: %w", number, err)
+		}
+	}
+	return nil
+}
+
+// ReindexInnerTxs indexes the inner txs stored for a block, for the ones written before the index existed
+func (db *HermezDb) ReindexInnerTxs(block uint64) error {
+	return db.forEachTxInnerTxs(block, func(txId uint32, its []*types.InnerTx) error {
+		return db.indexInnerTxs(block, txId, its)
+	})
+}
+
+func (db *HermezDb) indexInnerTxs(number uint64, txId uint32, its []*types.InnerTx) error {
+	for i, it := range its {
+		for address, roles := range innerTxAddressRoles(it) {
+			if err := db.tx.Put(INNER_TX_ADDRESS_INDEX, innerTxAddressKey(address, number, txId, uint32(i)), []byte{roles}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (db *HermezDb) unindexInnerTxs(number uint64, txId uint32, its []*types.InnerTx) error {
+	for i, it := range its {
+		for address := range innerTxAddressRoles(it) {
+			if err := db.tx.Delete(INNER_TX_ADDRESS_INDEX, innerTxAddressKey(address, number, txId, uint32(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func innerTxAddressRoles(it *types.InnerTx) map[common.Address]byte {
+	roles := make(map[common.Address]byte, 2)
+	if common.IsHexAddress(it.From) {
+		roles[common.HexToAddress(it.From)] |= InnerTxRoleFrom
+	}
+	if common.IsHexAddress(it.To) {
+		roles[common.HexToAddress(it.To)] |= InnerTxRoleTo
+	}
+	return roles
+}
+
+func innerTxAddressKey(address common.Address, number uint64, txId, index uint32) []byte {
+	key := make([]byte, 0, length.Addr+8+4+4)
+	key = append(key, address.Bytes()...)
+	key = binary.BigEndian.AppendUint64(key, number)
+	key = binary.BigEndian.AppendUint32(key, txId)
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+// GetInnerTxIndexEntries visits the inner txs the address takes part in from block fromBlock to block toBlock
+// included, in order, until visit returns false
+func (db *HermezDbReader) GetInnerTxIndexEntries(address common.Address, fromBlock, toBlock uint64, visit func(InnerTxIndexEntry) (bool, error)) error {
+	c, err := db.tx.Cursor(INNER_TX_ADDRESS_INDEX)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for k, v, err := c.Seek(innerTxAddressKey(address, fromBlock, 0, 0)); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(k[:length.Addr], address.Bytes()) {
+			break
+		}
+		entry := InnerTxIndexEntry{
+			BlockNumber: binary.BigEndian.Uint64(k[length.Addr:]),
+			TxIndex:     binary.BigEndian.Uint32(k[length.Addr+8:]),
+			Index:       binary.BigEndian.Uint32(k[length.Addr+12:]),
+		}
+		if entry.BlockNumber > toBlock {
+			break
+		}
+		if len(v) > 0 {
+			entry.Roles = v[0]
+		}
+		more, err := visit(entry)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+
+	return nil
+}
+
+// GetTxInnerTxs returns the inner txs stored for the transaction at the index txId of the block
+func (db *HermezDbReader) GetTxInnerTxs(blockNum uint64, txId uint32) ([]*types.InnerTx, error) {
+	data, err := db.tx.GetOne(INNER_TX, dbutils.LogKey(blockNum, txId))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	innerTxs := make([]*types.InnerTx, 0)
+	if err := rlp.DecodeBytes(data, &innerTxs); err != nil {
+		return nil, fmt.Errorf("inner txs unmarshal failed: %w", err)
+	}
+	return innerTxs, nil
+}
+
+func (db *HermezDbReader) forEachTxInnerTxs(blockNum uint64, f func(txId uint32, its []*types.InnerTx) error) error {
+	c, err := db.tx.Cursor(INNER_TX)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	prefix := Uint64ToBytes(blockNum)
+	for k, v, err := c.Seek(prefix); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(k, prefix) {
+			break
+		}
+		innerTxs := make([]*types.InnerTx, 0)
+		if err := rlp.DecodeBytes(v, &innerTxs); err != nil {
+			return fmt.Errorf("inner txs unmarshal failed: %w", err)
+		}
+		if err := f(binary.BigEndian.Uint32(k[8:]), innerTxs); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -69,6 +243,13 @@ func (db *HermezDbReader) GetInnerTxs(blockNum uint64) [][]*types.InnerTx {
 
 // TruncateInnerTx deletes all inner txs of a block
 func (db *HermezDb) TruncateInnerTx(block uint64) error {
+	// the index entries are found from the inner txs so they go first
+	if err := db.forEachTxInnerTxs(block, func(txId uint32, its []*types.InnerTx) error {
+		return db.unindexInnerTxs(block, txId, its)
+	}); err != nil {
+		return fmt.Errorf("unindex inner txs of block %d: %w", block, err)
+	}
+
 	prefix := make([]byte, 8)
 	binary.BigEndian.PutUint64(prefix, block)
 
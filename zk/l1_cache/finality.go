@@ -0,0 +1,144 @@
+package l1_cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// chainTracker follows the head and the finalized block of an L1 chain through one of its upstream endpoints, so that
+// the cache knows which responses can be kept forever and when an L1 reorg invalidated the others
+type chainTracker struct {
+	mtx sync.Mutex
+
+	chainID  string
+	endpoint string
+
+	finalized  uint64
+	headNumber uint64
+	headHash   string
+
+	client *http.Client
+}
+
+type rpcBlock struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   string         `json:"hash"`
+}
+
+func newChainTracker(chainID, endpoint string) *chainTracker {
+	return &chainTracker{
+		chainID:  chainID,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *chainTracker) setEndpoint(endpoint string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.endpoint = endpoint
+}
+
+// finalizedBlock returns the last finalized block seen, zero if not known yet
+func (t *chainTracker) finalizedBlock() uint64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.finalized
+}
+
+// poll refreshes the head and the finalized block and reports whether the previously seen head has been reorged out
+func (t *chainTracker) poll(ctx context.Context) (reorged bool, err error) {
+	t.mtx.Lock()
+	endpoint := t.endpoint
+	prevNumber, prevHash := t.headNumber, t.headHash
+	t.mtx.Unlock()
+
+	head, err := t.getBlock(ctx, endpoint, "latest")
+	if err != nil {
+		return false, err
+	}
+	if head == nil {
+		return false, errors.New("no latest block")
+	}
+
+	// not every L1 supports the finalized tag, in which case nothing is considered final
+	finalized, err := t.getBlock(ctx, endpoint, "finalized")
+	if err != nil {
+		log.Debug("[l1-cache] Failed to get the finalized block", "chainid", t.chainID, "err", err)
+	}
+
+	if prevHash != "" {
+		current := head
+		if uint64(head.Number) != prevNumber {
+			if current, err = t.getBlock(ctx, endpoint, hexutil.EncodeUint64(prevNumber)); err != nil {
+				return false, err
+			}
+		}
+		// the block may be missing altogether if the chain got shorter
+		reorged = current == nil || current.Hash != prevHash
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.headNumber, t.headHash = uint64(head.Number), head.Hash
+	if finalized != nil && uint64(finalized.Number) > t.finalized {
+		t.finalized = uint64(finalized.Number)
+	}
+
+	return reorged, nil
+}
+
+func (t *chainTracker) getBlock(ctx context.Context, endpoint, block string) (*rpcBlock, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getBlockByNumber",
+		"params":  []interface{}{block, false},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Result *rpcBlock       `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Error) > 0 {
+		return nil, fmt.Errorf("upstream error: %s", response.Error)
+	}
+
+	return response.Result, nil
+}
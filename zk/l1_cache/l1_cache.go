@@ -3,94 +3,251 @@ package l1_cache
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"errors"
 
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon-lib/metrics"
 	"github.com/ledgerwatch/log/v3"
 )
 
 const (
-	bucketName   = "Cache"
-	expiryBucket = "Expiry"
+	bucketName        = "Cache"
+	expiryBucket      = "Expiry"
+	accessBucket      = "Access"      // key -> last access time
+	lruBucket         = "LRU"         // last access time + key -> nil, ordered from least to most recently used
+	unfinalizedBucket = "Unfinalized" // keys of the responses covering blocks above finality
+	metaBucket        = "Meta"
+
+	sizeKey = "size"
 )
 
-// methods we don't cache
-var methodsToIgnore = map[string]struct{}{}
+var buckets = []string{bucketName, expiryBucket, accessBucket, lruBucket, unfinalizedBucket, metaBucket}
 
-// methods we configure expiry for
-var methodsToExpire = map[string]time.Duration{
-	//"eth_getBlockByNumber": 1 * time.Minute, // example here but currently we don't want any methods to expire
+var (
+	cacheSize         = metrics.GetOrCreateGauge("l1_cache_size_bytes")
+	evictedExpired    = metrics.GetOrCreateCounter(`l1_cache_evictions_total{reason="expired"}`)
+	evictedReorg      = metrics.GetOrCreateCounter(`l1_cache_evictions_total{reason="reorg"}`)
+	evictedLRU        = metrics.GetOrCreateCounter(`l1_cache_evictions_total{reason="lru"}`)
+	evictedError      = metrics.GetOrCreateCounter(`l1_cache_evictions_total{reason="error"}`)
+	cacheReorgsTotal  = metrics.GetOrCreateCounter("l1_cache_reorgs_total")
+	cacheUpstreamErrs = metrics.GetOrCreateCounter("l1_cache_upstream_errors_total")
+)
+
+func countRequest(method, status string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`l1_cache_requests_total{method="%s",status="%s"}`, method, status)).Inc()
 }
 
-// params that trigger expiration
-var paramsToExpire = map[string]struct{}{
-	"latest":    {},
-	"finalized": {},
+// Config configures how the L1 cache keeps responses
+type Config struct {
+	Port uint
+
+	// MethodTTLs overrides DefaultTTL per method for responses that may still change. A TTL of zero disables
+	// caching those responses for the method.
+	MethodTTLs map[string]time.Duration
+	// DefaultTTL is how long responses that may still change are kept, zero disables caching them
+	DefaultTTL time.Duration
+	// MaxSize caps the size of the cache, the least recently used responses are evicted above it. Zero means no cap.
+	MaxSize datasize.ByteSize
+	// FinalityPollInterval is how often the head and the finalized block of the L1 are checked
+	FinalityPollInterval time.Duration
 }
 
 type L1Cache struct {
 	server *http.Server
 	db     kv.RwDB
+	cfg    Config
+
+	trackersMtx sync.Mutex
+	trackers    map[string]*chainTracker // by chain id
 }
 
-func NewL1Cache(ctx context.Context, dbPath string, port uint) (*L1Cache, error) {
+func NewL1Cache(ctx context.Context, dbPath string, cfg Config) (*L1Cache, error) {
 	db := mdbx.NewMDBX(log.New()).Path(dbPath).MustOpen()
-
-	tx, err := db.BeginRw(ctx)
+	c, err := newL1Cache(ctx, db, cfg)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
-	defer tx.Rollback()
 
-	if err := tx.CreateBucket(bucketName); err != nil {
-		return nil, err
-	}
-	if err := tx.CreateBucket(expiryBucket); err != nil {
-		return nil, err
-	}
-	if err := tx.Commit(); err != nil {
-		return nil, err
-	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", c.handleRequest)
 
-	http.HandleFunc("/", handleRequest(db))
-	addr := fmt.Sprintf(":%d", port)
-	server := &http.Server{
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	c.server = &http.Server{
 		Addr:           addr,
-		Handler:        nil,
+		Handler:        mux,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
 	go func() {
-		log.Info("Starting L1 Cache Server on port:", "port", port)
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Info("Starting L1 Cache Server on port:", "port", cfg.Port)
+		if err := c.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			log.Error("L1 Cache Server stopped", "error", err)
 		}
 	}()
 
+	go c.trackFinality(ctx)
+
 	go func() {
 		<-ctx.Done()
 		log.Info("Shutting down L1 Cache Server...")
-		if err := server.Shutdown(context.Background()); err != nil {
+		if err := c.server.Shutdown(context.Background()); err != nil {
 			log.Error("Failed to shutdown L1 Cache Server", "error", err)
 		}
 		db.Close()
 	}()
 
+	return c, nil
+}
+
+func newL1Cache(ctx context.Context, db kv.RwDB, cfg Config) (*L1Cache, error) {
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, bucket := range buckets {
+		if err := tx.CreateBucket(bucket); err != nil {
+			return nil, err
+		}
+	}
+	if err := indexExistingEntries(tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &L1Cache{
-		server: server,
-		db:     db,
+		db:       db,
+		cfg:      cfg,
+		trackers: make(map[string]*chainTracker),
 	}, nil
 }
 
+// indexExistingEntries adds the entries of a cache created before the size cap to the LRU index. Entries that could
+// still change, e.g. responses for the latest block, used to be kept forever so they are dropped instead.
+func indexExistingEntries(tx kv.RwTx) error {
+	size, err := tx.GetOne(metaBucket, []byte(sizeKey))
+	if err != nil || size != nil {
+		return err
+	}
+
+	var total uint64
+	var keys, stale []string
+	if err := tx.ForEach(bucketName, nil, func(k, v []byte) error {
+		if !isImmutableEntry(string(k), v) {
+			stale = append(stale, string(k))
+			return nil
+		}
+		keys = append(keys, string(k))
+		total += uint64(len(k) + len(v))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if err := tx.Delete(bucketName, []byte(key)); err != nil {
+			return err
+		}
+		if err := tx.Delete(expiryBucket, []byte(key)); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if err := touch(tx, key); err != nil {
+			return err
+		}
+	}
+
+	log.Info("[l1-cache] Indexed existing cache entries", "kept", len(keys), "dropped", len(stale))
+	return putSize(tx, total)
+}
+
+// isImmutableEntry checks whether a cached response can never change, assuming every numbered block is final
+func isImmutableEntry(key string, response []byte) bool {
+	_, body, found := strings.Cut(key, "_")
+	if !found {
+		return false
+	}
+	var request struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	var jsonResponse struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if json.Unmarshal([]byte(body), &request) != nil || json.Unmarshal(response, &jsonResponse) != nil {
+		return false
+	}
+	policy := (&Config{}).policyFor(request.Method, request.Params, jsonResponse.Result, math.MaxUint64)
+	return policy.immutable
+}
+
+func getSize(tx kv.Tx) (uint64, error) {
+	v, err := tx.GetOne(metaBucket, []byte(sizeKey))
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func putSize(tx kv.RwTx, size uint64) error {
+	cacheSize.SetUint64(size)
+	return tx.Put(metaBucket, []byte(sizeKey), binary.BigEndian.AppendUint64(nil, size))
+}
+
+func addSize(tx kv.RwTx, delta int64) error {
+	size, err := getSize(tx)
+	if err != nil {
+		return err
+	}
+	if delta < 0 && uint64(-delta) > size {
+		return putSize(tx, 0)
+	}
+	return putSize(tx, uint64(int64(size)+delta))
+}
+
+func lruKey(accessTime []byte, key string) []byte {
+	return append(common.CopyBytes(accessTime), key...)
+}
+
+// touch marks the entry as the most recently used one
+func touch(tx kv.RwTx, key string) error {
+	prev, err := tx.GetOne(accessBucket, []byte(key))
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		if err := tx.Delete(lruBucket, lruKey(prev, key)); err != nil {
+			return err
+		}
+	}
+
+	now := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+	if err := tx.Put(accessBucket, []byte(key), now); err != nil {
+		return err
+	}
+	return tx.Put(lruBucket, lruKey(now, key), nil)
+}
+
 func fetchFromCache(tx kv.RwTx, key string) ([]byte, bool) {
 	data, err := tx.GetOne(bucketName, []byte(key))
 	if err != nil || data == nil {
@@ -103,6 +260,7 @@ func fetchFromCache(tx kv.RwTx, key string) ([]byte, bool) {
 		if err == nil && time.Now().After(expiryTime) {
 			// Cache entry has expired
 			evictFromCache(tx, key)
+			evictedExpired.Inc()
 			return nil, false
 		}
 	}
@@ -113,33 +271,129 @@ func fetchFromCache(tx kv.RwTx, key string) ([]byte, bool) {
 		if _, hasError := jsonResponse["error"]; hasError {
 			// Cache entry is an error, evict it
 			evictFromCache(tx, key)
+			evictedError.Inc()
 			return nil, false
 		}
 	}
 
+	data = common.CopyBytes(data)
+	if err := touch(tx, key); err != nil {
+		log.Warn("Failed to update cache access time", "error", err)
+	}
+
 	return data, true
 }
 
 func evictFromCache(tx kv.RwTx, key string) {
+	data, err := tx.GetOne(bucketName, []byte(key))
+	if err != nil {
+		log.Warn("Failed to evict from cache", "error", err)
+		return
+	}
+	if data != nil {
+		if err := addSize(tx, -int64(len(key)+len(data))); err != nil {
+			log.Warn("Failed to evict from cache", "error", err)
+		}
+	}
+
 	if err := tx.Delete(bucketName, []byte(key)); err != nil {
 		log.Warn("Failed to evict from cache", "error", err)
 	}
 	if err := tx.Delete(expiryBucket, []byte(key)); err != nil {
 		log.Warn("Failed to evict from cache", "error", err)
 	}
+	if err := tx.Delete(unfinalizedBucket, []byte(key)); err != nil {
+		log.Warn("Failed to evict from cache", "error", err)
+	}
+	accessTime, err := tx.GetOne(accessBucket, []byte(key))
+	if err == nil && accessTime != nil {
+		if err := tx.Delete(lruBucket, lruKey(accessTime, key)); err != nil {
+			log.Warn("Failed to evict from cache", "error", err)
+		}
+	}
+	if err := tx.Delete(accessBucket, []byte(key)); err != nil {
+		log.Warn("Failed to evict from cache", "error", err)
+	}
 }
 
-func saveToCache(tx kv.RwTx, key string, response []byte, duration time.Duration) error {
+func saveToCache(tx kv.RwTx, key string, response []byte, policy cachePolicy) error {
+	// replace the previous entry, if any, so that the size and the indexes stay consistent
+	evictFromCache(tx, key)
+
 	if err := tx.Put(bucketName, []byte(key), response); err != nil {
 		return err
 	}
-	// Only set expiry if duration is not zero (indicating that it should expire)
-	if duration > 0 {
-		expiryTime := time.Now().Add(duration).Format(time.RFC3339)
+
+	// immutable responses are kept forever, everything else expires and is dropped on a reorg
+	if !policy.immutable {
+		expiryTime := time.Now().Add(policy.ttl).Format(time.RFC3339)
 		if err := tx.Put(expiryBucket, []byte(key), []byte(expiryTime)); err != nil {
 			return err
 		}
+		if err := tx.Put(unfinalizedBucket, []byte(key), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := touch(tx, key); err != nil {
+		return err
+	}
+	return addSize(tx, int64(len(key)+len(response)))
+}
+
+// evictUnfinalized drops the responses of the chain covering blocks above finality, after an L1 reorg
+func evictUnfinalized(tx kv.RwTx, chainID string) (int, error) {
+	prefix := []byte(chainID + "_")
+	var keys []string
+	if err := tx.ForPrefix(unfinalizedBucket, prefix, func(k, _ []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		evictFromCache(tx, key)
+	}
+	evictedReorg.AddInt(len(keys))
+	return len(keys), nil
+}
+
+// enforceSizeLimit evicts the least recently used responses until the cache fits in maxSize
+func enforceSizeLimit(tx kv.RwTx, maxSize uint64) error {
+	if maxSize == 0 {
+		return nil
+	}
+
+	size, err := getSize(tx)
+	if err != nil || size <= maxSize {
+		return err
+	}
+
+	var keys []string
+	c, err := tx.Cursor(lruBucket)
+	if err != nil {
+		return err
+	}
+	for k, _, err := c.First(); k != nil && size > maxSize; k, _, err = c.Next() {
+		if err != nil {
+			c.Close()
+			return err
+		}
+		key := string(k[8:])
+		data, err := tx.GetOne(bucketName, []byte(key))
+		if err != nil {
+			c.Close()
+			return err
+		}
+		size -= min(size, uint64(len(key)+len(data)))
+		keys = append(keys, key)
 	}
+	c.Close()
+
+	for _, key := range keys {
+		evictFromCache(tx, key)
+	}
+	evictedLRU.AddInt(len(keys))
 	return nil
 }
 
@@ -149,121 +403,208 @@ func generateCacheKey(chainID string, body []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+
 	delete(request, "id")
+
 	modifiedBody, err := json.Marshal(request)
 	if err != nil {
 		return "", err
 	}
+
 	return fmt.Sprintf("%s_%s", chainID, modifiedBody), nil
 }
 
-func handleRequest(db kv.RwDB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		endpoint := r.URL.Query().Get("endpoint")
-		chainID := r.URL.Query().Get("chainid")
-		if endpoint == "" || chainID == "" {
-			http.Error(w, "Missing endpoint or chainid parameter", http.StatusBadRequest)
-			return
-		}
+// tracker returns the finality tracker of the chain, starting to track it on first use
+func (c *L1Cache) tracker(chainID, endpoint string) *chainTracker {
+	c.trackersMtx.Lock()
+	defer c.trackersMtx.Unlock()
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
-			return
-		}
-		defer r.Body.Close()
+	t, found := c.trackers[chainID]
+	if !found {
+		t = newChainTracker(chainID, endpoint)
+		c.trackers[chainID] = t
+		return t
+	}
+	t.setEndpoint(endpoint)
+	return t
+}
 
-		var request map[string]interface{}
-		if err := json.Unmarshal(body, &request); err != nil {
-			http.Error(w, "Invalid JSON-RPC request", http.StatusBadRequest)
-			return
-		}
+func (c *L1Cache) trackFinality(ctx context.Context) {
+	interval := c.cfg.FinalityPollInterval
+	if interval <= 0 {
+		interval = 12 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		method, ok := request["method"].(string)
-		if !ok {
-			http.Error(w, "Invalid JSON-RPC method", http.StatusBadRequest)
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			c.pollTrackers(ctx)
 		}
+	}
+}
 
-		cacheKey, err := generateCacheKey(chainID, body)
+func (c *L1Cache) pollTrackers(ctx context.Context) {
+	c.trackersMtx.Lock()
+	trackers := make([]*chainTracker, 0, len(c.trackers))
+	for _, t := range c.trackers {
+		trackers = append(trackers, t)
+	}
+	c.trackersMtx.Unlock()
+
+	for _, t := range trackers {
+		reorged, err := t.poll(ctx)
 		if err != nil {
-			http.Error(w, "Failed to generate cache key", http.StatusInternalServerError)
-			return
+			log.Warn("[l1-cache] Failed to check the L1 head", "chainid", t.chainID, "err", err)
+			continue
 		}
-
-		if _, ignore := methodsToIgnore[method]; !ignore {
-			tx, err := db.BeginRw(r.Context())
-			if err != nil {
-				http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
-				return
-			}
-			if cachedResponse, found := fetchFromCache(tx, cacheKey); found {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("X-Cache-Status", "HIT")
-				w.Write(cachedResponse)
-				tx.Commit()
-				return
-			}
-			tx.Rollback()
+		if reorged {
+			c.onReorg(ctx, t.chainID)
 		}
+	}
+}
+
+func (c *L1Cache) onReorg(ctx context.Context, chainID string) {
+	cacheReorgsTotal.Inc()
+	var evicted int
+	err := c.db.Update(ctx, func(tx kv.RwTx) error {
+		var err error
+		evicted, err = evictUnfinalized(tx, chainID)
+		return err
+	})
+	if err != nil {
+		log.Error("[l1-cache] Failed to evict responses after an L1 reorg", "chainid", chainID, "err", err)
+		return
+	}
+	log.Info("[l1-cache] L1 reorg detected, evicted responses above finality", "chainid", chainID, "evicted", evicted)
+}
+
+func (c *L1Cache) handleRequest(w http.ResponseWriter, r *http.Request) {
+	endpoint := r.URL.Query().Get("endpoint")
+	chainID := r.URL.Query().Get("chainid")
+
+	if endpoint == "" || chainID == "" {
+		http.Error(w, "Missing endpoint or chainid parameter", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var request struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "Invalid JSON-RPC request", http.StatusBadRequest)
+		return
+	}
+	method := request.Method
+	if method == "" {
+		http.Error(w, "Invalid JSON-RPC method", http.StatusBadRequest)
+		return
+	}
 
-		resp, err := http.Post(endpoint, "application/json", bytes.NewBuffer(body))
+	cacheKey, err := generateCacheKey(chainID, body)
+	if err != nil {
+		http.Error(w, "Failed to generate cache key", http.StatusInternalServerError)
+		return
+	}
+
+	tracker := c.tracker(chainID, endpoint)
+
+	if _, ignore := methodsToIgnore[method]; !ignore {
+		tx, err := c.db.BeginRw(r.Context())
 		if err != nil {
-			http.Error(w, "Failed to fetch from upstream", http.StatusInternalServerError)
+			http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
 			return
 		}
-		defer resp.Body.Close()
-
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			http.Error(w, "Failed to read upstream response", http.StatusInternalServerError)
+		if cachedResponse, found := fetchFromCache(tx, cacheKey); found {
+			if err := tx.Commit(); err != nil {
+				log.Warn("Failed to commit cache access", "error", err)
+			}
+			countRequest(method, "hit")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Cache-Status", "HIT")
+			w.Write(cachedResponse)
 			return
 		}
+		// commit the eviction of an expired entry, if any
+		if err := tx.Commit(); err != nil {
+			log.Warn("Failed to commit cache eviction", "error", err)
+		}
+	}
+	countRequest(method, "miss")
+
+	resp, err := http.Post(endpoint, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		cacheUpstreamErrs.Inc()
+		http.Error(w, "Failed to fetch from upstream", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusOK {
-			// Check if the response contains a JSON-RPC error
-			var jsonResponse map[string]interface{}
-			if err := json.Unmarshal(responseBody, &jsonResponse); err == nil {
-				if _, hasError := jsonResponse["error"]; hasError {
-					log.Warn("Received error response from upstream, not caching", "error", jsonResponse["error"])
-				} else {
-					if _, ignore := methodsToIgnore[method]; !ignore {
-						cacheDuration := time.Duration(0)
-						if duration, found := methodsToExpire[method]; found {
-							if method == "eth_getBlockByNumber" {
-								params, ok := request["params"].([]interface{})
-								if ok && len(params) > 0 {
-									param, ok := params[0].(string)
-									if ok {
-										if _, shouldExpire := paramsToExpire[param]; shouldExpire {
-											cacheDuration = duration
-										}
-									}
-								}
-							} else {
-								cacheDuration = duration
-							}
-						}
-						tx, err := db.BeginRw(r.Context())
-						if err != nil {
-							http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
-							return
-						}
-						defer tx.Rollback()
-						if err := saveToCache(tx, cacheKey, responseBody, cacheDuration); err != nil {
-							http.Error(w, "Failed to save to cache", http.StatusInternalServerError)
-							return
-						}
-						tx.Commit()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		cacheUpstreamErrs.Inc()
+		http.Error(w, "Failed to read upstream response", http.StatusInternalServerError)
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		// Check if the response contains a JSON-RPC error
+		var jsonResponse struct {
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(responseBody, &jsonResponse); err == nil {
+			if len(jsonResponse.Error) > 0 && string(jsonResponse.Error) != "null" {
+				log.Warn("Received error response from upstream, not caching", "error", string(jsonResponse.Error))
+			} else if policy := c.cfg.policyFor(method, request.Params, jsonResponse.Result, tracker.finalizedBlock()); policy.cache {
+				if err := c.db.Update(r.Context(), func(tx kv.RwTx) error {
+					if err := saveToCache(tx, cacheKey, responseBody, policy); err != nil {
+						return err
 					}
+					return enforceSizeLimit(tx, uint64(c.cfg.MaxSize))
+				}); err != nil {
+					http.Error(w, "Failed to save to cache", http.StatusInternalServerError)
+					return
 				}
-			} else {
-				fmt.Println("Failed to parse upstream response, not caching")
 			}
+		} else {
+			log.Debug("Failed to parse upstream response, not caching", "method", method)
 		}
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache-Status", "MISS")
-		w.Write(responseBody)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache-Status", "MISS")
+	w.Write(responseBody)
+}
+
+// ParseMethodTTLs parses a comma separated list of method=duration pairs
+func ParseMethodTTLs(s string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		method, ttl, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid method ttl %q, expected method=duration", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid method ttl %q: %w", pair, err)
+		}
+		ttls[strings.TrimSpace(method)] = d
 	}
+	return ttls, nil
 }
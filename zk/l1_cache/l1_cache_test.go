@@ -0,0 +1,292 @@
+package l1_cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+// mockL1 is an upstream answering eth_getBlockByNumber from a list of block hashes and echoing everything else
+type mockL1 struct {
+	mtx       sync.Mutex
+	hashes    []string
+	finalized uint64
+	calls     map[string]int
+}
+
+func (m *mockL1) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.calls[req.Method]++
+
+	if req.Method == "eth_getBlockByNumber" {
+		var number uint64
+		switch tag := req.Params[0].(string); tag {
+		case "latest":
+			number = uint64(len(m.hashes) - 1)
+		case "finalized":
+			number = m.finalized
+		default:
+			fmt.Sscanf(tag, "0x%x", &number)
+		}
+		if number >= uint64(len(m.hashes)) {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":null}`)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x%x","hash":"%s"}}`, number, m.hashes[number])
+		return
+	}
+
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s-%d"}`, req.Method, m.calls[req.Method])
+}
+
+func (m *mockL1) callCount(method string) int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.calls[method]
+}
+
+func newTestCache(t *testing.T, cfg Config) (*L1Cache, *mockL1, string) {
+	db := memdb.NewTestDB(t)
+	c, err := newL1Cache(context.Background(), db, cfg)
+	require.NoError(t, err)
+
+	l1 := &mockL1{hashes: []string{"0xa0", "0xa1", "0xa2", "0xa3"}, finalized: 2, calls: map[string]int{}}
+	upstream := httptest.NewServer(l1)
+	t.Cleanup(upstream.Close)
+
+	return c, l1, fmt.Sprintf("/?endpoint=%s&chainid=1", url.QueryEscape(upstream.URL))
+}
+
+func doRequest(t *testing.T, c *L1Cache, target, method string, params ...interface{}) (string, string) {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c.handleRequest(w, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Header().Get("X-Cache-Status"), string(resp.Result)
+}
+
+// mainnetBlock1 is the eth_getBlockByHash response of the first block of mainnet, a block tells its number in number
+const mainnetBlock1 = `{"difficulty":"0x3ff800000","extraData":"0x476574682f76312e302e302f6c696e75782f676f312e342e32","gasLimit":"0x1388","gasUsed":"0x0","hash":"0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6","logsBloom":"0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","miner":"0x05a56e2d52c817161883f50c441c3228cfe54d9f","mixHash":"0x969b900de27b6ac6a67742365dd65f55a0526c41fd18e1b16f1a1215c2e66f59","nonce":"0x539bd4979fef1ec4","number":"0x1","parentHash":"0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3","receiptsRoot":"0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421","sha3Uncles":"0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347","size":"0x219","stateRoot":"0xd67e4d450343046425ae4271474353857ab860dbc0a1dde64b41b5cd3a532bf3","timestamp":"0x55ba4224","totalDifficulty":"0x7ff800000","transactions":[],"transactionsRoot":"0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421","uncles":[]}`
+
+func TestPolicyFor(t *testing.T) {
+	cfg := &Config{DefaultTTL: time.Minute, MethodTTLs: map[string]time.Duration{"eth_gasPrice": 5 * time.Second, "eth_call": 0}}
+	const finalized = 100
+
+	tests := []struct {
+		name   string
+		method string
+		params []interface{}
+		result string
+		want   cachePolicy
+	}{
+		{"ignored", "eth_sendRawTransaction", []interface{}{"0x00"}, "", cachePolicy{}},
+		{"chain id", "eth_chainId", nil, "", cachePolicy{cache: true, immutable: true}},
+		{"logs below finality", "eth_getLogs", []interface{}{map[string]interface{}{"fromBlock": "0x1", "toBlock": "0x64"}}, "", cachePolicy{cache: true, immutable: true}},
+		{"logs above finality", "eth_getLogs", []interface{}{map[string]interface{}{"fromBlock": "0x1", "toBlock": "0x65"}}, "", cachePolicy{cache: true, ttl: time.Minute}},
+		{"logs to latest", "eth_getLogs", []interface{}{map[string]interface{}{"fromBlock": "0x1"}}, "", cachePolicy{cache: true, ttl: time.Minute}},
+		{"logs by hash", "eth_getLogs", []interface{}{map[string]interface{}{"blockHash": "0xabc"}}, "", cachePolicy{cache: true, immutable: true}},
+		{"block below finality", "eth_getBlockByNumber", []interface{}{"0x10", false}, "", cachePolicy{cache: true, immutable: true}},
+		{"latest block", "eth_getBlockByNumber", []interface{}{"latest", false}, "", cachePolicy{cache: true, ttl: time.Minute}},
+		{"balance without block", "eth_getBalance", []interface{}{"0x01"}, "", cachePolicy{cache: true, ttl: time.Minute}},
+		{"call with method ttl disabled", "eth_call", []interface{}{map[string]interface{}{}, "latest"}, "", cachePolicy{ttl: 0}},
+		{"call below finality", "eth_call", []interface{}{map[string]interface{}{}, "0x1"}, "", cachePolicy{cache: true, immutable: true}},
+		{"method ttl", "eth_gasPrice", nil, "", cachePolicy{cache: true, ttl: 5 * time.Second}},
+		{"final receipt", "eth_getTransactionReceipt", []interface{}{"0x01"}, `{"blockNumber":"0x5"}`, cachePolicy{cache: true, immutable: true}},
+		{"recent receipt", "eth_getTransactionReceipt", []interface{}{"0x01"}, `{"blockNumber":"0x500"}`, cachePolicy{cache: true, ttl: time.Minute}},
+		{"pending receipt", "eth_getTransactionReceipt", []interface{}{"0x01"}, `null`, cachePolicy{}},
+		{"final block by hash", "eth_getBlockByHash", []interface{}{"0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6", false}, mainnetBlock1, cachePolicy{cache: true, immutable: true}},
+		{"recent block by hash", "eth_getBlockByHash", []interface{}{"0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6", false}, strings.Replace(mainnetBlock1, `"number":"0x1"`, `"number":"0x500"`, 1), cachePolicy{cache: true, ttl: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, cfg.policyFor(tt.method, tt.params, json.RawMessage(tt.result), finalized))
+		})
+	}
+
+	t.Run("nothing is final before finality is known", func(t *testing.T) {
+		require.False(t, cfg.policyFor("eth_getBlockByNumber", []interface{}{"0x1", false}, nil, 0).immutable)
+	})
+}
+
+func TestL1Cache_ImmutableAndExpiringResponses(t *testing.T) {
+	c, l1, target := newTestCache(t, Config{DefaultTTL: time.Hour})
+	tracker := c.tracker("1", upstreamOf(t, target))
+	_, err := tracker.poll(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), tracker.finalizedBlock())
+
+	final := map[string]interface{}{"fromBlock": "0x0", "toBlock": "0x2"}
+	status, first := doRequest(t, c, target, "eth_getLogs", final)
+	require.Equal(t, "MISS", status)
+	status, second := doRequest(t, c, target, "eth_getLogs", final)
+	require.Equal(t, "HIT", status)
+	require.Equal(t, first, second)
+	require.Equal(t, 1, l1.callCount("eth_getLogs"))
+
+	recent := map[string]interface{}{"fromBlock": "0x0", "toBlock": "0x3"}
+	status, _ = doRequest(t, c, target, "eth_getLogs", recent)
+	require.Equal(t, "MISS", status)
+	status, _ = doRequest(t, c, target, "eth_getLogs", recent)
+	require.Equal(t, "HIT", status)
+
+	require.NoError(t, c.db.View(context.Background(), func(tx kv.Tx) error {
+		finalKey, _ := generateCacheKey("1", mustMarshal(t, "eth_getLogs", final))
+		recentKey, _ := generateCacheKey("1", mustMarshal(t, "eth_getLogs", recent))
+
+		expiry, err := tx.GetOne(expiryBucket, []byte(finalKey))
+		require.NoError(t, err)
+		require.Nil(t, expiry, "immutable responses never expire")
+
+		expiry, err = tx.GetOne(expiryBucket, []byte(recentKey))
+		require.NoError(t, err)
+		require.NotNil(t, expiry)
+		return nil
+	}))
+
+	// a reorg of the head drops the responses above finality but keeps the final ones
+	l1.mtx.Lock()
+	l1.hashes = []string{"0xa0", "0xa1", "0xa2", "0xb3"}
+	l1.mtx.Unlock()
+	c.pollTrackers(context.Background())
+
+	status, _ = doRequest(t, c, target, "eth_getLogs", recent)
+	require.Equal(t, "MISS", status)
+	status, _ = doRequest(t, c, target, "eth_getLogs", final)
+	require.Equal(t, "HIT", status)
+}
+
+func TestL1Cache_SizeLimit(t *testing.T) {
+	c, l1, target := newTestCache(t, Config{DefaultTTL: time.Hour})
+
+	doRequest(t, c, target, "eth_chainId")
+	doRequest(t, c, target, "net_version")
+
+	var size uint64
+	require.NoError(t, c.db.View(context.Background(), func(tx kv.Tx) error {
+		var err error
+		size, err = getSize(tx)
+		return err
+	}))
+
+	// room for about two entries, the least recently used one goes when a third is added
+	c.cfg.MaxSize = datasize.ByteSize(size + 16)
+	status, _ := doRequest(t, c, target, "eth_chainId")
+	require.Equal(t, "HIT", status)
+	doRequest(t, c, target, "eth_gasPrice")
+
+	status, _ = doRequest(t, c, target, "eth_chainId")
+	require.Equal(t, "HIT", status)
+	status, _ = doRequest(t, c, target, "net_version")
+	require.Equal(t, "MISS", status)
+	require.Equal(t, 2, l1.callCount("net_version"))
+}
+
+func TestChainTracker_DetectsReorg(t *testing.T) {
+	l1 := &mockL1{hashes: []string{"0xa0", "0xa1"}, calls: map[string]int{}}
+	upstream := httptest.NewServer(l1)
+	defer upstream.Close()
+
+	tracker := newChainTracker("1", upstream.URL)
+	for i, step := range []struct {
+		hashes  []string
+		reorged bool
+	}{
+		{[]string{"0xa0", "0xa1"}, false},
+		{[]string{"0xa0", "0xa1", "0xa2"}, false},
+		{[]string{"0xa0", "0xa1", "0xa2", "0xa3", "0xa4"}, false},
+		{[]string{"0xa0", "0xa1", "0xa2", "0xa3", "0xb4"}, true},
+		{[]string{"0xa0", "0xa1", "0xa2"}, true},
+	} {
+		l1.mtx.Lock()
+		l1.hashes = step.hashes
+		l1.mtx.Unlock()
+
+		reorged, err := tracker.poll(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, step.reorged, reorged, "step %d", i)
+	}
+}
+
+func TestParseMethodTTLs(t *testing.T) {
+	ttls, err := ParseMethodTTLs("eth_getLogs=1m, eth_gasPrice=5s,,eth_call=0s")
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Duration{"eth_getLogs": time.Minute, "eth_gasPrice": 5 * time.Second, "eth_call": 0}, ttls)
+
+	_, err = ParseMethodTTLs("eth_getLogs")
+	require.Error(t, err)
+	_, err = ParseMethodTTLs("eth_getLogs=soon")
+	require.Error(t, err)
+}
+
+func TestIndexExistingEntries(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	stale, _ := generateCacheKey("1", mustMarshal(t, "eth_getBlockByNumber", "latest", false))
+	final, _ := generateCacheKey("1", mustMarshal(t, "eth_getBlockByNumber", "0x1", false))
+
+	// a cache written before the size cap existed
+	require.NoError(t, db.Update(context.Background(), func(tx kv.RwTx) error {
+		require.NoError(t, tx.CreateBucket(bucketName))
+		require.NoError(t, tx.Put(bucketName, []byte(stale), []byte(`{"result":{}}`)))
+		return tx.Put(bucketName, []byte(final), []byte(`{"result":{}}`))
+	}))
+
+	_, err := newL1Cache(context.Background(), db, Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.View(context.Background(), func(tx kv.Tx) error {
+		v, err := tx.GetOne(bucketName, []byte(stale))
+		require.NoError(t, err)
+		require.Nil(t, v)
+
+		v, err = tx.GetOne(accessBucket, []byte(final))
+		require.NoError(t, err)
+		require.NotNil(t, v)
+
+		size, err := getSize(tx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(final)+len(`{"result":{}}`)), size)
+		return nil
+	}))
+}
+
+func mustMarshal(t *testing.T, method string, params ...interface{}) []byte {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	return body
+}
+
+func upstreamOf(t *testing.T, target string) string {
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+	return u.Query().Get("endpoint")
+}
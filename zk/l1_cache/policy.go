@@ -0,0 +1,143 @@
+package l1_cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+)
+
+// methods we don't cache
+var methodsToIgnore = map[string]struct{}{
+	"eth_sendRawTransaction": {},
+	"eth_sendTransaction":    {},
+	"eth_blockNumber":        {},
+}
+
+// methods whose response never changes for the chain
+var immutableMethods = map[string]struct{}{
+	"eth_chainId": {},
+	"net_version": {},
+}
+
+// position of the block number or tag in the params of the methods taking one, the tag defaults to latest if missing
+var blockParamIndex = map[string]int{
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_getStorageAt":                        2,
+}
+
+// methods looking a block or transaction up by hash, by the field of the response telling which block it belongs to
+var byHashMethods = map[string]string{
+	"eth_getBlockByHash":        "number",
+	"eth_getTransactionByHash":  "blockNumber",
+	"eth_getTransactionReceipt": "blockNumber",
+}
+
+// cachePolicy is how a response is cached
+type cachePolicy struct {
+	cache     bool          // false if the response must not be cached at all
+	immutable bool          // true if the response only covers finalized blocks and can be kept forever
+	ttl       time.Duration // how long a response that is not immutable is kept for
+}
+
+// resolveTTL returns how long a response of the method that may still change is kept for
+func (c *Config) resolveTTL(method string) time.Duration {
+	if ttl, found := c.MethodTTLs[method]; found {
+		return ttl
+	}
+	return c.DefaultTTL
+}
+
+// policyFor decides how to cache the response of a request. A response is immutable if everything it covers is at or
+// below the finalized L1 block, anything else is kept for the TTL of the method and dropped on an L1 reorg.
+func (c *Config) policyFor(method string, params []interface{}, result json.RawMessage, finalized uint64) cachePolicy {
+	if _, ignore := methodsToIgnore[method]; ignore {
+		return cachePolicy{}
+	}
+
+	mutable := cachePolicy{cache: true, ttl: c.resolveTTL(method)}
+	if mutable.ttl <= 0 {
+		mutable.cache = false
+	}
+	immutable := cachePolicy{cache: true, immutable: true}
+
+	if _, found := immutableMethods[method]; found {
+		return immutable
+	}
+
+	if method == "eth_getLogs" {
+		if len(params) == 0 {
+			return mutable
+		}
+		filter, ok := params[0].(map[string]interface{})
+		if !ok {
+			return mutable
+		}
+		// logs of a block looked up by hash never change
+		if _, byHash := filter["blockHash"]; byHash {
+			return immutable
+		}
+		if isFinalized(filter["toBlock"], finalized) {
+			return immutable
+		}
+		return mutable
+	}
+
+	if idx, found := blockParamIndex[method]; found {
+		var block interface{}
+		if len(params) > idx {
+			block = params[idx]
+		}
+		if isFinalized(block, finalized) {
+			return immutable
+		}
+		return mutable
+	}
+
+	if field, found := byHashMethods[method]; found {
+		if len(result) == 0 || string(result) == "null" {
+			// not known yet, e.g. a transaction that is still pending
+			return cachePolicy{}
+		}
+		var res map[string]json.RawMessage
+		if err := json.Unmarshal(result, &res); err != nil {
+			return mutable
+		}
+		var blockNumber *hexutil.Uint64
+		if err := json.Unmarshal(res[field], &blockNumber); err != nil || blockNumber == nil {
+			return mutable
+		}
+		if finalized > 0 && uint64(*blockNumber) <= finalized {
+			return immutable
+		}
+		return mutable
+	}
+
+	return mutable
+}
+
+// isFinalized checks a block number or tag param refers to a block at or below the finalized block
+func isFinalized(block interface{}, finalized uint64) bool {
+	tag, ok := block.(string)
+	if !ok {
+		// a missing block defaults to latest
+		return false
+	}
+	switch tag {
+	case "earliest":
+		return true
+	case "latest", "pending", "safe", "finalized", "":
+		return false
+	}
+	number, err := hexutil.DecodeUint64(tag)
+	if err != nil {
+		return false
+	}
+	return finalized > 0 && number <= finalized
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/common/length"
 	"github.com/ledgerwatch/erigon/accounts/abi"
-	"github.com/ledgerwatch/erigon/crypto"
 	"github.com/ledgerwatch/erigon/zk/contracts"
 	"github.com/ledgerwatch/erigon/zk/da"
 	zktx "github.com/ledgerwatch/erigon/zk/tx"
@@ -37,7 +36,7 @@ func BuildSequencesForRollup(data []byte) ([]RollupBaseEtrogBatchData, error) {
 	return sequences, err
 }
 
-func BuildSequencesForValidium(data []byte, daUrl string) ([]RollupBaseEtrogBatchData, error) {
+func BuildSequencesForValidium(data []byte, daBackend da.DABackend) ([]RollupBaseEtrogBatchData, error) {
 	var sequences []RollupBaseEtrogBatchData
 	var validiumSequences []ValidiumBatchData
 	err := json.Unmarshal(data, &validiumSequences)
@@ -48,14 +47,10 @@ func BuildSequencesForValidium(data []byte, daUrl string) ([]RollupBaseEtrogBatc
 
 	for _, validiumSequence := range validiumSequences {
 		hash := common.BytesToHash(validiumSequence.TransactionsHash[:])
-		data, err := da.GetOffChainData(context.Background(), daUrl, hash)
+		// the backend checks the data against the hash
+		data, err := daBackend.GetOffChainData(context.Background(), hash)
 		if err != nil {
-			return nil, err
-		}
-
-		actualTransactionsHash := crypto.Keccak256Hash(data)
-		if actualTransactionsHash != hash {
-			return nil, fmt.Errorf("unable to fetch off chain data for hash %s, got %s intead", hash.String(), actualTransactionsHash.String())
+			return nil, fmt.Errorf("unable to fetch off chain data for hash %s: %w", hash.String(), err)
 		}
 
 		sequences = append(sequences, RollupBaseEtrogBatchData{
@@ -69,7 +64,7 @@ func BuildSequencesForValidium(data []byte, daUrl string) ([]RollupBaseEtrogBatc
 	return sequences, nil
 }
 
-func DecodeL1BatchData(txData []byte, daUrl string) ([][]byte, common.Address, uint64, error) {
+func DecodeL1BatchData(txData []byte, daBackend da.DABackend) ([][]byte, common.Address, uint64, error) {
 	// we need to know which version of the ABI to use here so lets find it
 	idAsString := fmt.Sprintf("%x", txData[:4])
 	abiMapped, found := contracts.SequenceBatchesMapping[idAsString]
@@ -117,8 +112,8 @@ func DecodeL1BatchData(txData []byte, daUrl string) ([][]byte, common.Address, u
 		}
 		limitTimstamp = ts
 	case contracts.SequenceBatchesValidiumElderBerry:
-		if daUrl == "" {
-			return nil, common.Address{}, 0, fmt.Errorf("data availability url is required for validium")
+		if daBackend == nil {
+			return nil, common.Address{}, 0, fmt.Errorf("data availability backend is required for validium")
 		}
 		isValidium = true
 		cb, ok := data[3].(common.Address)
@@ -143,8 +138,8 @@ func DecodeL1BatchData(txData []byte, daUrl string) ([][]byte, common.Address, u
 		}
 		limitTimstamp = ts
 	case contracts.SequenceBatchesValidiumBanana:
-		if daUrl == "" {
-			return nil, common.Address{}, 0, fmt.Errorf("data availability url is required for validium")
+		if daBackend == nil {
+			return nil, common.Address{}, 0, fmt.Errorf("data availability backend is required for validium")
 		}
 		isValidium = true
 		cb, ok := data[4].(common.Address)
@@ -169,7 +164,7 @@ func DecodeL1BatchData(txData []byte, daUrl string) ([][]byte, common.Address, u
 	}
 
 	if isValidium {
-		sequences, err = BuildSequencesForValidium(bytedata, daUrl)
+		sequences, err = BuildSequencesForValidium(bytedata, daBackend)
 	} else {
 		sequences, err = BuildSequencesForRollup(bytedata)
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
 	"github.com/ledgerwatch/erigon/zk/contracts"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/erigon/zk/syncer"
 	zkTypes "github.com/ledgerwatch/erigon/zk/types"
 	"github.com/ledgerwatch/log/v3"
 )
@@ -24,7 +25,9 @@ type Syncer interface {
 	GetLogsChan() chan []types.Log
 	GetProgressMessageChan() chan string
 	IsDownloading() bool
+	GetLastCheckedL1Block() uint64
 	GetHeader(blockNumber uint64) (*types.Header, error)
+	CheckL1BlockFinalized(blockNo uint64) (bool, uint64, error)
 	L1QueryHeaders(logs []types.Log) (map[uint64]*types.Header, error)
 	StopQueryBlocks()
 	ConsumeQueryBlocks()
@@ -36,6 +39,7 @@ type Updater struct {
 	syncer       Syncer
 	progress     uint64
 	latestUpdate *zkTypes.L1InfoTreeUpdate
+	checkedHead  uint64 // the last checked L1 block whose hash is kept
 }
 
 func NewUpdater(cfg *ethconfig.Zk, syncer Syncer) *Updater {
@@ -72,6 +76,12 @@ func (u *Updater) WarmUp(tx kv.RwTx) (err error) {
 		progress = u.cfg.L1FirstBlock - 1
 	}
 
+	if u.cfg.TracksL1Reorgs() {
+		if progress, err = u.unwindReorg(tx, hermezDb, progress); err != nil {
+			return fmt.Errorf("unwindReorg: %w", err)
+		}
+	}
+
 	u.progress = progress
 
 	latestUpdate, err := hermezDb.GetLatestL1InfoTreeUpdate()
@@ -169,6 +179,11 @@ LOOP:
 						return nil, fmt.Errorf("GetHeader: %w", err)
 					}
 				}
+				if u.cfg.TracksL1Reorgs() {
+					if err = hermezDb.WriteL1BlockHash(string(stages.L1InfoTree), l.BlockNumber, header.Hash()); err != nil {
+						return nil, fmt.Errorf("WriteL1BlockHash: %w", err)
+					}
+				}
 
 				tmpUpdate, err := createL1InfoTreeUpdate(l, header)
 				if err != nil {
@@ -224,9 +239,55 @@ LOOP:
 		return nil, fmt.Errorf("SaveStageProgress: %w", err)
 	}
 
+	// the blocks checked without any update could be reorged to ones with updates, the hash of the last one is kept
+	// as well
+	if checkedHead := u.syncer.GetLastCheckedL1Block(); u.cfg.TracksL1Reorgs() && checkedHead > 0 && checkedHead != u.checkedHead {
+		header, err := u.syncer.GetHeader(checkedHead)
+		if err != nil {
+			return nil, fmt.Errorf("GetHeader: %w", err)
+		}
+		if err = hermezDb.WriteL1BlockHash(string(stages.L1InfoTree), checkedHead, header.Hash()); err != nil {
+			return nil, fmt.Errorf("WriteL1BlockHash: %w", err)
+		}
+		u.checkedHead = checkedHead
+	}
+
 	return allLogs, nil
 }
 
+// unwindReorg rolls back the info tree updates of the L1 blocks reorged out, along with their leaves and roots, and
+// restarts the syncer from the highest block still canonical to fetch them again. It returns the progress to start
+// from.
+func (u *Updater) unwindReorg(tx kv.RwTx, hermezDb *hermez_db.HermezDb, progress uint64) (uint64, error) {
+	ancestor, reorged, err := syncer.CheckL1Reorg(hermezDb, string(stages.L1InfoTree), u.syncer)
+	if err != nil || !reorged {
+		return progress, err
+	}
+
+	// the logs the syncer already fetched past the ancestor are dropped
+	if u.syncer.IsSyncStarted() {
+		u.syncer.StopQueryBlocks()
+		u.syncer.ConsumeQueryBlocks()
+		u.syncer.WaitQueryBlocksToFinish()
+	}
+	u.checkedHead = 0
+
+	deleted, err := hermezDb.TruncateL1InfoTreeUpdatesByL1Block(ancestor)
+	if err != nil {
+		return 0, fmt.Errorf("TruncateL1InfoTreeUpdatesByL1Block: %w", err)
+	}
+
+	if ancestor < progress {
+		progress = ancestor
+		if err = stages.SaveStageProgress(tx, stages.L1InfoTree, progress); err != nil {
+			return 0, fmt.Errorf("SaveStageProgress: %w", err)
+		}
+	}
+
+	log.Warn("L1 reorg, rolled back the info tree updates", "ancestor", ancestor, "progress", progress, "deletedUpdates", deleted)
+	return progress, nil
+}
+
 func chunkLogs(slice []types.Log, chunkSize int) [][]types.Log {
 	var chunks [][]types.Log
 	for i := 0; i < len(slice); i += chunkSize {
@@ -9,11 +9,15 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
 	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
 	"github.com/ledgerwatch/log/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
@@ -31,6 +35,8 @@ type Config struct {
 	Timeout               time.Duration
 	MaxConcurrentRequests int
 	OutputLocation        string
+	SlowRequestThreshold  time.Duration // requests taking longer than this mark the executor as unhealthy, 0 disables the check
+	UnhealthyBackoff      time.Duration // base time an unhealthy executor is skipped for, doubled on every consecutive failure
 }
 
 type Payload struct {
@@ -62,6 +68,10 @@ type Executor struct {
 	client     executor.ExecutorServiceClient
 	semaphore  chan struct{}
 
+	// number of requests that have been assigned to this executor but are still waiting to acquire access
+	pending atomic.Int32
+	health  *executorHealth
+
 	// if not empty then the executor will write the payload to this location before sending it to the
 	// remote executor
 	outputLocation string
@@ -70,12 +80,12 @@ type Executor struct {
 func NewExecutors(cfg Config) []*Executor {
 	executors := make([]*Executor, len(cfg.GrpcUrls))
 	for i, grpcUrl := range cfg.GrpcUrls {
-		executors[i] = NewExecutor(grpcUrl, cfg.Timeout, cfg.MaxConcurrentRequests, cfg.OutputLocation)
+		executors[i] = NewExecutor(grpcUrl, cfg.Timeout, cfg.MaxConcurrentRequests, cfg.OutputLocation, cfg.SlowRequestThreshold, cfg.UnhealthyBackoff)
 	}
 	return executors
 }
 
-func NewExecutor(grpcUrl string, timeout time.Duration, maxConcurrentRequests int, outputLocation string) *Executor {
+func NewExecutor(grpcUrl string, timeout time.Duration, maxConcurrentRequests int, outputLocation string, slowRequestThreshold, unhealthyBackoff time.Duration) *Executor {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -94,6 +104,7 @@ func NewExecutor(grpcUrl string, timeout time.Duration, maxConcurrentRequests in
 		connCancel:     cancel,
 		client:         client,
 		semaphore:      make(chan struct{}, maxConcurrentRequests),
+		health:         newExecutorHealth(grpcUrl, slowRequestThreshold, unhealthyBackoff),
 		outputLocation: outputLocation,
 	}
 
@@ -116,12 +127,74 @@ func (e *Executor) QueueLength() int {
 	return len(e.semaphore)
 }
 
+// Load returns how busy the executor is relative to the number of requests it can handle concurrently,
+// including the requests that have been assigned to it but are still waiting for access
+func (e *Executor) Load() float64 {
+	capacity := cap(e.semaphore)
+	if capacity == 0 {
+		capacity = 1
+	}
+	return float64(e.QueueLength()+int(e.pending.Load())) / float64(capacity)
+}
+
+// ExpectedWait estimates how long a new request would take on this executor, based on its current load
+// and the latency observed on previous requests
+func (e *Executor) ExpectedWait() time.Duration {
+	latency := defaultExecutorLatency
+	if e.health != nil {
+		latency = e.health.latency()
+	}
+	return time.Duration((e.Load() + 1) * float64(latency))
+}
+
+// IsHealthy returns false while the executor is in its backoff period after a failed or slow request
+func (e *Executor) IsHealthy() bool {
+	if e.health == nil {
+		return true
+	}
+	return e.health.isHealthy(time.Now())
+}
+
+// reserve marks the executor as assigned to a request that is about to call AquireAccess so that
+// concurrent selections take it into account
+func (e *Executor) reserve() {
+	e.pending.Add(1)
+	e.updateQueueMetric()
+}
+
 func (e *Executor) AquireAccess() {
 	e.semaphore <- struct{}{}
+	// consume the reservation made when this executor was selected, if any
+	for {
+		p := e.pending.Load()
+		if p <= 0 || e.pending.CompareAndSwap(p, p-1) {
+			break
+		}
+	}
+	e.updateQueueMetric()
 }
 
 func (e *Executor) ReleaseAccess() {
 	<-e.semaphore
+	e.updateQueueMetric()
+}
+
+func (e *Executor) updateQueueMetric() {
+	if e.health != nil {
+		e.health.queueGauge.SetInt(e.QueueLength() + int(e.pending.Load()))
+	}
+}
+
+func (e *Executor) recordSuccess(took time.Duration) {
+	if e.health != nil {
+		e.health.recordSuccess(e.grpcUrl, took)
+	}
+}
+
+func (e *Executor) recordFailure() {
+	if e.health != nil {
+		e.health.recordFailure(e.grpcUrl)
+	}
 }
 
 func (e *Executor) CheckOnline() bool {
@@ -217,24 +290,19 @@ func (e *Executor) Verify(p *Payload, request *VerifierRequest, oldStateRoot com
 		}
 	}
 
+	requestStart := time.Now()
 	resp, err := e.client.ProcessStatelessBatchV2(ctx, grpcRequest, grpc.MaxCallSendMsgSize(size), grpc.MaxCallRecvMsgSize(size))
 	if err != nil {
+		e.recordFailure()
 		return false, nil, nil, fmt.Errorf("failed to process stateless batch: %w", err)
 	}
 	if resp == nil {
+		e.recordFailure()
 		return false, nil, nil, fmt.Errorf("nil response")
 	}
+	e.recordSuccess(time.Since(requestStart))
 
-	counters := map[string]int{
-		"SHA": int(resp.CntSha256Hashes),
-		"A":   int(resp.CntArithmetics),
-		"B":   int(resp.CntBinaries),
-		"K":   int(resp.CntKeccakHashes),
-		"M":   int(resp.CntMemAligns),
-		"P":   int(resp.CntPoseidonHashes),
-		"S":   int(resp.CntSteps),
-		"D":   int(resp.CntPoseidonPaddings),
-	}
+	counters := executorCounters(resp)
 
 	match := bytes.Equal(resp.NewStateRoot, request.StateRoot.Bytes())
 
@@ -314,10 +382,81 @@ func responseCheck(resp *executor.ProcessBatchResponseV2, request *VerifierReque
 	return true, resp, nil
 }
 
+func executorCounters(resp *executor.ProcessBatchResponseV2) map[string]int {
+	return map[string]int{
+		"SHA": int(resp.CntSha256Hashes),
+		"A":   int(resp.CntArithmetics),
+		"B":   int(resp.CntBinaries),
+		"K":   int(resp.CntKeccakHashes),
+		"M":   int(resp.CntMemAligns),
+		"P":   int(resp.CntPoseidonHashes),
+		"S":   int(resp.CntSteps),
+		"D":   int(resp.CntPoseidonPaddings),
+	}
+}
+
 func counterUndershootCheck(respCounters, counters map[string]int, batchNo uint64) {
+	for _, k := range counterUndershoots(respCounters, counters) {
+		log.Warn("Counter undershoot", "counter", k, "erigon", counters[k], "legacy", respCounters[k], "batch", batchNo)
+	}
+}
+
+// counterUndershoots returns the sorted names of the counters where ours are lower than the ones of the executor
+func counterUndershoots(respCounters, counters map[string]int) []string {
+	var undershoots []string
 	for k, legacy := range respCounters {
 		if counters[k] < legacy {
-			log.Warn("Counter undershoot", "counter", k, "erigon", counters[k], "legacy", legacy, "batch", batchNo)
+			undershoots = append(undershoots, k)
 		}
 	}
+	sort.Strings(undershoots)
+	return undershoots
+}
+
+// newVerificationFailure builds the record of a failed verification, resp may be nil if the executor did not respond.
+// It returns nil if the verification did not fail.
+func newVerificationFailure(grpcUrl string, request *VerifierRequest, oldStateRoot common.Hash, resp *executor.ProcessBatchResponseV2, executorErr error) *zktypes.VerificationFailure {
+	var undershoots []string
+	if resp != nil {
+		undershoots = counterUndershoots(executorCounters(resp), request.Counters)
+	}
+	if executorErr == nil && len(undershoots) == 0 {
+		return nil
+	}
+
+	failure := &zktypes.VerificationFailure{
+		BatchNumber:        hexutil.Uint64(request.BatchNumber),
+		BlockNumbers:       hexBlockNumbers(request.BlockNumbers),
+		ForkId:             hexutil.Uint64(request.ForkId),
+		OldStateRoot:       oldStateRoot,
+		ExpectedStateRoot:  request.StateRoot,
+		Counters:           request.Counters,
+		CounterUndershoots: undershoots,
+		ExecutorUrl:        grpcUrl,
+		Timestamp:          time.Now(),
+	}
+	if executorErr != nil {
+		failure.Error = executorErr.Error()
+	}
+	if resp != nil {
+		failure.ExecutorForkId = hexutil.Uint64(resp.ForkId)
+		failure.ExecutorStateRoot = common.BytesToHash(resp.NewStateRoot)
+		failure.ExecutorCounters = executorCounters(resp)
+		if resp.Error != executor.ExecutorError_EXECUTOR_ERROR_UNSPECIFIED && resp.Error != executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR {
+			failure.ExecutorError = resp.Error.String()
+		}
+		if resp.ErrorRom != executor.RomError_ROM_ERROR_NO_ERROR && resp.ErrorRom != executor.RomError_ROM_ERROR_UNSPECIFIED {
+			failure.RomError = resp.ErrorRom.String()
+		}
+	}
+
+	return failure
+}
+
+func hexBlockNumbers(blockNumbers []uint64) []hexutil.Uint64 {
+	numbers := make([]hexutil.Uint64, len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		numbers[i] = hexutil.Uint64(blockNumber)
+	}
+	return numbers
 }
@@ -0,0 +1,112 @@
+package legacy_executor_verifier
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// weight given to the most recent observation when updating the moving average of the latency
+	latencyEwmaWeight = 0.3
+
+	// latency assumed for an executor we have not yet heard back from, so that it still gets picked
+	// but does not look infinitely fast compared to the ones we have measured
+	defaultExecutorLatency = time.Second
+
+	// the backoff for an unhealthy executor doubles on every consecutive failure, capped at 2^maxBackoffShift
+	// times the base backoff
+	maxBackoffShift = 5
+)
+
+// executorHealth keeps track of how an executor has been behaving so that the verifier can prefer
+// fast executors with spare capacity and stay away from slow or failing ones for a while
+type executorHealth struct {
+	mtx sync.Mutex
+
+	avgLatency          time.Duration
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+
+	slowThreshold time.Duration
+	backoff       time.Duration
+
+	queueGauge    metrics.Gauge
+	latencyGauge  metrics.Gauge
+	healthyGauge  metrics.Gauge
+	failedCounter metrics.Counter
+}
+
+func newExecutorHealth(grpcUrl string, slowThreshold, backoff time.Duration) *executorHealth {
+	h := &executorHealth{
+		slowThreshold: slowThreshold,
+		backoff:       backoff,
+		queueGauge:    metrics.GetOrCreateGauge(fmt.Sprintf(`executor_queue_length{executor="%s"}`, grpcUrl)),
+		latencyGauge:  metrics.GetOrCreateGauge(fmt.Sprintf(`executor_latency_ms{executor="%s"}`, grpcUrl)),
+		healthyGauge:  metrics.GetOrCreateGauge(fmt.Sprintf(`executor_healthy{executor="%s"}`, grpcUrl)),
+		failedCounter: metrics.GetOrCreateCounter(fmt.Sprintf(`executor_failures_total{executor="%s"}`, grpcUrl)),
+	}
+	h.healthyGauge.SetInt(1)
+	return h
+}
+
+// latency returns the moving average of the request latency or the default when nothing has been observed yet
+func (h *executorHealth) latency() time.Duration {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.avgLatency == 0 {
+		return defaultExecutorLatency
+	}
+	return h.avgLatency
+}
+
+func (h *executorHealth) isHealthy(now time.Time) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return !now.Before(h.unhealthyUntil)
+}
+
+// recordSuccess updates the latency average and marks the executor unhealthy if it has become too slow
+func (h *executorHealth) recordSuccess(grpcUrl string, took time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.avgLatency == 0 {
+		h.avgLatency = took
+	} else {
+		h.avgLatency = time.Duration(latencyEwmaWeight*float64(took) + (1-latencyEwmaWeight)*float64(h.avgLatency))
+	}
+	h.latencyGauge.SetInt(int(h.avgLatency.Milliseconds()))
+
+	if h.slowThreshold > 0 && took > h.slowThreshold {
+		log.Warn("Executor responded slowly", "grpcUrl", grpcUrl, "took", took, "threshold", h.slowThreshold)
+		h.markUnhealthy(grpcUrl)
+		return
+	}
+
+	h.consecutiveFailures = 0
+	h.unhealthyUntil = time.Time{}
+	h.healthyGauge.SetInt(1)
+}
+
+// recordFailure marks the executor unhealthy for a backoff period that grows with every consecutive failure
+func (h *executorHealth) recordFailure(grpcUrl string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.markUnhealthy(grpcUrl)
+}
+
+// markUnhealthy must be called with the mutex held
+func (h *executorHealth) markUnhealthy(grpcUrl string) {
+	h.consecutiveFailures++
+	h.failedCounter.Inc()
+
+	wait := h.backoff << min(h.consecutiveFailures-1, maxBackoffShift)
+	h.unhealthyUntil = time.Now().Add(wait)
+	h.healthyGauge.SetInt(0)
+
+	log.Warn("Executor marked as unhealthy", "grpcUrl", grpcUrl, "consecutive-failures", h.consecutiveFailures, "backoff", wait)
+}
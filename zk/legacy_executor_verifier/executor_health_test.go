@@ -0,0 +1,116 @@
+package legacy_executor_verifier
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestExecutor(t *testing.T, name string, maxConcurrentRequests int, slowThreshold time.Duration) *Executor {
+	// a new client stays idle until it is used, which CheckOnline treats as online
+	conn, err := grpc.NewClient("passthrough:///"+name, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	grpcUrl := fmt.Sprintf("%s-%s", t.Name(), name)
+	return &Executor{
+		grpcUrl:   grpcUrl,
+		conn:      conn,
+		semaphore: make(chan struct{}, maxConcurrentRequests),
+		health:    newExecutorHealth(grpcUrl, slowThreshold, time.Minute),
+	}
+}
+
+func newTestVerifier(executors ...*Executor) *LegacyExecutorVerifier {
+	return &LegacyExecutorVerifier{
+		executors:    executors,
+		mtxExecutors: &sync.Mutex{},
+	}
+}
+
+func TestGetNextOnlineAvailableExecutor_PrefersSpareCapacity(t *testing.T) {
+	busy := newTestExecutor(t, "busy", 2, 0)
+	idle := newTestExecutor(t, "idle", 2, 0)
+	v := newTestVerifier(busy, idle)
+
+	busy.AquireAccess()
+	busy.AquireAccess()
+
+	for i := 0; i < 4; i++ {
+		if e := v.GetNextOnlineAvailableExecutor(); e != idle {
+			t.Fatalf("iteration %d: expected the idle executor, got %s", i, e.grpcUrl)
+		}
+		idle.AquireAccess()
+		idle.ReleaseAccess()
+	}
+}
+
+func TestGetNextOnlineAvailableExecutor_PrefersLowLatency(t *testing.T) {
+	slow := newTestExecutor(t, "slow", 1, 0)
+	fast := newTestExecutor(t, "fast", 1, 0)
+	v := newTestVerifier(slow, fast)
+
+	slow.recordSuccess(10 * time.Second)
+	fast.recordSuccess(100 * time.Millisecond)
+
+	if e := v.GetNextOnlineAvailableExecutor(); e != fast {
+		t.Fatalf("expected the fast executor, got %s", e.grpcUrl)
+	}
+
+	// the reservation makes the fast executor busier, but it is still expected to answer sooner
+	if e := v.GetNextOnlineAvailableExecutor(); e != fast {
+		t.Fatalf("expected the fast executor, got %s", e.grpcUrl)
+	}
+}
+
+func TestGetNextOnlineAvailableExecutor_SkipsUnhealthy(t *testing.T) {
+	failing := newTestExecutor(t, "failing", 4, time.Second)
+	healthy := newTestExecutor(t, "healthy", 1, time.Second)
+	v := newTestVerifier(failing, healthy)
+
+	healthy.AquireAccess()
+	failing.recordFailure()
+
+	if failing.IsHealthy() {
+		t.Fatal("expected executor to be unhealthy after a failure")
+	}
+	if e := v.GetNextOnlineAvailableExecutor(); e != healthy {
+		t.Fatalf("expected the healthy executor, got %s", e.grpcUrl)
+	}
+
+	// a slow response also marks the executor as unhealthy
+	healthy.recordSuccess(2 * time.Second)
+	if healthy.IsHealthy() {
+		t.Fatal("expected executor to be unhealthy after a slow response")
+	}
+
+	// with nothing healthy left we still fall back to the least loaded executor rather than stalling
+	if e := v.GetNextOnlineAvailableExecutor(); e != failing {
+		t.Fatalf("expected fallback to the failing executor, got %s", e.grpcUrl)
+	}
+}
+
+func TestExecutorHealth_Backoff(t *testing.T) {
+	h := newExecutorHealth(t.Name(), 0, time.Second)
+
+	for i := 1; i <= 8; i++ {
+		before := time.Now()
+		h.recordFailure(t.Name())
+
+		expected := time.Second << min(i-1, maxBackoffShift)
+		if got := h.unhealthyUntil.Sub(before); got < expected || got > expected+time.Second {
+			t.Fatalf("failure %d: expected backoff of about %s, got %s", i, expected, got)
+		}
+	}
+
+	h.recordSuccess(t.Name(), time.Millisecond)
+	if !h.isHealthy(time.Now()) || h.consecutiveFailures != 0 {
+		t.Fatal("expected a successful response to reset the backoff")
+	}
+}
@@ -89,3 +89,38 @@ func TestExecutor_Verify(t *testing.T) {
 		}(tt)
 	}
 }
+
+func TestNewVerificationFailure(t *testing.T) {
+	request := NewVerifierRequest(12, 5, []uint64{50, 51}, common.HexToHash("0x1"), map[string]int{"S": 100, "K": 10})
+
+	resp := &executor.ProcessBatchResponseV2{
+		NewStateRoot:    common.HexToHash("0x1").Bytes(),
+		ForkId:          12,
+		CntSteps:        50,
+		CntKeccakHashes: 5,
+	}
+	if failure := newVerificationFailure("executor:50071", request, common.HexToHash("0x9"), resp, nil); failure != nil {
+		t.Fatalf("expected no failure, got %+v", failure)
+	}
+
+	resp.CntSteps = 150
+	resp.NewStateRoot = common.HexToHash("0x2").Bytes()
+	resp.ErrorRom = executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP
+	_, _, executorErr := responseCheck(resp, request)
+	failure := newVerificationFailure("executor:50071", request, common.HexToHash("0x9"), resp, executorErr)
+	if failure == nil {
+		t.Fatal("expected a failure")
+	}
+	if failure.BatchNumber != 5 || failure.ForkId != 12 || failure.ExecutorUrl != "executor:50071" {
+		t.Errorf("unexpected failure details %+v", failure)
+	}
+	if failure.ExecutorStateRoot != common.HexToHash("0x2") || failure.OldStateRoot != common.HexToHash("0x9") {
+		t.Errorf("unexpected roots %+v", failure)
+	}
+	if len(failure.CounterUndershoots) != 1 || failure.CounterUndershoots[0] != "S" {
+		t.Errorf("unexpected counter undershoots %v", failure.CounterUndershoots)
+	}
+	if failure.ExecutorCounters["S"] != 150 || failure.RomError != executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP.String() || failure.Error == "" {
+		t.Errorf("unexpected executor details %+v", failure)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/ledgerwatch/erigon/zk/datastream/server"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
 	"github.com/ledgerwatch/erigon/zk/utils"
 	"github.com/ledgerwatch/log/v3"
 )
@@ -86,6 +87,8 @@ type VerifierResponse struct {
 	ExecutorResponse *executor.ProcessBatchResponseV2
 	OriginalCounters map[string]int
 	Error            error
+	// Failure is the record of the failed verification the sequencer stores for zkevm_getVerificationFailures
+	Failure *zktypes.VerificationFailure
 }
 
 type VerifierBundle struct {
@@ -119,6 +122,7 @@ type LegacyExecutorVerifier struct {
 	cfg                    ethconfig.Zk
 	executors              []*Executor
 	executorNumber         int
+	mtxExecutors           *sync.Mutex
 	cancelAllVerifications atomic.Bool
 
 	streamServer     server.DataStreamServer
@@ -140,6 +144,7 @@ func NewLegacyExecutorVerifier(
 		cfg:                    cfg,
 		executors:              executors,
 		executorNumber:         0,
+		mtxExecutors:           &sync.Mutex{},
 		cancelAllVerifications: atomic.Bool{},
 		streamServer:           streamServer,
 		WitnessGenerator:       witnessGenerator,
@@ -162,10 +167,13 @@ func (v *LegacyExecutorVerifier) StartAsyncVerification(
 	var promise *Promise[*VerifierBundle]
 
 	request := NewVerifierRequestWithLimits(forkId, batchNumber, blockNumbers, stateRoot, counters, requestTimeout, retries)
-	if useRemoteExecutor {
-		promise = v.VerifyAsync(request)
-	} else {
+	switch {
+	case !useRemoteExecutor:
 		promise = v.VerifyWithoutExecutor(request)
+	case v.cfg.UseStatelessVerification():
+		promise = v.VerifyStateless(request)
+	default:
+		promise = v.VerifyAsync(request)
 	}
 
 	size := v.appendPromise(promise)
@@ -213,6 +221,8 @@ func (v *LegacyExecutorVerifier) VerifySync(tx kv.Tx, request *VerifierRequest,
 	if generalErr != nil {
 		return generalErr
 	}
+	// no failure is recorded here: the limbo re-verifies the transactions of a failed batch one by one, their
+	// records would replace the one of the batch
 	return executorErr
 }
 
@@ -316,6 +326,7 @@ func (v *LegacyExecutorVerifier) VerifyAsync(request *VerifierRequest) *Promise[
 			Witness:          witness,
 			ExecutorResponse: executorResponse,
 			Error:            executorErr,
+			Failure:          newVerificationFailure(e.grpcUrl, request, previousBlock.Root(), executorResponse, executorErr),
 		}
 		return verifierBundle, nil
 	})
@@ -428,25 +439,59 @@ func (v *LegacyExecutorVerifier) CancelAllRequests() {
 	v.promises = make([]*Promise[*VerifierBundle], 0)
 }
 
+// GetNextOnlineAvailableExecutor picks the online executor expected to handle a new request the soonest,
+// based on its queue, its capacity and the latency observed so far. Executors in their unhealthy backoff
+// period are only considered when no healthy executor is online.
+// The returned executor is reserved for the caller, which must call AquireAccess on it.
 func (v *LegacyExecutorVerifier) GetNextOnlineAvailableExecutor() *Executor {
-	var exec *Executor
+	v.mtxExecutors.Lock()
+	defer v.mtxExecutors.Unlock()
+
+	if len(v.executors) == 0 {
+		return nil
+	}
 
-	// TODO: find executors with spare capacity
+	// start from the executor after the last one picked so that ties are spread in a round-robin fashion
+	v.executorNumber++
+	if v.executorNumber >= len(v.executors) {
+		v.executorNumber = 0
+	}
 
-	// attempt to find an executor that is online amongst them all
+	var best, bestUnhealthy *Executor
 	for i := 0; i < len(v.executors); i++ {
-		v.executorNumber++
-		if v.executorNumber >= len(v.executors) {
-			v.executorNumber = 0
+		candidate := v.executors[(v.executorNumber+i)%len(v.executors)]
+
+		healthy := candidate.IsHealthy()
+		if healthy {
+			if best != nil && candidate.ExpectedWait() >= best.ExpectedWait() {
+				continue
+			}
+		} else if best != nil || (bestUnhealthy != nil && candidate.ExpectedWait() >= bestUnhealthy.ExpectedWait()) {
+			continue
 		}
-		temp := v.executors[v.executorNumber]
-		if temp.CheckOnline() {
-			exec = temp
-			break
+
+		if !candidate.CheckOnline() {
+			candidate.recordFailure()
+			continue
+		}
+
+		if healthy {
+			best = candidate
+		} else {
+			bestUnhealthy = candidate
 		}
 	}
 
-	return exec
+	if best == nil && bestUnhealthy != nil {
+		log.Warn("No healthy executor available, falling back to an unhealthy one", "grpcUrl", bestUnhealthy.grpcUrl)
+		best = bestUnhealthy
+	}
+
+	if best != nil {
+		best.reserve()
+	}
+
+	return best
 }
 
 func (v *LegacyExecutorVerifier) GetWholeBatchStreamBytes(
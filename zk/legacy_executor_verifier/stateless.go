@@ -0,0 +1,168 @@
+package legacy_executor_verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/erigon/zk/stateless"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/erigon/zk/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// statelessExecutorUrl stands for the executor url in the verification failures of the stateless verification
+const statelessExecutorUrl = "stateless"
+
+// VerifyStateless verifies the batch without an executor: it is re-executed on the SMT built from its witness and
+// checked against the state root and counters the sequencer got.
+func (v *LegacyExecutorVerifier) VerifyStateless(request *VerifierRequest) *Promise[*VerifierBundle] {
+	// ProcessResultsSequentially relies on the promise returning either a bundle or a bundle and an error, as VerifyAsync
+	return NewPromise[*VerifierBundle](func() (*VerifierBundle, error) {
+		verifierBundle := NewVerifierBundle(request, nil, false)
+		blockNumbers := request.BlockNumbers
+
+		innerCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := v.db.BeginRo(innerCtx)
+		if err != nil {
+			return verifierBundle, err
+		}
+		defer tx.Rollback()
+
+		hermezDb := hermez_db.NewHermezDbReader(tx)
+
+		witness, err := v.WitnessGenerator.GetWitnessByBlockRange(tx, innerCtx, blockNumbers[0], blockNumbers[len(blockNumbers)-1], false, v.cfg.WitnessFull)
+		if err != nil {
+			return verifierBundle, err
+		}
+
+		blocks := make([]*types.Block, 0, len(blockNumbers))
+		for _, blockNumber := range blockNumbers {
+			block, err := rawdb.ReadBlockByNumber(tx, blockNumber)
+			if err != nil {
+				return verifierBundle, err
+			}
+			blocks = append(blocks, block)
+		}
+		batchL2Data, err := utils.GenerateBatchDataFromDb(tx, hermezDb, blocks, request.ForkId)
+		if err != nil {
+			return verifierBundle, err
+		}
+
+		previousBlock, err := rawdb.ReadBlockByNumber(tx, blockNumbers[0]-1)
+		if err != nil {
+			return verifierBundle, err
+		}
+
+		cfg, err := v.statelessConfig(tx, hermezDb, previousBlock.NumberU64())
+		if err != nil {
+			return verifierBundle, err
+		}
+
+		batch, err := stateless.DecodeBatch(request.BatchNumber, request.ForkId, v.cfg.AddressSequencer, witness, batchL2Data, hermezDb.GetL1InfoTreeUpdate)
+		if err != nil {
+			return verifierBundle, err
+		}
+
+		verifierBundle.markAsreadyForSendingRequest()
+
+		if v.cancelAllVerifications.Load() {
+			return nil, ErrPromiseCancelled
+		}
+
+		t := utils.StartTimer("legacy-executor-verifier", "verify-stateless")
+		defer t.LogTimer()
+
+		result, verifyErr := stateless.Verify(innerCtx, *cfg, batch, request.StateRoot)
+		if verifyErr != nil && !errors.Is(verifyErr, stateless.ErrStateRootMismatch) && !errors.Is(verifyErr, stateless.ErrCountersOverflow) {
+			// the batch could not be re-executed at all, this is on our end rather than a bad batch
+			return verifierBundle, verifyErr
+		}
+		if verifyErr != nil {
+			log.Error("[Verifier] Stateless verification failed", "batch", request.BatchNumber, "err", verifyErr)
+		}
+
+		verifierBundle.Response = &VerifierResponse{
+			Valid:            verifyErr == nil,
+			Witness:          witness,
+			OriginalCounters: request.Counters,
+			Error:            verifyErr,
+			Failure:          newStatelessVerificationFailure(request, previousBlock.Root(), result, verifyErr),
+		}
+		return verifierBundle, nil
+	})
+}
+
+// statelessConfig returns the config the batch is re-executed with, the counters are estimated with the smt depth
+// the sequencer had at the start of the batch when it is known
+func (v *LegacyExecutorVerifier) statelessConfig(tx kv.Tx, hermezDb *hermez_db.HermezDbReader, previousBlockNumber uint64) (*stateless.Config, error) {
+	genesisHash, err := rawdb.ReadCanonicalHash(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+	chainConfig, err := rawdb.ReadChainConfig(tx, genesisHash)
+	if err != nil {
+		return nil, err
+	}
+	if chainConfig == nil {
+		return nil, fmt.Errorf("no chain config for genesis %s", genesisHash)
+	}
+	if err = utils.UpdateZkEVMBlockCfg(chainConfig, hermezDb, "stateless"); err != nil {
+		return nil, err
+	}
+
+	_, smtDepth, err := hermezDb.GetClosestSmtDepth(previousBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateless.Config{
+		ChainConfig:       chainConfig,
+		SmtReduction:      v.cfg.VirtualCountersSmtReduction,
+		SmtDepth:          int(smtDepth),
+		UnlimitedCounters: v.cfg.ShouldCountersBeUnlimited(false),
+	}, nil
+}
+
+// newStatelessVerificationFailure builds the record of a failed stateless verification, it returns nil if the
+// verification did not fail
+func newStatelessVerificationFailure(request *VerifierRequest, oldStateRoot common.Hash, result *stateless.Result, verifyErr error) *zktypes.VerificationFailure {
+	var undershoots []string
+	if result != nil {
+		undershoots = counterUndershoots(result.Counters, request.Counters)
+	}
+	if verifyErr == nil && len(undershoots) == 0 {
+		return nil
+	}
+
+	failure := &zktypes.VerificationFailure{
+		BatchNumber:        hexutil.Uint64(request.BatchNumber),
+		BlockNumbers:       hexBlockNumbers(request.BlockNumbers),
+		ForkId:             hexutil.Uint64(request.ForkId),
+		ExecutorForkId:     hexutil.Uint64(request.ForkId),
+		OldStateRoot:       oldStateRoot,
+		ExpectedStateRoot:  request.StateRoot,
+		Counters:           request.Counters,
+		CounterUndershoots: undershoots,
+		ExecutorUrl:        statelessExecutorUrl,
+		Timestamp:          time.Now(),
+	}
+	if verifyErr != nil {
+		failure.Error = verifyErr.Error()
+	}
+	if result != nil {
+		failure.ExecutorStateRoot = result.NewStateRoot
+		failure.ExecutorCounters = result.Counters
+	}
+
+	return failure
+}
@@ -14,6 +14,8 @@ const (
 	BatchTimeOut         BatchFinalizeType = "EmptyBatchTimeOut"
 	BatchCounterOverflow BatchFinalizeType = "BatchCounterOverflow"
 	BatchLimboRecovery   BatchFinalizeType = "LimboRecovery"
+	BatchDataOverflow    BatchFinalizeType = "BatchL2DataOverflow"
+	BatchGasOverflow     BatchFinalizeType = "BlockGasOverflow"
 )
 
 var (
@@ -24,10 +26,14 @@ var (
 	SeqTxCountName       = SeqPrefix + "tx_count"
 	SeqZKOverflowBlockCounterName   = SeqPrefix + "zk_overflow_block_count"
 	SeqBlockGasUsedName  = SeqPrefix + "block_gas_used"
+	SeqLaneHeldTxCountName = SeqPrefix + "lane_held_tx_count"
+	SeqQuotaThrottledTxCountName = SeqPrefix + "quota_throttled_tx_count"
 
 	RpcPrefix              = "rpc_"
 	RpcDynamicGasPriceName = RpcPrefix + "dynamic_gas_price"
 	RpcInnerTxExecutedName = RpcPrefix + "inner_tx_executed"
+	RpcInnerTxTracedName   = RpcPrefix + "inner_tx_traced_blocks"
+	RpcCoinPriceStaleName  = RpcPrefix + "coin_price_stale"
 )
 
 func Init() {
@@ -37,8 +43,12 @@ func Init() {
 	prometheus.MustRegister(SeqTxCount)
 	prometheus.MustRegister(SeqZKOverflowBlockCounter)
 	prometheus.MustRegister(SeqBlockGasUsed)
+	prometheus.MustRegister(SeqLaneHeldTxCount)
+	prometheus.MustRegister(SeqQuotaThrottledTxCount)
 	prometheus.MustRegister(RpcDynamicGasPrice)
 	prometheus.MustRegister(RpcInnerTxExecuted)
+	prometheus.MustRegister(RpcInnerTxTracedBlocks)
+	prometheus.MustRegister(RpcCoinPriceStale)
 }
 
 var BatchExecuteTimeGauge = prometheus.NewGaugeVec(
@@ -83,6 +93,20 @@ var RpcInnerTxExecuted = prometheus.NewCounter(
 	},
 )
 
+var RpcInnerTxTracedBlocks = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: RpcInnerTxTracedName,
+		Help: "[RPC] blocks re-executed to get their inner txs as they were not stored",
+	},
+)
+
+var RpcCoinPriceStale = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: RpcCoinPriceStaleName,
+		Help: "[RPC] 1 while the coin price source of the gas pricer is stale and its last good prices are used",
+	},
+)
+
 var SeqTxDuration = prometheus.NewSummary(
 	prometheus.SummaryOpts{
 		Name: SeqTxDurationName,
@@ -116,3 +140,19 @@ var SeqBlockGasUsed = prometheus.NewGauge(
 		Help: "[SEQUENCER] gas used per block",
 	},
 )
+
+var SeqLaneHeldTxCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: SeqLaneHeldTxCountName,
+		Help: "[SEQUENCER] txs held back as their priority lane used its share of the block gas or batch counters",
+	},
+	[]string{"lane", "resource"},
+)
+
+var SeqQuotaThrottledTxCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: SeqQuotaThrottledTxCountName,
+		Help: "[SEQUENCER] txs held back as their sender or destination contract went over its throughput quota",
+	},
+	[]string{"kind", "resource"},
+)
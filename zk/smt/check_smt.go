@@ -0,0 +1,538 @@
+package smt
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/length"
+	"github.com/ledgerwatch/erigon-lib/etl"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/dbutils"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type SmtIssueKind string
+
+const (
+	SmtIssueMissingNode  SmtIssueKind = "missing node"
+	SmtIssueBadHash      SmtIssueKind = "bad hash"
+	SmtIssueBadHashKey   SmtIssueKind = "bad hash key"
+	SmtIssueBadKeySource SmtIssueKind = "bad key source"
+	SmtIssueBadValue     SmtIssueKind = "value not matching the state"
+	SmtIssueMissingLeaf  SmtIssueKind = "missing leaf"
+)
+
+// bounds the issues kept by a report, they are all counted but a broken db would otherwise fill the memory
+const maxReportedSmtIssues = 1000
+
+type SmtIssue struct {
+	Kind SmtIssueKind
+	Path []int         // position of the node in the tree, the root of the subtree to rebuild to fix the issue
+	Key  utils.NodeKey // hash of the node, or key of the leaf for the issues about its content
+	Info string
+}
+
+type SmtCheckReport struct {
+	Root               common.Hash
+	Nodes              uint64 // branches and leaves reached from the root
+	Leaves             uint64
+	StateLeaves        uint64 // leaves expected out of the PlainState
+	Orphans            uint64 // branches and leaves no root uses
+	UnreferencedValues uint64 // leaf values no leaf uses, kept in the db by the tree updates
+	Pruned             uint64
+	Issues             []SmtIssue
+	IssueCounts        map[SmtIssueKind]uint64
+}
+
+func (r *SmtCheckReport) Healthy() bool {
+	return len(r.IssueCounts) == 0
+}
+
+// DamagedPaths returns the positions of the subtrees to rebuild to fix the issues reported, leaving out the ones
+// inside another. Only the first maxReportedSmtIssues issues are considered, a badly broken tree needs several runs
+func (r *SmtCheckReport) DamagedPaths() [][]int {
+	paths := make([][]int, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		paths = append(paths, issue.Path)
+	}
+	return outermostSmtPaths(paths)
+}
+
+func (r *SmtCheckReport) addIssue(kind SmtIssueKind, path []int, key utils.NodeKey, info string) {
+	r.IssueCounts[kind]++
+	if len(r.Issues) < maxReportedSmtIssues {
+		r.Issues = append(r.Issues, SmtIssue{Kind: kind, Path: append([]int{}, path...), Key: key, Info: info})
+	}
+}
+
+type smtChecker struct {
+	ctx       context.Context
+	logPrefix string
+	tx        kv.RwTx
+	eridb     *db2.EriDb
+	psr       *state.PlainStateReader
+	visited   *etl.Collector
+	report    *SmtCheckReport
+	logEvery  *time.Ticker
+}
+
+// CheckSmt walks the SMT from its last root verifying the hash of every node, and that the leaves, their hash keys
+// and key sources match the PlainState, which must be at the block the tree was computed for. The nodes of
+// TableSmt the root doesn't reach are counted as orphans and deleted when prune is set, leaving out the ones kept by
+// zkevm.smt-history-blocks. Nothing is deleted when an issue is found, the nodes under a broken branch being orphans
+func CheckSmt(ctx context.Context, logPrefix string, tx kv.RwTx, tmpDir string, prune bool) (*SmtCheckReport, error) {
+	c := &smtChecker{
+		ctx:       ctx,
+		logPrefix: logPrefix,
+		tx:        tx,
+		eridb:     db2.NewEriDb(tx),
+		psr:       state.NewPlainStateReader(tx),
+		visited:   etl.NewCollector(logPrefix, tmpDir, etl.NewSortableBuffer(etl.BufferOptimalSize), log.Root()),
+		report:    &SmtCheckReport{IssueCounts: make(map[SmtIssueKind]uint64)},
+		logEvery:  time.NewTicker(20 * time.Second),
+	}
+	defer c.visited.Close()
+	defer c.logEvery.Stop()
+
+	root, err := c.eridb.GetLastRoot()
+	if err != nil {
+		return nil, err
+	}
+	c.report.Root = common.BigToHash(root)
+
+	log.Info(fmt.Sprintf("[%s] Checking the tree", logPrefix), "root", c.report.Root)
+	if root.Sign() != 0 {
+		if err := c.walk(utils.ScalarToRoot(root), []int{}); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info(fmt.Sprintf("[%s] Checking the tree holds the state", logPrefix), "leaves", c.report.Leaves)
+	if err := forEachSmtStateLeaf(ctx, c.psr, c.checkStateLeaf); err != nil {
+		return nil, err
+	}
+
+	log.Info(fmt.Sprintf("[%s] Looking for orphaned nodes", logPrefix), "nodes", c.report.Nodes)
+	if err := c.findOrphans(tmpDir, prune && c.report.Healthy()); err != nil {
+		return nil, err
+	}
+
+	return c.report, nil
+}
+
+func (c *smtChecker) walk(key utils.NodeKey, path []int) error {
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-c.logEvery.C:
+		log.Info(fmt.Sprintf("[%s] Checking the tree", c.logPrefix), "nodes", c.report.Nodes, "leaves", c.report.Leaves)
+	default:
+	}
+
+	value, err := c.eridb.Get(key)
+	if err != nil {
+		return err
+	}
+	if value[0] == nil {
+		c.report.addIssue(SmtIssueMissingNode, path, key, "")
+		return nil
+	}
+	if err := c.visit(key); err != nil {
+		return err
+	}
+	c.report.Nodes++
+
+	if value.IsFinalNode() {
+		return c.checkLeaf(key, value, path)
+	}
+
+	if utils.NodeKey(utils.Hash(value.Get0to8(), utils.BranchCapacity)) != key {
+		c.report.addIssue(SmtIssueBadHash, path, key, "branch")
+	}
+	for i := 0; i < 2; i++ {
+		child := utils.NodeKeyFromBigIntArray(value[i*4 : i*4+4])
+		if child.IsZero() {
+			continue
+		}
+		childPath := append(append(make([]int, 0, len(path)+1), path...), i)
+		if err := c.walk(child, childPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *smtChecker) checkLeaf(key utils.NodeKey, value utils.NodeValue12, path []int) error {
+	c.report.Leaves++
+
+	if utils.NodeKey(utils.Hash(value.Get0to8(), utils.LeafCapacity)) != key {
+		c.report.addIssue(SmtIssueBadHash, path, key, "leaf")
+	}
+	nodeKey := *utils.JoinKey(path, *value.Get0to4())
+
+	valueHash := *value.Get4to8()
+	leafValue, err := c.eridb.Get(valueHash)
+	if err != nil {
+		return err
+	}
+	if leafValue[0] == nil {
+		c.report.addIssue(SmtIssueMissingNode, path, valueHash, "leaf value")
+		return nil
+	}
+	if err := c.visit(valueHash); err != nil {
+		return err
+	}
+	if utils.NodeKey(utils.Hash(leafValue.Get0to8(), utils.BranchCapacity)) != valueHash {
+		c.report.addIssue(SmtIssueBadHash, path, valueHash, "leaf value")
+	}
+
+	// not found is an error as well
+	if hashKey, err := c.eridb.GetHashKey(key); err != nil || hashKey != nodeKey {
+		c.report.addIssue(SmtIssueBadHashKey, path, nodeKey, "")
+	}
+
+	source, err := c.eridb.GetKeySource(nodeKey)
+	if err != nil {
+		if !errors.Is(err, db2.ErrNotFound) {
+			return err
+		}
+		c.report.addIssue(SmtIssueBadKeySource, path, nodeKey, "not found")
+		return nil
+	}
+	kind, address, position, err := utils.DecodeKeySource(source)
+	if err != nil {
+		c.report.addIssue(SmtIssueBadKeySource, path, nodeKey, err.Error())
+		return nil
+	}
+	if smtKey(kind, address, position) != nodeKey {
+		c.report.addIssue(SmtIssueBadKeySource, path, nodeKey, fmt.Sprintf("%s doesn't match the key", describeSmtKey(kind, address, position)))
+		return nil
+	}
+
+	expected, err := smtStateValue(c.psr, kind, address, position)
+	if err != nil {
+		return err
+	}
+	actual := utils.ArrayBigToScalar(utils.BigIntArrayFromNodeValue8(leafValue.GetNodeValue8()))
+	if expected.Cmp(actual) != 0 {
+		c.report.addIssue(SmtIssueBadValue, path, nodeKey, fmt.Sprintf("%s is %d in the tree and %d in the state", describeSmtKey(kind, address, position), actual, expected))
+	}
+
+	return nil
+}
+
+// checkStateLeaf reports the leaves of the state missing from the tree, the ones found are checked by the walk
+func (c *smtChecker) checkStateLeaf(leaf *smtStateLeaf) error {
+	c.report.StateLeaves++
+	if c.report.StateLeaves%100_000 == 0 {
+		select {
+		case <-c.logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Checking the tree holds the state", c.logPrefix), "leaves", c.report.StateLeaves)
+		default:
+		}
+	}
+
+	found, path, err := findSmtLeaf(c.eridb, utils.ScalarToRoot(c.report.Root.Big()), leaf.key)
+	if err != nil || found {
+		return err
+	}
+	c.report.addIssue(SmtIssueMissingLeaf, path, leaf.key, describeSmtKey(leaf.kind, leaf.address, leaf.position))
+
+	return nil
+}
+
+func (c *smtChecker) visit(key utils.NodeKey) error {
+	return c.visited.Collect([]byte(utils.ConvertBigIntToHex(utils.ArrayToScalar(key[:]))), nil)
+}
+
+// findOrphans goes through TableSmt and the nodes visited, both sorted the same way
+func (c *smtChecker) findOrphans(tmpDir string, prune bool) error {
+	// the trees of the history may use the values of the current one dropped since, which aren't retained
+	_, keepValues, err := c.eridb.GetHistoryFrom()
+	if err != nil {
+		return err
+	}
+
+	orphans := etl.NewCollector(c.logPrefix, tmpDir, etl.NewSortableBuffer(etl.BufferOptimalSize), log.Root())
+	defer orphans.Close()
+
+	cursor, err := c.tx.Cursor(db2.TableSmt)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	orphan := func(k, v []byte) error {
+		retained, err := c.tx.Has(db2.TableRetainedNodeBlocks, k)
+		if err != nil || retained {
+			return err
+		}
+		if isSmtValueNode(v) {
+			c.report.UnreferencedValues++
+			if keepValues {
+				return nil
+			}
+		} else {
+			c.report.Orphans++
+		}
+		if !prune {
+			return nil
+		}
+		return orphans.Collect(k, nil)
+	}
+
+	k, v, err := cursor.First()
+	if err != nil {
+		return err
+	}
+	if err := c.visited.Load(c.tx, "", func(visitedKey, _ []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		for ; k != nil && bytes.Compare(k, visitedKey) < 0; k, v, err = cursor.Next() {
+			if err != nil {
+				return err
+			}
+			if err := orphan(k, v); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(k, visitedKey) {
+			k, v, err = cursor.Next()
+		}
+		return err
+	}, etl.TransformArgs{Quit: c.ctx.Done()}); err != nil {
+		return err
+	}
+	for ; k != nil; k, v, err = cursor.Next() {
+		if err != nil {
+			return err
+		}
+		if err := orphan(k, v); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	cursor.Close()
+
+	return orphans.Load(c.tx, "", func(k, _ []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+		if err := c.tx.Delete(db2.TableSmt, k); err != nil {
+			return err
+		}
+		// for the leaves
+		if err := c.eridb.DeleteHashKey(utils.ScalarToRoot(utils.ConvertHexToBigInt(string(k)))); err != nil {
+			return err
+		}
+		c.report.Pruned++
+		return nil
+	}, etl.TransformArgs{Quit: c.ctx.Done()})
+}
+
+// isSmtValueNode tells the leaf values, which limbs hold 32 bits, from the branches holding hashes
+func isSmtValueNode(v []byte) bool {
+	value := utils.ScalarToNodeValue(utils.ConvertHexToBigInt(string(v)))
+	for i, limb := range value {
+		if limb.BitLen() > 32 || (i >= 8 && limb.Sign() != 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// findSmtLeaf looks for the leaf of the key from the root, returning where it is or should be in the tree
+func findSmtLeaf(db smt.RoDB, root utils.NodeKey, key utils.NodeKey) (bool, []int, error) {
+	keyPath := key.GetPath()
+	node := root
+	for level := 0; level < len(keyPath); level++ {
+		if node.IsZero() {
+			return false, keyPath[:level], nil
+		}
+		value, err := db.Get(node)
+		if err != nil {
+			return false, nil, err
+		}
+		if value[0] == nil {
+			return false, keyPath[:level], nil
+		}
+		if value.IsFinalNode() {
+			return *utils.JoinKey(keyPath[:level], *value.Get0to4()) == key, keyPath[:level], nil
+		}
+		node = utils.NodeKeyFromBigIntArray(value[keyPath[level]*4 : keyPath[level]*4+4])
+	}
+	return false, keyPath, nil
+}
+
+type smtStateLeaf struct {
+	key      utils.NodeKey
+	value    *big.Int
+	kind     int
+	address  common.Address
+	position common.Hash
+}
+
+// forEachSmtStateLeaf goes through the non zero values the tree of the PlainState is made of
+func forEachSmtStateLeaf(ctx context.Context, psr *state.PlainStateReader, fn func(leaf *smtStateLeaf) error) error {
+	var account accounts.Account
+	var address common.Address
+	var incarnation uint64
+	var skipStorage bool
+
+	emit := func(kind int, position common.Hash, value *big.Int) error {
+		if value.Sign() == 0 {
+			return nil
+		}
+		return fn(&smtStateLeaf{key: smtKey(kind, address, position), value: value, kind: kind, address: address, position: position})
+	}
+
+	return psr.ForEach(kv.PlainState, nil, func(k, v []byte) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if len(k) == length.Addr {
+			address = common.BytesToAddress(k)
+			if skipStorage = account.DecodeForStorage(v) != nil; skipStorage {
+				return nil
+			}
+			incarnation = account.Incarnation
+
+			code, err := psr.ReadAccountCode(address, account.Incarnation, account.CodeHash)
+			if err != nil {
+				return err
+			}
+			codeHash, codeLength := smtCodeValues(code)
+
+			if err := emit(utils.KEY_BALANCE, common.Hash{}, account.Balance.ToBig()); err != nil {
+				return err
+			}
+			if err := emit(utils.KEY_NONCE, common.Hash{}, new(big.Int).SetUint64(account.Nonce)); err != nil {
+				return err
+			}
+			if err := emit(utils.SC_CODE, common.Hash{}, codeHash); err != nil {
+				return err
+			}
+			return emit(utils.SC_LENGTH, common.Hash{}, codeLength)
+		}
+
+		_, storageIncarnation, position := dbutils.PlainParseCompositeStorageKey(k)
+		if skipStorage || storageIncarnation != incarnation {
+			return nil
+		}
+		return emit(utils.SC_STORAGE, position, new(big.Int).SetBytes(v))
+	})
+}
+
+// smtStateValue reads the value of the leaf of the key source from the PlainState, zero if the tree shouldn't hold it
+func smtStateValue(psr *state.PlainStateReader, kind int, address common.Address, position common.Hash) (*big.Int, error) {
+	account, err := psr.ReadAccountData(address)
+	if err != nil || account == nil {
+		return big.NewInt(0), err
+	}
+
+	switch kind {
+	case utils.KEY_BALANCE:
+		return account.Balance.ToBig(), nil
+	case utils.KEY_NONCE:
+		return new(big.Int).SetUint64(account.Nonce), nil
+	case utils.SC_CODE, utils.SC_LENGTH:
+		code, err := psr.ReadAccountCode(address, account.Incarnation, account.CodeHash)
+		if err != nil {
+			return nil, err
+		}
+		codeHash, codeLength := smtCodeValues(code)
+		if kind == utils.SC_CODE {
+			return codeHash, nil
+		}
+		return codeLength, nil
+	case utils.SC_STORAGE:
+		value, err := psr.ReadAccountStorage(address, account.Incarnation, &position)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(value), nil
+	}
+
+	return big.NewInt(0), nil
+}
+
+func smtCodeValues(code []byte) (*big.Int, *big.Int) {
+	if len(code) == 0 {
+		return big.NewInt(0), big.NewInt(0)
+	}
+	return utils.HashContractBytecodeBigInt("0x" + hex.EncodeToString(code)), big.NewInt(int64(len(code)))
+}
+
+func smtKey(kind int, address common.Address, position common.Hash) utils.NodeKey {
+	if kind == utils.SC_STORAGE {
+		return utils.KeyContractStorage(utils.ScalarToArrayBig(utils.ConvertHexToBigInt(address.String())), position.String())
+	}
+	return utils.Key(address.String(), kind)
+}
+
+func describeSmtKey(kind int, address common.Address, position common.Hash) string {
+	switch kind {
+	case utils.KEY_BALANCE:
+		return fmt.Sprintf("balance of %s", address)
+	case utils.KEY_NONCE:
+		return fmt.Sprintf("nonce of %s", address)
+	case utils.SC_CODE:
+		return fmt.Sprintf("code hash of %s", address)
+	case utils.SC_LENGTH:
+		return fmt.Sprintf("code length of %s", address)
+	case utils.SC_STORAGE:
+		return fmt.Sprintf("storage %s of %s", position, address)
+	}
+	return fmt.Sprintf("unknown key type %d of %s", kind, address)
+}
+
+// outermostSmtPaths drops the paths inside another one and the duplicates
+func outermostSmtPaths(paths [][]int) [][]int {
+	sorted := append([][]int{}, paths...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) < len(sorted[j])
+	})
+
+	outermost := make([][]int, 0, len(sorted))
+	for _, path := range sorted {
+		inside := false
+		for _, outer := range outermost {
+			if isSmtPathPrefix(outer, path) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			outermost = append(outermost, path)
+		}
+	}
+	return outermost
+}
+
+func isSmtPathPrefix(prefix, path []int) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
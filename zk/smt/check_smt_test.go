@@ -0,0 +1,139 @@
+package smt
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/dbutils"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/crypto"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+type testSmtState struct {
+	accounts map[common.Address]*accounts.Account
+	code     map[common.Address]string
+	storage  map[common.Address]map[string]string
+}
+
+// write puts the state in the PlainState and returns the root of its tree
+func (s *testSmtState) write(t *testing.T, tx kv.RwTx) *big.Int {
+	for address, account := range s.accounts {
+		if code, ok := s.code[address]; ok {
+			codeBytes, err := hex.DecodeString(code[2:])
+			require.NoError(t, err)
+			account.CodeHash = crypto.Keccak256Hash(codeBytes)
+			require.NoError(t, tx.Put(kv.Code, account.CodeHash.Bytes(), codeBytes))
+		}
+		value := make([]byte, account.EncodingLengthForStorage())
+		account.EncodeForStorage(value)
+		require.NoError(t, tx.Put(kv.PlainState, address.Bytes(), value))
+
+		for position, storageValue := range s.storage[address] {
+			positionHash := common.HexToHash(position)
+			storageKey := dbutils.PlainGenerateCompositeStorageKey(address.Bytes(), account.Incarnation, positionHash.Bytes())
+			require.NoError(t, tx.Put(kv.PlainState, storageKey, common.HexToHash(storageValue).Bytes()))
+		}
+	}
+
+	memSmt := smt.NewSMT(nil, false)
+	_, _, err := memSmt.SetStorage(context.Background(), "test", s.accounts, s.code, s.storage)
+	require.NoError(t, err)
+	return memSmt.LastRoot()
+}
+
+func TestCheckSmt(t *testing.T) {
+	ctx, db := context.Background(), memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, db2.CreateEriDbBuckets(tx))
+	eridb := db2.NewEriDb(tx)
+	dbSmt := smt.NewSMT(eridb, false)
+
+	contract := common.HexToAddress("0xc0")
+	state := &testSmtState{
+		accounts: map[common.Address]*accounts.Account{
+			common.HexToAddress("0xa1"): {Balance: *uint256.NewInt(1), Nonce: 2},
+			common.HexToAddress("0xa2"): {Balance: *uint256.NewInt(3)},
+			common.HexToAddress("0xa3"): {Balance: *uint256.NewInt(4), Nonce: 5},
+			contract:                    {Nonce: 1, Incarnation: 1},
+		},
+		code:    map[common.Address]string{contract: "0x600160005500"},
+		storage: map[common.Address]map[string]string{contract: {"0x01": "0x0a", "0x02": "0x0b"}},
+	}
+	root := state.write(t, tx)
+	_, _, err := dbSmt.SetStorage(ctx, "test", state.accounts, state.code, state.storage)
+	require.NoError(t, err)
+	require.Equal(t, root, dbSmt.LastRoot())
+
+	check := func(prune bool) *SmtCheckReport {
+		report, err := CheckSmt(ctx, "test", tx, t.TempDir(), prune)
+		require.NoError(t, err)
+		return report
+	}
+
+	report := check(false)
+	require.True(t, report.Healthy(), "%+v", report.Issues)
+	require.Equal(t, uint64(10), report.Leaves)
+	require.Equal(t, uint64(10), report.StateLeaves)
+	require.Equal(t, uint64(0), report.Orphans)
+
+	// a missing branch, the subtree under it being rebuilt as it was
+	rootValue, err := eridb.Get(utils.ScalarToRoot(root))
+	require.NoError(t, err)
+	require.NoError(t, eridb.DeleteByNodeKey(utils.NodeKeyFromBigIntArray(rootValue[0:4])))
+
+	report = check(false)
+	require.False(t, report.Healthy())
+	require.Equal(t, uint64(1), report.IssueCounts[SmtIssueMissingNode])
+	require.NotZero(t, report.IssueCounts[SmtIssueMissingLeaf])
+	require.Equal(t, [][]int{{0}}, report.DamagedPaths())
+
+	_, err = RebuildSmtSubtrees(ctx, "test", tx, report.DamagedPaths())
+	require.NoError(t, err)
+	require.Equal(t, root, dbSmt.LastRoot())
+	report = check(false)
+	require.True(t, report.Healthy(), "%+v", report.Issues)
+	require.Equal(t, uint64(0), report.Orphans)
+
+	// a value changed in the state alone, the leaf being rebuilt to match it
+	state.storage[contract]["0x02"] = "0x0c"
+	root = state.write(t, tx)
+
+	report = check(false)
+	require.Equal(t, map[SmtIssueKind]uint64{SmtIssueBadValue: 1}, report.IssueCounts)
+
+	_, err = RebuildSmtSubtrees(ctx, "test", tx, report.DamagedPaths())
+	require.NoError(t, err)
+	require.Equal(t, root, dbSmt.LastRoot())
+	report = check(false)
+	require.True(t, report.Healthy(), "%+v", report.Issues)
+	require.Equal(t, uint64(0), report.Orphans)
+	require.Equal(t, uint64(1), report.UnreferencedValues, "the value replaced")
+
+	// a branch and a leaf value no root uses
+	orphan := utils.NodeKey{1, 2, 3, 4}
+	require.NoError(t, eridb.Insert(orphan, utils.NodeValue12{
+		big.NewInt(1 << 40), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),
+		big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0),
+	}))
+
+	report = check(true)
+	require.True(t, report.Healthy(), "%+v", report.Issues)
+	require.Equal(t, uint64(1), report.Orphans)
+	require.Equal(t, uint64(1), report.UnreferencedValues)
+	require.Equal(t, uint64(2), report.Pruned)
+
+	report = check(false)
+	require.Equal(t, uint64(0), report.Orphans)
+	require.Equal(t, uint64(0), report.UnreferencedValues)
+	require.Equal(t, root, dbSmt.LastRoot())
+}
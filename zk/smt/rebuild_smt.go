@@ -0,0 +1,286 @@
+package smt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/state"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// RebuildSmtSubtrees rebuilds in place the subtrees of the SMT at the given paths out of the PlainState, which must
+// be at the block the tree was computed for: the nodes of each subtree are replaced, as well as the branches above
+// it up to a new root. A path is cut where the tree stops branching, and moved up until its subtree holds two leaves
+// at least, a single one belonging to a branch above. It returns the paths of the subtrees rebuilt
+func RebuildSmtSubtrees(ctx context.Context, logPrefix string, tx kv.RwTx, paths [][]int) ([][]int, error) {
+	eridb := db2.NewEriDb(tx)
+	psr := state.NewPlainStateReader(tx)
+
+	cut := make([][]int, 0, len(paths))
+	for _, path := range paths {
+		branches, _, err := smtBranchesAlong(eridb, path)
+		if err != nil {
+			return nil, err
+		}
+		cut = append(cut, path[:len(branches)])
+	}
+	paths = outermostSmtPaths(cut)
+
+	// a single pass over the state finds the leaves of every subtree, one more is needed for the paths moved up
+	var leaves [][]*smtStateLeaf
+	for {
+		var counts [][]uint64
+		var err error
+		if leaves, counts, err = collectSmtSubtreeLeaves(ctx, psr, paths); err != nil {
+			return nil, err
+		}
+
+		moved := false
+		for i, path := range paths {
+			depth := len(path)
+			for depth > 0 && counts[i][depth] < 2 {
+				depth--
+			}
+			if depth < len(path) {
+				paths[i], moved = path[:depth], true
+			}
+		}
+		if !moved {
+			break
+		}
+		paths = outermostSmtPaths(paths)
+	}
+
+	for i, path := range paths {
+		log.Info(fmt.Sprintf("[%s] Rebuilding the subtree", logPrefix), "path", SmtPathString(path), "leaves", len(leaves[i]))
+		if err := rebuildSmtSubtree(ctx, logPrefix, eridb, path, leaves[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+func rebuildSmtSubtree(ctx context.Context, logPrefix string, eridb *db2.EriDb, path []int, leaves []*smtStateLeaf) error {
+	branches, subtree, err := smtBranchesAlong(eridb, path)
+	if err != nil {
+		return err
+	}
+	if len(branches) != len(path) {
+		return fmt.Errorf("the tree doesn't branch down to %s", SmtPathString(path))
+	}
+
+	// the tree of the leaves alone has the same subtree
+	memSmt := smt.NewSMT(nil, false)
+	if len(leaves) > 0 {
+		keys := make([]*utils.NodeKey, 0, len(leaves))
+		values := make([]*utils.NodeValue8, 0, len(leaves))
+		for _, leaf := range leaves {
+			value, err := utils.NodeValue8FromBigInt(leaf.value)
+			if err != nil {
+				return err
+			}
+			keys, values = append(keys, &leaf.key), append(values, value)
+		}
+		if _, err := memSmt.InsertBatch(smt.NewInsertBatchConfig(ctx, logPrefix, false), keys, values, nil, nil); err != nil {
+			return err
+		}
+	}
+	memBranches, newSubtree, err := smtBranchesAlong(memSmt.Db, path)
+	if err != nil {
+		return err
+	}
+	if len(memBranches) != len(path) {
+		return fmt.Errorf("the leaves of %s don't branch down to it", SmtPathString(path))
+	}
+
+	// the old nodes go first, as the new ones may be the same
+	for _, branch := range branches {
+		if err := eridb.DeleteByNodeKey(branch.key); err != nil {
+			return err
+		}
+	}
+	if err := deleteSmtSubtree(eridb, subtree, path); err != nil {
+		return err
+	}
+
+	if err := copySmtSubtree(memSmt.Db, eridb, newSubtree); err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if err := eridb.InsertKeySource(leaf.key, utils.EncodeKeySource(leaf.kind, leaf.address, leaf.position)); err != nil {
+			return err
+		}
+	}
+
+	hash := newSubtree
+	for depth := len(path) - 1; depth >= 0; depth-- {
+		in := branches[depth].value.Get0to8()
+		copy(in[path[depth]*4:path[depth]*4+4], hash[:])
+		newHash, value := utils.HashKeyAndValueByPointers(&in, &utils.BranchCapacity)
+		if err := eridb.Insert(*newHash, *value); err != nil {
+			return err
+		}
+		hash = *newHash
+	}
+
+	return eridb.SetLastRoot(hash.ToBigInt())
+}
+
+type smtBranch struct {
+	key   utils.NodeKey
+	value utils.NodeValue12
+}
+
+// smtBranchesAlong returns the branches from the root down the path, stopping where the tree has none, and the
+// node found under them
+func smtBranchesAlong(db smt.RoDB, path []int) ([]smtBranch, utils.NodeKey, error) {
+	root, err := db.GetLastRoot()
+	if err != nil {
+		return nil, utils.NodeKey{}, err
+	}
+
+	branches := make([]smtBranch, 0, len(path))
+	node := utils.ScalarToRoot(root)
+	for len(branches) < len(path) && !node.IsZero() {
+		value, err := db.Get(node)
+		if err != nil {
+			return nil, utils.NodeKey{}, err
+		}
+		if value[0] == nil || value.IsFinalNode() {
+			break
+		}
+		branches = append(branches, smtBranch{key: node, value: value})
+		direction := path[len(branches)-1]
+		node = utils.NodeKeyFromBigIntArray(value[direction*4 : direction*4+4])
+	}
+
+	return branches, node, nil
+}
+
+// collectSmtSubtreeLeaves returns the leaves of the state under each path, along with how many there are under each
+// of its prefixes
+func collectSmtSubtreeLeaves(ctx context.Context, psr *state.PlainStateReader, paths [][]int) ([][]*smtStateLeaf, [][]uint64, error) {
+	leaves := make([][]*smtStateLeaf, len(paths))
+	counts := make([][]uint64, len(paths))
+	for i, path := range paths {
+		counts[i] = make([]uint64, len(path)+1)
+	}
+
+	if err := forEachSmtStateLeaf(ctx, psr, func(leaf *smtStateLeaf) error {
+		keyPath := leaf.key.GetPath()
+		for i, path := range paths {
+			depth := 0
+			for depth < len(path) && path[depth] == keyPath[depth] {
+				depth++
+			}
+			for d := 0; d <= depth; d++ {
+				counts[i][d]++
+			}
+			if depth == len(path) {
+				leaves[i] = append(leaves[i], leaf)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return leaves, counts, nil
+}
+
+// deleteSmtSubtree deletes what is left of a subtree, the leaf values being kept as any leaf may use them
+func deleteSmtSubtree(eridb *db2.EriDb, key utils.NodeKey, path []int) error {
+	if key.IsZero() {
+		return nil
+	}
+	value, err := eridb.Get(key)
+	if err != nil || value[0] == nil {
+		return err
+	}
+
+	if value.IsFinalNode() {
+		if err := eridb.DeleteKeySource(*utils.JoinKey(path, *value.Get0to4())); err != nil {
+			return err
+		}
+		if err := eridb.DeleteHashKey(key); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < 2; i++ {
+			childPath := append(append(make([]int, 0, len(path)+1), path...), i)
+			if err := deleteSmtSubtree(eridb, utils.NodeKeyFromBigIntArray(value[i*4:i*4+4]), childPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return eridb.DeleteByNodeKey(key)
+}
+
+func copySmtSubtree(from smt.RoDB, to *db2.EriDb, key utils.NodeKey) error {
+	if key.IsZero() {
+		return nil
+	}
+	value, err := from.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if value.IsFinalNode() {
+		valueHash := *value.Get4to8()
+		leafValue, err := from.Get(valueHash)
+		if err != nil {
+			return err
+		}
+		if err := to.Insert(valueHash, leafValue); err != nil {
+			return err
+		}
+		nodeKey, err := from.GetHashKey(key)
+		if err != nil {
+			return err
+		}
+		if err := to.InsertHashKey(key, nodeKey); err != nil {
+			return err
+		}
+	} else {
+		for i := 0; i < 2; i++ {
+			if err := copySmtSubtree(from, to, utils.NodeKeyFromBigIntArray(value[i*4:i*4+4])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return to.Insert(key, value)
+}
+
+// SmtPathString formats a path as bits from the root
+func SmtPathString(path []int) string {
+	if len(path) == 0 {
+		return "root"
+	}
+	s := make([]byte, len(path))
+	for i, bit := range path {
+		s[i] = '0' + byte(bit)
+	}
+	return string(s)
+}
+
+// ParseSmtPath parses a path written as bits from the root, as printed by the check
+func ParseSmtPath(s string) ([]int, error) {
+	if s == "root" {
+		return []int{}, nil
+	}
+	path := make([]int, len(s))
+	for i, c := range s {
+		if c != '0' && c != '1' {
+			return nil, fmt.Errorf("invalid smt path %q, expected bits from the root", s)
+		}
+		path[i] = int(c - '0')
+	}
+	return path, nil
+}
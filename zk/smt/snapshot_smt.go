@@ -0,0 +1,565 @@
+package smt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// An SMT snapshot is the stream of the nodes reached from the root of a block, in pre-order, each leaf carrying its
+// value and key source and the code of a contract following the first leaf holding its hash. The nodes being
+// addressed by their hash the import checks the stream against the root as it reads it, a SHA-256 of the whole file
+// guarding the rest. The integers are big endian:
+//
+//	header:  "ZKSMTSNP", version uint16, block uint64, root [32]byte
+//	branch:  'B', key [4]uint64, value [12]uint64
+//	leaf:    'L', key [4]uint64, value [12]uint64, leaf value [12]uint64, key source length uvarint, key source
+//	code:    'C', hash [32]byte, code length uvarint, code
+//	trailer: 'E', nodes uint64, leaves uint64, codes uint64, depth uint8, SHA-256 of the bytes before it [32]byte
+const SmtSnapshotVersion uint16 = 1
+
+var smtSnapshotMagic = []byte("ZKSMTSNP")
+
+const (
+	smtSnapshotBranch byte = 'B'
+	smtSnapshotLeaf   byte = 'L'
+	smtSnapshotCode   byte = 'C'
+	smtSnapshotEnd    byte = 'E'
+)
+
+type SmtSnapshotInfo struct {
+	Version uint16
+	Block   uint64
+	Root    common.Hash
+	Nodes   uint64 // branches and leaves
+	Leaves  uint64
+	Codes   uint64
+	Depth   uint8 // level of the deepest leaf
+}
+
+type smtExporter struct {
+	ctx       context.Context
+	logPrefix string
+	eridb     *db2.EriRoDb
+	w         *bufio.Writer
+	info      *SmtSnapshotInfo
+	codes     map[common.Hash]struct{}
+	path      []int
+	logEvery  *time.Ticker
+}
+
+// ExportSmt writes the snapshot of the tree of the block, which root must be reachable in the db
+func ExportSmt(ctx context.Context, logPrefix string, eridb *db2.EriRoDb, block uint64, root common.Hash, w io.Writer) (*SmtSnapshotInfo, error) {
+	digest := sha256.New()
+	e := &smtExporter{
+		ctx:       ctx,
+		logPrefix: logPrefix,
+		eridb:     eridb,
+		w:         bufio.NewWriterSize(io.MultiWriter(w, digest), 1<<20),
+		info:      &SmtSnapshotInfo{Version: SmtSnapshotVersion, Block: block, Root: root},
+		codes:     make(map[common.Hash]struct{}),
+		path:      make([]int, 256),
+		logEvery:  time.NewTicker(20 * time.Second),
+	}
+	defer e.logEvery.Stop()
+
+	e.w.Write(smtSnapshotMagic)
+	e.writeUint(uint64(SmtSnapshotVersion), 2)
+	e.writeUint(block, 8)
+	e.w.Write(root.Bytes())
+
+	if root != (common.Hash{}) {
+		if err := e.walk(utils.ScalarToRoot(root.Big()), 0); err != nil {
+			return nil, err
+		}
+	}
+
+	e.w.WriteByte(smtSnapshotEnd)
+	e.writeUint(e.info.Nodes, 8)
+	e.writeUint(e.info.Leaves, 8)
+	e.writeUint(e.info.Codes, 8)
+	e.w.WriteByte(e.info.Depth)
+	if err := e.w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(digest.Sum(nil)); err != nil {
+		return nil, err
+	}
+
+	return e.info, nil
+}
+
+func (e *smtExporter) walk(key utils.NodeKey, level int) error {
+	select {
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	case <-e.logEvery.C:
+		log.Info(fmt.Sprintf("[%s] Exporting the tree", e.logPrefix), "nodes", e.info.Nodes, "leaves", e.info.Leaves)
+	default:
+	}
+
+	value, err := e.eridb.Get(key)
+	if err != nil {
+		return err
+	}
+	if value[0] == nil {
+		return fmt.Errorf("node %s at %s not found, run smt_check", common.BigToHash(key.ToBigInt()), SmtPathString(e.path[:level]))
+	}
+	e.info.Nodes++
+
+	if !value.IsFinalNode() {
+		e.w.WriteByte(smtSnapshotBranch)
+		e.writeKey(key)
+		if err := e.writeValue(value); err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			child := utils.NodeKeyFromBigIntArray(value[i*4 : i*4+4])
+			if child.IsZero() {
+				continue
+			}
+			e.path[level] = i
+			if err := e.walk(child, level+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	e.info.Leaves++
+	if uint8(level) > e.info.Depth {
+		e.info.Depth = uint8(level)
+	}
+
+	nodeKey := *utils.JoinKey(e.path[:level], *value.Get0to4())
+	leafValue, err := e.eridb.Get(*value.Get4to8())
+	if err != nil {
+		return err
+	}
+	if leafValue[0] == nil {
+		return fmt.Errorf("value of the leaf %s not found, run smt_check", common.BigToHash(nodeKey.ToBigInt()))
+	}
+	source, err := e.eridb.GetKeySource(nodeKey)
+	if err != nil {
+		return fmt.Errorf("key source of the leaf %s: %w", common.BigToHash(nodeKey.ToBigInt()), err)
+	}
+
+	e.w.WriteByte(smtSnapshotLeaf)
+	e.writeKey(key)
+	if err := e.writeValue(value); err != nil {
+		return err
+	}
+	if err := e.writeValue(leafValue); err != nil {
+		return err
+	}
+	e.writeBytes(source)
+
+	kind, _, _, err := utils.DecodeKeySource(source)
+	if err != nil {
+		return fmt.Errorf("key source of the leaf %s: %w", common.BigToHash(nodeKey.ToBigInt()), err)
+	}
+	if kind != utils.SC_CODE {
+		return nil
+	}
+	codeHash := smtLeafHash(leafValue)
+	if _, ok := e.codes[codeHash]; ok || codeHash == (common.Hash{}) {
+		return nil
+	}
+	code, err := e.eridb.GetCode(codeHash.Bytes())
+	if err != nil {
+		return err
+	}
+	e.codes[codeHash] = struct{}{}
+	e.info.Codes++
+
+	e.w.WriteByte(smtSnapshotCode)
+	e.w.Write(codeHash.Bytes())
+	e.writeBytes(code)
+
+	return nil
+}
+
+// the errors of the buffered writer are returned by the flush
+func (e *smtExporter) writeUint(v uint64, size int) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	e.w.Write(buf[8-size:])
+}
+
+func (e *smtExporter) writeKey(key utils.NodeKey) {
+	for _, v := range key {
+		e.writeUint(v, 8)
+	}
+}
+
+func (e *smtExporter) writeValue(value utils.NodeValue12) error {
+	for _, v := range value {
+		if v != nil && !v.IsUint64() {
+			return fmt.Errorf("node value %d doesn't fit 64 bits", v)
+		}
+		var limb uint64
+		if v != nil {
+			limb = v.Uint64()
+		}
+		e.writeUint(limb, 8)
+	}
+	return nil
+}
+
+func (e *smtExporter) writeBytes(b []byte) {
+	e.w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	e.w.Write(b)
+}
+
+// smtLeafHash returns the value of a leaf as a hash, the one of the code for the code leaves
+func smtLeafHash(leafValue utils.NodeValue12) common.Hash {
+	return common.BigToHash(utils.ArrayBigToScalar(utils.BigIntArrayFromNodeValue8(leafValue.GetNodeValue8())))
+}
+
+// smtSnapshotReader hashes what it reads, up to the digest closing the file
+type smtSnapshotReader struct {
+	r      *bufio.Reader
+	digest hash.Hash
+	buf    [8]byte
+}
+
+func (r *smtSnapshotReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, smtSnapshotTruncated(err)
+	}
+	r.digest.Write([]byte{b})
+	return b, nil
+}
+
+func (r *smtSnapshotReader) read(b []byte) error {
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return smtSnapshotTruncated(err)
+	}
+	r.digest.Write(b)
+	return nil
+}
+
+func (r *smtSnapshotReader) readUint(size int) (uint64, error) {
+	clear(r.buf[:])
+	if err := r.read(r.buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(r.buf[:]), nil
+}
+
+func (r *smtSnapshotReader) readKey() (utils.NodeKey, error) {
+	var key utils.NodeKey
+	for i := range key {
+		v, err := r.readUint(8)
+		if err != nil {
+			return key, err
+		}
+		key[i] = v
+	}
+	return key, nil
+}
+
+func (r *smtSnapshotReader) readValue() ([12]uint64, error) {
+	var value [12]uint64
+	for i := range value {
+		v, err := r.readUint(8)
+		if err != nil {
+			return value, err
+		}
+		value[i] = v
+	}
+	return value, nil
+}
+
+func (r *smtSnapshotReader) readBytes() ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > 1<<26 {
+		return nil, fmt.Errorf("invalid SMT snapshot, record of %d bytes", size)
+	}
+	b := make([]byte, size)
+	return b, r.read(b)
+}
+
+func smtSnapshotTruncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.New("invalid SMT snapshot, the file is truncated")
+	}
+	return err
+}
+
+type smtSnapshotNode struct {
+	key       utils.NodeKey
+	level     int
+	direction int
+}
+
+// ImportSmt replaces the SMT of the db with the tree of the snapshot, which root must be the state root of its block
+// in hermez_stateRoots. Nothing is checked against the PlainState, the transaction must only be committed once it
+// holds the state of the block
+func ImportSmt(ctx context.Context, logPrefix string, tx kv.RwTx, r io.Reader) (*SmtSnapshotInfo, error) {
+	in := &smtSnapshotReader{r: bufio.NewReaderSize(r, 1<<20), digest: sha256.New()}
+
+	magic := make([]byte, len(smtSnapshotMagic))
+	if err := in.read(magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, smtSnapshotMagic) {
+		return nil, errors.New("not an SMT snapshot")
+	}
+	version, err := in.readUint(2)
+	if err != nil {
+		return nil, err
+	}
+	if uint16(version) != SmtSnapshotVersion {
+		return nil, fmt.Errorf("unsupported SMT snapshot version %d, expected %d", version, SmtSnapshotVersion)
+	}
+	info := &SmtSnapshotInfo{Version: uint16(version)}
+	if info.Block, err = in.readUint(8); err != nil {
+		return nil, err
+	}
+	if err := in.read(info.Root[:]); err != nil {
+		return nil, err
+	}
+
+	stateRoot, err := hermez_db.NewHermezDbReader(tx).GetStateRoot(info.Block)
+	if err != nil {
+		return nil, err
+	}
+	if stateRoot == (common.Hash{}) {
+		return nil, fmt.Errorf("no state root for block %d, sync the batches up to it first", info.Block)
+	}
+	if stateRoot != info.Root {
+		return nil, fmt.Errorf("the snapshot root %x doesn't match the state root %x of block %d", info.Root, stateRoot, info.Block)
+	}
+
+	for _, table := range db2.HermezSmtTables {
+		if err := tx.ClearBucket(table); err != nil {
+			return nil, err
+		}
+	}
+	eridb := db2.NewEriDb(tx)
+
+	var pending []smtSnapshotNode
+	if info.Root != (common.Hash{}) {
+		pending = append(pending, smtSnapshotNode{key: utils.ScalarToRoot(info.Root.Big())})
+	}
+	codes := make(map[common.Hash]bool) // whether the code of a hash the leaves hold has been imported
+	path := make([]int, 256)
+	logEvery := time.NewTicker(20 * time.Second)
+	defer logEvery.Stop()
+
+	log.Info(fmt.Sprintf("[%s] Importing the tree", logPrefix), "block", info.Block, "root", info.Root)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-logEvery.C:
+			log.Info(fmt.Sprintf("[%s] Importing the tree", logPrefix), "nodes", info.Nodes, "leaves", info.Leaves)
+		default:
+		}
+
+		kind, err := in.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case smtSnapshotBranch, smtSnapshotLeaf:
+			key, err := in.readKey()
+			if err != nil {
+				return nil, err
+			}
+			value, err := in.readValue()
+			if err != nil {
+				return nil, err
+			}
+
+			// the nodes come in the order of the walk from the root, each one being the next the tree leads to
+			if len(pending) == 0 {
+				return nil, fmt.Errorf("invalid SMT snapshot, node %s not part of the tree", common.BigToHash(key.ToBigInt()))
+			}
+			node := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			if node.key != key {
+				return nil, fmt.Errorf("invalid SMT snapshot, node %s found where %s was expected", common.BigToHash(key.ToBigInt()), common.BigToHash(node.key.ToBigInt()))
+			}
+			if node.level > 0 {
+				path[node.level-1] = node.direction
+			}
+			info.Nodes++
+
+			capacity := utils.BranchCapacity
+			if kind == smtSnapshotLeaf {
+				capacity = utils.LeafCapacity
+			}
+			if err := checkSmtSnapshotHash(key, value, capacity); err != nil {
+				return nil, err
+			}
+			if err := eridb.Insert(key, smtNodeValue12(value)); err != nil {
+				return nil, err
+			}
+
+			if kind == smtSnapshotBranch {
+				for i := 1; i >= 0; i-- {
+					child := utils.NodeKey{value[i*4], value[i*4+1], value[i*4+2], value[i*4+3]}
+					if !child.IsZero() {
+						pending = append(pending, smtSnapshotNode{key: child, level: node.level + 1, direction: i})
+					}
+				}
+				continue
+			}
+
+			leafValue, err := in.readValue()
+			if err != nil {
+				return nil, err
+			}
+			source, err := in.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := importSmtSnapshotLeaf(eridb, key, value, leafValue, source, path[:node.level], codes); err != nil {
+				return nil, err
+			}
+			info.Leaves++
+			if uint8(node.level) > info.Depth {
+				info.Depth = uint8(node.level)
+			}
+
+		case smtSnapshotCode:
+			var codeHash common.Hash
+			if err := in.read(codeHash[:]); err != nil {
+				return nil, err
+			}
+			code, err := in.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if imported, ok := codes[codeHash]; !ok || imported {
+				return nil, fmt.Errorf("invalid SMT snapshot, code %s no leaf holds or seen before", codeHash)
+			}
+			if utils.HashContractBytecodeBigInt("0x"+hex.EncodeToString(code)).Cmp(codeHash.Big()) != 0 {
+				return nil, fmt.Errorf("invalid SMT snapshot, code not matching its hash %s", codeHash)
+			}
+			if err := tx.Put(kv.Code, codeHash.Bytes(), code); err != nil {
+				return nil, err
+			}
+			codes[codeHash] = true
+			info.Codes++
+
+		case smtSnapshotEnd:
+			var counts [3]uint64
+			for i := range counts {
+				if counts[i], err = in.readUint(8); err != nil {
+					return nil, err
+				}
+			}
+			depth, err := in.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			expected := in.digest.Sum(nil)
+			digest := make([]byte, sha256.Size)
+			if _, err := io.ReadFull(in.r, digest); err != nil {
+				return nil, smtSnapshotTruncated(err)
+			}
+			if !bytes.Equal(digest, expected) {
+				return nil, errors.New("invalid SMT snapshot, checksum mismatch")
+			}
+			if _, err := in.r.ReadByte(); !errors.Is(err, io.EOF) {
+				return nil, errors.New("invalid SMT snapshot, data after the checksum")
+			}
+
+			if len(pending) > 0 {
+				return nil, fmt.Errorf("invalid SMT snapshot, %d nodes missing", len(pending))
+			}
+			for codeHash, imported := range codes {
+				if !imported {
+					return nil, fmt.Errorf("invalid SMT snapshot, code %s missing", codeHash)
+				}
+			}
+			if counts != [3]uint64{info.Nodes, info.Leaves, info.Codes} || depth != info.Depth {
+				return nil, errors.New("invalid SMT snapshot, the counts of the trailer don't match the content")
+			}
+
+			if err := eridb.SetLastRoot(info.Root.Big()); err != nil {
+				return nil, err
+			}
+			return info, nil
+
+		default:
+			return nil, fmt.Errorf("invalid SMT snapshot, unknown record %q", kind)
+		}
+	}
+}
+
+func importSmtSnapshotLeaf(eridb *db2.EriDb, key utils.NodeKey, value, leafValue [12]uint64, source []byte, path []int, codes map[common.Hash]bool) error {
+	valueHash := utils.NodeKey{value[4], value[5], value[6], value[7]}
+	if err := checkSmtSnapshotHash(valueHash, leafValue, utils.BranchCapacity); err != nil {
+		return err
+	}
+	nodeKey := *utils.JoinKey(path, utils.NodeKey{value[0], value[1], value[2], value[3]})
+
+	kind, address, position, err := utils.DecodeKeySource(source)
+	if err != nil {
+		return fmt.Errorf("invalid SMT snapshot, key source of the leaf %s: %w", common.BigToHash(nodeKey.ToBigInt()), err)
+	}
+	if smtKey(kind, address, position) != nodeKey {
+		return fmt.Errorf("invalid SMT snapshot, %s doesn't match the key of its leaf %s", describeSmtKey(kind, address, position), common.BigToHash(nodeKey.ToBigInt()))
+	}
+
+	leafValue12 := smtNodeValue12(leafValue)
+	if err := eridb.Insert(valueHash, leafValue12); err != nil {
+		return err
+	}
+	if err := eridb.InsertHashKey(key, nodeKey); err != nil {
+		return err
+	}
+	if err := eridb.InsertKeySource(nodeKey, source); err != nil {
+		return err
+	}
+	if err := eridb.InsertAccountValue(nodeKey, *leafValue12.GetNodeValue8()); err != nil {
+		return err
+	}
+
+	if kind == utils.SC_CODE {
+		if codeHash := smtLeafHash(leafValue12); codeHash != (common.Hash{}) && !codes[codeHash] {
+			codes[codeHash] = false
+		}
+	}
+	return nil
+}
+
+func checkSmtSnapshotHash(key utils.NodeKey, value [12]uint64, capacity [4]uint64) error {
+	if [4]uint64(value[8:12]) != capacity || utils.NodeKey(utils.Hash([8]uint64(value[:8]), capacity)) != key {
+		return fmt.Errorf("invalid SMT snapshot, node %s not matching its hash", common.BigToHash(key.ToBigInt()))
+	}
+	return nil
+}
+
+func smtNodeValue12(value [12]uint64) utils.NodeValue12 {
+	var nv utils.NodeValue12
+	for i, v := range value {
+		nv[i] = new(big.Int).SetUint64(v)
+	}
+	return nv
+}
@@ -0,0 +1,95 @@
+package smt
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmtSnapshot(t *testing.T) {
+	ctx := context.Background()
+	const block = 7
+
+	// the source: a tree with two contracts sharing a code
+	source := memdb.BeginRw(t, memdb.NewTestDB(t))
+	require.NoError(t, db2.CreateEriDbBuckets(source))
+	sourceDb := db2.NewEriDb(source)
+	dbSmt := smt.NewSMT(sourceDb, false)
+
+	code := "0x600160005500"
+	contract1, contract2 := common.HexToAddress("0xc1"), common.HexToAddress("0xc2")
+	state := &testSmtState{
+		accounts: map[common.Address]*accounts.Account{
+			common.HexToAddress("0xa1"): {Balance: *uint256.NewInt(1), Nonce: 2},
+			common.HexToAddress("0xa2"): {Balance: *uint256.NewInt(3)},
+			contract1:                   {Nonce: 1, Incarnation: 1},
+			contract2:                   {Nonce: 1, Incarnation: 1},
+		},
+		code:    map[common.Address]string{contract1: code, contract2: code},
+		storage: map[common.Address]map[string]string{contract1: {"0x01": "0x0a"}, contract2: {"0x01": "0x0b", "0x02": "0x0c"}},
+	}
+	root := state.write(t, source)
+	_, _, err := dbSmt.SetStorage(ctx, "test", state.accounts, state.code, state.storage)
+	require.NoError(t, err)
+	require.Equal(t, root, dbSmt.LastRoot())
+	codeBytes, err := hex.DecodeString(code[2:])
+	require.NoError(t, err)
+	require.NoError(t, sourceDb.AddCode(codeBytes))
+
+	var snapshot bytes.Buffer
+	exported, err := ExportSmt(ctx, "test", db2.NewRoEriDb(source), block, common.BigToHash(root), &snapshot)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), exported.Leaves)
+	require.Equal(t, uint64(1), exported.Codes)
+
+	// importing into a node with the state root of the block and the state written, the tree being a healthy one
+	importInto := func(stateRoot common.Hash, data []byte) (kv.RwTx, *SmtSnapshotInfo, error) {
+		tx := memdb.BeginRw(t, memdb.NewTestDB(t))
+		require.NoError(t, db2.CreateEriDbBuckets(tx))
+		require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+		require.NoError(t, hermez_db.NewHermezDb(tx).WriteStateRoot(block, stateRoot))
+		info, err := ImportSmt(ctx, "test", tx, bytes.NewReader(data))
+		return tx, info, err
+	}
+
+	tx, imported, err := importInto(common.BigToHash(root), snapshot.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, exported, imported)
+	require.Equal(t, root, smt.NewSMT(db2.NewEriDb(tx), false).LastRoot())
+	state.write(t, tx)
+	report, err := CheckSmt(ctx, "test", tx, t.TempDir(), false)
+	require.NoError(t, err)
+	require.True(t, report.Healthy(), "%+v", report.Issues)
+	require.Equal(t, uint64(12), report.StateLeaves)
+	require.Equal(t, uint64(0), report.Orphans)
+	require.Equal(t, uint64(0), report.UnreferencedValues)
+	importedCode, err := db2.NewRoEriDb(tx).GetCode(common.BigToHash(utils.HashContractBytecodeBigInt(code)).Bytes())
+	require.NoError(t, err)
+	require.Equal(t, codeBytes, importedCode)
+
+	// another state root
+	_, _, err = importInto(common.HexToHash("0x01"), snapshot.Bytes())
+	require.ErrorContains(t, err, "doesn't match the state root")
+
+	// a byte changed, either the node or the checksum not matching
+	corrupted := bytes.Clone(snapshot.Bytes())
+	corrupted[len(corrupted)/2] ^= 1
+	_, _, err = importInto(common.BigToHash(root), corrupted)
+	require.ErrorContains(t, err, "invalid SMT snapshot")
+
+	// a file cut short
+	_, _, err = importInto(common.BigToHash(root), snapshot.Bytes()[:snapshot.Len()-10])
+	require.ErrorContains(t, err, "truncated")
+}
@@ -18,12 +18,16 @@ import (
 )
 
 func UnwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, tx kv.RwTx, checkRoot bool, expectedRootHash *common.Hash, quiet bool) (common.Hash, error) {
+	return UnwindZkSMTInDb(ctx, logPrefix, from, to, tx, db2.NewEriDb(tx), checkRoot, expectedRootHash, quiet)
+}
+
+// UnwindZkSMTInDb unwinds the SMT through the given db of the tx, set up to retain the history of the tree if needed
+func UnwindZkSMTInDb(ctx context.Context, logPrefix string, from, to uint64, tx kv.RwTx, eridb *db2.EriDb, checkRoot bool, expectedRootHash *common.Hash, quiet bool) (common.Hash, error) {
 	if !quiet {
 		log.Info(fmt.Sprintf("[%s] Unwind trie hashes started", logPrefix))
 		defer log.Info(fmt.Sprintf("[%s] Unwind ended", logPrefix))
 	}
 
-	eridb := db2.NewEriDb(tx)
 	eridb.RollbackBatch()
 
 	dbSmt := smt.NewSMT(eridb, false)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"os"
 	"sync/atomic"
@@ -467,6 +468,9 @@ func UnwindBatchesStage(u *stagedsync.UnwindState, tx kv.RwTx, cfg BatchesCfg, c
 		if err := hermezDb.DeleteBatchGlobalExitRoots(fromBatch); err != nil {
 			return fmt.Errorf("DeleteBatchGlobalExitRoots: %w", err)
 		}
+		if err := hermezDb.DeleteVerificationFailures(fromBatch, toBatch); err != nil {
+			return fmt.Errorf("DeleteVerificationFailures: %w", err)
+		}
 	}
 
 	if highestVerifiedBatch >= fromBatch {
@@ -640,6 +644,9 @@ func PruneBatchesStage(s *stagedsync.PruneState, tx kv.RwTx, cfg BatchesCfg, ctx
 	if err := hermezDb.DeleteBlockBatches(0, toBlock); err != nil {
 		return fmt.Errorf("DeleteBlockBatches: %w", err)
 	}
+	if err := hermezDb.DeleteVerificationFailures(0, math.MaxUint64); err != nil {
+		return fmt.Errorf("DeleteVerificationFailures: %w", err)
+	}
 	if hermezDb.DeleteBlockGlobalExitRoots(0, toBlock); err != nil {
 		return fmt.Errorf("DeleteBlockGlobalExitRoots: %w", err)
 	}
@@ -673,7 +680,10 @@ func rollback(
 	tx kv.RwTx,
 	u stagedsync.Unwinder,
 ) (uint64, error) {
-	dsClient := buildNewStreamClient(ctx, cfg, latestFork)
+	dsClient, err := buildNewStreamClient(ctx, cfg, latestFork)
+	if err != nil {
+		return 0, err
+	}
 	if err := dsClient.Start(); err != nil {
 		return 0, err
 	}
@@ -835,7 +845,10 @@ func getHighestDSL2Block(ctx context.Context, batchCfg BatchesCfg, latestFork ui
 	// but we're going to open a new connection rather than use the one for syncing blocks.
 	// This is so we can keep the logic simple and just dispose of the connection when we're done
 	// greatly simplifying state juggling of the connection if it errors
-	dsClient := buildNewStreamClient(ctx, batchCfg, latestFork)
+	dsClient, err := buildNewStreamClient(ctx, batchCfg, latestFork)
+	if err != nil {
+		return 0, err
+	}
 	if err = dsClient.Start(); err != nil {
 		return 0, err
 	}
@@ -852,7 +865,11 @@ func getHighestDSL2Block(ctx context.Context, batchCfg BatchesCfg, latestFork ui
 	return fullBlock.L2BlockNumber, nil
 }
 
-func buildNewStreamClient(ctx context.Context, batchesCfg BatchesCfg, latestFork uint16) *client.StreamClient {
+func buildNewStreamClient(ctx context.Context, batchesCfg BatchesCfg, latestFork uint16) (*client.StreamClient, error) {
 	cfg := batchesCfg.zkCfg
-	return client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestFork)
+	c := client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestFork)
+	if err := c.SetAuth(cfg.L2DataStreamerToken, cfg.L2DataStreamerTLSCertFile, cfg.L2DataStreamerTLSKeyFile, cfg.L2DataStreamerTLSCAFile); err != nil {
+		return nil, fmt.Errorf("datastream client authentication: %w", err)
+	}
+	return c, nil
 }
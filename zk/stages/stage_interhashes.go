@@ -128,6 +128,16 @@ func SpawnZkIntermediateHashesStage(s *stagedsync.StageState, u stagedsync.Unwin
 	eridb := db2.NewEriDb(tx)
 	smt := smt.NewSMT(eridb, false)
 
+	if shouldIncrement {
+		err = prepareSmtHistory(eridb, cfg.zk, s.BlockNumber, to)
+	} else {
+		// the tree is rebuilt from scratch, its history starting over
+		err = dropSmtHistory(eridb)
+	}
+	if err != nil {
+		return trie.EmptyRoot, err
+	}
+
 	if cfg.zk.SmtRegenerateInMemory {
 		log.Info(fmt.Sprintf("[%s] SMT using mapmutation", logPrefix))
 		eridb.OpenBatch(quit)
@@ -177,6 +187,15 @@ func SpawnZkIntermediateHashesStage(s *stagedsync.StageState, u stagedsync.Unwin
 		}
 	}
 
+	if shouldIncrement {
+		err = pruneSmtHistory(tx, eridb, cfg.zk, to)
+	} else if cfg.zk.SmtHistoryBlocks > 0 {
+		err = eridb.SetHistoryFrom(to)
+	}
+	if err != nil {
+		return trie.EmptyRoot, err
+	}
+
 	if err = s.Update(tx, to); err != nil {
 		return trie.EmptyRoot, err
 	}
@@ -214,7 +233,12 @@ func UnwindZkIntermediateHashesStage(u *stagedsync.UnwindState, s *stagedsync.St
 		expectedRootHash = syncHeadHeader.Root
 	}
 
-	if _, err = zkSmt.UnwindZkSMT(ctx, s.LogPrefix(), s.BlockNumber, u.UnwindPoint, tx, cfg.checkRoot, &expectedRootHash, silent); err != nil {
+	eridb := db2.NewEriDb(tx)
+	if err = prepareSmtHistory(eridb, cfg.zk, s.BlockNumber, u.UnwindPoint); err != nil {
+		return err
+	}
+
+	if _, err = zkSmt.UnwindZkSMTInDb(ctx, s.LogPrefix(), s.BlockNumber, u.UnwindPoint, tx, eridb, cfg.checkRoot, &expectedRootHash, silent); err != nil {
 		return err
 	}
 	hermezDb := hermez_db.NewHermezDb(tx)
@@ -0,0 +1,159 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+)
+
+// bounds the retained nodes deleted by a single tree update, catching up with a shortened history over several ones
+const smtHistoryPruneLimit = 100_000
+
+// ErrSmtHistoryNotRetained is returned for the blocks older than the SMT history kept by the node
+var ErrSmtHistoryNotRetained = errors.New("smt history not retained for the block")
+
+// prepareSmtHistory is called before the tree of previousBlock is updated into the one of block. It makes the nodes
+// the update drops stay in the db when zkevm.smt-history-blocks is set, and deletes the ones kept so far otherwise
+func prepareSmtHistory(eridb *db2.EriDb, zkCfg *ethconfig.Zk, previousBlock, block uint64) error {
+	if zkCfg == nil {
+		return nil
+	}
+
+	historyFrom, found, err := eridb.GetHistoryFrom()
+	if err != nil {
+		return err
+	}
+
+	if zkCfg.SmtHistoryBlocks == 0 {
+		return dropSmtHistory(eridb)
+	}
+
+	if !found || block < historyFrom {
+		// the tree in the db is the first one of the history, or the one of the block an unwind goes back to
+		if historyFrom = previousBlock; block < historyFrom {
+			historyFrom = block
+		}
+		if err := eridb.SetHistoryFrom(historyFrom); err != nil {
+			return err
+		}
+	}
+	eridb.RetainHistory(block)
+
+	return nil
+}
+
+// dropSmtHistory deletes all the retained nodes. They must go before the tree changes without them being recorded,
+// as the ones brought back by the change would be deleted with them otherwise
+func dropSmtHistory(eridb *db2.EriDb) error {
+	if _, found, err := eridb.GetHistoryFrom(); err != nil || !found {
+		return err
+	}
+	if _, _, err := eridb.PruneHistory(math.MaxUint64, 0); err != nil {
+		return err
+	}
+	return eridb.ClearHistoryFrom()
+}
+
+// pruneSmtHistory deletes the nodes only used by the trees older than zkevm.smt-history-blocks, keeping the ones of
+// the blocks not verified yet whatever their age
+func pruneSmtHistory(tx kv.RwTx, eridb *db2.EriDb, zkCfg *ethconfig.Zk, block uint64) error {
+	if zkCfg == nil || zkCfg.SmtHistoryBlocks == 0 || block <= zkCfg.SmtHistoryBlocks {
+		return nil
+	}
+
+	historyFrom, found, err := eridb.GetHistoryFrom()
+	if err != nil || !found {
+		return err
+	}
+
+	pruneTo := block - zkCfg.SmtHistoryBlocks
+
+	verifiedBatch, err := stages.GetStageProgress(tx, stages.L1VerificationsBatchNo)
+	if err != nil {
+		return err
+	}
+	hermezDb := hermez_db.NewHermezDbReader(tx)
+	// not found when the node is behind the verified batch, all its blocks being verified
+	verifiedBlock, found, err := hermezDb.GetHighestBlockInBatch(verifiedBatch)
+	if err != nil {
+		return err
+	}
+	if found && verifiedBlock < pruneTo {
+		pruneTo = verifiedBlock
+	}
+
+	// the roots of the blocks the tree was never computed for can't be traversed
+	if pruneTo, found, err = hermezDb.GetLastSmtDepthBlock(pruneTo); err != nil || !found || pruneTo <= historyFrom {
+		return err
+	}
+
+	prunedTo, _, err := eridb.PruneHistory(pruneTo, smtHistoryPruneLimit)
+	if err != nil {
+		return err
+	}
+	if prunedTo > historyFrom {
+		return eridb.SetHistoryFrom(prunedTo)
+	}
+
+	return nil
+}
+
+// RestoreSmtHistory turns the SMT of the memory batch into the tree of the block, out of the nodes kept by
+// zkevm.smt-history-blocks: the root of the closest block the tree was computed for is restored and the changes of
+// the blocks after it applied on top. The batch must be discarded afterwards
+func RestoreSmtHistory(ctx context.Context, logPrefix string, batch kv.RwTx, block uint64) error {
+	eridb := db2.NewEriDb(batch)
+
+	historyFrom, found, err := eridb.GetHistoryFrom()
+	if err != nil {
+		return err
+	}
+	if !found || block < historyFrom {
+		return ErrSmtHistoryNotRetained
+	}
+
+	from, found, err := hermez_db.NewHermezDbReader(batch).GetLastSmtDepthBlock(block)
+	if err != nil {
+		return err
+	}
+	if !found || from < historyFrom {
+		from = historyFrom
+	}
+
+	fromHeader := rawdb.ReadHeaderByNumber(batch, from)
+	if fromHeader == nil {
+		return fmt.Errorf("no header found with number %d", from)
+	}
+	header := rawdb.ReadHeaderByNumber(batch, block)
+	if header == nil {
+		return fmt.Errorf("no header found with number %d", block)
+	}
+
+	if err := eridb.SetLastRoot(fromHeader.Root.Big()); err != nil {
+		return err
+	}
+
+	root := fromHeader.Root
+	if from < block {
+		dbSmt := smt.NewSMT(eridb, false)
+		if root, err = zkIncrementIntermediateHashes(ctx, logPrefix, &stagedsync.StageState{BlockNumber: from}, batch, eridb, dbSmt, from, block); err != nil {
+			return err
+		}
+	}
+
+	if root != header.Root {
+		return fmt.Errorf("restored smt root %x of block %d doesn't match the header root %x", root, block, header.Root)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package stages
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmtHistory(t *testing.T) {
+	ctx, db := context.Background(), memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+	require.NoError(t, db2.CreateEriDbBuckets(tx))
+
+	zkCfg := &ethconfig.Zk{SmtHistoryBlocks: 1}
+	hermezDb := hermez_db.NewHermezDb(tx)
+	eridb := db2.NewEriDb(tx)
+	dbSmt := smt.NewSMT(eridb, false)
+
+	alice, bob := common.HexToAddress("0xa1"), common.HexToAddress("0xb2")
+	balanceAt := func(root *big.Int, address common.Address) uint64 {
+		lastRoot := dbSmt.LastRoot()
+		defer dbSmt.SetLastRoot(lastRoot)
+		dbSmt.SetLastRoot(root)
+		balance, err := dbSmt.GetAccountBalance(address)
+		require.NoError(t, err)
+		return balance.Uint64()
+	}
+
+	// each block sets the balances and is part of batch 1
+	roots := []*big.Int{dbSmt.LastRoot()}
+	for block, balances := range []map[common.Address]uint64{{alice: 1, bob: 2}, {alice: 3}, {alice: 4, bob: 5}} {
+		number := uint64(block + 1)
+		require.NoError(t, prepareSmtHistory(eridb, zkCfg, number-1, number))
+
+		changes := make(map[common.Address]*accounts.Account)
+		for address, balance := range balances {
+			changes[address] = &accounts.Account{Balance: *uint256.NewInt(balance)}
+		}
+		_, _, err := dbSmt.SetStorage(ctx, "test", changes, nil, nil)
+		require.NoError(t, err)
+		roots = append(roots, dbSmt.LastRoot())
+
+		require.NoError(t, hermezDb.WriteSmtDepth(number, uint64(dbSmt.GetDepth())))
+		require.NoError(t, hermezDb.WriteBlockBatch(number, 1))
+		require.NoError(t, pruneSmtHistory(tx, eridb, zkCfg, number))
+	}
+
+	// nothing is verified, every tree stays whole
+	require.Equal(t, uint64(1), balanceAt(roots[1], alice))
+	require.Equal(t, uint64(3), balanceAt(roots[2], alice))
+	require.Equal(t, uint64(2), balanceAt(roots[2], bob))
+	require.Equal(t, uint64(5), balanceAt(roots[3], bob))
+	historyFrom, _, err := eridb.GetHistoryFrom()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), historyFrom)
+
+	// once verified the trees older than the history go
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1VerificationsBatchNo, 1))
+	require.NoError(t, pruneSmtHistory(tx, eridb, zkCfg, 3))
+	historyFrom, _, err = eridb.GetHistoryFrom()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), historyFrom)
+	require.Equal(t, uint64(3), balanceAt(roots[2], alice))
+	require.Equal(t, uint64(4), balanceAt(roots[3], alice))
+	require.Equal(t, uint64(0), balanceAt(roots[1], alice), "the root of block 1 is gone")
+
+	// dropping the history keeps the current tree
+	require.NoError(t, prepareSmtHistory(eridb, &ethconfig.Zk{}, 3, 4))
+	_, found, err := eridb.GetHistoryFrom()
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Equal(t, uint64(4), balanceAt(roots[3], alice))
+	require.Equal(t, uint64(5), balanceAt(roots[3], bob))
+}
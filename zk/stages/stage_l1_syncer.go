@@ -21,6 +21,7 @@ import (
 	"github.com/ledgerwatch/erigon/zk/contracts"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/sequencer"
+	"github.com/ledgerwatch/erigon/zk/syncer"
 	"github.com/ledgerwatch/erigon/zk/types"
 )
 
@@ -107,6 +108,13 @@ func SpawnStageL1Syncer(
 		return fmt.Errorf("GetStageProgress, %w", err)
 	}
 
+	trackReorgs := cfg.zkCfg.TracksL1Reorgs()
+	if trackReorgs {
+		if l1BlockProgress, err = unwindL1SyncerReorg(logPrefix, tx, hermezDb, cfg, l1BlockProgress); err != nil {
+			return fmt.Errorf("unwindL1SyncerReorg: %w", err)
+		}
+	}
+
 	// start syncer if not started
 	if !cfg.syncer.IsSyncStarted() {
 		if l1BlockProgress == 0 {
@@ -138,6 +146,11 @@ Loop:
 			for _, l := range logs {
 				l := l
 				info, batchLogType := parseLogType(cfg.zkCfg.L1RollupId, &l)
+				if trackReorgs && batchLogType != logUnknown && batchLogType != logIncompatible {
+					if err := hermezDb.WriteL1BlockHash(string(stages.L1Syncer), l.BlockNumber, l.BlockHash); err != nil {
+						return fmt.Errorf("WriteL1BlockHash: %w", err)
+					}
+				}
 				switch batchLogType {
 				case logSequence:
 					fallthrough
@@ -197,6 +210,17 @@ Loop:
 
 	lastCheckedL1BlockCounter.Set(float64(latestCheckedBlock))
 
+	// the blocks checked without any log could be reorged to ones with logs, the hash of the last one is kept as well
+	if trackReorgs && latestCheckedBlock > 0 {
+		header, err := cfg.syncer.GetHeader(latestCheckedBlock)
+		if err != nil {
+			return fmt.Errorf("GetHeader: %w", err)
+		}
+		if err = hermezDb.WriteL1BlockHash(string(stages.L1Syncer), latestCheckedBlock, header.Hash()); err != nil {
+			return fmt.Errorf("WriteL1BlockHash: %w", err)
+		}
+	}
+
 	if highestWrittenL1BlockNo > l1BlockProgress {
 		log.Info(fmt.Sprintf("[%s] Saving L1 syncer progress", logPrefix), "latestCheckedBlock", latestCheckedBlock, "newVerificationsCount", newVerificationsCount, "newSequencesCount", newSequencesCount, "highestWrittenL1BlockNo", highestWrittenL1BlockNo)
 
@@ -296,6 +320,50 @@ func parseLogType(l1RollupId uint64, log *ethTypes.Log) (l1BatchInfo types.L1Bat
 	}, batchLogType
 }
 
+// unwindL1SyncerReorg rolls back the sequences and verifications of the L1 blocks reorged out and restarts the syncer
+// from the highest block still canonical to fetch them again. It returns the L1 block progress to start from.
+func unwindL1SyncerReorg(logPrefix string, tx kv.RwTx, hermezDb *hermez_db.HermezDb, cfg L1SyncerCfg, l1BlockProgress uint64) (uint64, error) {
+	ancestor, reorged, err := syncer.CheckL1Reorg(hermezDb, string(stages.L1Syncer), cfg.syncer)
+	if err != nil || !reorged {
+		return l1BlockProgress, err
+	}
+
+	// the logs the syncer already fetched past the ancestor are dropped
+	if cfg.syncer.IsSyncStarted() {
+		cfg.syncer.StopQueryBlocks()
+		cfg.syncer.ConsumeQueryBlocks()
+		cfg.syncer.WaitQueryBlocksToFinish()
+	}
+
+	if err = hermezDb.TruncateSequencesByL1Block(ancestor); err != nil {
+		return 0, fmt.Errorf("TruncateSequencesByL1Block: %w", err)
+	}
+	if err = hermezDb.TruncateVerificationsByL1Block(ancestor); err != nil {
+		return 0, fmt.Errorf("TruncateVerificationsByL1Block: %w", err)
+	}
+	highestVerification, err := hermezDb.GetLatestVerification()
+	if err != nil {
+		return 0, fmt.Errorf("GetLatestVerification: %w", err)
+	}
+	var highestVerifiedBatchNo uint64
+	if highestVerification != nil {
+		highestVerifiedBatchNo = highestVerification.BatchNo
+	}
+	if err = stages.SaveStageProgress(tx, stages.L1VerificationsBatchNo, highestVerifiedBatchNo); err != nil {
+		return 0, fmt.Errorf("SaveStageProgress: %w", err)
+	}
+
+	if ancestor < l1BlockProgress {
+		l1BlockProgress = ancestor
+		if err = stages.SaveStageProgress(tx, stages.L1Syncer, l1BlockProgress); err != nil {
+			return 0, fmt.Errorf("SaveStageProgress: %w", err)
+		}
+	}
+
+	log.Warn(fmt.Sprintf("[%s] L1 reorg, rolled back the sequences and verifications", logPrefix), "ancestor", ancestor, "l1BlockProgress", l1BlockProgress, "highestVerifiedBatchNo", highestVerifiedBatchNo)
+	return l1BlockProgress, nil
+}
+
 func UnwindL1SyncerStage(u *stagedsync.UnwindState, tx kv.RwTx, cfg L1SyncerCfg, ctx context.Context) (err error) {
 	// we want to keep L1 data during an unwind, as we only sync finalised data there should be
 	// no need to unwind here
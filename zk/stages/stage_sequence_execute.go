@@ -83,19 +83,28 @@ func sequencingBatchStep(
 	pending, basefee, queued := cfg.txPool.CountContent()
 	metrics.AddPoolTxCount(pending, basefee, queued)
 
-	// at this point of time the datastream could not be ahead of the executor
-	if err = validateIfDatastreamIsAheadOfExecution(s, ctx, cfg); err != nil {
-		return err
-	}
+	// a dry run leaves the datastream, the database and the info tree updater of the node alone
+	var sdb *stageDb
+	if cfg.dryRun != nil {
+		if sdb, err = newDryRunStageDb(ctx, cfg.db, cfg.dirs.Tmp); err != nil {
+			return err
+		}
+	} else {
+		// at this point of time the datastream could not be ahead of the executor
+		if err = validateIfDatastreamIsAheadOfExecution(s, ctx, cfg); err != nil {
+			return err
+		}
 
-	sdb, err := newStageDb(ctx, cfg.db)
-	if err != nil {
-		return err
+		if sdb, err = newStageDb(ctx, cfg.db); err != nil {
+			return err
+		}
 	}
 	defer sdb.tx.Rollback()
 
-	if err = cfg.infoTreeUpdater.WarmUp(sdb.tx); err != nil {
-		return err
+	if cfg.dryRun == nil {
+		if err = cfg.infoTreeUpdater.WarmUp(sdb.tx); err != nil {
+			return err
+		}
 	}
 
 	executionAt, err := s.ExecutionAt(sdb.tx)
@@ -132,6 +141,12 @@ func sequencingBatchStep(
 	blockDataSizeChecker := NewBlockDataChecker(cfg.zk.ShouldCountersBeUnlimited(batchState.isL1Recovery()))
 	streamWriter := newSequencerBatchStreamWriter(batchContext, batchState)
 
+	if executionAt == 0 && cfg.dryRun != nil {
+		log.Warn(fmt.Sprintf("[%s] Nothing to build a dry run batch on yet", logPrefix))
+		time.Sleep(10 * time.Second)
+		return nil
+	}
+
 	// injected batch
 	if executionAt == 0 {
 		if err = processInjectedInitialBatch(batchContext, batchState); err != nil {
@@ -148,7 +163,7 @@ func sequencingBatchStep(
 		return sdb.tx.Commit()
 	}
 
-	if shouldCheckForExecutionAndDataStreamAlignment {
+	if shouldCheckForExecutionAndDataStreamAlignment && cfg.dryRun == nil {
 		// handle cases where the last batch wasn't committed to the data stream.
 		// this could occur because we're migrating from an RPC node to a sequencer
 		// or because the sequencer was restarted and not all processes completed (like waiting from remote executor)
@@ -174,14 +189,16 @@ func sequencingBatchStep(
 		shouldCheckForExecutionAndDataStreamAlignment = false
 	}
 
-	needsUnwind, exitStage, err := tryHaltSequencer(batchContext, batchState, streamWriter, u, executionAt)
-	if needsUnwind || err != nil {
-		return err
-	}
-	if exitStage {
-		log.Info(fmt.Sprintf("[%s] Exiting stage during halted sequencer", logPrefix))
-		// commit the tx so any updates to the stream etc are persisted
-		return sdb.tx.Commit()
+	if cfg.dryRun == nil {
+		needsUnwind, exitStage, err := tryHaltSequencer(batchContext, batchState, streamWriter, u, executionAt)
+		if needsUnwind || err != nil {
+			return err
+		}
+		if exitStage {
+			log.Info(fmt.Sprintf("[%s] Exiting stage during halted sequencer", logPrefix))
+			// commit the tx so any updates to the stream etc are persisted
+			return sdb.tx.Commit()
+		}
 	}
 
 	if err := utils.UpdateZkEVMBlockCfg(cfg.chainConfig, sdb.hermezDb, logPrefix); err != nil {
@@ -190,6 +207,14 @@ func sequencingBatchStep(
 
 	batchCounters := prepareBatchCounters(batchContext, batchState)
 
+	// the block space reserved to the priority lanes and the throughput quotas, only when building batches from the pool
+	var lanes *laneBudgets
+	var quotas *quotaBudgets
+	if !batchState.isAnyRecovery() {
+		lanes = newLaneBudgets(cfg.txPool.Lanes(), batchCounters)
+		quotas = newQuotaBudgets(cfg.txPool.Quotas(), batchCounters)
+	}
+
 	if batchState.isL1Recovery() {
 		if cfg.zk.L1SyncStopBatch > 0 && batchState.batchNumber > cfg.zk.L1SyncStopBatch {
 			log.Info(fmt.Sprintf("[%s] L1 recovery has completed!", logPrefix), "batch", batchState.batchNumber)
@@ -239,6 +264,7 @@ func sequencingBatchStep(
 	batchTimer := time.NewTimer(cfg.zk.SequencerBatchSealTime)
 
 	log.Info(fmt.Sprintf("[%s] Starting batch %d...", logPrefix, batchState.batchNumber))
+	cfg.dryRun.startBatch(batchState.batchNumber, batchState.forkId)
 
 	// For X Layer
 	var batchCloseReason metrics.BatchFinalizeType
@@ -256,6 +282,7 @@ func sequencingBatchStep(
 		metrics.GetLogStatistics().CumulativeCounting(metrics.BlockCounter)
 		if batchTimedOut {
 			log.Debug(fmt.Sprintf("[%s] Closing batch due to timeout", logPrefix))
+			batchCloseReason = metrics.BatchTimeOut
 			break
 		}
 		startTime := time.Now()
@@ -263,6 +290,13 @@ func sequencingBatchStep(
 		logTicker.Reset(10 * time.Second)
 		blockTimer := time.NewTimer(cfg.zk.SequencerBlockSealTime)
 		ethBlockGasPool := new(core.GasPool).AddGas(transactionGasLimit) // used only in normalcy mode per block
+		if lanes != nil {
+			lanes.startBlock(utils.GetBlockGasLimitForFork(batchState.forkId))
+		}
+		if quotas != nil {
+			quotas.startBlock()
+			cfg.txPool.StartQuotaBlock()
+		}
 
 		if batchState.isL1Recovery() {
 			blockNumbersInBatchSoFar, err := batchContext.sdb.hermezDb.GetL2BlockNosByBatch(batchState.batchNumber)
@@ -300,6 +334,7 @@ func sequencingBatchStep(
 
 		if batchDataOverflow := blockDataSizeChecker.AddBlockStartData(); batchDataOverflow {
 			log.Info(fmt.Sprintf("[%s] BatchL2Data limit reached. Stopping.", logPrefix), "blockNumber", blockNumber)
+			batchCloseReason = metrics.BatchDataOverflow
 			break
 		}
 
@@ -339,6 +374,7 @@ func sequencingBatchStep(
 
 		innerBreak := false
 		emptyBlockOverflow := false
+		bundlesAttempted := false
 		sendersToTriggerStatechanges := make(map[common.Address]struct{})
 		processingTxTime := time.Now()
 	OuterLoopTransactions:
@@ -373,6 +409,9 @@ func sequencingBatchStep(
 
 			select {
 			case <-infoTreeTicker.C:
+				if cfg.dryRun != nil {
+					break
+				}
 				newLogs, err := cfg.infoTreeUpdater.CheckForInfoTreeUpdates(logPrefix, sdb.tx)
 				if err != nil {
 					return err
@@ -386,6 +425,14 @@ func sequencingBatchStep(
 			default:
 			}
 
+			// bundles go first in the block so that they land in it together
+			if !batchState.isAnyRecovery() && !bundlesAttempted {
+				bundlesAttempted = true
+				if err = addBundles(batchContext, batchState, ibs, batchCounters, &blockContext, header, l1TreeUpdateIndex, blockDataSizeChecker, ethBlockGasPool); err != nil {
+					return err
+				}
+			}
+
 			if batchState.isLimboRecovery() {
 				batchState.blockState.transactionsForInclusion, err = getLimboTransaction(ctx, cfg, batchState.limboRecoveryData.limboTxHash, executionAt)
 				if err != nil {
@@ -439,6 +486,9 @@ func sequencingBatchStep(
 
 			badTxHashes := make([]common.Hash, 0)
 			minedTxHashes := make([]common.Hash, 0)
+			if lanes != nil {
+				lanes.startRound(batchState.blockState.transactionsForInclusion, types.MakeSigner(cfg.chainConfig, executionAt, 0), sendersToSkip)
+			}
 
 		InnerLoopTransactions:
 			for i, transaction := range batchState.blockState.transactionsForInclusion {
@@ -464,6 +514,7 @@ func sequencingBatchStep(
 							"hash", transaction.Hash())
 						badTxHashes = append(badTxHashes, txHash)
 						batchState.blockState.transactionsToDiscard = append(batchState.blockState.transactionsToDiscard, batchState.blockState.transactionHashesToSlots[txHash])
+						cfg.dryRun.onRejected(txHash, blockNumber, err.Error())
 						continue
 					}
 
@@ -475,6 +526,20 @@ func sequencingBatchStep(
 					continue
 				}
 
+				// left for a later block or batch once its lane used its share of the space
+				lane := 0
+				if lanes != nil {
+					var admitted bool
+					if lane, admitted = lanes.admit(txSender, transaction.GetTo()); !admitted {
+						cfg.dryRun.onRejected(txHash, blockNumber, "priority lane used its share")
+						continue
+					}
+				}
+				if quotas != nil && !quotas.admit(txSender, transaction.GetTo()) {
+					cfg.dryRun.onRejected(txHash, blockNumber, "over the throughput quota of its sender or contract")
+					continue
+				}
+
 				effectiveGas := batchState.blockState.getL1EffectiveGases(cfg, i)
 
 				// The copying of this structure is intentional
@@ -516,6 +581,7 @@ func sequencingBatchStep(
 						log.Info(fmt.Sprintf("[%s] nonce issue detected for sender, skipping transactions for now", logPrefix), "sender", txSender.Hex(), "nonceIssue", err)
 						sendersToSkip[txSender] = struct{}{}
 						sendersToTriggerStatechanges[txSender] = struct{}{}
+						cfg.dryRun.onRejected(txHash, blockNumber, err.Error())
 						continue
 					}
 
@@ -525,6 +591,7 @@ func sequencingBatchStep(
 					log.Warn(fmt.Sprintf("[%s] error adding transaction to batch, discarding from pool", logPrefix), "hash", txHash, "err", err)
 					badTxHashes = append(badTxHashes, txHash)
 					batchState.blockState.transactionsToDiscard = append(batchState.blockState.transactionsToDiscard, batchState.blockState.transactionHashesToSlots[txHash])
+					cfg.dryRun.onRejected(txHash, blockNumber, err.Error())
 				}
 
 				switch anyOverflow {
@@ -562,7 +629,10 @@ func sequencingBatchStep(
 						if singleTxOverflow || (!batchState.hasAnyTransactionsInThisBatch && len(batchState.builtBlocks) == 0) {
 							ocs, _ := tempCounters.CounterStats(l1TreeUpdateIndex != 0)
 							// mark the transaction to be removed from the pool
-							cfg.txPool.MarkForDiscardFromPendingBest(txHash)
+							if cfg.dryRun == nil {
+								cfg.txPool.MarkForDiscardFromPendingBest(txHash)
+							}
+							cfg.dryRun.onRejected(txHash, blockNumber, "single transaction overflows the batch counters")
 							counter, err := handleBadTxHashCounter(sdb.hermezDb, txHash)
 							if err != nil {
 								return err
@@ -573,12 +643,14 @@ func sequencingBatchStep(
 							badTxHashes = append(badTxHashes, txHash)
 						} else {
 							batchState.newOverflowTransaction()
+							cfg.dryRun.onRejected(txHash, blockNumber, "overflowed the batch counters")
 							transactionNotAddedText := fmt.Sprintf("[%s] transaction %s was not included in this batch because it overflowed.", logPrefix, txHash)
 							ocs, _ := batchCounters.CounterStats(l1TreeUpdateIndex != 0)
 							log.Info(transactionNotAddedText, "Counters context:", ocs, "overflow transactions", batchState.overflowTransactions)
 							if batchState.reachedOverflowTransactionLimit() || cfg.zk.SealBatchImmediatelyOnOverflow {
 								log.Info(fmt.Sprintf("[%s] closing batch due to overflow counters", logPrefix), "counters: ", batchState.overflowTransactions, "immediate", cfg.zk.SealBatchImmediatelyOnOverflow)
 								runLoopBlocks = false
+								batchCloseReason = metrics.BatchCounterOverflow
 								if len(batchState.blockState.builtBlockElements.transactions) == 0 {
 									emptyBlockOverflow = true
 								}
@@ -602,7 +674,9 @@ func sequencingBatchStep(
 						panic(fmt.Sprintf("block gas limit overflow in recovery block: %d", blockNumber))
 					}
 					log.Info(fmt.Sprintf("[%s] gas overflowed adding transaction to block", logPrefix), "block", blockNumber, "tx-hash", txHash)
+					cfg.dryRun.onRejected(txHash, blockNumber, "overflowed the block gas")
 					runLoopBlocks = false
+					batchCloseReason = metrics.BatchGasOverflow
 					break OuterLoopTransactions
 				case overflowNone:
 				}
@@ -612,6 +686,12 @@ func sequencingBatchStep(
 					blockDataSizeChecker = &backupDataSizeChecker
 					batchState.onAddedTransaction(transaction, receipt, execResult, effectiveGas)
 					minedTxHashes = append(minedTxHashes, txHash)
+					if lanes != nil {
+						lanes.use(lane, execResult.UsedGas, txCounters)
+					}
+					if quotas != nil {
+						quotas.use(txSender, transaction.GetTo(), execResult.UsedGas, txCounters)
+					}
 				}
 
 				// We will only update the processed index in resequence job if there isn't overflow
@@ -668,6 +748,7 @@ func sequencingBatchStep(
 
 			if batchState.isLimboRecovery() {
 				runLoopBlocks = false
+				batchCloseReason = metrics.BatchLimboRecovery
 				break OuterLoopTransactions
 			}
 		}
@@ -722,15 +803,20 @@ func sequencingBatchStep(
 			metrics.GetLogStatistics().CumulativeTiming(metrics.BatchCommitDBTiming, time.Since(commitTime))
 		}
 
-		// remove mined transactions from the pool
-		toRemove := append(batchState.blockState.builtBlockElements.txSlots, batchState.blockState.transactionsToDiscard...)
-		if err := cfg.txPool.RemoveMinedTransactions(ctx, sdb.tx, header.GasLimit, toRemove); err != nil {
-			return err
-		}
+		if cfg.dryRun == nil {
+			// remove mined transactions from the pool
+			toRemove := append(batchState.blockState.builtBlockElements.txSlots, batchState.blockState.transactionsToDiscard...)
+			if err := cfg.txPool.RemoveMinedTransactions(ctx, sdb.tx, header.GasLimit, toRemove); err != nil {
+				return err
+			}
+			for _, bundleHash := range batchState.blockState.builtBlockElements.bundles {
+				cfg.txPool.MarkBundleIncluded(bundleHash, blockNumber)
+			}
 
-		// now trigger sender state changes in the pool where we encountered nonce issues during execution
-		if err := cfg.txPool.TriggerSenderStateChanges(ctx, sdb.tx, header.GasLimit, sendersToTriggerStatechanges); err != nil {
-			return err
+			// now trigger sender state changes in the pool where we encountered nonce issues during execution
+			if err := cfg.txPool.TriggerSenderStateChanges(ctx, sdb.tx, header.GasLimit, sendersToTriggerStatechanges); err != nil {
+				return err
+			}
 		}
 
 		t.LogTimer()
@@ -747,13 +833,17 @@ func sequencingBatchStep(
 			log.Info(fmt.Sprintf("[%s] Finish block %d with %d transactions...", logPrefix, blockNumber, len(batchState.blockState.builtBlockElements.transactions)), "info-tree-index", infoTreeIndexProgress, "taken", time.Since(startTime))
 		}
 
-		// do not use remote executor in l1recovery mode
-		// if we need remote executor in l1 recovery then we must allow commit/start DB transactions
-		useExecutorForVerification := !batchState.isL1Recovery() && batchState.hasExecutorForThisBatch
+		// do not use remote executor nor the stateless verification in l1recovery mode
+		// if we need them in l1 recovery then we must allow commit/start DB transactions
+		useExecutorForVerification := !batchState.isL1Recovery() && (batchState.hasExecutorForThisBatch || cfg.zk.UseStatelessVerification())
 		counters, err := batchCounters.CombineCollectors(l1TreeUpdateIndex != 0)
 		if err != nil {
 			return err
 		}
+		if cfg.dryRun != nil {
+			cfg.dryRun.onBlock(block, counters.UsedAsMap())
+			continue
+		}
 		cfg.legacyVerifier.StartAsyncVerification(batchContext.s.LogPrefix(), batchState.forkId, batchState.batchNumber, block.Root(), counters.UsedAsMap(), batchState.builtBlocks, useExecutorForVerification, batchContext.cfg.zk.SequencerBatchVerificationTimeout, batchContext.cfg.zk.SequencerBatchVerificationRetries)
 
 		// check for new responses from the verifier
@@ -795,6 +885,10 @@ func sequencingBatchStep(
 
 	log.Info(fmt.Sprintf("[%s] Finish batch %d...", batchContext.s.LogPrefix(), batchState.batchNumber))
 
+	if err = cfg.dryRun.finishBatch(logPrefix, batchCloseReason); err != nil {
+		return err
+	}
+
 	// For X Layer
 	metrics.GetLogStatistics().SetTag(metrics.BatchCloseReason, string(batchCloseReason))
 	metrics.GetLogStatistics().SetTag(metrics.FinalizeBatchNumber, strconv.Itoa(int(batchState.batchNumber)))
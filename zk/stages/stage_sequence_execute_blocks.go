@@ -188,6 +188,9 @@ func finaliseBlock(
 	// For X Layer
 	zkIncStart := time.Now()
 	quit := batchContext.ctx.Done()
+	if err = prepareSmtHistory(batchContext.sdb.eridb, batchContext.cfg.zk, newHeader.Number.Uint64()-1, newHeader.Number.Uint64()); err != nil {
+		return nil, err
+	}
 	batchContext.sdb.eridb.OpenBatch(quit)
 	// this is actually the interhashes stage
 	newRoot, err := zkIncrementIntermediateHashes(batchContext.ctx, batchContext.s.LogPrefix(), batchContext.s, batchContext.sdb.tx, batchContext.sdb.eridb, batchContext.sdb.smt, newHeader.Number.Uint64()-1, newHeader.Number.Uint64())
@@ -199,6 +202,9 @@ func finaliseBlock(
 	if err = batchContext.sdb.eridb.CommitBatch(); err != nil {
 		return nil, err
 	}
+	if err = pruneSmtHistory(batchContext.sdb.tx, batchContext.sdb.eridb, batchContext.cfg.zk, newHeader.Number.Uint64()); err != nil {
+		return nil, err
+	}
 
 	// For X Layer
 	metrics.GetLogStatistics().CumulativeTiming(metrics.ZkIncIntermediateHashesTiming, time.Since(zkIncStart))
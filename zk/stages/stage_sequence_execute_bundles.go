@@ -0,0 +1,245 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/core/vm/evmtypes"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+var (
+	errBundleReverted = errors.New("bundle transaction reverted")
+	errBundleTooLarge = errors.New("bundle cannot fit into a batch")
+	// errBundleDiverged means a bundle failed once run for real after a successful simulation, its first transactions
+	// cannot be rolled back anymore so the block cannot be committed
+	errBundleDiverged = errors.New("bundle execution diverged from its simulation")
+)
+
+/*
+the intra block state cannot revert across transactions: the journal is cleared when a transaction is finalised.  So a
+bundle is first simulated on a throwaway state reading through the block state, with copies of the counters, gas and
+data size trackers, each transaction getting its own snapshot there as usual.  Only once all of its transactions went
+through without reverting or overflowing is the bundle executed for real, which then cannot fail.
+*/
+
+// addBundles tries the pending bundles of the pool at the start of the block, ahead of its regular transactions
+func addBundles(
+	batchContext *BatchContext,
+	batchState *BatchState,
+	ibs *state.IntraBlockState,
+	batchCounters *vm.BatchCounterCollector,
+	blockContext *evmtypes.BlockContext,
+	header *types.Header,
+	l1InfoIndex uint64,
+	blockDataSizeChecker *BlockDataChecker,
+	ethBlockGasPool *core.GasPool,
+) error {
+	cfg := batchContext.cfg
+	logPrefix := batchContext.s.LogPrefix()
+	blockNumber := header.Number.Uint64()
+
+	for _, bundle := range cfg.txPool.YieldBundles(blockNumber) {
+		transactions, err := decodeBundle(bundle)
+		if err != nil {
+			log.Warn(fmt.Sprintf("[%s] Failed to decode bundle, discarding it", logPrefix), "bundle", bundle.Hash, "err", err)
+			markBundleFailed(cfg, bundle.Hash, blockNumber, err)
+			continue
+		}
+
+		receipts, execResults, effectiveGases, anyOverflow, err := attemptAddBundle(*cfg, batchContext.sdb, ibs, batchCounters, blockContext, header, transactions, batchState.forkId, l1InfoIndex, blockDataSizeChecker, ethBlockGasPool)
+		if err != nil {
+			if err = failBundle(cfg, logPrefix, bundle.Hash, blockNumber, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if anyOverflow != overflowNone {
+			// a bundle overflowing an empty batch never fits, otherwise it is left for the next batch
+			if !batchState.hasAnyTransactionsInThisBatch && len(batchState.builtBlocks) == 0 {
+				log.Info(fmt.Sprintf("[%s] Bundle cannot fit into a batch, discarding it", logPrefix), "bundle", bundle.Hash)
+				markBundleFailed(cfg, bundle.Hash, blockNumber, errBundleTooLarge)
+				continue
+			}
+			log.Info(fmt.Sprintf("[%s] Bundle does not fit into what is left of the batch, leaving it for the next one", logPrefix), "bundle", bundle.Hash)
+			return nil
+		}
+
+		for i, transaction := range transactions {
+			batchState.onAddedTransaction(transaction, receipts[i], execResults[i], effectiveGases[i])
+		}
+		batchState.blockState.builtBlockElements.bundles = append(batchState.blockState.builtBlockElements.bundles, bundle.Hash)
+		log.Info(fmt.Sprintf("[%s] Bundle added", logPrefix), "bundle", bundle.Hash, "block", blockNumber, "txs", len(transactions))
+	}
+
+	return nil
+}
+
+// failBundle drops a bundle that could not be added, the error is returned when the block cannot be committed anymore
+// and the bundle must not be yielded again once the batch is retried
+func failBundle(cfg *SequenceBlockCfg, logPrefix string, bundleHash common.Hash, blockNumber uint64, err error) error {
+	markBundleFailed(cfg, bundleHash, blockNumber, err)
+	if errors.Is(err, errBundleDiverged) {
+		log.Error(fmt.Sprintf("[%s] Bundle diverged from its simulation, discarding it", logPrefix), "bundle", bundleHash, "err", err)
+		return err
+	}
+	log.Info(fmt.Sprintf("[%s] Bundle failed, discarding it", logPrefix), "bundle", bundleHash, "err", err)
+	return nil
+}
+
+// markBundleFailed drops a bundle from the pool, a dry run only reports it
+func markBundleFailed(cfg *SequenceBlockCfg, bundleHash common.Hash, blockNumber uint64, reason error) {
+	if cfg.dryRun != nil {
+		cfg.dryRun.onRejected(bundleHash, blockNumber, fmt.Sprintf("bundle: %v", reason))
+		return
+	}
+	cfg.txPool.MarkBundleFailed(bundleHash, reason)
+}
+
+func decodeBundle(bundle *txpool.Bundle) ([]types.Transaction, error) {
+	transactions := make([]types.Transaction, len(bundle.Txs))
+	for i, txBytes := range bundle.Txs {
+		transaction, err := types.DecodeTransaction(txBytes)
+		if err != nil {
+			return nil, err
+		}
+		// the pool recovered the senders when the bundle was submitted
+		transaction.SetSender(bundle.Senders[i])
+		transactions[i] = transaction
+	}
+	return transactions, nil
+}
+
+// attemptAddBundle adds all the transactions of a bundle to the block or none of them.  An error means the bundle can
+// never be added, an overflow that it may fit into another batch.
+func attemptAddBundle(
+	cfg SequenceBlockCfg,
+	sdb *stageDb,
+	ibs *state.IntraBlockState,
+	batchCounters *vm.BatchCounterCollector,
+	blockContext *evmtypes.BlockContext,
+	header *types.Header,
+	transactions []types.Transaction,
+	forkId, l1InfoIndex uint64,
+	blockDataSizeChecker *BlockDataChecker,
+	ethBlockGasPool *core.GasPool,
+) ([]*types.Receipt, []*core.ExecutionResult, []uint8, overflowType, error) {
+	effectiveGases := make([]uint8, len(transactions))
+	for i, transaction := range transactions {
+		effectiveGases[i] = DeriveEffectiveGasPrice(cfg, transaction)
+	}
+
+	if anyOverflow, err := simulateBundle(cfg, sdb, ibs, batchCounters, blockContext, header, transactions, effectiveGases, forkId, l1InfoIndex, blockDataSizeChecker, ethBlockGasPool); err != nil || anyOverflow != overflowNone {
+		return nil, nil, nil, anyOverflow, err
+	}
+
+	receipts := make([]*types.Receipt, 0, len(transactions))
+	execResults := make([]*core.ExecutionResult, 0, len(transactions))
+	for i, transaction := range transactions {
+		receipt, execResult, _, anyOverflow, err := attemptAddTransaction(cfg, sdb, ibs, batchCounters, blockContext, header, transaction, effectiveGases[i], false, forkId, l1InfoIndex, blockDataSizeChecker, ethBlockGasPool)
+		if err != nil {
+			return nil, nil, nil, overflowNone, fmt.Errorf("%w: transaction %s: %v", errBundleDiverged, transaction.Hash(), err)
+		}
+		if anyOverflow != overflowNone || receipt.Status != types.ReceiptStatusSuccessful {
+			return nil, nil, nil, overflowNone, fmt.Errorf("%w: transaction %s", errBundleDiverged, transaction.Hash())
+		}
+		receipts = append(receipts, receipt)
+		execResults = append(execResults, execResult)
+	}
+
+	return receipts, execResults, effectiveGases, overflowNone, nil
+}
+
+// simulateBundle runs the transactions of a bundle on top of the block state, leaving it and the trackers untouched
+func simulateBundle(
+	cfg SequenceBlockCfg,
+	sdb *stageDb,
+	ibs *state.IntraBlockState,
+	batchCounters *vm.BatchCounterCollector,
+	blockContext *evmtypes.BlockContext,
+	header *types.Header,
+	transactions []types.Transaction,
+	effectiveGases []uint8,
+	forkId, l1InfoIndex uint64,
+	blockDataSizeChecker *BlockDataChecker,
+	ethBlockGasPool *core.GasPool,
+) (anyOverflow overflowType, err error) {
+	simIbs := state.New(&ibsStateReader{ibs: ibs})
+	simCounters := batchCounters.Clone()
+	simHeader := types.CopyHeader(header)
+	simDataSizeChecker := *blockDataSizeChecker
+	simGasPool := new(core.GasPool).AddGas(ethBlockGasPool.Gas())
+
+	// attemptAddTransaction stores the effective gas price percentage of every transaction it adds
+	executed := make([]common.Hash, 0, len(transactions))
+	defer func() {
+		if (err != nil || anyOverflow != overflowNone) && len(executed) > 0 {
+			if deleteErr := sdb.hermezDb.DeleteEffectiveGasPricePercentages(&executed); deleteErr != nil {
+				err = deleteErr
+			}
+		}
+	}()
+
+	var receipt *types.Receipt
+	for i, transaction := range transactions {
+		receipt, _, _, anyOverflow, err = attemptAddTransaction(cfg, sdb, simIbs, simCounters, blockContext, simHeader, transaction, effectiveGases[i], false, forkId, l1InfoIndex, &simDataSizeChecker, simGasPool)
+		if err != nil {
+			return overflowNone, fmt.Errorf("transaction %s: %w", transaction.Hash(), err)
+		}
+		if anyOverflow != overflowNone {
+			return anyOverflow, nil
+		}
+		executed = append(executed, transaction.Hash())
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return overflowNone, fmt.Errorf("%w: %s", errBundleReverted, transaction.Hash())
+		}
+	}
+
+	return overflowNone, nil
+}
+
+// ibsStateReader reads the state as left by the transactions already finalised in an intra block state
+type ibsStateReader struct {
+	ibs *state.IntraBlockState
+}
+
+func (r *ibsStateReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	if !r.ibs.Exist(address) {
+		return nil, r.ibs.Error()
+	}
+	return &accounts.Account{
+		Initialised: true,
+		Nonce:       r.ibs.GetNonce(address),
+		Balance:     *r.ibs.GetBalance(address),
+		CodeHash:    r.ibs.GetCodeHash(address),
+		Incarnation: r.ibs.GetIncarnation(address),
+	}, r.ibs.Error()
+}
+
+func (r *ibsStateReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	var value uint256.Int
+	r.ibs.GetState(address, key, &value)
+	return value.Bytes(), r.ibs.Error()
+}
+
+func (r *ibsStateReader) ReadAccountCode(address common.Address, incarnation uint64, codeHash common.Hash) ([]byte, error) {
+	return r.ibs.GetCode(address), r.ibs.Error()
+}
+
+func (r *ibsStateReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	return r.ibs.GetCodeSize(address), r.ibs.Error()
+}
+
+func (r *ibsStateReader) ReadAccountIncarnation(address common.Address) (uint64, error) {
+	return r.ibs.GetIncarnation(address), r.ibs.Error()
+}
@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/chain"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/core/state"
+)
+
+func TestIbsStateReader(t *testing.T) {
+	tx := memdb.BeginRw(t, memdb.NewTestDB(t))
+	ibs := state.New(state.NewPlainStateReader(tx))
+
+	account, contract, missing := common.Address{1}, common.Address{2}, common.Address{3}
+	key, other := common.Hash{1}, common.Hash{2}
+	code := []byte{0x60, 0x00}
+
+	ibs.SetBalance(account, uint256.NewInt(100))
+	ibs.SetNonce(account, 5)
+	ibs.CreateAccount(contract, true)
+	ibs.SetCode(contract, code)
+	ibs.SetState(contract, &key, *uint256.NewInt(42))
+	require.NoError(t, ibs.FinalizeTx(&chain.Rules{}, state.NewNoopWriter()))
+
+	// the simulated state starts from what the block state holds after its last transaction
+	simIbs := state.New(&ibsStateReader{ibs: ibs})
+	require.Equal(t, uint64(100), simIbs.GetBalance(account).Uint64())
+	require.Equal(t, uint64(5), simIbs.GetNonce(account))
+	require.Equal(t, code, simIbs.GetCode(contract))
+	require.Equal(t, ibs.GetCodeHash(contract), simIbs.GetCodeHash(contract))
+	require.Equal(t, len(code), simIbs.GetCodeSize(contract))
+	require.False(t, simIbs.Exist(missing))
+
+	var value uint256.Int
+	simIbs.GetState(contract, &key, &value)
+	require.Equal(t, uint64(42), value.Uint64())
+	simIbs.GetCommittedState(contract, &key, &value)
+	require.Equal(t, uint64(42), value.Uint64())
+	simIbs.GetState(contract, &other, &value)
+	require.True(t, value.IsZero())
+
+	// and changing it leaves the block state untouched
+	simIbs.SetBalance(account, uint256.NewInt(1))
+	simIbs.SetNonce(account, 6)
+	simIbs.SetState(contract, &key, *uint256.NewInt(7))
+	simIbs.SetBalance(missing, uint256.NewInt(3))
+	require.NoError(t, simIbs.FinalizeTx(&chain.Rules{}, state.NewNoopWriter()))
+
+	require.Equal(t, uint64(100), ibs.GetBalance(account).Uint64())
+	require.Equal(t, uint64(5), ibs.GetNonce(account))
+	ibs.GetState(contract, &key, &value)
+	require.Equal(t, uint64(42), value.Uint64())
+	require.False(t, ibs.Exist(missing))
+}
+
+func TestFailBundle(t *testing.T) {
+	cfg := &SequenceBlockCfg{dryRun: &dryRunReporter{}}
+	cfg.dryRun.startBatch(1, 12)
+	reverted, diverged := common.Hash{1}, common.Hash{2}
+
+	// a reverted bundle is dropped and the block goes on
+	require.NoError(t, failBundle(cfg, "test", reverted, 5, fmt.Errorf("%w: %s", errBundleReverted, common.Hash{3})))
+
+	// a diverged one is dropped as well, so that the retried batch does not yield it again
+	err := failBundle(cfg, "test", diverged, 5, fmt.Errorf("%w: transaction %s", errBundleDiverged, common.Hash{4}))
+	require.ErrorIs(t, err, errBundleDiverged)
+
+	rejected := cfg.dryRun.batch.Rejected
+	require.Len(t, rejected, 2)
+	require.Equal(t, reverted, rejected[0].Hash)
+	require.Equal(t, diverged, rejected[1].Hash)
+	require.Equal(t, uint64(5), rejected[1].Block)
+	require.Contains(t, rejected[1].Reason, errBundleDiverged.Error())
+}
@@ -95,6 +95,13 @@ func (sbc *SequencerBatchStreamWriter) writeBlockDetailsToDatastream(verifiedBun
 			}
 		}
 
+		// the failure goes with the batch data in the sequencer transaction, so it is in the db before any unwind it triggers
+		if response.Failure != nil {
+			if err := sbc.sdb.hermezDb.WriteVerificationFailure(response.Failure); err != nil {
+				return checkedVerifierBundles, err
+			}
+		}
+
 		checkedVerifierBundles = append(checkedVerifierBundles, bundle)
 
 		// just break early if there is an invalid response as we don't want to process the remainder anyway
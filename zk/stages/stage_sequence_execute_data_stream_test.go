@@ -0,0 +1,45 @@
+package stages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	verifier "github.com/ledgerwatch/erigon/zk/legacy_executor_verifier"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+)
+
+func TestWriteBlockDetailsRecordsVerificationFailure(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+
+	streamWriter := &SequencerBatchStreamWriter{
+		logPrefix: "test",
+		sdb:       &stageDb{tx: tx, hermezDb: hermez_db.NewHermezDb(tx)},
+	}
+	request := verifier.NewVerifierRequest(12, 5, []uint64{50, 51}, common.HexToHash("0x1"), nil)
+	failure := &zktypes.VerificationFailure{BatchNumber: 5, ForkId: 12, ExecutorUrl: "stateless", OldStateRoot: common.HexToHash("0x9")}
+	invalid := verifier.NewVerifierBundle(request, &verifier.VerifierResponse{Valid: false, Failure: failure}, true)
+	// the bundles after an invalid one are not processed
+	next := verifier.NewVerifierBundle(verifier.NewVerifierRequest(12, 6, []uint64{52}, common.HexToHash("0x2"), nil), &verifier.VerifierResponse{Valid: false, Failure: &zktypes.VerificationFailure{BatchNumber: 6}}, true)
+
+	checked, err := streamWriter.writeBlockDetailsToDatastream([]*verifier.VerifierBundle{invalid, next})
+	require.NoError(t, err)
+	require.Equal(t, []*verifier.VerifierBundle{invalid}, checked)
+
+	// the failure is committed with the sequencer transaction, ahead of the unwind the invalid batch triggers
+	require.NoError(t, tx.Commit())
+	require.NoError(t, db.View(context.Background(), func(tx kv.Tx) error {
+		failures, err := hermez_db.NewHermezDbReader(tx).GetVerificationFailures(0, 10, 10)
+		require.Len(t, failures, 1)
+		require.Equal(t, failure.BatchNumber, failures[0].BatchNumber)
+		require.Equal(t, failure.OldStateRoot, failures[0].OldStateRoot)
+		return err
+	}))
+}
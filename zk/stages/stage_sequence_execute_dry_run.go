@@ -0,0 +1,144 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/zk/metrics"
+)
+
+/*
+a dry run goes through the batch loop of the sequencer against the pool and state of a follower node, so that a change
+to the sequencer config can be rehearsed on production traffic.  The blocks are built on an in memory overlay of the
+database that is dropped at the end of the batch, nothing goes to the datastream, the executors or the pool, and each
+batch is reported instead.
+*/
+
+const sequencerDryRunStage stages.SyncStage = "SequencerDryRun"
+
+// DryRunBatch is a batch the sequencer would have built
+type DryRunBatch struct {
+	BatchNumber uint64                    `json:"batchNumber"`
+	ForkId      uint64                    `json:"forkId"`
+	StartedAt   time.Time                 `json:"startedAt"`
+	DurationMs  int64                     `json:"durationMs"`
+	CloseReason metrics.BatchFinalizeType `json:"closeReason"`
+	Blocks      []DryRunBlock             `json:"blocks"`
+	Counters    map[string]int            `json:"counters"`
+	Rejected    []DryRunRejectedTx        `json:"rejected"`
+}
+
+// DryRunBlock lists the transactions of a block in execution order
+type DryRunBlock struct {
+	Number       uint64        `json:"number"`
+	GasUsed      uint64        `json:"gasUsed"`
+	Transactions []common.Hash `json:"transactions"`
+}
+
+// DryRunRejectedTx is a transaction yielded by the pool that did not make it into the block
+type DryRunRejectedTx struct {
+	Hash   common.Hash `json:"hash"`
+	Block  uint64      `json:"block"`
+	Reason string      `json:"reason"`
+}
+
+// dryRunReporter collects the batch being built, its methods do nothing on a nil reporter
+type dryRunReporter struct {
+	out   io.Writer // one json batch per line, nil to only log them
+	batch *DryRunBatch
+}
+
+func (r *dryRunReporter) startBatch(batchNumber, forkId uint64) {
+	if r == nil {
+		return
+	}
+	r.batch = &DryRunBatch{
+		BatchNumber: batchNumber,
+		ForkId:      forkId,
+		StartedAt:   time.Now(),
+		Blocks:      []DryRunBlock{},
+		Rejected:    []DryRunRejectedTx{},
+	}
+}
+
+// onBlock records a built block along with the counters the batch used so far
+func (r *dryRunReporter) onBlock(block *types.Block, counters map[string]int) {
+	if r == nil || r.batch == nil {
+		return
+	}
+	hashes := make([]common.Hash, 0, len(block.Transactions()))
+	for _, transaction := range block.Transactions() {
+		hashes = append(hashes, transaction.Hash())
+	}
+	r.batch.Blocks = append(r.batch.Blocks, DryRunBlock{Number: block.NumberU64(), GasUsed: block.GasUsed(), Transactions: hashes})
+	r.batch.Counters = counters
+}
+
+func (r *dryRunReporter) onRejected(txHash common.Hash, blockNumber uint64, reason string) {
+	if r == nil || r.batch == nil {
+		return
+	}
+	r.batch.Rejected = append(r.batch.Rejected, DryRunRejectedTx{Hash: txHash, Block: blockNumber, Reason: reason})
+}
+
+func (r *dryRunReporter) finishBatch(logPrefix string, closeReason metrics.BatchFinalizeType) error {
+	if r == nil || r.batch == nil {
+		return nil
+	}
+	batch := r.batch
+	r.batch = nil
+	batch.CloseReason = closeReason
+	batch.DurationMs = time.Since(batch.StartedAt).Milliseconds()
+
+	txs := 0
+	for _, block := range batch.Blocks {
+		txs += len(block.Transactions)
+	}
+	log.Info(fmt.Sprintf("[%s] Dry run batch %d", logPrefix, batch.BatchNumber), "blocks", len(batch.Blocks), "txs", txs, "rejected", len(batch.Rejected), "closeReason", closeReason, "counters", batch.Counters, "taken", time.Duration(batch.DurationMs)*time.Millisecond)
+
+	if r.out == nil {
+		return nil
+	}
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	_, err = r.out.Write(append(line, '\n'))
+	return err
+}
+
+// RunSequencerDryRun builds batches one after the other on top of the head of the node until the context is done,
+// reporting them to the log and to out when set
+func RunSequencerDryRun(ctx context.Context, cfg SequenceBlockCfg, historyCfg stagedsync.HistoryCfg, out io.Writer) {
+	cfg.dryRun = &dryRunReporter{out: out}
+	// nothing built by a dry run must reach the subscribers of the node
+	cfg.accumulator = nil
+	// the batches are built from the pool, never recovered from the L1
+	zkCfg := *cfg.zk
+	zkCfg.L1SyncStartBlock = 0
+	cfg.zk = &zkCfg
+
+	sync := stagedsync.New(cfg.syncCfg, []*stagedsync.Stage{{ID: sequencerDryRunStage}}, nil, nil, log.Root())
+	s, err := sync.StageState(sequencerDryRunStage, nil, cfg.db)
+	if err != nil {
+		log.Error("[SequencerDryRun] Failed to start", "err", err)
+		return
+	}
+
+	log.Info(fmt.Sprintf("[%s] Starting sequencer dry run", s.LogPrefix()))
+	for ctx.Err() == nil {
+		if err = sequencingBatchStep(s, nil, ctx, cfg, historyCfg, nil); err != nil && ctx.Err() == nil {
+			log.Warn(fmt.Sprintf("[%s] Dry run batch failed", s.LogPrefix()), "err", err)
+			time.Sleep(10 * time.Second)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/zk/metrics"
+)
+
+func TestDryRunStageDbLeavesDbAlone(t *testing.T) {
+	db := memdb.NewTestDB(t)
+	sdb, err := newDryRunStageDb(context.Background(), db, t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, sdb.tx.Put(kv.SyncStageProgress, []byte("dry"), []byte{1}))
+	require.NoError(t, sdb.CommitAndStart())
+	value, err := sdb.tx.GetOne(kv.SyncStageProgress, []byte("dry"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, value)
+	sdb.tx.Rollback()
+	sdb.tx.Rollback()
+
+	require.NoError(t, db.View(context.Background(), func(tx kv.Tx) error {
+		value, err := tx.GetOne(kv.SyncStageProgress, []byte("dry"))
+		require.Nil(t, value)
+		return err
+	}))
+}
+
+func TestDryRunReporter(t *testing.T) {
+	// a nil reporter, when not in a dry run, does nothing
+	var none *dryRunReporter
+	none.startBatch(1, 12)
+	none.onRejected(common.Hash{1}, 1, "nonce")
+	require.NoError(t, none.finishBatch("test", metrics.BatchTimeOut))
+
+	out := &bytes.Buffer{}
+	reporter := &dryRunReporter{out: out}
+	reporter.startBatch(5, 12)
+	transaction := types.NewTransaction(0, common.Address{1}, nil, 21000, nil, nil)
+	header := &types.Header{Number: common.Big1, GasUsed: 21000}
+	reporter.onBlock(types.NewBlockWithHeader(header).WithBody([]types.Transaction{transaction}, nil), map[string]int{"gas": 10})
+	reporter.onRejected(common.Hash{2}, 1, "counters overflow")
+	require.NoError(t, reporter.finishBatch("test", metrics.BatchCounterOverflow))
+	// nothing more is reported until the next batch starts
+	reporter.onRejected(common.Hash{3}, 2, "nonce")
+	require.NoError(t, reporter.finishBatch("test", metrics.BatchTimeOut))
+
+	var batch DryRunBatch
+	require.NoError(t, json.Unmarshal(out.Bytes(), &batch))
+	require.Equal(t, uint64(5), batch.BatchNumber)
+	require.Equal(t, metrics.BatchCounterOverflow, batch.CloseReason)
+	require.Equal(t, []DryRunBlock{{Number: 1, GasUsed: 21000, Transactions: []common.Hash{transaction.Hash()}}}, batch.Blocks)
+	require.Equal(t, map[string]int{"gas": 10}, batch.Counters)
+	require.Equal(t, []DryRunRejectedTx{{Hash: common.Hash{2}, Block: 1, Reason: "counters overflow"}}, batch.Rejected)
+}
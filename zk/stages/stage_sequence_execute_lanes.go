@@ -0,0 +1,110 @@
+package stages
+
+import (
+	"github.com/ledgerwatch/erigon-lib/common"
+
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/zk/metrics"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+// laneBudgets holds back the transactions of a priority lane once it used its share of the block gas or of the batch
+// counters, leaving the space reserved to the other lanes with transactions for inclusion to them
+type laneBudgets struct {
+	lanes    *txpool.Lanes
+	gas      *txpool.LaneBudget   // of the block
+	counters []*txpool.LaneBudget // of the batch, by counter key
+	// the senders held back in the block, their next transactions would fail on the nonce
+	heldSenders map[common.Address]struct{}
+}
+
+// newLaneBudgets returns nil when no priority lane is configured
+func newLaneBudgets(lanes *txpool.Lanes, batchCounters *vm.BatchCounterCollector) *laneBudgets {
+	if lanes.Len() == 1 {
+		return nil
+	}
+
+	limits := batchCounters.NewCounters()
+	counters := make([]*txpool.LaneBudget, len(limits))
+	for k, counter := range limits {
+		if counter != nil {
+			counters[k] = lanes.CounterBudget(uint64(counter.Limit()))
+		}
+	}
+
+	return &laneBudgets{
+		lanes:    lanes,
+		counters: counters,
+	}
+}
+
+func (lb *laneBudgets) startBlock(gasLimit uint64) {
+	lb.gas = lb.lanes.GasBudget(gasLimit)
+	lb.heldSenders = make(map[common.Address]struct{})
+}
+
+// startRound tells the budgets which lanes have transactions for inclusion, the others leave what is left of their
+// share to them.  The senders held back are tried again as the space left to their lane may have changed.
+func (lb *laneBudgets) startRound(transactions []types.Transaction, signer *types.Signer, sendersToSkip map[common.Address]struct{}) {
+	pending := make([]bool, lb.lanes.Len())
+	for _, transaction := range transactions {
+		sender, ok := transaction.GetSender()
+		if !ok {
+			var err error
+			if sender, err = signer.Sender(transaction); err != nil {
+				// left for the transactions loop to discard
+				continue
+			}
+			transaction.SetSender(sender)
+		}
+		if _, skip := sendersToSkip[sender]; !skip {
+			pending[lb.lanes.Classify(sender, transaction.GetTo())] = true
+		}
+	}
+	for lane := range pending {
+		lb.gas.SetPending(lane, pending[lane])
+		for _, counter := range lb.counters {
+			if counter != nil {
+				counter.SetPending(lane, pending[lane])
+			}
+		}
+	}
+	clear(lb.heldSenders)
+}
+
+// admit returns the lane of a transaction and whether it still has room for it
+func (lb *laneBudgets) admit(sender common.Address, to *common.Address) (int, bool) {
+	lane := lb.lanes.Classify(sender, to)
+	if _, held := lb.heldSenders[sender]; held {
+		return lane, false
+	}
+
+	resource := ""
+	if !lb.gas.HasRoom(lane) {
+		resource = "gas"
+	} else {
+		for _, counter := range lb.counters {
+			if counter != nil && !counter.HasRoom(lane) {
+				resource = "counters"
+				break
+			}
+		}
+	}
+	if resource == "" {
+		return lane, true
+	}
+
+	lb.heldSenders[sender] = struct{}{}
+	metrics.SeqLaneHeldTxCount.WithLabelValues(lb.lanes.Name(lane), resource).Inc()
+	return lane, false
+}
+
+func (lb *laneBudgets) use(lane int, gas uint64, txCounters *vm.TransactionCounter) {
+	lb.gas.Use(lane, gas)
+	for k, counter := range txCounters.CombineCounters() {
+		if counter != nil && k < len(lb.counters) && lb.counters[k] != nil {
+			lb.counters[k].Use(lane, uint64(counter.Used()))
+		}
+	}
+}
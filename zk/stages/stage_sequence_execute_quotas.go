@@ -0,0 +1,57 @@
+package stages
+
+import (
+	"github.com/ledgerwatch/erigon-lib/common"
+
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/zk/metrics"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+// quotaBudgets holds back the transactions of the senders and destination contracts over their throughput quota in
+// the block or batch
+type quotaBudgets struct {
+	usage *txpool.QuotaUsage
+}
+
+// newQuotaBudgets returns nil when no quota is configured
+func newQuotaBudgets(quotas *txpool.Quotas, batchCounters *vm.BatchCounterCollector) *quotaBudgets {
+	limits := batchCounters.NewCounters()
+	counterLimits := make([]int, len(limits))
+	for k, counter := range limits {
+		if counter != nil {
+			counterLimits[k] = counter.Limit()
+		}
+	}
+
+	usage := quotas.NewUsage(counterLimits)
+	if usage == nil {
+		return nil
+	}
+	return &quotaBudgets{usage: usage}
+}
+
+func (qb *quotaBudgets) startBlock() {
+	qb.usage.StartBlock()
+}
+
+// admit tells whether the quotas of a transaction still have room for it, its gas is only known once executed.  A
+// transaction held back stays up for inclusion and is tried again in the next block.
+func (qb *quotaBudgets) admit(sender common.Address, to *common.Address) bool {
+	kind, resource, throttled := qb.usage.Throttle(sender, to, 0)
+	if throttled {
+		metrics.SeqQuotaThrottledTxCount.WithLabelValues(kind, resource).Inc()
+	}
+	return !throttled
+}
+
+func (qb *quotaBudgets) use(sender common.Address, to *common.Address, gas uint64, txCounters *vm.TransactionCounter) {
+	counters := txCounters.CombineCounters()
+	used := make([]int, len(counters))
+	for k, counter := range counters {
+		if counter != nil {
+			used[k] = counter.Used()
+		}
+	}
+	qb.usage.Use(sender, to, gas, used)
+}
@@ -303,6 +303,7 @@ type BuiltBlockElements struct {
 	effectiveGases   []uint8
 	executionResults []*core.ExecutionResult
 	txSlots          []common.Hash
+	bundles          []common.Hash
 }
 
 func (bbe *BuiltBlockElements) resetBlockBuildingArrays() {
@@ -310,6 +311,7 @@ func (bbe *BuiltBlockElements) resetBlockBuildingArrays() {
 	bbe.receipts = types.Receipts{}
 	bbe.effectiveGases = []uint8{}
 	bbe.executionResults = []*core.ExecutionResult{}
+	bbe.bundles = []common.Hash{}
 }
 
 func (bbe *BuiltBlockElements) onFinishAddingTransaction(transaction types.Transaction, receipt *types.Receipt, execResult *core.ExecutionResult, effectiveGas uint8, slotId common.Hash) {
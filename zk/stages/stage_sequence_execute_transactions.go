@@ -41,7 +41,9 @@ func getNextPoolTransactions(ctx context.Context, cfg SequenceBlockCfg, executio
 			return err
 		}
 		for _, txId := range toRemove {
-			cfg.txPool.MarkForDiscardFromPendingBest(txId)
+			if cfg.dryRun == nil {
+				cfg.txPool.MarkForDiscardFromPendingBest(txId)
+			}
 		}
 		transactions = append(transactions, yieldedTxs...)
 		ids = append(ids, yieldedIds...)
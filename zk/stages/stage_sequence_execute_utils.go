@@ -85,6 +85,9 @@ type SequenceBlockCfg struct {
 	yieldSize      uint16
 
 	infoTreeUpdater *l1infotree.Updater
+
+	// dryRun is set when building batches that are reported instead of committed
+	dryRun *dryRunReporter
 }
 
 func StageSequenceBlocksCfg(
@@ -4,10 +4,12 @@ import (
 	"context"
 
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/membatchwithdb"
 	"github.com/ledgerwatch/erigon/core/state"
 	db2 "github.com/ledgerwatch/erigon/smt/pkg/db"
 	smtNs "github.com/ledgerwatch/erigon/smt/pkg/smt"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/log/v3"
 )
 
 type stageDb struct {
@@ -19,6 +21,9 @@ type stageDb struct {
 	eridb       *db2.EriDb
 	stateReader *state.PlainStateReader
 	smt         *smtNs.SMT
+
+	// dryRun means tx is an in memory overlay on a read only transaction that is never committed
+	dryRun bool
 }
 
 func newStageDb(ctx context.Context, db kv.RwDB) (sdb *stageDb, err error) {
@@ -35,6 +40,22 @@ func newStageDb(ctx context.Context, db kv.RwDB) (sdb *stageDb, err error) {
 	return sdb, nil
 }
 
+// newDryRunStageDb returns a stage db whose writes stay in memory and are dropped on rollback
+func newDryRunStageDb(ctx context.Context, db kv.RwDB, tmpDir string) (sdb *stageDb, err error) {
+	var roTx kv.Tx
+	if roTx, err = db.BeginRo(ctx); err != nil {
+		return nil, err
+	}
+
+	sdb = &stageDb{
+		ctx:    ctx,
+		db:     db,
+		dryRun: true,
+	}
+	sdb.SetTx(&dryRunTx{MemoryMutation: membatchwithdb.NewMemoryBatch(roTx, tmpDir, log.Root()), roTx: roTx})
+	return sdb, nil
+}
+
 func (sdb *stageDb) SetTx(tx kv.RwTx) {
 	sdb.tx = tx
 	sdb.hermezDb = hermez_db.NewHermezDb(tx)
@@ -44,6 +65,10 @@ func (sdb *stageDb) SetTx(tx kv.RwTx) {
 }
 
 func (sdb *stageDb) CommitAndStart() (err error) {
+	if sdb.dryRun {
+		return nil
+	}
+
 	if err = sdb.tx.Commit(); err != nil {
 		return err
 	}
@@ -56,3 +81,19 @@ func (sdb *stageDb) CommitAndStart() (err error) {
 	sdb.SetTx(tx)
 	return nil
 }
+
+// dryRunTx releases the read only transaction below the overlay with it, the stage rolls back its tx several times
+type dryRunTx struct {
+	*membatchwithdb.MemoryMutation
+	roTx       kv.Tx
+	rolledBack bool
+}
+
+func (tx *dryRunTx) Rollback() {
+	if tx.rolledBack {
+		return
+	}
+	tx.rolledBack = true
+	tx.MemoryMutation.Rollback()
+	tx.roTx.Rollback()
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/zk/da"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/l1_data"
 	"github.com/ledgerwatch/erigon/zk/syncer"
@@ -20,16 +21,24 @@ import (
 )
 
 type SequencerL1BlockSyncCfg struct {
-	db     kv.RwDB
-	zkCfg  *ethconfig.Zk
-	syncer *syncer.L1Syncer
+	db        kv.RwDB
+	zkCfg     *ethconfig.Zk
+	syncer    *syncer.L1Syncer
+	daBackend da.DABackend
 }
 
 func StageSequencerL1BlockSyncCfg(db kv.RwDB, zkCfg *ethconfig.Zk, syncer *syncer.L1Syncer) SequencerL1BlockSyncCfg {
+	// the config is validated when the flags are parsed
+	daBackend, err := da.NewBackend(zkCfg.DAConfig())
+	if err != nil {
+		log.Error("Failed to create the data availability backend", "err", err)
+	}
+
 	return SequencerL1BlockSyncCfg{
-		db:     db,
-		zkCfg:  zkCfg,
-		syncer: syncer,
+		db:        db,
+		zkCfg:     zkCfg,
+		syncer:    syncer,
+		daBackend: daBackend,
 	}
 }
 
@@ -166,7 +175,7 @@ LOOP:
 					return funcErr
 				}
 
-				batches, coinbase, limitTimestamp, err := l1_data.DecodeL1BatchData(transaction.GetData(), cfg.zkCfg.DAUrl)
+				batches, coinbase, limitTimestamp, err := l1_data.DecodeL1BatchData(transaction.GetData(), cfg.daBackend)
 				if err != nil {
 					funcErr = err
 					return funcErr
@@ -0,0 +1,299 @@
+package stateless
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon-lib/chain"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/core/vm/evmtypes"
+	"github.com/ledgerwatch/erigon/smt/pkg/blockinfo"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	zktx "github.com/ledgerwatch/erigon/zk/tx"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/erigon/zk/utils"
+)
+
+// the zkevm block gas limit is infinite so, as the sequencer does, a gas pool is created per transaction
+const transactionGasLimit = 30000000
+
+var (
+	ErrStateRootMismatch = errors.New("stateless state root mismatches")
+	ErrCountersOverflow  = errors.New("stateless counters overflow")
+	ErrUnsupportedFork   = errors.New("stateless verification is not supported for the fork")
+)
+
+// Config holds what the batches are re-executed with
+type Config struct {
+	ChainConfig       *chain.Config
+	SmtReduction      float64 // the virtual counters smt reduction
+	SmtDepth          int     // the smt depth the counters are estimated with, the one of the witness tree when 0
+	UnlimitedCounters bool
+}
+
+// Block is an L2 block of a batch along with the L1 info tree data it uses
+type Block struct {
+	DeltaTimestamp               uint32
+	L1InfoTreeIndex              uint32
+	GlobalExitRoot               common.Hash
+	L1BlockHash                  common.Hash
+	Transactions                 []types.Transaction
+	EffectiveGasPricePercentages []uint8
+}
+
+// Batch is what a batch is verified from: the witness of the state it starts from and its blocks
+type Batch struct {
+	Number   uint64
+	ForkId   uint64
+	Coinbase common.Address
+	Witness  []byte
+	Blocks   []Block
+}
+
+// Result is the outcome of the re-execution of a batch
+type Result struct {
+	OldStateRoot common.Hash
+	NewStateRoot common.Hash
+	BlockRoots   []common.Hash
+	Receipts     []types.Receipts // of each block
+	Counters     map[string]int
+	Overflow     bool
+}
+
+// L1InfoTreeReader returns the l1 info tree update of an index
+type L1InfoTreeReader func(index uint64) (*zktypes.L1InfoTreeUpdate, error)
+
+// DecodeBatch builds a batch from its L2 data, the global exit root and l1 block hash of the blocks using an l1 info
+// tree index are read through l1InfoTree
+func DecodeBatch(number, forkId uint64, coinbase common.Address, witness, batchL2Data []byte, l1InfoTree L1InfoTreeReader) (*Batch, error) {
+	decoded, err := zktx.DecodeBatchL2Blocks(batchL2Data, forkId)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeBatchL2Blocks: %w", err)
+	}
+
+	batch := &Batch{
+		Number:   number,
+		ForkId:   forkId,
+		Coinbase: coinbase,
+		Witness:  witness,
+		Blocks:   make([]Block, 0, len(decoded)),
+	}
+	for _, d := range decoded {
+		block := Block{
+			DeltaTimestamp:               d.DeltaTimestamp,
+			L1InfoTreeIndex:              d.L1InfoTreeIndex,
+			Transactions:                 d.Transactions,
+			EffectiveGasPricePercentages: d.EffectiveGasPricePercentages,
+		}
+		if d.L1InfoTreeIndex > 0 {
+			update, err := l1InfoTree(uint64(d.L1InfoTreeIndex))
+			if err != nil {
+				return nil, fmt.Errorf("l1 info tree index %d: %w", d.L1InfoTreeIndex, err)
+			}
+			if update == nil {
+				return nil, fmt.Errorf("l1 info tree index %d not found", d.L1InfoTreeIndex)
+			}
+			block.GlobalExitRoot, block.L1BlockHash = update.GER, update.ParentHash
+		}
+		batch.Blocks = append(batch.Blocks, block)
+	}
+
+	return batch, nil
+}
+
+// Verify re-executes the batch and checks that it ends on the expected state root without overflowing the counters
+func Verify(ctx context.Context, cfg Config, batch *Batch, expectedRoot common.Hash) (*Result, error) {
+	result, err := Execute(ctx, cfg, batch)
+	if err != nil {
+		return nil, err
+	}
+	if result.Overflow {
+		return result, fmt.Errorf("%w: %v", ErrCountersOverflow, result.Counters)
+	}
+	if result.NewStateRoot != expectedRoot {
+		return result, fmt.Errorf("%w: expected %s, got %s", ErrStateRootMismatch, expectedRoot, result.NewStateRoot)
+	}
+	return result, nil
+}
+
+// Execute builds the SMT of the batch witness in memory and re-executes the blocks of the batch on it, the way the
+// sequencer built them, collecting the zk counters. The number and timestamp of the blocks carry on from the ones of
+// the system contract in the witness.
+func Execute(ctx context.Context, cfg Config, batch *Batch) (*Result, error) {
+	if batch.ForkId < uint64(chain.ForkID7Etrog) {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedFork, batch.ForkId)
+	}
+
+	w, err := trie.NewWitnessFromReader(bytes.NewReader(batch.Witness), false)
+	if err != nil {
+		return nil, fmt.Errorf("parse witness: %w", err)
+	}
+	s, err := smt.BuildSMTFromWitness(w)
+	if err != nil {
+		return nil, fmt.Errorf("BuildSMTFromWitness: %w", err)
+	}
+
+	smtDepth := cfg.SmtDepth
+	if smtDepth == 0 {
+		smtDepth = s.GetDepth()
+	}
+	batchCounters := vm.NewBatchCounterCollector(smtDepth, uint16(batch.ForkId), cfg.SmtReduction, cfg.UnlimitedCounters, nil)
+
+	root := common.BigToHash(s.LastRoot())
+	result := &Result{
+		OldStateRoot: root,
+		BlockRoots:   make([]common.Hash, 0, len(batch.Blocks)),
+		Receipts:     make([]types.Receipts, 0, len(batch.Blocks)),
+	}
+
+	pre := state.New(s)
+	blockNumber := pre.GetBlockNumber().Uint64()
+	timestamp := pre.ScalableGetTimestamp()
+
+	var verifyMerkleProof bool
+	for _, block := range batch.Blocks {
+		blockNumber++
+		timestamp += uint64(block.DeltaTimestamp)
+		verifyMerkleProof = block.L1InfoTreeIndex != 0
+
+		if cfg.ChainConfig.IsNormalcy(blockNumber) {
+			return nil, fmt.Errorf("block %d: stateless verification is not supported in normalcy", blockNumber)
+		}
+
+		overflow, err := batchCounters.StartNewBlock(verifyMerkleProof)
+		if err != nil {
+			return nil, err
+		}
+		result.Overflow = result.Overflow || overflow
+
+		var receipts types.Receipts
+		if root, receipts, overflow, err = executeBlock(ctx, cfg, s, batchCounters, smtDepth, batch, &block, blockNumber, timestamp, root); err != nil {
+			return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+		result.Overflow = result.Overflow || overflow
+		result.BlockRoots = append(result.BlockRoots, root)
+		result.Receipts = append(result.Receipts, receipts)
+	}
+
+	counters, err := batchCounters.CombineCollectors(verifyMerkleProof)
+	if err != nil {
+		return nil, err
+	}
+	result.NewStateRoot = root
+	result.Counters = counters.UsedAsMap()
+
+	return result, nil
+}
+
+// executeBlock executes a block on the SMT and returns the new state root along with the receipts of the block
+func executeBlock(
+	ctx context.Context,
+	cfg Config,
+	s *smt.SMT,
+	batchCounters *vm.BatchCounterCollector,
+	smtDepth int,
+	batch *Batch,
+	block *Block,
+	blockNumber, timestamp uint64,
+	prevRoot common.Hash,
+) (common.Hash, types.Receipts, bool, error) {
+	chainConfig := cfg.ChainConfig
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(blockNumber),
+		Time:       timestamp,
+		Coinbase:   batch.Coinbase,
+		GasLimit:   utils.GetBlockGasLimitForFork(batch.ForkId),
+		Difficulty: common.Big0,
+	}
+
+	ibs := state.New(s)
+	ibs.PreExecuteStateSet(chainConfig, blockNumber, timestamp, &prevRoot)
+
+	var ger, l1BlockHash common.Hash
+	if block.L1InfoTreeIndex > 0 {
+		ger, l1BlockHash = block.GlobalExitRoot, block.L1BlockHash
+		// a reused global exit root is already in the contract
+		if ibs.ReadGerManagerL1BlockHash(ger) == (common.Hash{}) {
+			ibs.WriteGerManagerL1BlockHash(ger, l1BlockHash)
+		}
+	}
+
+	// blockhash reads the state roots of the system contract in the zkevm
+	getHashFn := func(uint64) common.Hash { return common.Hash{} }
+	blockContext := core.NewEVMBlockContext(header, getHashFn, nil, &batch.Coinbase)
+	signer := types.MakeSigner(chainConfig, blockNumber, timestamp)
+	noop := state.NewNoopWriter()
+
+	var anyOverflow bool
+	txInfos := make([]blockinfo.ExecutedTxInfo, 0, len(block.Transactions))
+	receipts := make(types.Receipts, 0, len(block.Transactions))
+	for i, transaction := range block.Transactions {
+		effectiveGasPrice := uint8(zktypes.EFFECTIVE_GAS_PRICE_PERCENTAGE_MAXIMUM)
+		if i < len(block.EffectiveGasPricePercentages) {
+			effectiveGasPrice = block.EffectiveGasPricePercentages[i]
+		}
+
+		txCounters := vm.NewTransactionCounter(transaction, smtDepth, uint16(batch.ForkId), cfg.SmtReduction, cfg.UnlimitedCounters)
+		overflow, err := batchCounters.AddNewTransactionCounters(txCounters)
+		if err != nil {
+			return common.Hash{}, nil, false, err
+		}
+		anyOverflow = anyOverflow || overflow
+
+		ibs.Init(transaction.Hash(), common.Hash{}, 0)
+		evm := vm.NewZkEVM(blockContext, evmtypes.TxContext{}, ibs, chainConfig, vm.ZkConfig{CounterCollector: txCounters.ExecutionCounters()})
+		gasPool := new(core.GasPool).AddGas(transactionGasLimit)
+
+		receipt, execResult, _, err := core.ApplyTransaction_zkevm(chainConfig, nil, evm, gasPool, ibs, noop, header, transaction, &header.GasUsed, effectiveGasPrice, false)
+		if err != nil {
+			return common.Hash{}, nil, false, fmt.Errorf("tx %s: %w", transaction.Hash(), err)
+		}
+		if err = txCounters.ProcessTx(ibs, execResult.ReturnData); err != nil {
+			return common.Hash{}, nil, false, err
+		}
+		batchCounters.UpdateExecutionAndProcessingCountersCache(txCounters)
+		if overflow, err = batchCounters.CheckForOverflow(block.L1InfoTreeIndex != 0); err != nil {
+			return common.Hash{}, nil, false, err
+		}
+		anyOverflow = anyOverflow || overflow
+		ibs.FinalizeTx(evm.ChainRules(), noop)
+		receipts = append(receipts, receipt)
+
+		from, err := transaction.Sender(*signer)
+		if err != nil {
+			return common.Hash{}, nil, false, err
+		}
+		txInfos = append(txInfos, blockinfo.ExecutedTxInfo{
+			Tx:                transaction,
+			EffectiveGasPrice: effectiveGasPrice,
+			Receipt:           core.CreateReceiptForBlockInfoTree(receipt, chainConfig, blockNumber, execResult),
+			Signer:            &from,
+		})
+	}
+
+	blockInfoRoot, err := blockinfo.BuildBlockInfoTree(&header.Coinbase, blockNumber, header.Time, header.GasLimit, header.GasUsed, ger, l1BlockHash, prevRoot, &txInfos)
+	if err != nil {
+		return common.Hash{}, nil, false, err
+	}
+	ibs.PostExecuteStateSet(chainConfig, blockNumber, blockInfoRoot)
+
+	// zkevm blocks carry neither rewards nor withdrawals, there is nothing for the engine to finalize
+	writer := newSmtWriter()
+	if err = ibs.CommitBlock(chainConfig.Rules(blockNumber, timestamp), writer); err != nil {
+		return common.Hash{}, nil, false, fmt.Errorf("CommitBlock: %w", err)
+	}
+	root, err := writer.apply(ctx, s)
+	if err != nil {
+		return common.Hash{}, nil, false, err
+	}
+
+	return root, receipts, anyOverflow, nil
+}
@@ -0,0 +1,135 @@
+package stateless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/erigon/zk/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// vector is the part of the executor test vectors in zk/tests/testdata a batch is verified from
+type vector struct {
+	Genesis []struct {
+		Address  string                      `json:"address"`
+		Nonce    string                      `json:"nonce"`
+		Balance  string                      `json:"balance"`
+		ByteCode string                      `json:"bytecode"`
+		Storage  map[common.Hash]common.Hash `json:"storage,omitempty"`
+	} `json:"genesis"`
+	Txs []struct {
+		Type            int    `json:"type"`
+		IndexL1InfoTree uint64 `json:"indexL1InfoTree"`
+		Reason          string `json:"reason"`
+		L1Info          *struct {
+			GlobalExitRoot common.Hash `json:"globalExitRoot"`
+			BlockHash      common.Hash `json:"blockHash"`
+		} `json:"l1Info"`
+	} `json:"txs"`
+	BatchL2Data      hexutil.Bytes `json:"batchL2Data"`
+	SequencerAddress string        `json:"sequencerAddress"`
+	ChainId          int64         `json:"chainID"`
+	ForkId           uint64        `json:"forkID"`
+	ExpectedOldRoot  common.Hash   `json:"expectedOldRoot"`
+	ExpectedNewRoot  common.Hash   `json:"expectedNewRoot"`
+}
+
+func TestVerifyVectors(t *testing.T) {
+	files, err := filepath.Glob("../tests/testdata/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var vectors []vector
+		require.NoError(t, json.Unmarshal(contents, &vectors))
+
+		for i, v := range vectors {
+			t.Run(filepath.Base(file)+"/"+strconv.Itoa(i), func(t *testing.T) {
+				verifyVector(t, v)
+			})
+		}
+	}
+}
+
+func verifyVector(t *testing.T, v vector) {
+	l1InfoTree := make(map[uint64]*zktypes.L1InfoTreeUpdate)
+	for _, tx := range v.Txs {
+		// the executor drops the invalid transactions of a batch, the ones of the sequencer never have any
+		if tx.Reason != "" {
+			t.Skip("invalid transaction:", tx.Reason)
+		}
+		if tx.L1Info != nil && tx.IndexL1InfoTree != 0 {
+			l1InfoTree[tx.IndexL1InfoTree] = &zktypes.L1InfoTreeUpdate{GER: tx.L1Info.GlobalExitRoot, ParentHash: tx.L1Info.BlockHash}
+		}
+	}
+
+	accChanges := make(map[common.Address]*accounts.Account)
+	codeChanges := make(map[common.Address]string)
+	storageChanges := make(map[common.Address]map[string]string)
+	genesis := smt.NewSMT(nil, false)
+	for _, g := range v.Genesis {
+		addr := common.HexToAddress(g.Address)
+		nonce, err := strconv.ParseUint(g.Nonce, 10, 64)
+		require.NoError(t, err)
+		balance, ok := new(big.Int).SetString(g.Balance, 10)
+		require.True(t, ok)
+		acc := accounts.NewAccount()
+		acc.Nonce = nonce
+		acc.Balance.SetFromBig(balance)
+		accChanges[addr] = &acc
+		if code := common.FromHex(g.ByteCode); len(code) > 0 {
+			codeChanges[addr] = g.ByteCode
+			require.NoError(t, genesis.Db.AddCode(code))
+		}
+		for k, v := range g.Storage {
+			if storageChanges[addr] == nil {
+				storageChanges[addr] = make(map[string]string)
+			}
+			storageChanges[addr][fmt.Sprintf("0x%032x", k)] = fmt.Sprintf("0x%032x", v)
+		}
+	}
+	_, _, err := genesis.SetStorage(context.Background(), "test", accChanges, codeChanges, storageChanges)
+	require.NoError(t, err)
+	require.Equal(t, v.ExpectedOldRoot, common.BigToHash(genesis.LastRoot()))
+
+	w, err := genesis.BuildWitness(&trie.AlwaysTrueRetainDecider{}, context.Background())
+	require.NoError(t, err)
+	var witness bytes.Buffer
+	_, err = w.WriteInto(&witness, false)
+	require.NoError(t, err)
+
+	chainConfig := params.ChainConfigByChainName("hermez-dev")
+	chainConfig.ChainID = big.NewInt(v.ChainId)
+	require.NoError(t, utils.RecoverySetBlockConfigForks(0, v.ForkId, chainConfig, "test"))
+
+	batch, err := DecodeBatch(1, v.ForkId, common.HexToAddress(v.SequencerAddress), witness.Bytes(), v.BatchL2Data, func(index uint64) (*zktypes.L1InfoTreeUpdate, error) {
+		return l1InfoTree[index], nil
+	})
+	require.NoError(t, err)
+
+	result, err := Verify(context.Background(), Config{ChainConfig: chainConfig, SmtReduction: 0.6}, batch, v.ExpectedNewRoot)
+	require.NoError(t, err)
+	require.Equal(t, v.ExpectedOldRoot, result.OldStateRoot)
+	require.Len(t, result.BlockRoots, len(batch.Blocks))
+	require.Len(t, result.Receipts, len(batch.Blocks))
+	for i, block := range batch.Blocks {
+		require.Len(t, result.Receipts[i], len(block.Transactions))
+	}
+	require.NotZero(t, result.Counters["S"])
+}
@@ -0,0 +1,77 @@
+package stateless
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/status-im/keycard-go/hexutils"
+)
+
+var _ state.StateWriter = (*smtWriter)(nil)
+
+// smtWriter collects the changes of a block committed by the intra block state in the shape the SMT inserts them
+type smtWriter struct {
+	accChanges     map[common.Address]*accounts.Account
+	codeChanges    map[common.Address]string
+	storageChanges map[common.Address]map[string]string
+	codes          [][]byte
+}
+
+func newSmtWriter() *smtWriter {
+	return &smtWriter{
+		accChanges:     make(map[common.Address]*accounts.Account),
+		codeChanges:    make(map[common.Address]string),
+		storageChanges: make(map[common.Address]map[string]string),
+	}
+}
+
+func (w *smtWriter) UpdateAccountData(address common.Address, original, account *accounts.Account) error {
+	acc := new(accounts.Account)
+	acc.Copy(account)
+	w.accChanges[address] = acc
+	return nil
+}
+
+func (w *smtWriter) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	if len(code) == 0 {
+		return nil
+	}
+	w.codeChanges[address] = "0x" + hexutils.BytesToHex(code)
+	w.codes = append(w.codes, code)
+	return nil
+}
+
+func (w *smtWriter) DeleteAccount(address common.Address, original *accounts.Account) error {
+	w.accChanges[address] = nil
+	return nil
+}
+
+func (w *smtWriter) WriteAccountStorage(address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	if w.storageChanges[address] == nil {
+		w.storageChanges[address] = make(map[string]string)
+	}
+	w.storageChanges[address][fmt.Sprintf("0x%032x", *key)] = fmt.Sprintf("0x%032x", common.Hash(value.Bytes32()))
+	return nil
+}
+
+func (w *smtWriter) CreateContract(address common.Address) error {
+	return nil
+}
+
+// apply inserts the changes into the SMT, keeping the new code around for the following blocks, and returns its root
+func (w *smtWriter) apply(ctx context.Context, s *smt.SMT) (common.Hash, error) {
+	for _, code := range w.codes {
+		if err := s.Db.AddCode(code); err != nil {
+			return common.Hash{}, fmt.Errorf("AddCode: %w", err)
+		}
+	}
+	if _, _, err := s.SetStorage(ctx, "stateless", w.accChanges, w.codeChanges, w.storageChanges); err != nil {
+		return common.Hash{}, fmt.Errorf("SetStorage: %w", err)
+	}
+	return common.BigToHash(s.LastRoot()), nil
+}
@@ -0,0 +1,247 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	ethereum "github.com/ledgerwatch/erigon"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// an endpoint starts with the max score, every answer moves it up by the reward and every error down by the
+	// penalty. Falling below the min score excludes it, it's re-admitted with the min score once its backoff passes
+	maxEndpointScore       = 100
+	minEndpointScore       = 50
+	endpointSuccessReward  = 5
+	endpointFailurePenalty = 25
+
+	// the backoff of an excluded endpoint doubles on every exclusion in a row, capped at 2^maxExclusionShift times
+	// the base backoff
+	maxExclusionShift = 5
+)
+
+var errCrossCheckMismatch = errors.New("L1 endpoints disagree")
+
+// L1EndpointsConfig tunes how the L1 endpoints are scored
+type L1EndpointsConfig struct {
+	MaxLag           uint64        // an endpoint whose head is more blocks behind the best one is excluded, 0 disables the check
+	ExclusionBackoff time.Duration // base time an excluded endpoint is skipped for, doubled on every exclusion in a row
+}
+
+func DefaultL1EndpointsConfig() L1EndpointsConfig {
+	return L1EndpointsConfig{
+		ExclusionBackoff: 30 * time.Second,
+	}
+}
+
+// L1Endpoints spreads the L1 requests of the syncers sharing it over the endpoints, scoring them on their errors
+// and on how far their head lags behind the others so a misbehaving one is excluded for a while
+type L1Endpoints struct {
+	cfg       L1EndpointsConfig
+	endpoints []*l1Endpoint
+
+	mtx   sync.Mutex
+	index int
+
+	crossCheckMismatches metrics.Counter
+}
+
+func NewL1Endpoints(etherMans []IEtherman, cfg L1EndpointsConfig) *L1Endpoints {
+	endpoints := make([]*l1Endpoint, len(etherMans))
+	for i, em := range etherMans {
+		endpoints[i] = newL1Endpoint(i, em, cfg.ExclusionBackoff)
+	}
+	return &L1Endpoints{
+		cfg:                  cfg,
+		endpoints:            endpoints,
+		crossCheckMismatches: metrics.GetOrCreateCounter("l1_endpoint_cross_check_mismatches_total"),
+	}
+}
+
+// next returns the next healthy endpoint, round robin, whose head reached minHead and which isn't the one to skip.
+// When no healthy one qualifies an excluded one is used rather than none, the syncer never stalls, but never one
+// known to be behind minHead when another one reached it.
+func (e *L1Endpoints) next(minHead uint64, skip *l1Endpoint) *l1Endpoint {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	now := time.Now()
+	var reached, other *l1Endpoint
+	for i := 0; i < len(e.endpoints); i++ {
+		endpoint := e.endpoints[(e.index+i)%len(e.endpoints)]
+		if endpoint == skip {
+			continue
+		}
+		healthy, head := endpoint.state(now)
+		if head < minHead {
+			if other == nil {
+				other = endpoint
+			}
+			continue
+		}
+		if healthy {
+			e.index = (e.index + i + 1) % len(e.endpoints)
+			return endpoint
+		}
+		if reached == nil {
+			reached = endpoint
+		}
+	}
+	e.index = (e.index + 1) % len(e.endpoints)
+	if reached != nil {
+		return reached
+	}
+	return other
+}
+
+// healthy returns the endpoints not excluded, all of them when they all are
+func (e *L1Endpoints) healthy() []*l1Endpoint {
+	now := time.Now()
+	healthy := make([]*l1Endpoint, 0, len(e.endpoints))
+	for _, endpoint := range e.endpoints {
+		if ok, _ := endpoint.state(now); ok {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if len(healthy) == 0 {
+		return e.endpoints
+	}
+	return healthy
+}
+
+// updateHeads records the heads the endpoints answered with and excludes the ones lagging too far behind the best
+// head of the healthy ones, which it returns
+func (e *L1Endpoints) updateHeads(heads map[*l1Endpoint]uint64) (uint64, bool) {
+	now := time.Now()
+	var best uint64
+	var found bool
+	for endpoint, head := range heads {
+		endpoint.recordHead(head)
+		if ok, _ := endpoint.state(now); ok && head >= best {
+			best, found = head, true
+		}
+	}
+	// when every endpoint answering is excluded their best head is still the best known
+	if !found {
+		for _, head := range heads {
+			best, found = max(best, head), true
+		}
+	}
+
+	if e.cfg.MaxLag > 0 {
+		for endpoint, head := range heads {
+			if head+e.cfg.MaxLag < best {
+				endpoint.exclude(fmt.Sprintf("head %d lags behind %d", head, best))
+			}
+		}
+	}
+	return best, found
+}
+
+// l1Endpoint is an L1 client with its health
+type l1Endpoint struct {
+	IEtherman
+	id      int
+	backoff time.Duration
+
+	mtx           sync.Mutex
+	score         int
+	head          uint64
+	exclusions    int // in a row, reset once the score is back to the max
+	excluded      bool
+	excludedUntil time.Time
+
+	scoreGauge   metrics.Gauge
+	headGauge    metrics.Gauge
+	healthyGauge metrics.Gauge
+	failures     metrics.Counter
+}
+
+func newL1Endpoint(id int, em IEtherman, backoff time.Duration) *l1Endpoint {
+	endpoint := &l1Endpoint{
+		IEtherman:    em,
+		id:           id,
+		backoff:      backoff,
+		score:        maxEndpointScore,
+		scoreGauge:   metrics.GetOrCreateGauge(fmt.Sprintf(`l1_endpoint_score{endpoint="%d"}`, id)),
+		headGauge:    metrics.GetOrCreateGauge(fmt.Sprintf(`l1_endpoint_head{endpoint="%d"}`, id)),
+		healthyGauge: metrics.GetOrCreateGauge(fmt.Sprintf(`l1_endpoint_healthy{endpoint="%d"}`, id)),
+		failures:     metrics.GetOrCreateCounter(fmt.Sprintf(`l1_endpoint_failures_total{endpoint="%d"}`, id)),
+	}
+	endpoint.scoreGauge.SetInt(endpoint.score)
+	endpoint.healthyGauge.SetInt(1)
+	return endpoint
+}
+
+// state returns whether the endpoint can be used and the last head it answered with, re-admitting it once its
+// backoff passed
+func (p *l1Endpoint) state(now time.Time) (bool, uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.excluded && !now.Before(p.excludedUntil) {
+		p.excluded = false
+		p.score = minEndpointScore
+		p.scoreGauge.SetInt(p.score)
+		p.healthyGauge.SetInt(1)
+		log.Info("L1 endpoint re-admitted", "endpoint", p.id, "score", p.score)
+	}
+	return !p.excluded, p.head
+}
+
+// record scores an answer of the endpoint, a cancelled request or a missing item not being its fault
+func (p *l1Endpoint) record(err error) {
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, ethereum.NotFound)) {
+		return
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if err == nil {
+		p.score = min(p.score+endpointSuccessReward, maxEndpointScore)
+		if p.score == maxEndpointScore {
+			p.exclusions = 0
+		}
+		p.scoreGauge.SetInt(p.score)
+		return
+	}
+
+	p.failures.Inc()
+	p.score = max(p.score-endpointFailurePenalty, 0)
+	p.scoreGauge.SetInt(p.score)
+	if p.score < minEndpointScore && !p.excluded {
+		p.excludeLocked(err.Error())
+	}
+}
+
+func (p *l1Endpoint) recordHead(head uint64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.head = head
+	p.headGauge.SetUint64(head)
+}
+
+func (p *l1Endpoint) exclude(reason string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if !p.excluded {
+		p.excludeLocked(reason)
+	}
+}
+
+// excludeLocked must be called with the mutex held
+func (p *l1Endpoint) excludeLocked(reason string) {
+	p.exclusions++
+	wait := p.backoff << min(p.exclusions-1, maxExclusionShift)
+	p.excluded = true
+	p.excludedUntil = time.Now().Add(wait)
+	p.healthyGauge.SetInt(0)
+
+	log.Warn("L1 endpoint excluded", "endpoint", p.id, "reason", reason, "score", p.score, "exclusions", p.exclusions, "backoff", wait)
+}
@@ -0,0 +1,198 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ledgerwatch/erigon"
+	"github.com/ledgerwatch/erigon-lib/common"
+	ethTypes "github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// testEtherman is an L1 endpoint with a head, the logs up to it and the hashes of its blocks from forkFrom on set by
+// fork
+type testEtherman struct {
+	mtx      sync.Mutex
+	head     uint64
+	fork     byte
+	forkFrom uint64
+	final    uint64 // the finalized block, the head when not set
+	logs     []ethTypes.Log
+	err      error
+	filterTo uint64 // the highest block logs were asked up to
+}
+
+func (e *testEtherman) header(number uint64) *ethTypes.Header {
+	fork := e.fork
+	if number < e.forkFrom {
+		fork = 0
+	}
+	return &ethTypes.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{fork}}
+}
+
+func (e *testEtherman) HeaderByNumber(ctx context.Context, blockNumber *big.Int) (*ethTypes.Header, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.header(blockNumber.Uint64()), nil
+}
+
+func (e *testEtherman) BlockByNumber(ctx context.Context, blockNumber *big.Int) (*ethTypes.Block, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	number := e.head
+	if blockNumber != nil && blockNumber.Sign() >= 0 {
+		number = blockNumber.Uint64()
+	} else if blockNumber != nil && blockNumber.Int64() == rpc.FinalizedBlockNumber.Int64() && e.final > 0 {
+		number = e.final
+	}
+	return ethTypes.NewBlockWithHeader(e.header(number)), nil
+}
+
+func (e *testEtherman) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]ethTypes.Log, error) {
+	e.mtx.Lock()
+	e.filterTo = max(e.filterTo, query.ToBlock.Uint64())
+	e.mtx.Unlock()
+	if e.err != nil {
+		return nil, e.err
+	}
+	var logs []ethTypes.Log
+	for _, l := range e.logs {
+		// a lagging endpoint doesn't know the logs past its head, without an error
+		if l.BlockNumber >= query.FromBlock.Uint64() && l.BlockNumber <= query.ToBlock.Uint64() && l.BlockNumber <= e.head {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func (e *testEtherman) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, e.err
+}
+
+func (e *testEtherman) TransactionByHash(ctx context.Context, hash common.Hash) (ethTypes.Transaction, bool, error) {
+	return nil, false, e.err
+}
+
+func (e *testEtherman) TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethTypes.Receipt, error) {
+	return nil, e.err
+}
+
+func (e *testEtherman) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return nil, e.err
+}
+
+func TestL1EndpointsHealth(t *testing.T) {
+	a, b, c := &testEtherman{}, &testEtherman{}, &testEtherman{}
+	endpoints := NewL1Endpoints([]IEtherman{a, b, c}, L1EndpointsConfig{MaxLag: 5, ExclusionBackoff: 50 * time.Millisecond})
+	failing := endpoints.endpoints[2]
+
+	// three errors in a row take the score below the min
+	for i := 0; i < 3; i++ {
+		failing.record(errors.New("boom"))
+	}
+	for i := 0; i < 10; i++ {
+		require.NotSame(t, c, endpoints.next(0, nil).IEtherman)
+	}
+	require.Len(t, endpoints.healthy(), 2)
+
+	// not its fault
+	endpoints.endpoints[0].record(context.Canceled)
+	endpoints.endpoints[0].record(ethereum.NotFound)
+	require.Equal(t, maxEndpointScore, endpoints.endpoints[0].score)
+
+	// re-admitted once the backoff passed, on probation: the next error excludes it for twice as long
+	time.Sleep(60 * time.Millisecond)
+	require.Len(t, endpoints.healthy(), 3)
+	require.Equal(t, minEndpointScore, failing.score)
+	failing.record(errors.New("boom"))
+	require.Equal(t, 2, failing.exclusions)
+	require.WithinDuration(t, time.Now().Add(100*time.Millisecond), failing.excludedUntil, 50*time.Millisecond)
+
+	// lagging behind the best head
+	time.Sleep(120 * time.Millisecond)
+	best, ok := endpoints.updateHeads(map[*l1Endpoint]uint64{endpoints.endpoints[0]: 100, endpoints.endpoints[1]: 97, failing: 90})
+	require.True(t, ok)
+	require.Equal(t, uint64(100), best)
+	require.Len(t, endpoints.healthy(), 2)
+
+	// the logs up to block 99 can't come from an endpoint which didn't reach it, an excluded one is used rather
+	// than none
+	for i := 0; i < 10; i++ {
+		require.Same(t, a, endpoints.next(99, nil).IEtherman)
+		require.NotSame(t, c, endpoints.next(0, nil).IEtherman)
+	}
+	endpoints.endpoints[0].exclude("test")
+	require.Same(t, a, endpoints.next(99, nil).IEtherman)
+	require.NotSame(t, a, endpoints.next(99, endpoints.endpoints[0]).IEtherman)
+}
+
+func TestL1SyncerLaggingEndpoint(t *testing.T) {
+	address := common.HexToAddress("0x1")
+	logs := []ethTypes.Log{{Address: address, BlockNumber: 42}, {Address: address, BlockNumber: 98}}
+	// the lagging endpoint comes first, it answers without the logs of the blocks it didn't reach yet
+	lagging := &testEtherman{head: 90, logs: logs}
+	synced := &testEtherman{head: 100, logs: logs}
+
+	s := NewL1Syncer(context.Background(), []IEtherman{lagging, synced}, []common.Address{address}, nil, 10, 0, "latest")
+	latest, err := s.getLatestL1Block()
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), latest)
+
+	var received []ethTypes.Log
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for l := range s.logsChan {
+			received = append(received, l...)
+		}
+	}()
+	require.NoError(t, s.queryBlocks())
+	close(s.logsChan)
+	<-done
+
+	require.ElementsMatch(t, logs, received)
+	require.LessOrEqual(t, lagging.filterTo, lagging.head)
+	require.Equal(t, uint64(100), synced.filterTo)
+}
+
+func TestL1SyncerCrossCheck(t *testing.T) {
+	address := common.HexToAddress("0x1")
+	logs := []ethTypes.Log{{Address: address, BlockNumber: 42, BlockHash: common.HexToHash("0xaa")}}
+	first, second := &testEtherman{head: 100, logs: logs}, &testEtherman{head: 100, logs: logs}
+
+	s := NewL1SyncerWithEndpoints(context.Background(), NewL1Endpoints([]IEtherman{first, second}, DefaultL1EndpointsConfig()),
+		[]common.Address{address}, nil, 10, 0, "latest", true)
+	_, err := s.getLatestL1Block()
+	require.NoError(t, err)
+	query := ethereum.FilterQuery{FromBlock: big.NewInt(40), ToBlock: big.NewInt(50)}
+	endpoint := s.endpoints.endpoints[0]
+
+	// agreeing
+	require.NoError(t, s.crossCheckLogs(endpoint, query, logs))
+
+	// another block hash in the logs
+	other := []ethTypes.Log{logs[0]}
+	other[0].BlockHash = common.HexToHash("0xbb")
+	second.logs = other
+	require.ErrorIs(t, s.crossCheckLogs(endpoint, query, logs), errCrossCheckMismatch)
+
+	// a missing log
+	second.logs = nil
+	require.ErrorIs(t, s.crossCheckLogs(endpoint, query, logs), errCrossCheckMismatch)
+
+	// the same logs on another fork
+	second.logs, second.fork = logs, 1
+	require.ErrorIs(t, s.crossCheckLogs(endpoint, query, logs), errCrossCheckMismatch)
+
+	// a single endpoint can't be cross checked
+	require.False(t, NewL1SyncerWithEndpoints(context.Background(), NewL1Endpoints([]IEtherman{first}, DefaultL1EndpointsConfig()),
+		nil, nil, 10, 0, "latest", true).crossCheck)
+}
@@ -0,0 +1,77 @@
+package syncer
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	ethTypes "github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// L1ChainReader reads the canonical L1 chain the ingested blocks are checked against
+type L1ChainReader interface {
+	GetHeader(number uint64) (*ethTypes.Header, error)
+	CheckL1BlockFinalized(blockNo uint64) (bool, uint64, error)
+}
+
+// CheckL1Reorg compares the hashes kept for the source of the L1 blocks it ingested data from with the ones of the
+// canonical chain. When some were reorged out it deletes their hashes and returns the highest block still canonical,
+// the data of the blocks after it is to be rolled back and fetched again. The hashes of the finalized blocks are then
+// pruned.
+func CheckL1Reorg(hermezDb *hermez_db.HermezDb, source string, reader L1ChainReader) (ancestor uint64, reorged bool, err error) {
+	hashes, err := hermezDb.GetL1BlockHashes(source)
+	if err != nil {
+		return 0, false, fmt.Errorf("GetL1BlockHashes: %w", err)
+	}
+	if len(hashes) == 0 {
+		return 0, false, nil
+	}
+
+	ancestor, reorged, err = findL1ReorgAncestor(reader, hashes)
+	if err != nil {
+		return 0, false, err
+	}
+	if reorged {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`l1_reorgs_total{source="%s"}`, source)).Inc()
+		log.Warn("L1 reorg detected", "source", source, "ancestor", ancestor)
+		if err = hermezDb.TruncateL1BlockHashes(source, ancestor); err != nil {
+			return 0, false, fmt.Errorf("TruncateL1BlockHashes: %w", err)
+		}
+	}
+
+	_, finalized, err := reader.CheckL1BlockFinalized(0)
+	if err != nil {
+		return 0, false, fmt.Errorf("CheckL1BlockFinalized: %w", err)
+	}
+	if err = hermezDb.PruneL1BlockHashes(source, finalized); err != nil {
+		return 0, false, fmt.Errorf("PruneL1BlockHashes: %w", err)
+	}
+
+	return ancestor, reorged, nil
+}
+
+// findL1ReorgAncestor walks the kept blocks down from the highest one until one is still canonical, the blocks below
+// it are then as well. When none is, the block before the lowest one is the ancestor, the hash of the highest
+// finalized block being kept when pruning.
+func findL1ReorgAncestor(reader L1ChainReader, hashes map[uint64]common.Hash) (uint64, bool, error) {
+	blockNos := make([]uint64, 0, len(hashes))
+	for blockNo := range hashes {
+		blockNos = append(blockNos, blockNo)
+	}
+	slices.Sort(blockNos)
+
+	for i := len(blockNos) - 1; i >= 0; i-- {
+		header, err := reader.GetHeader(blockNos[i])
+		if err != nil {
+			return 0, false, fmt.Errorf("GetHeader %d: %w", blockNos[i], err)
+		}
+		if header.Hash() == hashes[blockNos[i]] {
+			return blockNos[i], i < len(blockNos)-1, nil
+		}
+	}
+
+	return blockNos[0] - 1, true, nil
+}
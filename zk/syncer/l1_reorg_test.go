@@ -0,0 +1,63 @@
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckL1Reorg(t *testing.T) {
+	tx := memdb.BeginRw(t, memdb.NewTestDB(t))
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+	hermezDb := hermez_db.NewHermezDb(tx)
+
+	em := &testEtherman{head: 20, final: 5}
+	s := NewL1Syncer(context.Background(), []IEtherman{em}, nil, nil, 10, 0, "latest")
+
+	keep := func(blockNos ...uint64) {
+		for _, blockNo := range blockNos {
+			header, err := s.GetHeader(blockNo)
+			require.NoError(t, err)
+			require.NoError(t, hermezDb.WriteL1BlockHash("test", blockNo, header.Hash()))
+		}
+	}
+	check := func() (uint64, bool, map[uint64]common.Hash) {
+		ancestor, reorged, err := CheckL1Reorg(hermezDb, "test", s)
+		require.NoError(t, err)
+		hashes, err := hermezDb.GetL1BlockHashes("test")
+		require.NoError(t, err)
+		return ancestor, reorged, hashes
+	}
+
+	// nothing kept yet
+	_, reorged, _ := check()
+	require.False(t, reorged)
+
+	// all canonical, the hashes of the blocks before the highest finalized one are pruned
+	keep(3, 4, 8, 12, 15)
+	ancestor, reorged, hashes := check()
+	require.False(t, reorged)
+	require.Equal(t, uint64(15), ancestor)
+	require.Len(t, hashes, 4)
+	require.Contains(t, hashes, uint64(4))
+
+	// the blocks from 13 on are reorged out
+	em.fork, em.forkFrom = 1, 13
+	ancestor, reorged, hashes = check()
+	require.True(t, reorged)
+	require.Equal(t, uint64(12), ancestor)
+	require.Len(t, hashes, 3)
+	require.NotContains(t, hashes, uint64(15))
+
+	// every block kept past the finalized one is reorged out
+	keep(13, 14)
+	em.fork, em.forkFrom = 2, 5
+	ancestor, reorged, hashes = check()
+	require.True(t, reorged)
+	require.Equal(t, uint64(4), ancestor)
+	require.Len(t, hashes, 1)
+}
@@ -1,9 +1,11 @@
 package syncer
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/big"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,6 +23,9 @@ import (
 
 var (
 	batchWorkers = 2
+
+	// how long an endpoint is waited for when asked for its head, past it it's scored as failing
+	latestL1BlockTimeout = 30 * time.Second
 )
 
 var errorShortResponseLT32 = fmt.Errorf("response too short to contain hash data")
@@ -60,9 +65,7 @@ type jobResult struct {
 
 type L1Syncer struct {
 	ctx                 context.Context
-	etherMans           []IEtherman
-	ethermanIndex       uint8
-	ethermanMtx         *sync.Mutex
+	endpoints           *L1Endpoints
 	l1ContractAddresses []common.Address
 	topics              [][]common.Hash
 	blockRange          uint64
@@ -82,14 +85,24 @@ type L1Syncer struct {
 	logsChanProgress chan string
 
 	highestBlockType string // finalized, latest, safe
+	crossCheck       bool   // the logs are only accepted once two endpoints agree on them
 }
 
 func NewL1Syncer(ctx context.Context, etherMans []IEtherman, l1ContractAddresses []common.Address, topics [][]common.Hash, blockRange, queryDelay uint64, highestBlockType string) *L1Syncer {
+	return NewL1SyncerWithEndpoints(ctx, NewL1Endpoints(etherMans, DefaultL1EndpointsConfig()), l1ContractAddresses, topics, blockRange, queryDelay, highestBlockType, false)
+}
+
+// NewL1SyncerWithEndpoints returns a syncer sharing the endpoints, and their health, with other syncers. With
+// crossCheck the logs and the hash of the last block of every range are compared between two endpoints before
+// they are accepted.
+func NewL1SyncerWithEndpoints(ctx context.Context, endpoints *L1Endpoints, l1ContractAddresses []common.Address, topics [][]common.Hash, blockRange, queryDelay uint64, highestBlockType string, crossCheck bool) *L1Syncer {
+	if crossCheck && len(endpoints.endpoints) < 2 {
+		log.Warn("L1 cross check needs two endpoints at least, it is disabled", "endpoints", len(endpoints.endpoints))
+		crossCheck = false
+	}
 	return &L1Syncer{
 		ctx:                 ctx,
-		etherMans:           etherMans,
-		ethermanIndex:       0,
-		ethermanMtx:         &sync.Mutex{},
+		endpoints:           endpoints,
 		l1ContractAddresses: l1ContractAddresses,
 		topics:              topics,
 		blockRange:          blockRange,
@@ -97,21 +110,13 @@ func NewL1Syncer(ctx context.Context, etherMans []IEtherman, l1ContractAddresses
 		logsChan:            make(chan []ethTypes.Log),
 		logsChanProgress:    make(chan string),
 		highestBlockType:    highestBlockType,
+		crossCheck:          crossCheck,
 	}
 }
 
-func (s *L1Syncer) getNextEtherman() IEtherman {
-	s.ethermanMtx.Lock()
-	defer s.ethermanMtx.Unlock()
-
-	if s.ethermanIndex >= uint8(len(s.etherMans)) {
-		s.ethermanIndex = 0
-	}
-
-	etherman := s.etherMans[s.ethermanIndex]
-	s.ethermanIndex++
-
-	return etherman
+// getNextEtherman returns the next healthy endpoint, the caller records how it answered
+func (s *L1Syncer) getNextEtherman() *l1Endpoint {
+	return s.endpoints.next(0, nil)
 }
 
 func (s *L1Syncer) IsSyncStarted() bool {
@@ -206,18 +211,25 @@ func (s *L1Syncer) RunQueryBlocks(lastCheckedBlock uint64) {
 }
 
 func (s *L1Syncer) GetHeader(number uint64) (*ethTypes.Header, error) {
-	em := s.getNextEtherman()
-	return em.HeaderByNumber(s.ctx, new(big.Int).SetUint64(number))
+	// an endpoint behind the block doesn't know it yet
+	em := s.endpoints.next(number, nil)
+	header, err := em.HeaderByNumber(s.ctx, new(big.Int).SetUint64(number))
+	em.record(err)
+	return header, err
 }
 
 func (s *L1Syncer) GetBlock(number uint64) (*ethTypes.Block, error) {
 	em := s.getNextEtherman()
-	return em.BlockByNumber(s.ctx, new(big.Int).SetUint64(number))
+	block, err := em.BlockByNumber(s.ctx, new(big.Int).SetUint64(number))
+	em.record(err)
+	return block, err
 }
 
 func (s *L1Syncer) GetTransaction(hash common.Hash) (ethTypes.Transaction, bool, error) {
 	em := s.getNextEtherman()
-	return em.TransactionByHash(s.ctx, hash)
+	tx, pending, err := em.TransactionByHash(s.ctx, hash)
+	em.record(err)
+	return tx, pending, err
 }
 
 func (s *L1Syncer) GetPreElderberryAccInputHash(ctx context.Context, addr *common.Address, batchNum uint64) (common.Hash, error) {
@@ -243,11 +255,13 @@ func (s *L1Syncer) GetElderberryAccInputHash(ctx context.Context, addr *common.A
 func (s *L1Syncer) GetL1BlockTimeStampByTxHash(ctx context.Context, txHash common.Hash) (uint64, error) {
 	em := s.getNextEtherman()
 	r, err := em.TransactionReceipt(ctx, txHash)
+	em.record(err)
 	if err != nil {
 		return 0, err
 	}
 
 	header, err := em.HeaderByNumber(context.Background(), r.BlockNumber)
+	em.record(err)
 	if err != nil {
 		return 0, err
 	}
@@ -270,7 +284,7 @@ func (s *L1Syncer) L1QueryHeaders(logs []ethTypes.Log) (map[uint64]*ethTypes.Hea
 
 	headersQueue := make(chan *ethTypes.Header, logsSize)
 
-	process := func(em IEtherman) {
+	process := func(em *l1Endpoint) {
 		ctx := context.Background()
 		for {
 			l, ok := <-logQueue
@@ -278,6 +292,7 @@ func (s *L1Syncer) L1QueryHeaders(logs []ethTypes.Log) (map[uint64]*ethTypes.Hea
 				break
 			}
 			header, err := em.HeaderByNumber(ctx, new(big.Int).SetUint64(l.BlockNumber))
+			em.record(err)
 			if err != nil {
 				log.Error("Error getting block", "err", err)
 				// assume a transient error and try again
@@ -292,7 +307,7 @@ func (s *L1Syncer) L1QueryHeaders(logs []ethTypes.Log) (map[uint64]*ethTypes.Hea
 
 	// launch the workers - some endpoints might be faster than others so will consume more of the queue
 	// but, we really don't care about that.  We want the data as fast as possible
-	mans := s.etherMans
+	mans := s.endpoints.healthy()
 	for i := 0; i < len(mans); i++ {
 		go process(mans[i])
 	}
@@ -308,9 +323,9 @@ func (s *L1Syncer) L1QueryHeaders(logs []ethTypes.Log) (map[uint64]*ethTypes.Hea
 	return headersMap, nil
 }
 
+// getLatestL1Block asks every endpoint for its head, the ones lagging too far behind the best head being excluded,
+// and returns the best head
 func (s *L1Syncer) getLatestL1Block() (uint64, error) {
-	em := s.getNextEtherman()
-
 	var blockNumber *big.Int
 
 	switch s.highestBlockType {
@@ -322,12 +337,40 @@ func (s *L1Syncer) getLatestL1Block() (uint64, error) {
 		blockNumber = nil
 	}
 
-	latestBlock, err := em.BlockByNumber(context.Background(), blockNumber)
-	if err != nil {
-		return 0, err
+	endpoints := s.endpoints.endpoints
+	heads := make([]uint64, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	wg.Add(len(endpoints))
+	for i, em := range endpoints {
+		go func(i int, em *l1Endpoint) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(s.ctx, latestL1BlockTimeout)
+			defer cancel()
+			latestBlock, err := em.BlockByNumber(ctx, blockNumber)
+			em.record(err)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			heads[i] = latestBlock.NumberU64()
+		}(i, em)
 	}
+	wg.Wait()
 
-	latest := latestBlock.NumberU64()
+	answered := make(map[*l1Endpoint]uint64, len(endpoints))
+	var err error
+	for i, em := range endpoints {
+		if errs[i] != nil {
+			err = errs[i]
+			continue
+		}
+		answered[em] = heads[i]
+	}
+	latest, ok := s.endpoints.updateHeads(answered)
+	if !ok {
+		return 0, err
+	}
 	s.latestL1Block = latest
 
 	return latest, nil
@@ -446,8 +489,13 @@ func (s *L1Syncer) getSequencedLogs(jobs <-chan fetchJob, results chan jobResult
 			var err error
 			retry := 0
 			for {
-				em := s.getNextEtherman()
+				// an endpoint whose head didn't reach the end of the range would miss its logs
+				em := s.endpoints.next(j.To, nil)
 				logs, err = em.FilterLogs(context.Background(), query)
+				em.record(err)
+				if err == nil && s.crossCheck {
+					err = s.crossCheckLogs(em, query, logs)
+				}
 				if err != nil {
 					log.Debug("getSequencedLogs retry error", "err", err)
 					retry++
@@ -482,6 +530,7 @@ func (s *L1Syncer) callSequencedBatchesMap(ctx context.Context, addr *common.Add
 	em := s.getNextEtherman()
 
 	resp, err := em.StorageAt(ctx, *addr, mkh, nil)
+	em.record(err)
 	if err != nil {
 		return
 	}
@@ -509,6 +558,7 @@ func (s *L1Syncer) callGetRollupSequencedBatches(ctx context.Context, addr *comm
 		To:   addr,
 		Data: common.FromHex(rollupSequencedBatchesSignature + rollupID + batchNumber),
 	}, nil)
+	em.record(err)
 
 	if err != nil {
 		return common.Hash{}, 0, err
@@ -549,6 +599,7 @@ func (s *L1Syncer) callGetAddress(ctx context.Context, addr *common.Address, dat
 		To:   addr,
 		Data: common.FromHex(data),
 	}, nil)
+	em.record(err)
 
 	if err != nil {
 		return common.Address{}, err
@@ -564,9 +615,65 @@ func (s *L1Syncer) callGetAddress(ctx context.Context, addr *common.Address, dat
 func (s *L1Syncer) CheckL1BlockFinalized(blockNo uint64) (finalized bool, finalizedBn uint64, err error) {
 	em := s.getNextEtherman()
 	block, err := em.BlockByNumber(s.ctx, big.NewInt(rpc.FinalizedBlockNumber.Int64()))
+	em.record(err)
 	if err != nil {
 		return false, 0, err
 	}
 
 	return block.NumberU64() >= blockNo, block.NumberU64(), nil
 }
+
+// crossCheckLogs asks another endpoint for the logs of the query and compares them, along with the hash of the last
+// block of the range, with the ones the first endpoint answered
+func (s *L1Syncer) crossCheckLogs(first *l1Endpoint, query ethereum.FilterQuery, logs []ethTypes.Log) error {
+	second := s.endpoints.next(query.ToBlock.Uint64(), first)
+	if second == nil {
+		return fmt.Errorf("%w: no endpoint to cross check endpoint %d with", errCrossCheckMismatch, first.id)
+	}
+
+	otherLogs, err := second.FilterLogs(context.Background(), query)
+	second.record(err)
+	if err != nil {
+		return err
+	}
+	mismatch := compareLogs(logs, otherLogs)
+
+	if mismatch == "" {
+		firstHeader, err := first.HeaderByNumber(context.Background(), query.ToBlock)
+		first.record(err)
+		if err != nil {
+			return err
+		}
+		secondHeader, err := second.HeaderByNumber(context.Background(), query.ToBlock)
+		second.record(err)
+		if err != nil {
+			return err
+		}
+		if firstHeader.Hash() != secondHeader.Hash() {
+			mismatch = fmt.Sprintf("block %d hash %s != %s", query.ToBlock.Uint64(), firstHeader.Hash(), secondHeader.Hash())
+		}
+	}
+	if mismatch == "" {
+		return nil
+	}
+
+	s.endpoints.crossCheckMismatches.Inc()
+	log.Warn("L1 endpoints disagree on the logs", "from", query.FromBlock, "to", query.ToBlock,
+		"endpoint", first.id, "other", second.id, "mismatch", mismatch)
+	return fmt.Errorf("%w: endpoints %d and %d, %s", errCrossCheckMismatch, first.id, second.id, mismatch)
+}
+
+// compareLogs returns what differs between the logs, empty when they're the same
+func compareLogs(logs, other []ethTypes.Log) string {
+	if len(logs) != len(other) {
+		return fmt.Sprintf("%d logs != %d", len(logs), len(other))
+	}
+	for i := range logs {
+		a, b := &logs[i], &other[i]
+		if a.BlockNumber != b.BlockNumber || a.BlockHash != b.BlockHash || a.TxHash != b.TxHash || a.Index != b.Index ||
+			a.Address != b.Address || a.Removed != b.Removed || !bytes.Equal(a.Data, b.Data) || !slices.Equal(a.Topics, b.Topics) {
+			return fmt.Sprintf("log %d of block %d tx %s", a.Index, a.BlockNumber, a.TxHash)
+		}
+	}
+	return ""
+}
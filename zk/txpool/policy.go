@@ -6,9 +6,11 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/types"
@@ -135,9 +137,13 @@ const (
 	SendTx Policy = iota
 	// Deploy is the name of the policy that governs that an address may deploy a contract
 	Deploy
+	// CallOnly is the name of the restriction policy that limits an address to calling the given contracts
+	CallOnly
+	// MaxValue is the name of the restriction policy that limits the value an address may transfer per transaction
+	MaxValue
 )
 
-var policiesList = []Policy{SendTx, Deploy}
+var policiesList = []Policy{SendTx, Deploy, CallOnly, MaxValue}
 
 func (p Policy) ToByte() byte {
 	return byte(p)
@@ -147,10 +153,14 @@ func (p Policy) ToByteArray() []byte {
 	return []byte{byte(p)}
 }
 
+func (p Policy) String() string {
+	return policyName(p)
+}
+
 // IsSupportedPolicy checks if the given policy is supported
 func IsSupportedPolicy(policy Policy) bool {
 	switch policy {
-	case SendTx, Deploy:
+	case SendTx, Deploy, CallOnly, MaxValue:
 		return true
 	default:
 		return false
@@ -163,28 +173,37 @@ func ResolvePolicy(policy string) (Policy, error) {
 		return SendTx, nil
 	case "deploy":
 		return Deploy, nil
+	case "callOnly":
+		return CallOnly, nil
+	case "maxValue":
+		return MaxValue, nil
 	default:
 		return SendTx, errUnknownPolicy
 	}
 }
 
-// containsPolicy checks if the given policy is present in the policy list
-func containsPolicy(policies []byte, policy Policy) bool {
-	return bytes.Contains(policies, policy.ToByteArray())
-}
-
 // address policyMapping returns a string of user policies.
 func policyMapping(policies []byte, pList []Policy) string {
-	policyPresence := make(map[string]bool)
+	policyPresence := make(map[string]string)
+
+	entries, err := decodePolicyEntries(policies)
+	if err != nil {
+		return fmt.Sprintf("\t%s", err)
+	}
 
 	for _, policy := range pList {
-		// Check if the policy exists in the provided byte slice
-		exists := bytes.Contains(policies, policy.ToByteArray())
 		if policyName(policy) == "unknown" {
 			continue
 		}
-		// Store the result in the map with the policy name
-		policyPresence[policyName(policy)] = exists
+		// Store the result in the map with the policy name, adding the parameters and validity window if any
+		presence := "false"
+		if idx := findPolicyEntry(entries, policy); idx >= 0 {
+			presence = "true"
+			if details := strings.TrimPrefix(entries[idx].String(), policyName(policy)); details != "" {
+				presence += details
+			}
+		}
+		policyPresence[policyName(policy)] = presence
 	}
 
 	// could be used to return a map here
@@ -193,8 +212,8 @@ func policyMapping(policies []byte, pList []Policy) string {
 	formattedPolicies := make([]string, 0, len(policyPresence))
 
 	// Populate the slice with formatted strings
-	for policy, exists := range policyPresence {
-		formattedPolicies = append(formattedPolicies, fmt.Sprintf("\t%s: %v", policy, exists))
+	for policy, presence := range policyPresence {
+		formattedPolicies = append(formattedPolicies, fmt.Sprintf("\t%s: %s", policy, presence))
 	}
 
 	// Join the formatted strings with ", "
@@ -208,6 +227,10 @@ func policyName(policy Policy) string {
 		return "sendTx"
 	case Deploy:
 		return "deploy"
+	case CallOnly:
+		return "callOnly"
+	case MaxValue:
+		return "maxValue"
 	default:
 		return "unknown"
 	}
@@ -248,19 +271,21 @@ func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Add
 			table = Allowlist
 		}
 
-		var policyBytes []byte
 		value, err = tx.GetOne(table, addr.Bytes())
 		if err != nil {
 			return err
 		}
 
-		policyBytes = value
-		if policyBytes != nil && containsPolicy(policyBytes, policy) {
-			// If address is in the allowlist and has the policy, return true
-			// If address is in the blocklist and has the policy, return false
-			hasPolicy = true
+		entries, err := decodePolicyEntries(value)
+		if err != nil {
+			return err
 		}
 
+		// If address is in the allowlist and has the policy, return true
+		// If address is in the blocklist and has the policy, return false
+		// A policy outside of its validity window is treated as if the address did not have it
+		hasPolicy = hasActivePolicy(entries, policy, time.Now())
+
 		return nil
 	})
 	if err != nil {
@@ -272,10 +297,31 @@ func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Add
 
 // UpdatePolicies sets a policy for an address
 func UpdatePolicies(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []common.Address, policies [][]Policy) error {
+	entries := make([][]PolicyEntry, len(policies))
+	for i, addrPolicies := range policies {
+		entries[i] = make([]PolicyEntry, 0, len(addrPolicies))
+		for _, p := range addrPolicies {
+			entries[i] = append(entries[i], PolicyEntry{Policy: p})
+		}
+	}
+
+	return UpdatePolicyEntries(ctx, aclDB, aclType, addrs, entries)
+}
+
+// UpdatePolicyEntries sets the policy entries of each address to the given ones, removing the address
+// from the table if it has no entries
+func UpdatePolicyEntries(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []common.Address, entries [][]PolicyEntry) error {
 	table, err := resolveTable(aclType)
 	if err != nil {
 		return err
 	}
+	for _, addrEntries := range entries {
+		for _, e := range addrEntries {
+			if err := e.Validate(); err != nil {
+				return err
+			}
+		}
+	}
 	// Create an array to hold policy transactions
 	var policyTransactions []PolicyTransaction
 	timeNow := time.Now()
@@ -289,14 +335,10 @@ func UpdatePolicies(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []
 				timeTx:    timeNow,
 			})
 
-			if len(policies[i]) > 0 {
+			if len(entries[i]) > 0 {
 				// just update the policies for the address to match the one provided
-				policyBytes := make([]byte, 0, len(policies[i]))
-				for _, p := range policies[i] {
-					policyBytes = append(policyBytes, p.ToByte())
-				}
 				// Update the policies in the table
-				if err := tx.Put(table, addr.Bytes(), policyBytes); err != nil {
+				if err := tx.Put(table, addr.Bytes(), encodePolicyEntries(entries[i])); err != nil {
 					return err
 				}
 				continue
@@ -325,6 +367,25 @@ type PolicyTransaction struct {
 	policy    Policy
 	operation Operation
 	timeTx    time.Time
+
+	// set when a policy entry with a validity window or parameters is added
+	notBefore time.Time
+	notAfter  time.Time
+	params    []byte
+}
+
+// policyTransactionForEntry creates the history record of an operation on a policy entry
+func policyTransactionForEntry(aclType string, addr common.Address, entry PolicyEntry, operation Operation, timeTx time.Time) PolicyTransaction {
+	return PolicyTransaction{
+		aclType:   ResolveACLTypeToBinary(aclType),
+		addr:      addr,
+		policy:    entry.Policy,
+		operation: operation,
+		timeTx:    timeTx,
+		notBefore: entry.NotBefore,
+		notAfter:  entry.NotAfter,
+		params:    entry.params(),
+	}
 }
 
 // Convert time.Time to bytes (Unix timestamp)
@@ -344,6 +405,12 @@ func InsertPolicyTransactions(ctx context.Context, aclDB kv.RwDB, pts []PolicyTr
 			// composite key.
 			addressTimestamp := append(pt.addr.Bytes(), unixBytes...)
 			value := append([]byte{pt.aclType.ToByte(), pt.operation.ToByte(), pt.policy.ToByte()}, addressTimestamp...)
+			if !pt.notBefore.IsZero() || !pt.notAfter.IsZero() || len(pt.params) > 0 {
+				// the validity window and the params of the entry follow the fixed size part
+				value = binary.BigEndian.AppendUint64(value, timeToUnix(pt.notBefore))
+				value = binary.BigEndian.AppendUint64(value, timeToUnix(pt.notAfter))
+				value = append(value, pt.params...)
+			}
 
 			if err := tx.Put(PolicyTransactions, addressTimestamp, value); err != nil {
 				return err
@@ -404,6 +471,41 @@ func LastPolicyTransactions(ctx context.Context, aclDB kv.RwDB, count int) ([]Po
 	return pts, err
 }
 
+// PolicyTransactionsPage returns count policy transactions, newest first, skipping the first offset ones,
+// together with the total number of policy transactions recorded
+func PolicyTransactionsPage(ctx context.Context, aclDB kv.RwDB, offset, count int) ([]PolicyTransaction, int, error) {
+	var pts []PolicyTransaction
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		// the table is keyed by address, so the transactions have to be sorted by time to get the newest
+		return tx.ForEach(PolicyTransactions, nil, func(k, v []byte) error {
+			pt, err := byteToPolicyTransaction(v)
+			if err != nil {
+				return err
+			}
+			pts = append(pts, pt)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(pts, func(i, j int) bool {
+		return pts[i].timeTx.After(pts[j].timeTx)
+	})
+
+	total := len(pts)
+	if offset >= total || count <= 0 {
+		return nil, total, nil
+	}
+	end := offset + count
+	if end > total {
+		end = total
+	}
+
+	return pts[offset:end], total, nil
+}
+
 func byteToPolicyTransaction(value []byte) (PolicyTransaction, error) {
 	// if the length is the size of mode change = 9, then it is a mode change transaction
 	if len(value) == 9 {
@@ -420,7 +522,8 @@ func byteToPolicyTransaction(value []byte) (PolicyTransaction, error) {
 	// 1 byte for policy,
 	// 20 bytes for address,
 	// 8 bytes for timestamp = 31 bytes in total
-	if len(value) != 31 {
+	// optionally followed by 8 bytes for not-before, 8 bytes for not-after and the policy params
+	if len(value) != 31 && len(value) < 47 {
 		return PolicyTransaction{}, fmt.Errorf("invalid value length %d", len(value))
 	}
 
@@ -441,14 +544,55 @@ func byteToPolicyTransaction(value []byte) (PolicyTransaction, error) {
 	timestampBytes := value[23:31]
 	timeTx := bytesToTimestamp(timestampBytes)
 
-	// Return the reconstructed PolicyTransaction struct
-	return PolicyTransaction{
+	pt := PolicyTransaction{
 		aclType:   aclType,
 		addr:      addr,
 		policy:    policy,
 		operation: operation,
 		timeTx:    timeTx,
-	}, nil
+	}
+
+	if len(value) > 31 {
+		pt.notBefore = unixToTime(binary.BigEndian.Uint64(value[31:39]))
+		pt.notAfter = unixToTime(binary.BigEndian.Uint64(value[39:47]))
+		pt.params = common.CopyBytes(value[47:])
+	}
+
+	// Return the reconstructed PolicyTransaction struct
+	return pt, nil
+}
+
+// ACLType returns the ACL the transaction was recorded for
+func (pt PolicyTransaction) ACLType() ACLTypeBinary {
+	return pt.aclType
+}
+
+// Address returns the address the transaction was recorded for, empty on mode changes
+func (pt PolicyTransaction) Address() common.Address {
+	return pt.addr
+}
+
+// Operation returns the operation the transaction recorded
+func (pt PolicyTransaction) Operation() Operation {
+	return pt.operation
+}
+
+// Time returns when the transaction was recorded
+func (pt PolicyTransaction) Time() time.Time {
+	return pt.timeTx
+}
+
+// Entry reconstructs the policy entry the transaction was recorded for
+func (pt PolicyTransaction) Entry() PolicyEntry {
+	e := PolicyEntry{
+		Policy:    pt.policy,
+		NotBefore: pt.notBefore,
+		NotAfter:  pt.notAfter,
+	}
+	if err := e.setParams(pt.params); err != nil {
+		return PolicyEntry{Policy: pt.policy}
+	}
+	return e
 }
 
 func (pt PolicyTransaction) ToString() string {
@@ -464,15 +608,21 @@ func (pt PolicyTransaction) ToString() string {
 	return fmt.Sprintf("ACLType: %s, Address: %s, Policy: %s, Operation: %s, Time: %s",
 		pt.aclType.String(),
 		hex.EncodeToString(pt.addr[:]), // Convert address to hexadecimal string representation
-		policyName(pt.policy),          // Use policyName function to get the policy name
+		pt.Entry().String(),            // Policy name followed by its params and validity window, if any
 		pt.operation.String(),
 		pt.timeTx.Format(time.RFC3339)) // Use RFC3339 format for the time
 }
 
 // AddPolicy adds a policy to the ACL of given address
 func AddPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.Address, policy Policy) error {
-	if !IsSupportedPolicy(policy) {
-		return errUnknownPolicy
+	return AddPolicyEntry(ctx, aclDB, aclType, addr, PolicyEntry{Policy: policy})
+}
+
+// AddPolicyEntry adds a policy entry to the ACL of given address. If the address already has the policy,
+// its validity window and parameters are replaced by the ones of the given entry.
+func AddPolicyEntry(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.Address, entry PolicyEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
 	}
 
 	table, err := resolveTable(aclType)
@@ -486,31 +636,27 @@ func AddPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.A
 			return err
 		}
 
-		policyBytes := policy.ToByteArray()
-		if value == nil {
-			return tx.Put(table, addr.Bytes(), policyBytes)
+		entries, err := decodePolicyEntries(value)
+		if err != nil {
+			return err
 		}
 
 		// Check if the policy already exists
-		if containsPolicy(value, policy) {
-			return nil
+		if idx := findPolicyEntry(entries, entry.Policy); idx >= 0 {
+			entries[idx] = entry
+		} else {
+			entries = append(entries, entry)
 		}
 
-		value = append(value, policyBytes...)
-
-		return tx.Put(table, addr.Bytes(), value)
+		return tx.Put(table, addr.Bytes(), encodePolicyEntries(entries))
 	})
 	if err != nil {
 		return err
 	}
 
-	err = InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{{
-		aclType:   ResolveACLTypeToBinary(aclType),
-		addr:      addr,
-		policy:    policy,
-		operation: Add,
-		timeTx:    time.Now(),
-	}})
+	err = InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{
+		policyTransactionForEntry(aclType, addr, entry, Add, time.Now()),
+	})
 
 	return err
 }
@@ -532,19 +678,23 @@ func RemovePolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr commo
 			return nil
 		}
 
-		updatedPolicies := []byte{}
+		entries, err := decodePolicyEntries(policies)
+		if err != nil {
+			return err
+		}
 
-		for _, p := range policies {
-			if p != policy.ToByte() {
-				updatedPolicies = append(updatedPolicies, p)
+		updatedEntries := make([]PolicyEntry, 0, len(entries))
+		for _, e := range entries {
+			if e.Policy != policy {
+				updatedEntries = append(updatedEntries, e)
 			}
 		}
 
-		if len(updatedPolicies) == 0 {
+		if len(updatedEntries) == 0 {
 			return tx.Delete(table, addr.Bytes())
 		}
 
-		return tx.Put(table, addr.Bytes(), updatedPolicies)
+		return tx.Put(table, addr.Bytes(), encodePolicyEntries(updatedEntries))
 	})
 	if err != nil {
 		return err
@@ -648,6 +798,28 @@ func ListContentAtACL(ctx context.Context, db kv.RwDB) ([]string, error) {
 	return combinedBuffers, err
 }
 
+// ListPolicyEntries returns the policy entries of every address in the given ACL
+func ListPolicyEntries(ctx context.Context, aclDB kv.RwDB, aclType string) (map[common.Address][]PolicyEntry, error) {
+	table, err := resolveTable(aclType)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[common.Address][]PolicyEntry)
+	err = aclDB.View(ctx, func(tx kv.Tx) error {
+		return tx.ForEach(table, nil, func(k, v []byte) error {
+			addrEntries, err := decodePolicyEntries(v)
+			if err != nil {
+				return err
+			}
+			entries[common.BytesToAddress(k)] = addrEntries
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
 // SetMode sets the mode of the ACL
 func SetMode(ctx context.Context, aclDB kv.RwDB, mode string) error {
 	m, err := ResolveACLMode(mode)
@@ -710,6 +882,57 @@ func resolvePolicy(txn *types.TxSlot) Policy {
 	return SendTx
 }
 
+// checkPolicyRestrictions checks the transaction against the restriction policies (CallOnly and MaxValue) the sender
+// has in the table of the active ACL mode. Restrictions apply the same way in allowlist and blocklist mode.
+func checkPolicyRestrictions(ctx context.Context, aclDB kv.RwDB, addr common.Address, creation bool, to common.Address, value *uint256.Int) (DiscardReason, error) {
+	reason := Success
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		mode, err := tx.GetOne(Config, []byte(modeKey))
+		if err != nil {
+			return err
+		}
+
+		if mode == nil || string(mode) == DisabledMode {
+			return nil
+		}
+
+		table := BlockList
+		if string(mode) == AllowlistMode {
+			table = Allowlist
+		}
+
+		policies, err := tx.GetOne(table, addr.Bytes())
+		if err != nil {
+			return err
+		}
+
+		entries, err := decodePolicyEntries(policies)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, e := range entries {
+			if !e.IsActiveAt(now) {
+				continue
+			}
+			if r := e.allowsTx(creation, to, value); r != Success {
+				reason = r
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	return reason, err
+}
+
+// isTxAllowedByRestrictions checks the transaction against the restriction policies of its sender
+func (p *TxPool) isTxAllowedByRestrictions(ctx context.Context, addr common.Address, txn *types.TxSlot) (DiscardReason, error) {
+	return checkPolicyRestrictions(ctx, p.aclDB, addr, txn.Creation, txn.To, &txn.Value)
+}
+
 // isActionAllowed checks if the given action is allowed for the given address
 func (p *TxPool) isActionAllowed(ctx context.Context, addr common.Address, policy Policy) (bool, error) {
 	hasPolicy, mode, err := checkIfAccountHasPolicy(ctx, p.aclDB, addr, policy)
@@ -726,3 +949,8 @@ func (p *TxPool) isActionAllowed(ctx context.Context, addr common.Address, polic
 		return hasPolicy, nil
 	}
 }
+
+// ACLDB returns the database holding the ACLs of the pool so they can be managed while the node is running
+func (p *TxPool) ACLDB() kv.RwDB {
+	return p.aclDB
+}
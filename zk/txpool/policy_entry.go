@@ -0,0 +1,267 @@
+package txpool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/length"
+)
+
+// policyEntriesMarker prefixes ACL values written in the extended format. Values that do not start with it
+// are the original format: a plain list of policy bytes.
+const policyEntriesMarker byte = 0xff
+
+// entry header: 1 byte for policy, 8 bytes for not-before, 8 bytes for not-after, 2 bytes for params length
+const policyEntryHeaderLen = 1 + 8 + 8 + 2
+
+var (
+	errInvalidPolicyWindow = errors.New("policy not-after must be later than not-before")
+	errMissingContracts    = errors.New("callOnly policy requires at least one contract address")
+	errMissingMaxValue     = errors.New("maxValue policy requires a value")
+	errInvalidPolicyEntry  = errors.New("invalid policy entry")
+)
+
+// PolicyEntry is a policy assigned to an address in an ACL table. It can be limited to a validity window
+// and, for the restriction policies (CallOnly and MaxValue), carries the parameters transactions are checked against.
+type PolicyEntry struct {
+	Policy    Policy
+	NotBefore time.Time // zero value means the entry is valid from the moment it is added
+	NotAfter  time.Time // zero value means the entry never expires
+
+	Contracts []common.Address // CallOnly: the only contracts the address may call
+	MaxValue  *uint256.Int     // MaxValue: the maximum value the address may transfer per transaction
+}
+
+// IsActiveAt returns true if t falls into the validity window of the entry
+func (e PolicyEntry) IsActiveAt(t time.Time) bool {
+	if !e.NotBefore.IsZero() && t.Before(e.NotBefore) {
+		return false
+	}
+	if !e.NotAfter.IsZero() && !t.Before(e.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// Validate checks the entry holds the parameters its policy needs
+func (e PolicyEntry) Validate() error {
+	if !IsSupportedPolicy(e.Policy) {
+		return errUnknownPolicy
+	}
+	if !e.NotBefore.IsZero() && !e.NotAfter.IsZero() && !e.NotAfter.After(e.NotBefore) {
+		return errInvalidPolicyWindow
+	}
+	switch e.Policy {
+	case CallOnly:
+		if len(e.Contracts) == 0 {
+			return errMissingContracts
+		}
+		if len(e.Contracts) > math.MaxUint16/length.Addr {
+			return fmt.Errorf("%w: too many contracts", errInvalidPolicyEntry)
+		}
+	case MaxValue:
+		if e.MaxValue == nil {
+			return errMissingMaxValue
+		}
+	}
+	return nil
+}
+
+// isPlain returns true if the entry can be stored in the original format
+func (e PolicyEntry) isPlain() bool {
+	return e.NotBefore.IsZero() && e.NotAfter.IsZero() && len(e.params()) == 0
+}
+
+// params encodes the policy specific parameters of the entry
+func (e PolicyEntry) params() []byte {
+	switch e.Policy {
+	case CallOnly:
+		params := make([]byte, 0, len(e.Contracts)*length.Addr)
+		for _, c := range e.Contracts {
+			params = append(params, c.Bytes()...)
+		}
+		return params
+	case MaxValue:
+		if e.MaxValue == nil {
+			return nil
+		}
+		b := e.MaxValue.Bytes32()
+		return b[:]
+	default:
+		return nil
+	}
+}
+
+func (e *PolicyEntry) setParams(params []byte) error {
+	switch e.Policy {
+	case CallOnly:
+		if len(params)%length.Addr != 0 {
+			return fmt.Errorf("%w: callOnly params length %d", errInvalidPolicyEntry, len(params))
+		}
+		e.Contracts = make([]common.Address, 0, len(params)/length.Addr)
+		for i := 0; i < len(params); i += length.Addr {
+			e.Contracts = append(e.Contracts, common.BytesToAddress(params[i:i+length.Addr]))
+		}
+	case MaxValue:
+		if len(params) != 32 {
+			return fmt.Errorf("%w: maxValue params length %d", errInvalidPolicyEntry, len(params))
+		}
+		e.MaxValue = new(uint256.Int).SetBytes(params)
+	}
+	return nil
+}
+
+// String returns the policy name followed by its parameters and validity window, if any
+func (e PolicyEntry) String() string {
+	var sb strings.Builder
+	sb.WriteString(policyName(e.Policy))
+	switch e.Policy {
+	case CallOnly:
+		contracts := make([]string, len(e.Contracts))
+		for i, c := range e.Contracts {
+			contracts[i] = c.Hex()
+		}
+		sb.WriteString(fmt.Sprintf(" [%s]", strings.Join(contracts, ", ")))
+	case MaxValue:
+		if e.MaxValue != nil {
+			sb.WriteString(fmt.Sprintf(" [%s]", e.MaxValue.Dec()))
+		}
+	}
+	if !e.NotBefore.IsZero() {
+		sb.WriteString(fmt.Sprintf(" not before %s", e.NotBefore.UTC().Format(time.RFC3339)))
+	}
+	if !e.NotAfter.IsZero() {
+		sb.WriteString(fmt.Sprintf(" not after %s", e.NotAfter.UTC().Format(time.RFC3339)))
+	}
+	return sb.String()
+}
+
+// allowsTx checks a transaction against a restriction policy entry
+func (e PolicyEntry) allowsTx(creation bool, to common.Address, value *uint256.Int) DiscardReason {
+	switch e.Policy {
+	case CallOnly:
+		if creation {
+			return SenderDisallowedCallTarget
+		}
+		for _, c := range e.Contracts {
+			if c == to {
+				return Success
+			}
+		}
+		return SenderDisallowedCallTarget
+	case MaxValue:
+		if e.MaxValue != nil && value.Gt(e.MaxValue) {
+			return SenderExceededMaxValue
+		}
+	}
+	return Success
+}
+
+func timeToUnix(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+func unixToTime(u uint64) time.Time {
+	if u == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(u), 0)
+}
+
+// encodePolicyEntries encodes the entries of an address for storing in an ACL table. The original format is kept
+// as long as none of the entries have a validity window or parameters.
+func encodePolicyEntries(entries []PolicyEntry) []byte {
+	plain := true
+	for _, e := range entries {
+		if !e.isPlain() {
+			plain = false
+			break
+		}
+	}
+
+	if plain {
+		value := make([]byte, 0, len(entries))
+		for _, e := range entries {
+			value = append(value, e.Policy.ToByte())
+		}
+		return value
+	}
+
+	value := []byte{policyEntriesMarker}
+	for _, e := range entries {
+		params := e.params()
+		header := make([]byte, policyEntryHeaderLen)
+		header[0] = e.Policy.ToByte()
+		binary.BigEndian.PutUint64(header[1:9], timeToUnix(e.NotBefore))
+		binary.BigEndian.PutUint64(header[9:17], timeToUnix(e.NotAfter))
+		binary.BigEndian.PutUint16(header[17:19], uint16(len(params)))
+		value = append(value, header...)
+		value = append(value, params...)
+	}
+	return value
+}
+
+// decodePolicyEntries decodes the value of an ACL table in either the original or the extended format
+func decodePolicyEntries(value []byte) ([]PolicyEntry, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	if value[0] != policyEntriesMarker {
+		entries := make([]PolicyEntry, 0, len(value))
+		for _, p := range value {
+			entries = append(entries, PolicyEntry{Policy: Policy(p)})
+		}
+		return entries, nil
+	}
+
+	var entries []PolicyEntry
+	rest := value[1:]
+	for len(rest) > 0 {
+		if len(rest) < policyEntryHeaderLen {
+			return nil, fmt.Errorf("%w: truncated header", errInvalidPolicyEntry)
+		}
+		paramsLen := int(binary.BigEndian.Uint16(rest[17:19]))
+		if len(rest) < policyEntryHeaderLen+paramsLen {
+			return nil, fmt.Errorf("%w: truncated params", errInvalidPolicyEntry)
+		}
+
+		e := PolicyEntry{
+			Policy:    Policy(rest[0]),
+			NotBefore: unixToTime(binary.BigEndian.Uint64(rest[1:9])),
+			NotAfter:  unixToTime(binary.BigEndian.Uint64(rest[9:17])),
+		}
+		if err := e.setParams(rest[policyEntryHeaderLen : policyEntryHeaderLen+paramsLen]); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+		rest = rest[policyEntryHeaderLen+paramsLen:]
+	}
+
+	return entries, nil
+}
+
+// findPolicyEntry returns the index of the entry for the given policy or -1
+func findPolicyEntry(entries []PolicyEntry, policy Policy) int {
+	for i, e := range entries {
+		if e.Policy == policy {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasActivePolicy checks if the entries contain the policy and it is within its validity window at t
+func hasActivePolicy(entries []PolicyEntry, policy Policy, t time.Time) bool {
+	idx := findPolicyEntry(entries, policy)
+	return idx >= 0 && entries[idx].IsActiveAt(t)
+}
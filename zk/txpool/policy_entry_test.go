@@ -0,0 +1,163 @@
+package txpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyEntriesEncoding(t *testing.T) {
+	t.Run("plain entries keep the original format", func(t *testing.T) {
+		value := encodePolicyEntries([]PolicyEntry{{Policy: SendTx}, {Policy: Deploy}})
+		require.Equal(t, []byte{SendTx.ToByte(), Deploy.ToByte()}, value)
+
+		entries, err := decodePolicyEntries(value)
+		require.NoError(t, err)
+		require.Equal(t, []PolicyEntry{{Policy: SendTx}, {Policy: Deploy}}, entries)
+	})
+
+	t.Run("extended entries round trip", func(t *testing.T) {
+		notBefore := time.Unix(1700000000, 0)
+		notAfter := time.Unix(1800000000, 0)
+		entries := []PolicyEntry{
+			{Policy: SendTx, NotAfter: notAfter},
+			{Policy: CallOnly, Contracts: []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}},
+			{Policy: MaxValue, NotBefore: notBefore, NotAfter: notAfter, MaxValue: uint256.NewInt(1000)},
+		}
+
+		value := encodePolicyEntries(entries)
+		require.Equal(t, policyEntriesMarker, value[0])
+
+		decoded, err := decodePolicyEntries(value)
+		require.NoError(t, err)
+		require.Equal(t, entries, decoded)
+	})
+
+	t.Run("truncated value", func(t *testing.T) {
+		value := encodePolicyEntries([]PolicyEntry{{Policy: SendTx, NotAfter: time.Unix(1800000000, 0)}})
+		_, err := decodePolicyEntries(value[:len(value)-1])
+		require.ErrorIs(t, err, errInvalidPolicyEntry)
+	})
+}
+
+func TestPolicyEntryValidate(t *testing.T) {
+	now := time.Now()
+
+	require.NoError(t, PolicyEntry{Policy: SendTx, NotBefore: now, NotAfter: now.Add(time.Hour)}.Validate())
+	require.ErrorIs(t, PolicyEntry{Policy: SendTx, NotBefore: now, NotAfter: now}.Validate(), errInvalidPolicyWindow)
+	require.ErrorIs(t, PolicyEntry{Policy: CallOnly}.Validate(), errMissingContracts)
+	require.ErrorIs(t, PolicyEntry{Policy: MaxValue}.Validate(), errMissingMaxValue)
+	require.ErrorIs(t, PolicyEntry{Policy: Policy(33)}.Validate(), errUnknownPolicy)
+}
+
+func TestPolicyEntryWindow(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	require.NoError(t, SetMode(ctx, db, AllowlistMode))
+	txPool := &TxPool{aclDB: db}
+
+	expired := common.HexToAddress("0x01")
+	pending := common.HexToAddress("0x02")
+	active := common.HexToAddress("0x03")
+
+	now := time.Now()
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", expired, PolicyEntry{Policy: SendTx, NotAfter: now.Add(-time.Minute)}))
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", pending, PolicyEntry{Policy: SendTx, NotBefore: now.Add(time.Hour)}))
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", active, PolicyEntry{Policy: SendTx, NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}))
+
+	for addr, want := range map[common.Address]bool{expired: false, pending: false, active: true} {
+		allowed, err := txPool.isActionAllowed(ctx, addr, SendTx)
+		require.NoError(t, err)
+		require.Equal(t, want, allowed, addr.Hex())
+	}
+
+	// adding the policy again without a window makes it permanent
+	require.NoError(t, AddPolicy(ctx, db, "allowlist", expired, SendTx))
+	allowed, err := txPool.isActionAllowed(ctx, expired, SendTx)
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
+
+func TestPolicyRestrictions(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	sender := common.HexToAddress("0x1234567890abcdef")
+	allowedContract := common.HexToAddress("0xc0ffee")
+	otherContract := common.HexToAddress("0xdead")
+
+	require.NoError(t, SetMode(ctx, db, AllowlistMode))
+	require.NoError(t, AddPolicy(ctx, db, "allowlist", sender, SendTx))
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", sender, PolicyEntry{Policy: CallOnly, Contracts: []common.Address{allowedContract}}))
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", sender, PolicyEntry{Policy: MaxValue, MaxValue: uint256.NewInt(100)}))
+
+	tests := []struct {
+		name     string
+		creation bool
+		to       common.Address
+		value    uint64
+		want     DiscardReason
+	}{
+		{"allowed call", false, allowedContract, 100, Success},
+		{"other contract", false, otherContract, 0, SenderDisallowedCallTarget},
+		{"contract creation", true, common.Address{}, 0, SenderDisallowedCallTarget},
+		{"value too high", false, allowedContract, 101, SenderExceededMaxValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := checkPolicyRestrictions(ctx, db, sender, tt.creation, tt.to, uint256.NewInt(tt.value))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, reason)
+		})
+	}
+
+	t.Run("restrictions do not apply to other addresses or other modes", func(t *testing.T) {
+		reason, err := checkPolicyRestrictions(ctx, db, common.HexToAddress("0x99"), false, otherContract, uint256.NewInt(1000))
+		require.NoError(t, err)
+		require.Equal(t, Success, reason)
+
+		require.NoError(t, SetMode(ctx, db, BlocklistMode))
+		reason, err = checkPolicyRestrictions(ctx, db, sender, false, otherContract, uint256.NewInt(1000))
+		require.NoError(t, err)
+		require.Equal(t, Success, reason)
+	})
+
+	t.Run("removing the policy lifts the restriction", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, AllowlistMode))
+		require.NoError(t, RemovePolicy(ctx, db, "allowlist", sender, CallOnly))
+
+		reason, err := checkPolicyRestrictions(ctx, db, sender, false, otherContract, uint256.NewInt(1))
+		require.NoError(t, err)
+		require.Equal(t, Success, reason)
+
+		hasPolicy, err := DoesAccountHavePolicy(ctx, db, sender, MaxValue)
+		require.NoError(t, err)
+		require.True(t, hasPolicy)
+	})
+}
+
+func TestPolicyEntryHistory(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	addr := common.HexToAddress("0x1234567890abcdef")
+	entry := PolicyEntry{
+		Policy:    CallOnly,
+		NotAfter:  time.Unix(1800000000, 0),
+		Contracts: []common.Address{common.HexToAddress("0xc0ffee")},
+	}
+	require.NoError(t, AddPolicyEntry(ctx, db, "allowlist", addr, entry))
+
+	pts, err := LastPolicyTransactions(ctx, db, 1)
+	require.NoError(t, err)
+	require.Len(t, pts, 1)
+	require.Equal(t, Add, pts[0].operation)
+	require.Equal(t, addr, pts[0].addr)
+	require.Equal(t, entry, pts[0].Entry())
+	require.Contains(t, pts[0].ToString(), "callOnly [0x0000000000000000000000000000000000C0FFEE] not after 2027-01-15T08:00:00Z")
+}
@@ -152,6 +152,8 @@ const (
 	SmartContractDeploymentDisabled DiscardReason = 28 // to == null not allowed, config set to block smart contract deployment
 	GasLimitTooHigh                 DiscardReason = 29 // gas limit is too high
 	Expired                         DiscardReason = 30 // used when a transaction is purged from the pool
+	SenderDisallowedCallTarget      DiscardReason = 31 // sender is restricted to calling other contracts by ACL policy
+	SenderExceededMaxValue          DiscardReason = 32 // transaction value is above the maximum allowed for the sender by ACL policy
 
 	// For X Layer
 	ReceiverDisallowedReceiveTx DiscardReason = 127 // receiver is not allowed to receive transactions
@@ -218,6 +220,10 @@ func (r DiscardReason) String() string {
 		return "You are not allowed to send transactions on the X Layer as we are under the phase 1, X layer will be open to the public soon"
 	case SenderDisallowedDeploy:
 		return "sender disallowed to deploy contract by ACL policy"
+	case SenderDisallowedCallTarget:
+		return "sender disallowed to call this address by ACL policy"
+	case SenderExceededMaxValue:
+		return "transaction value exceeds the maximum allowed for sender by ACL policy"
 	case DiscardByLimbo:
 		return "limbo error"
 	case SmartContractDeploymentDisabled:
@@ -343,6 +349,11 @@ type TxPool struct {
 	apolloCfg    ApolloConfig
 	gpCache      GPCache // GPCache will only work in sequencer node, without rpc node
 	freeGasAddrs map[string]bool
+	lanes        *Lanes // built from lanesCfg, the priority lanes configuration last seen
+	lanesCfg     []ethconfig.PriorityLane
+	quotas       *Quotas     // built from the throughput quotas configuration last seen
+	quotaUsage   *QuotaUsage // what the yields on top of quotaUsageOf used of the quotas
+	quotaUsageOf uint64
 
 	// we cannot be in a flushing state whilst getting transactions from the pool, so we have this mutex which is
 	// exposed publicly so anything wanting to get "best" transactions can ensure a flush isn't happening and
@@ -351,6 +362,9 @@ type TxPool struct {
 
 	// limbo specific fields where bad batch transactions identified by the executor go
 	limbo *Limbo
+
+	// bundles are kept apart from the sub pools and only ever yielded as a whole
+	bundles *bundlePool
 }
 
 func CreateTxPoolBuckets(tx kv.RwTx) error {
@@ -405,6 +419,7 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 		flushMtx:                &sync.Mutex{},
 		aclDB:                   aclDB,
 		limbo:                   newLimbo(),
+		bundles:                 newBundlePool(ethCfg.Zk.TxPoolBundleLimit, ethCfg.Zk.TxPoolBundleMaxTxs, ethCfg.Zk.TxPoolBundleLifetime),
 		// X Layer config
 		xlayerCfg: XLayerConfig{
 			EnableWhitelist:      ethCfg.DeprecatedTxPool.EnableWhitelist,
@@ -416,7 +431,10 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 			FreeGasExAddrs:       ethCfg.DeprecatedTxPool.FreeGasExAddrs,
 			FreeGasCountPerAddr:  ethCfg.DeprecatedTxPool.FreeGasCountPerAddr,
 			FreeGasLimit:         ethCfg.DeprecatedTxPool.FreeGasLimit,
-			EnableFreeGasList:    ethCfg.DeprecatedTxPool.EnableFreeGasList},
+			EnableFreeGasList:    ethCfg.DeprecatedTxPool.EnableFreeGasList,
+			PriorityLanes:        ethCfg.DeprecatedTxPool.PriorityLanes,
+			SenderQuotas:         ethCfg.DeprecatedTxPool.SenderQuotas,
+			ContractQuotas:       ethCfg.DeprecatedTxPool.ContractQuotas},
 		freeGasAddrs: map[string]bool{},
 	}
 	tp.setFreeGasList(ethCfg.DeprecatedTxPool.FreeGasList)
@@ -874,6 +892,14 @@ func (p *TxPool) validateTx(txn *types.TxSlot, isLocal bool, stateCache kvcache.
 		}
 	}
 
+	reason, err := p.isTxAllowedByRestrictions(context.TODO(), from, txn)
+	if err != nil {
+		panic(err)
+	}
+	if reason != Success {
+		return reason
+	}
+
 	return Success
 }
 
@@ -47,6 +47,11 @@ type XLayerConfig struct {
 	EnableFreeGasList  bool
 	FreeGasFromNameMap map[string]string                 // map[from]projectName
 	FreeGasList        map[string]*ethconfig.FreeGasInfo // map[projectName]FreeGasInfo
+	// PriorityLanes are the lanes block space is reserved for, by priority
+	PriorityLanes []ethconfig.PriorityLane
+	// SenderQuotas and ContractQuotas cap the throughput of senders and destination contracts, by address
+	SenderQuotas   map[string]ethconfig.ThroughputQuota
+	ContractQuotas map[string]ethconfig.ThroughputQuota
 }
 
 type GPCache interface {
@@ -84,6 +89,9 @@ type ApolloConfig interface {
 	CheckFreeClaimAddr(localFreeClaimGasAddrs common.OrderedList[common.Address], addr common.Address) bool
 	CheckFreeGasExAddr(localFreeGasExAddrs common.OrderedList[common.Address], addr common.Address) bool
 	GetEnableFreeGasList(localEnableFreeGasList bool) bool
+	GetPriorityLanes(localPriorityLanes []ethconfig.PriorityLane) []ethconfig.PriorityLane
+	GetSenderQuotas(localSenderQuotas map[string]ethconfig.ThroughputQuota) map[string]ethconfig.ThroughputQuota
+	GetContractQuotas(localContractQuotas map[string]ethconfig.ThroughputQuota) map[string]ethconfig.ThroughputQuota
 }
 
 // SetApolloConfig sets the apollo config with the node's apollo config
@@ -207,74 +207,120 @@ func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableG
 
 	p.pending.EnforceBestInvariants()
 
-	for i := 0; count < int(n) && i < len(best.ms); i++ {
-		// if we wouldn't have enough gas for a standard transaction then quit out early
-		if availableGas < fixedgas.TxGas {
-			break
+	// the lanes are yielded by priority, each within its share of the available gas
+	lanes := p.lanesLocked()
+	var txLanes []int
+	if lanes.Len() > 1 {
+		txLanes = p.txLanesLocked(lanes, best.ms)
+	}
+	gasBudget := lanes.GasBudget(availableGas)
+	if txLanes != nil {
+		// the lanes with nothing to yield leave their share to the others
+		pending := make([]bool, lanes.Len())
+		for i, mt := range best.ms {
+			if !toSkip.Contains(mt.Tx.IDHash) {
+				pending[txLanes[i]] = true
+			}
 		}
+		for lane := range pending {
+			gasBudget.SetPending(lane, pending[lane])
+		}
+	}
+	// the counters a transaction uses are only known to the sequencer, here the quotas go by intrinsic gas
+	quotaUsage := p.quotaUsageLocked(onTopOf)
+
+	for lane := 0; lane < lanes.Len(); lane++ {
+		yielded := count
+		for i := 0; count < int(n) && i < len(best.ms); i++ {
+			// if we wouldn't have enough gas for a standard transaction then quit out early
+			if availableGas < fixedgas.TxGas {
+				break
+			}
 
-		mt := best.ms[i]
-		//log.Trace("Processing transaction", "txID", mt.Tx.IDHash)
+			mt := best.ms[i]
+			//log.Trace("Processing transaction", "txID", mt.Tx.IDHash)
 
-		if toSkip.Contains(mt.Tx.IDHash) {
-			//log.Trace("Skipping transaction, already in toSkip", "txID", mt.Tx.IDHash)
-			continue
-		}
+			if txLanes != nil && txLanes[i] != lane {
+				continue
+			}
 
-		if !isLondon && mt.Tx.Type == 0x2 {
-			// remove ldn txs when not in london
-			toRemove = append(toRemove, mt)
-			toSkip.Add(mt.Tx.IDHash)
-			//log.Info("Removing London transaction in non-London environment", "txID", mt.Tx.IDHash)
-			continue
-		}
+			if toSkip.Contains(mt.Tx.IDHash) {
+				//log.Trace("Skipping transaction, already in toSkip", "txID", mt.Tx.IDHash)
+				continue
+			}
 
-		if mt.Tx.Gas > transactionGasLimit {
-			// Skip transactions with very large gas limit, these shouldn't enter the pool at all
-			//log.Debug("found a transaction in the pending pool with too high gas for tx - clear the tx pool")
-			//log.Trace("Skipping transaction with too high gas", "txID", mt.Tx.IDHash, "gas", mt.Tx.Gas)
-			continue
-		}
-		rlpTx, sender, isLocal, err := p.getRlpLocked(tx, mt.Tx.IDHash[:])
-		if err != nil {
-			//log.Trace("Error getting RLP of transaction", "txID", mt.Tx.IDHash, "error", err)
-			return false, count, err
-		}
-		if len(rlpTx) == 0 {
-			toRemove = append(toRemove, mt)
-			//log.Info("Removing transaction with empty RLP", "txID", common.BytesToHash(mt.Tx.IDHash[:]))
-			continue
-		}
+			if !isLondon && mt.Tx.Type == 0x2 {
+				// remove ldn txs when not in london
+				toRemove = append(toRemove, mt)
+				toSkip.Add(mt.Tx.IDHash)
+				//log.Info("Removing London transaction in non-London environment", "txID", mt.Tx.IDHash)
+				continue
+			}
 
-		// Skip transactions that require more blob gas than is available
-		blobCount := uint64(len(mt.Tx.BlobHashes))
-		if blobCount*fixedgas.BlobGasPerBlob > availableBlobGas {
-			//log.Trace("Skipping transaction due to insufficient blob gas", "txID", mt.Tx.IDHash, "requiredBlobGas", blobCount*fixedgas.BlobGasPerBlob, "availableBlobGas", availableBlobGas)
-			continue
-		}
-		availableBlobGas -= blobCount * fixedgas.BlobGasPerBlob
-
-		// make sure we have enough gas in the caller to add this transaction.
-		// not an exact science using intrinsic gas but as close as we could hope for at
-		// this stage
-		intrinsicGas, _ := CalcIntrinsicGas(uint64(mt.Tx.DataLen), uint64(mt.Tx.DataNonZeroLen), nil, mt.Tx.Creation, true, true, isShanghai)
-		if intrinsicGas > availableGas {
-			// we might find another TX with a low enough intrinsic gas to include so carry on
-			//log.Trace("Skipping transaction due to insufficient gas", "txID", mt.Tx.IDHash, "intrinsicGas", intrinsicGas, "availableGas", availableGas)
-			continue
-		}
+			if mt.Tx.Gas > transactionGasLimit {
+				// Skip transactions with very large gas limit, these shouldn't enter the pool at all
+				//log.Debug("found a transaction in the pending pool with too high gas for tx - clear the tx pool")
+				//log.Trace("Skipping transaction with too high gas", "txID", mt.Tx.IDHash, "gas", mt.Tx.Gas)
+				continue
+			}
+			rlpTx, sender, isLocal, err := p.getRlpLocked(tx, mt.Tx.IDHash[:])
+			if err != nil {
+				//log.Trace("Error getting RLP of transaction", "txID", mt.Tx.IDHash, "error", err)
+				return false, count, err
+			}
+			if len(rlpTx) == 0 {
+				toRemove = append(toRemove, mt)
+				//log.Info("Removing transaction with empty RLP", "txID", common.BytesToHash(mt.Tx.IDHash[:]))
+				continue
+			}
 
-		if intrinsicGas <= availableGas { // check for potential underflow
-			availableGas -= intrinsicGas
-		}
+			// Skip transactions that require more blob gas than is available
+			blobCount := uint64(len(mt.Tx.BlobHashes))
+			if blobCount*fixedgas.BlobGasPerBlob > availableBlobGas {
+				//log.Trace("Skipping transaction due to insufficient blob gas", "txID", mt.Tx.IDHash, "requiredBlobGas", blobCount*fixedgas.BlobGasPerBlob, "availableBlobGas", availableBlobGas)
+				continue
+			}
+			availableBlobGas -= blobCount * fixedgas.BlobGasPerBlob
+
+			// make sure we have enough gas in the caller to add this transaction.
+			// not an exact science using intrinsic gas but as close as we could hope for at
+			// this stage
+			intrinsicGas, _ := CalcIntrinsicGas(uint64(mt.Tx.DataLen), uint64(mt.Tx.DataNonZeroLen), nil, mt.Tx.Creation, true, true, isShanghai)
+			if intrinsicGas > availableGas || !gasBudget.Allows(lane, intrinsicGas) {
+				// we might find another TX with a low enough intrinsic gas to include so carry on
+				//log.Trace("Skipping transaction due to insufficient gas", "txID", mt.Tx.IDHash, "intrinsicGas", intrinsicGas, "availableGas", availableGas)
+				continue
+			}
+			var to *common.Address
+			if !mt.Tx.Creation {
+				to = &mt.Tx.To
+			}
+			if quotaUsage != nil {
+				if kind, resource, throttled := quotaUsage.Throttle(sender, to, intrinsicGas); throttled {
+					quotaThrottledCounter(kind, resource).Inc()
+					continue
+				}
+				quotaUsage.Use(sender, to, intrinsicGas, nil)
+			}
+			gasBudget.Use(lane, intrinsicGas)
+
+			if intrinsicGas <= availableGas { // check for potential underflow
+				availableGas -= intrinsicGas
+			}
 
-		//log.Trace("Including transaction", "txID", mt.Tx.IDHash)
-		txs.Txs[count] = rlpTx
-		txs.TxIds[count] = mt.Tx.IDHash
-		copy(txs.Senders.At(count), sender.Bytes())
-		txs.IsLocal[count] = isLocal
-		toSkip.Add(mt.Tx.IDHash)
-		count++
+			//log.Trace("Including transaction", "txID", mt.Tx.IDHash)
+			txs.Txs[count] = rlpTx
+			txs.TxIds[count] = mt.Tx.IDHash
+			copy(txs.Senders.At(count), sender.Bytes())
+			txs.IsLocal[count] = isLocal
+			toSkip.Add(mt.Tx.IDHash)
+			count++
+		}
+		if txLanes != nil {
+			laneYieldedCounter(lanes.Name(lane)).Add(count - yielded)
+		}
+		// the lane had its turn, the next ones can take what it left of its share
+		gasBudget.SetPending(lane, false)
 	}
 
 	txs.Resize(uint(count))
@@ -0,0 +1,296 @@
+package txpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcache"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/log/v3"
+)
+
+/*
+bundles are groups of transactions the sequencer includes all together, in order and contiguously in a single block, or
+not at all.  They are kept apart from the regular sub pools: their transactions are never yielded one by one but only
+as a whole through YieldBundles, and the sequencer reports back whether a bundle made it into a block.
+*/
+
+const (
+	BundlePending  BundleState = "pending"
+	BundleIncluded BundleState = "included"
+	BundleFailed   BundleState = "failed"
+	BundleExpired  BundleState = "expired"
+
+	// bundleStatusHistory is the number of included, failed or expired bundles whose status is remembered
+	bundleStatusHistory = 10_000
+)
+
+var (
+	ErrBundlesDisabled   = errors.New("bundles are disabled")
+	ErrBundleEmpty       = errors.New("bundle has no transactions")
+	ErrBundleTooLarge    = errors.New("bundle has too many transactions")
+	ErrBundlePoolFull    = errors.New("bundle pool is full")
+	ErrBundleKnown       = errors.New("bundle already known")
+	ErrBundleExpired     = errors.New("bundle max block number already passed")
+	ErrBundleDuplicateTx = errors.New("bundle contains the same transaction twice")
+
+	pendingBundlesGauge    = metrics.GetOrCreateGauge(`txpool_bundles_pending`)
+	includedBundlesCounter = metrics.GetOrCreateCounter(`txpool_bundles_included`)
+	failedBundlesCounter   = metrics.GetOrCreateCounter(`txpool_bundles_failed`)
+	expiredBundlesCounter  = metrics.GetOrCreateCounter(`txpool_bundles_expired`)
+)
+
+type BundleState string
+
+// Bundle is a group of transactions to be included all together and in order, or not at all
+type Bundle struct {
+	Hash     common.Hash
+	Txs      [][]byte // rlp of the transactions, in execution order
+	TxHashes []common.Hash
+	Senders  []common.Address
+	// MaxBlockNumber is the last block the bundle may be included in, 0 for no limit
+	MaxBlockNumber uint64
+	received       time.Time
+}
+
+// BundleStatus reports what happened to a bundle
+type BundleStatus struct {
+	Hash        common.Hash   `json:"hash"`
+	State       BundleState   `json:"state"`
+	TxHashes    []common.Hash `json:"txHashes"`
+	BlockNumber uint64        `json:"blockNumber,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// BundleHash is the hash a bundle of transactions is identified by, the keccak of the hashes of its transactions
+func BundleHash(txHashes []common.Hash) common.Hash {
+	data := make([]byte, 0, len(txHashes)*32)
+	for _, hash := range txHashes {
+		data = append(data, hash[:]...)
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+type bundlePool struct {
+	lock     sync.Mutex
+	limit    int
+	maxTxs   int
+	lifetime time.Duration
+
+	pending  []*Bundle // in arrival order, which is the order they are yielded in
+	byHash   map[common.Hash]*Bundle
+	statuses *simplelru.LRU[common.Hash, *BundleStatus]
+}
+
+func newBundlePool(limit, maxTxs int, lifetime time.Duration) *bundlePool {
+	statuses, _ := simplelru.NewLRU[common.Hash, *BundleStatus](bundleStatusHistory, nil)
+	return &bundlePool{
+		limit:    limit,
+		maxTxs:   maxTxs,
+		lifetime: lifetime,
+		byHash:   make(map[common.Hash]*Bundle),
+		statuses: statuses,
+	}
+}
+
+func (bp *bundlePool) add(bundle *Bundle) error {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+
+	if _, ok := bp.byHash[bundle.Hash]; ok {
+		return ErrBundleKnown
+	}
+	if status, ok := bp.statuses.Peek(bundle.Hash); ok && status.State == BundleIncluded {
+		return ErrBundleKnown
+	}
+	bp.expireLocked(0, bundle.received)
+	if len(bp.pending) >= bp.limit {
+		return ErrBundlePoolFull
+	}
+
+	bp.pending = append(bp.pending, bundle)
+	bp.byHash[bundle.Hash] = bundle
+	bp.statuses.Remove(bundle.Hash)
+	pendingBundlesGauge.SetInt(len(bp.pending))
+	return nil
+}
+
+// yield returns the pending bundles that may still be included in the block, after dropping the expired ones
+func (bp *bundlePool) yield(blockNumber uint64, now time.Time) []*Bundle {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+
+	bp.expireLocked(blockNumber, now)
+	bundles := make([]*Bundle, len(bp.pending))
+	copy(bundles, bp.pending)
+	return bundles
+}
+
+// expireLocked drops the bundles that outlived their lifetime or, when the block number is known, their max block number
+func (bp *bundlePool) expireLocked(blockNumber uint64, now time.Time) {
+	kept := bp.pending[:0]
+	for _, bundle := range bp.pending {
+		expired := (bp.lifetime > 0 && now.Sub(bundle.received) > bp.lifetime) ||
+			(blockNumber != 0 && bundle.MaxBlockNumber != 0 && blockNumber > bundle.MaxBlockNumber)
+		if !expired {
+			kept = append(kept, bundle)
+			continue
+		}
+		delete(bp.byHash, bundle.Hash)
+		bp.statuses.Add(bundle.Hash, &BundleStatus{Hash: bundle.Hash, State: BundleExpired, TxHashes: bundle.TxHashes})
+		expiredBundlesCounter.Inc()
+	}
+	clear(bp.pending[len(kept):])
+	bp.pending = kept
+	pendingBundlesGauge.SetInt(len(bp.pending))
+}
+
+func (bp *bundlePool) finish(hash common.Hash, status *BundleStatus) bool {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+
+	bundle, ok := bp.byHash[hash]
+	if !ok {
+		return false
+	}
+	delete(bp.byHash, hash)
+	for i, b := range bp.pending {
+		if b == bundle {
+			bp.pending = append(bp.pending[:i], bp.pending[i+1:]...)
+			break
+		}
+	}
+	status.TxHashes = bundle.TxHashes
+	bp.statuses.Add(hash, status)
+	pendingBundlesGauge.SetInt(len(bp.pending))
+	return true
+}
+
+func (bp *bundlePool) status(hash common.Hash) (*BundleStatus, bool) {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+
+	if bundle, ok := bp.byHash[hash]; ok {
+		return &BundleStatus{Hash: hash, State: BundlePending, TxHashes: bundle.TxHashes}, true
+	}
+	return bp.statuses.Get(hash)
+}
+
+// AddBundle validates the transactions of a bundle as local transactions and queues the bundle for the sequencer.
+// The nonces are only checked against the state, a bundle can hold several transactions of a sender.
+func (p *TxPool) AddBundle(ctx context.Context, rlpTxs [][]byte, maxBlockNumber uint64) (common.Hash, error) {
+	if p.bundles.limit <= 0 {
+		return common.Hash{}, ErrBundlesDisabled
+	}
+	if len(rlpTxs) == 0 {
+		return common.Hash{}, ErrBundleEmpty
+	}
+	if len(rlpTxs) > p.bundles.maxTxs {
+		return common.Hash{}, fmt.Errorf("%w: %d, max %d", ErrBundleTooLarge, len(rlpTxs), p.bundles.maxTxs)
+	}
+	if maxBlockNumber != 0 && maxBlockNumber <= p.lastSeenBlock.Load() {
+		return common.Hash{}, ErrBundleExpired
+	}
+
+	parseCtx := types.NewTxParseContext(p.chainID).ChainIDRequired()
+	parseCtx.ValidateRLP(p.ValidateSerializedTxn)
+
+	var slots types.TxSlots
+	slots.Resize(uint(len(rlpTxs)))
+	bundle := &Bundle{
+		Txs:            rlpTxs,
+		TxHashes:       make([]common.Hash, len(rlpTxs)),
+		Senders:        make([]common.Address, len(rlpTxs)),
+		MaxBlockNumber: maxBlockNumber,
+		received:       time.Now(),
+	}
+	seen := make(map[common.Hash]struct{}, len(rlpTxs))
+	for i, rlpTx := range rlpTxs {
+		slots.Txs[i] = &types.TxSlot{}
+		if _, err := parseCtx.ParseTransaction(rlpTx, 0, slots.Txs[i], slots.Senders.At(i), false /* hasEnvelope */, false, nil); err != nil {
+			return common.Hash{}, fmt.Errorf("bundle transaction %d: %w", i, err)
+		}
+		slots.IsLocal[i] = true
+		bundle.TxHashes[i] = slots.Txs[i].IDHash
+		bundle.Senders[i] = slots.Senders.AddressAt(i)
+		if _, ok := seen[bundle.TxHashes[i]]; ok {
+			return common.Hash{}, ErrBundleDuplicateTx
+		}
+		seen[bundle.TxHashes[i]] = struct{}{}
+	}
+	bundle.Hash = BundleHash(bundle.TxHashes)
+
+	coreTx, err := p.coreDB().BeginRo(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer coreTx.Rollback()
+
+	cacheView, err := p.cache().View(ctx, coreTx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if err = p.validateBundle(&slots, cacheView); err != nil {
+		return common.Hash{}, err
+	}
+
+	if err = p.bundles.add(bundle); err != nil {
+		return common.Hash{}, err
+	}
+	log.Debug("[txpool] Bundle added", "hash", bundle.Hash, "txs", len(bundle.Txs), "maxBlockNumber", maxBlockNumber)
+
+	return bundle.Hash, nil
+}
+
+func (p *TxPool) validateBundle(slots *types.TxSlots, cacheView kvcache.CacheView) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.Started() {
+		return errors.New("txpool is not started yet")
+	}
+	if err := p.senders.registerNewSenders(slots); err != nil {
+		return err
+	}
+	for i, txn := range slots.Txs {
+		if reason := p.validateTx(txn, true, cacheView, slots.Senders.AddressAt(i)); reason != Success {
+			return fmt.Errorf("bundle transaction %d (%x): %s", i, txn.IDHash, reason)
+		}
+	}
+	return nil
+}
+
+// YieldBundles returns the bundles the sequencer can try to include in the block, in arrival order
+func (p *TxPool) YieldBundles(blockNumber uint64) []*Bundle {
+	if p.isDeniedYieldingTransactions() {
+		return nil
+	}
+	return p.bundles.yield(blockNumber, time.Now())
+}
+
+// MarkBundleIncluded removes a bundle the sequencer included in a block
+func (p *TxPool) MarkBundleIncluded(hash common.Hash, blockNumber uint64) {
+	if p.bundles.finish(hash, &BundleStatus{Hash: hash, State: BundleIncluded, BlockNumber: blockNumber}) {
+		includedBundlesCounter.Inc()
+	}
+}
+
+// MarkBundleFailed removes a bundle the sequencer could not include, as one of its transactions reverted or failed
+func (p *TxPool) MarkBundleFailed(hash common.Hash, reason error) {
+	if p.bundles.finish(hash, &BundleStatus{Hash: hash, State: BundleFailed, Error: reason.Error()}) {
+		failedBundlesCounter.Inc()
+	}
+}
+
+// BundleStatus returns the status of a pending bundle or of one of the last finished ones
+func (p *TxPool) BundleStatus(hash common.Hash) (*BundleStatus, bool) {
+	return p.bundles.status(hash)
+}
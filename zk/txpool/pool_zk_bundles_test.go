@@ -0,0 +1,123 @@
+package txpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/stretchr/testify/require"
+)
+
+func testBundle(seed byte, maxBlockNumber uint64, received time.Time) *Bundle {
+	txHashes := []common.Hash{{seed, 1}, {seed, 2}}
+	return &Bundle{
+		Hash:           BundleHash(txHashes),
+		Txs:            [][]byte{{seed, 1}, {seed, 2}},
+		TxHashes:       txHashes,
+		Senders:        []common.Address{{seed}, {seed}},
+		MaxBlockNumber: maxBlockNumber,
+		received:       received,
+	}
+}
+
+func TestBundleHash(t *testing.T) {
+	a, b := common.Hash{1}, common.Hash{2}
+
+	require.Equal(t, BundleHash([]common.Hash{a, b}), BundleHash([]common.Hash{a, b}))
+	require.NotEqual(t, BundleHash([]common.Hash{a, b}), BundleHash([]common.Hash{b, a}))
+	require.NotEqual(t, BundleHash([]common.Hash{a}), BundleHash([]common.Hash{a, b}))
+}
+
+func TestBundlePool(t *testing.T) {
+	now := time.Now()
+
+	t.Run("yields in arrival order", func(t *testing.T) {
+		bp := newBundlePool(10, 16, time.Minute)
+		first, second := testBundle(1, 0, now), testBundle(2, 0, now)
+		require.NoError(t, bp.add(first))
+		require.NoError(t, bp.add(second))
+
+		require.Equal(t, []*Bundle{first, second}, bp.yield(100, now))
+		// yielding does not remove the bundles
+		require.Len(t, bp.yield(100, now), 2)
+	})
+
+	t.Run("rejects duplicates and a full pool", func(t *testing.T) {
+		bp := newBundlePool(1, 16, time.Minute)
+		require.NoError(t, bp.add(testBundle(1, 0, now)))
+		require.ErrorIs(t, bp.add(testBundle(1, 0, now)), ErrBundleKnown)
+		require.ErrorIs(t, bp.add(testBundle(2, 0, now)), ErrBundlePoolFull)
+	})
+
+	t.Run("expires on max block number and lifetime", func(t *testing.T) {
+		bp := newBundlePool(10, 16, time.Minute)
+		byBlock, byTime, kept := testBundle(1, 10, now), testBundle(2, 0, now.Add(-2*time.Minute)), testBundle(3, 11, now)
+		require.NoError(t, bp.add(byBlock))
+		require.NoError(t, bp.add(byTime))
+		require.NoError(t, bp.add(kept))
+
+		require.Equal(t, []*Bundle{kept}, bp.yield(11, now))
+
+		for _, bundle := range []*Bundle{byBlock, byTime} {
+			status, ok := bp.status(bundle.Hash)
+			require.True(t, ok)
+			require.Equal(t, BundleExpired, status.State)
+		}
+	})
+
+	t.Run("reports the status of finished bundles", func(t *testing.T) {
+		bp := newBundlePool(10, 16, time.Minute)
+		included, failed := testBundle(1, 0, now), testBundle(2, 0, now)
+		require.NoError(t, bp.add(included))
+		require.NoError(t, bp.add(failed))
+
+		status, ok := bp.status(included.Hash)
+		require.True(t, ok)
+		require.Equal(t, BundlePending, status.State)
+
+		require.True(t, bp.finish(included.Hash, &BundleStatus{Hash: included.Hash, State: BundleIncluded, BlockNumber: 7}))
+		require.True(t, bp.finish(failed.Hash, &BundleStatus{Hash: failed.Hash, State: BundleFailed, Error: "reverted"}))
+		require.False(t, bp.finish(failed.Hash, &BundleStatus{Hash: failed.Hash, State: BundleFailed}))
+		require.Empty(t, bp.yield(100, now))
+
+		status, ok = bp.status(included.Hash)
+		require.True(t, ok)
+		require.Equal(t, &BundleStatus{Hash: included.Hash, State: BundleIncluded, TxHashes: included.TxHashes, BlockNumber: 7}, status)
+
+		status, ok = bp.status(failed.Hash)
+		require.True(t, ok)
+		require.Equal(t, BundleFailed, status.State)
+		require.Equal(t, "reverted", status.Error)
+
+		// an included bundle cannot be submitted again, a failed one can
+		require.ErrorIs(t, bp.add(testBundle(1, 0, now)), ErrBundleKnown)
+		require.NoError(t, bp.add(testBundle(2, 0, now)))
+	})
+
+	t.Run("unknown bundle", func(t *testing.T) {
+		bp := newBundlePool(10, 16, time.Minute)
+		_, ok := bp.status(common.Hash{1})
+		require.False(t, ok)
+	})
+}
+
+func TestAddBundleLimits(t *testing.T) {
+	ctx := context.Background()
+
+	disabled := &TxPool{bundles: newBundlePool(0, 16, time.Minute)}
+	_, err := disabled.AddBundle(ctx, [][]byte{{1}}, 0)
+	require.ErrorIs(t, err, ErrBundlesDisabled)
+
+	p := &TxPool{bundles: newBundlePool(10, 2, time.Minute)}
+	_, err = p.AddBundle(ctx, nil, 0)
+	require.ErrorIs(t, err, ErrBundleEmpty)
+
+	_, err = p.AddBundle(ctx, [][]byte{{1}, {2}, {3}}, 0)
+	require.True(t, errors.Is(err, ErrBundleTooLarge))
+
+	p.lastSeenBlock.Store(10)
+	_, err = p.AddBundle(ctx, [][]byte{{1}}, 10)
+	require.ErrorIs(t, err, ErrBundleExpired)
+}
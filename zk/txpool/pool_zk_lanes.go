@@ -0,0 +1,234 @@
+package txpool
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+)
+
+/*
+priority lanes reserve a share of the block space to some transactions, e.g. the bridge claims we subsidise, so that
+they do not wait behind spam during congestion.  A transaction goes to the first lane it matches, or to the public lane
+when it matches none.  A lane can use the share reserved to it plus whatever is reserved to no lane, the lanes taking
+from the unreserved share by priority, so the public lane only gets what the others left of it.  The reservations are
+work-conserving: a lane with no transaction pending leaves what is left of its share to the others.
+
+The pool yields the lanes in priority order, each within its share of the gas available to the yield.  The sequencer
+then keeps track of the block gas and batch counters each lane used, holding a lane back once its budget is spent.
+*/
+
+// Lanes is a snapshot of the priority lanes configuration, the public lane coming last
+type Lanes struct {
+	lanes         []lane
+	isClaim       func(common.Address) bool
+	isWhitelisted func(common.Address) bool
+}
+
+type lane struct {
+	name         string
+	gasShare     float64
+	counterShare float64
+	claims       bool
+	whitelist    bool
+	from         map[common.Address]struct{}
+	to           map[common.Address]struct{}
+}
+
+func newLanes(cfg []ethconfig.PriorityLane, isClaim, isWhitelisted func(common.Address) bool) *Lanes {
+	lanes := &Lanes{
+		lanes:         make([]lane, 0, len(cfg)+1),
+		isClaim:       isClaim,
+		isWhitelisted: isWhitelisted,
+	}
+	for _, c := range cfg {
+		l := lane{
+			name:         c.Name,
+			gasShare:     c.GasShare,
+			counterShare: c.CounterShare,
+			claims:       c.Claims,
+			whitelist:    c.Whitelist,
+			from:         make(map[common.Address]struct{}, len(c.FromList)),
+			to:           make(map[common.Address]struct{}, len(c.ToList)),
+		}
+		for _, addr := range c.FromList {
+			l.from[common.HexToAddress(addr)] = struct{}{}
+		}
+		for _, addr := range c.ToList {
+			l.to[common.HexToAddress(addr)] = struct{}{}
+		}
+		lanes.lanes = append(lanes.lanes, l)
+	}
+	lanes.lanes = append(lanes.lanes, lane{name: ethconfig.PublicLane})
+	return lanes
+}
+
+// Len is the number of lanes, the public one included
+func (l *Lanes) Len() int {
+	return len(l.lanes)
+}
+
+func (l *Lanes) Name(lane int) string {
+	return l.lanes[lane].name
+}
+
+// Classify returns the lane of a transaction, to is nil for a contract creation
+func (l *Lanes) Classify(sender common.Address, to *common.Address) int {
+	public := len(l.lanes) - 1
+	for i, lane := range l.lanes[:public] {
+		if _, ok := lane.from[sender]; ok {
+			return i
+		}
+		if to != nil {
+			if _, ok := lane.to[*to]; ok {
+				return i
+			}
+		}
+		if lane.claims && l.isClaim(sender) {
+			return i
+		}
+		if lane.whitelist && l.isWhitelisted(sender) {
+			return i
+		}
+	}
+	return public
+}
+
+// GasBudget splits a gas limit between the lanes
+func (l *Lanes) GasBudget(limit uint64) *LaneBudget {
+	return l.budget(limit, func(lane *lane) float64 { return lane.gasShare })
+}
+
+// CounterBudget splits the limit of a zk counter between the lanes
+func (l *Lanes) CounterBudget(limit uint64) *LaneBudget {
+	return l.budget(limit, func(lane *lane) float64 { return lane.counterShare })
+}
+
+func (l *Lanes) budget(limit uint64, share func(*lane) float64) *LaneBudget {
+	b := &LaneBudget{
+		reserved: make([]uint64, len(l.lanes)),
+		used:     make([]uint64, len(l.lanes)),
+		pending:  make([]bool, len(l.lanes)),
+		limit:    limit,
+	}
+	shared := limit
+	for i := range l.lanes {
+		b.reserved[i] = min(uint64(float64(limit)*share(&l.lanes[i])), shared)
+		shared -= b.reserved[i]
+		b.pending[i] = true
+	}
+	return b
+}
+
+// LaneBudget tracks what each lane used of a resource against the share of it reserved to the lane
+type LaneBudget struct {
+	reserved []uint64
+	used     []uint64
+	pending  []bool // the lanes with transactions waiting, all of them until told otherwise
+	limit    uint64
+}
+
+// SetPending tells whether a lane has transactions waiting, one without any leaves what is left of its reserved share
+// to the others
+func (b *LaneBudget) SetPending(lane int, pending bool) {
+	b.pending[lane] = pending
+}
+
+// Allows tells whether a lane can use the amount out of its reserved share and what is left of the rest once the
+// other lanes with transactions pending got what is left of their own share
+func (b *LaneBudget) Allows(lane int, amount uint64) bool {
+	if amount <= b.reservedLeft(lane) {
+		return true
+	}
+	var used uint64
+	for i := range b.used {
+		used += b.used[i]
+	}
+	left := b.limit - min(used, b.limit)
+	for i := range b.reserved {
+		if i != lane && b.pending[i] {
+			left -= min(b.reservedLeft(i), left)
+		}
+	}
+	return amount <= left
+}
+
+func (b *LaneBudget) reservedLeft(lane int) uint64 {
+	return b.reserved[lane] - min(b.used[lane], b.reserved[lane])
+}
+
+// HasRoom tells whether a lane has any of the resource left, for when the amount is only known after the fact
+func (b *LaneBudget) HasRoom(lane int) bool {
+	return b.Allows(lane, 1)
+}
+
+func (b *LaneBudget) Use(lane int, amount uint64) {
+	b.used[lane] += amount
+}
+
+// Lanes returns the current priority lanes, reloaded when their configuration changed through Apollo
+func (p *TxPool) Lanes() *Lanes {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.lanesLocked()
+}
+
+func (p *TxPool) lanesLocked() *Lanes {
+	cfg := p.xlayerCfg.PriorityLanes
+	if p.apolloCfg != nil {
+		cfg = p.apolloCfg.GetPriorityLanes(cfg)
+	}
+	// a configuration change always comes as a new slice
+	if p.lanes == nil || len(cfg) != len(p.lanesCfg) || (len(cfg) > 0 && &cfg[0] != &p.lanesCfg[0]) {
+		p.lanes = newLanes(cfg, p.isClaimSender, p.isWhitelistedSender)
+		p.lanesCfg = cfg
+	}
+	return p.lanes
+}
+
+func (p *TxPool) isClaimSender(addr common.Address) bool {
+	if p.apolloCfg == nil {
+		return p.xlayerCfg.FreeClaimGasAddrs.Contains(addr)
+	}
+	return p.apolloCfg.CheckFreeClaimAddr(p.xlayerCfg.FreeClaimGasAddrs, addr)
+}
+
+func (p *TxPool) isWhitelistedSender(addr common.Address) bool {
+	if p.apolloCfg == nil {
+		return p.xlayerCfg.WhiteList.Contains(addr)
+	}
+	return p.apolloCfg.CheckWhitelistAddr(p.xlayerCfg.WhiteList, addr)
+}
+
+// txLanesLocked classifies the best transactions.  A transaction cannot go ahead of the lower nonces of its sender, so
+// it falls to the lowest priority lane of them.
+func (p *TxPool) txLanesLocked(lanes *Lanes, ms []*metaTx) []int {
+	txLanes := make([]int, len(ms))
+	bySender := make(map[uint64][]int)
+	for i, mt := range ms {
+		var to *common.Address
+		if !mt.Tx.Creation {
+			to = &mt.Tx.To
+		}
+		txLanes[i] = lanes.Classify(p.senders.senderID2Addr[mt.Tx.SenderID], to)
+		bySender[mt.Tx.SenderID] = append(bySender[mt.Tx.SenderID], i)
+	}
+	for _, idxs := range bySender {
+		if len(idxs) == 1 {
+			continue
+		}
+		sort.Slice(idxs, func(a, b int) bool { return ms[idxs[a]].Tx.Nonce < ms[idxs[b]].Tx.Nonce })
+		lowest := 0
+		for _, i := range idxs {
+			lowest = max(lowest, txLanes[i])
+			txLanes[i] = lowest
+		}
+	}
+	return txLanes
+}
+
+func laneYieldedCounter(name string) *metrics.Counter {
+	return metrics.GetOrCreateCounter(fmt.Sprintf(`txpool_lane_yielded{lane=%q}`, name))
+}
@@ -0,0 +1,148 @@
+package txpool
+
+import (
+	"sync"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+)
+
+var (
+	claimer  = common.Address{1}
+	operator = common.Address{2}
+	project  = common.Address{3}
+	user     = common.Address{4}
+)
+
+func testLanes() *Lanes {
+	return newLanes([]ethconfig.PriorityLane{
+		{Name: "claims", GasShare: 0.2, CounterShare: 0.2, Claims: true},
+		{Name: "operators", GasShare: 0.1, FromList: []string{operator.Hex()}},
+		{Name: "projects", GasShare: 0.1, ToList: []string{project.Hex()}},
+	}, func(addr common.Address) bool { return addr == claimer }, func(common.Address) bool { return false })
+}
+
+func TestLanesClassify(t *testing.T) {
+	lanes := testLanes()
+	require.Equal(t, 4, lanes.Len())
+	require.Equal(t, ethconfig.PublicLane, lanes.Name(3))
+
+	require.Equal(t, 0, lanes.Classify(claimer, &user))
+	require.Equal(t, 1, lanes.Classify(operator, &user))
+	require.Equal(t, 2, lanes.Classify(user, &project))
+	require.Equal(t, 3, lanes.Classify(user, &user))
+	require.Equal(t, 3, lanes.Classify(user, nil))
+	// the first lane matched wins
+	require.Equal(t, 0, lanes.Classify(claimer, &project))
+
+	require.Equal(t, 1, newLanes(nil, nil, nil).Len())
+}
+
+func TestLaneBudget(t *testing.T) {
+	// claims: 200 reserved, operators: 100, projects: 100, shared: 600
+	budget := testLanes().GasBudget(1000)
+
+	// the public lane only gets the unreserved share
+	require.True(t, budget.Allows(3, 600))
+	require.False(t, budget.Allows(3, 601))
+	budget.Use(3, 500)
+
+	// a lane gets its reserved share and what is left of the unreserved one
+	require.True(t, budget.Allows(0, 300))
+	require.False(t, budget.Allows(0, 301))
+	budget.Use(0, 300)
+	require.False(t, budget.HasRoom(3))
+	require.False(t, budget.HasRoom(0))
+
+	// the others still have their reserved share
+	require.True(t, budget.Allows(1, 100))
+	require.False(t, budget.Allows(1, 101))
+	require.True(t, budget.Allows(2, 100))
+}
+
+func TestLaneBudgetWorkConserving(t *testing.T) {
+	budget := testLanes().GasBudget(1000)
+
+	// the share of the claims lane goes to the others while it has nothing pending
+	budget.SetPending(0, false)
+	require.True(t, budget.Allows(3, 800))
+	require.False(t, budget.Allows(3, 801))
+	budget.Use(3, 750)
+
+	// and it gets back what is left of it once it has
+	budget.SetPending(0, true)
+	require.True(t, budget.Allows(0, 200))
+	require.False(t, budget.Allows(0, 201))
+	require.False(t, budget.HasRoom(3))
+
+	// a lane without anything pending does not hold on to what is left of its share
+	budget.SetPending(1, false)
+	budget.SetPending(2, false)
+	require.True(t, budget.Allows(3, 50))
+	require.False(t, budget.Allows(3, 51))
+}
+
+func TestBestLanesWorkConserving(t *testing.T) {
+	p := &TxPool{
+		byHash:   map[string]*metaTx{},
+		senders:  newSendersCache(nil),
+		pending:  NewPendingSubPool(PendingSubPool, 10),
+		limbo:    newLimbo(),
+		lock:     &sync.Mutex{},
+		flushMtx: &sync.Mutex{},
+		xlayerCfg: XLayerConfig{PriorityLanes: []ethconfig.PriorityLane{
+			{Name: "operators", GasShare: 0.5, FromList: []string{operator.Hex()}},
+		}},
+	}
+	p.senders.senderID2Addr[1] = user
+	p.senders.senderID2Addr[2] = operator
+	p.lastSeenBlock.Store(1)
+	add := func(senderID, nonce uint64) {
+		mt := &metaTx{Tx: &types.TxSlot{SenderID: senderID, Nonce: nonce, Gas: 21_000, To: project, Rlp: []byte{byte(senderID), byte(nonce)}}, currentSubPool: PendingSubPool}
+		mt.Tx.IDHash[0], mt.Tx.IDHash[1] = byte(senderID), byte(nonce+1)
+		p.byHash[string(mt.Tx.IDHash[:])] = mt
+		p.pending.Add(mt)
+	}
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		add(1, nonce)
+	}
+
+	yield := func() types.TxsRlp {
+		var txs types.TxsRlp
+		_, _, err := p.best(10, &txs, nil, 1, 100_000, 0, mapset.NewSet[[32]byte]())
+		require.NoError(t, err)
+		return txs
+	}
+
+	// the public transactions take the whole gas while the operators have nothing pending, not just the unreserved half
+	require.Len(t, yield().Txs, 4)
+
+	// the operators go first once they have, the public transactions then get what they left
+	add(2, 0)
+	txs := yield()
+	require.Len(t, txs.Txs, 4)
+	require.Equal(t, operator, txs.Senders.AddressAt(0))
+}
+
+func TestTxLanesKeepNonceOrder(t *testing.T) {
+	p := &TxPool{senders: newSendersCache(nil)}
+	p.senders.senderID2Addr[1] = user
+	p.senders.senderID2Addr[2] = claimer
+
+	mt := func(senderID, nonce uint64, to common.Address) *metaTx {
+		return &metaTx{Tx: &types.TxSlot{SenderID: senderID, Nonce: nonce, To: to}}
+	}
+	ms := []*metaTx{
+		mt(1, 1, project), // behind a public transaction of its sender
+		mt(1, 0, user),
+		mt(2, 0, user),
+		mt(1, 2, project),
+	}
+
+	require.Equal(t, []int{3, 3, 0, 3}, p.txLanesLocked(testLanes(), ms))
+}
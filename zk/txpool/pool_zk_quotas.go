@@ -0,0 +1,249 @@
+package txpool
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+)
+
+/*
+throughput quotas cap what a single sender or destination contract gets of the sequencer: the transactions it has in a
+block, the gas it uses in a batch and its share of each batch zk counter, so that one hot account or contract cannot
+crowd out the others.  The * quota applies to every address without a quota of its own, each on its own.
+
+The pool holds back the transactions over the quotas, on the transaction count and intrinsic gas of what it yielded
+on top of the same block so far.  The sequencer yields every block of a batch on top of the block the batch started
+from and tells the pool with StartQuotaBlock when it starts the next one, the pool gas quota thus goes by batch as the
+sequencer one.  The sequencer also tracks the actual gas and counters used in the block and batch.  A sender held back
+stays held back for the rest of the block as its next transactions would fail on the nonce.
+*/
+
+const (
+	QuotaSender   = "sender"
+	QuotaContract = "contract"
+
+	QuotaTxs      = "txs"
+	QuotaGas      = "gas"
+	QuotaCounters = "counters"
+)
+
+// Quotas is a snapshot of the throughput quotas configuration
+type Quotas struct {
+	senders      map[common.Address]ethconfig.ThroughputQuota
+	contracts    map[common.Address]ethconfig.ThroughputQuota
+	anySender    *ethconfig.ThroughputQuota
+	anyContract  *ethconfig.ThroughputQuota
+	sendersCfg   map[string]ethconfig.ThroughputQuota
+	contractsCfg map[string]ethconfig.ThroughputQuota
+}
+
+func newQuotas(senders, contracts map[string]ethconfig.ThroughputQuota) *Quotas {
+	q := &Quotas{sendersCfg: senders, contractsCfg: contracts}
+	q.senders, q.anySender = parseQuotas(senders)
+	q.contracts, q.anyContract = parseQuotas(contracts)
+	return q
+}
+
+func parseQuotas(cfg map[string]ethconfig.ThroughputQuota) (map[common.Address]ethconfig.ThroughputQuota, *ethconfig.ThroughputQuota) {
+	quotas := make(map[common.Address]ethconfig.ThroughputQuota, len(cfg))
+	var anyAddress *ethconfig.ThroughputQuota
+	for addr, quota := range cfg {
+		if addr == ethconfig.AnyAddressQuota {
+			anyQuota := quota
+			anyAddress = &anyQuota
+			continue
+		}
+		quotas[common.HexToAddress(addr)] = quota
+	}
+	return quotas, anyAddress
+}
+
+// Empty tells whether no quota is configured
+func (q *Quotas) Empty() bool {
+	return len(q.senders) == 0 && len(q.contracts) == 0 && q.anySender == nil && q.anyContract == nil
+}
+
+func (q *Quotas) sender(addr common.Address) (ethconfig.ThroughputQuota, bool) {
+	if quota, ok := q.senders[addr]; ok {
+		return quota, true
+	}
+	if q.anySender != nil {
+		return *q.anySender, true
+	}
+	return ethconfig.ThroughputQuota{}, false
+}
+
+// contract returns the quota of a destination, to is nil for a contract creation
+func (q *Quotas) contract(to *common.Address) (ethconfig.ThroughputQuota, bool) {
+	if to == nil {
+		return ethconfig.ThroughputQuota{}, false
+	}
+	if quota, ok := q.contracts[*to]; ok {
+		return quota, true
+	}
+	if q.anyContract != nil {
+		return *q.anyContract, true
+	}
+	return ethconfig.ThroughputQuota{}, false
+}
+
+// NewUsage starts tracking the quotas of a batch, counterLimits being the limits of the batch counters by counter key
+// or nil when the counters are not tracked.  It returns nil when no quota is configured.
+func (q *Quotas) NewUsage(counterLimits []int) *QuotaUsage {
+	if q.Empty() {
+		return nil
+	}
+	return &QuotaUsage{
+		quotas:        q,
+		counterLimits: counterLimits,
+		senders:       make(map[common.Address]*quotaUse),
+		contracts:     make(map[common.Address]*quotaUse),
+		held:          make(map[common.Address]struct{}),
+	}
+}
+
+// QuotaUsage tracks what the senders and destination contracts used of their quotas in a block and batch
+type QuotaUsage struct {
+	quotas        *Quotas
+	counterLimits []int
+	senders       map[common.Address]*quotaUse
+	contracts     map[common.Address]*quotaUse
+	held          map[common.Address]struct{} // the senders held back in the block
+}
+
+type quotaUse struct {
+	blockTxs uint64
+	batchGas uint64
+	counters []int
+}
+
+// StartBlock resets what is tracked per block
+func (u *QuotaUsage) StartBlock() {
+	for _, use := range u.senders {
+		use.blockTxs = 0
+	}
+	for _, use := range u.contracts {
+		use.blockTxs = 0
+	}
+	clear(u.held)
+}
+
+// Throttle tells whether a transaction goes over the quota of its sender or of its destination contract, and which
+// quota and resource it goes over.  gas is the gas the transaction is expected to use, 0 when it is not known yet in
+// which case only a spent gas quota throttles it.
+func (u *QuotaUsage) Throttle(sender common.Address, to *common.Address, gas uint64) (kind, resource string, throttled bool) {
+	if _, held := u.held[sender]; held {
+		return QuotaSender, "held", true
+	}
+
+	if quota, ok := u.quotas.sender(sender); ok {
+		if resource = u.exceeds(quota, u.senders[sender], gas); resource != "" {
+			kind = QuotaSender
+		}
+	}
+	if resource == "" {
+		if quota, ok := u.quotas.contract(to); ok {
+			if resource = u.exceeds(quota, u.contracts[*to], gas); resource != "" {
+				kind = QuotaContract
+			}
+		}
+	}
+	if resource == "" {
+		return "", "", false
+	}
+
+	u.held[sender] = struct{}{}
+	return kind, resource, true
+}
+
+// exceeds returns the resource one more transaction would go over the quota of, if any
+func (u *QuotaUsage) exceeds(quota ethconfig.ThroughputQuota, use *quotaUse, gas uint64) string {
+	if use == nil {
+		use = &quotaUse{}
+	}
+	if quota.MaxTxsPerBlock > 0 && use.blockTxs >= quota.MaxTxsPerBlock {
+		return QuotaTxs
+	}
+	if quota.MaxGasPerBatch > 0 && (use.batchGas >= quota.MaxGasPerBatch || use.batchGas+gas > quota.MaxGasPerBatch) {
+		return QuotaGas
+	}
+	if quota.MaxCounterShare > 0 {
+		for k, limit := range u.counterLimits {
+			if k < len(use.counters) && float64(use.counters[k]) >= float64(limit)*quota.MaxCounterShare {
+				return QuotaCounters
+			}
+		}
+	}
+	return ""
+}
+
+// Use records a transaction against the quotas of its sender and destination contract, counters being what it used
+// of the batch counters by counter key or nil when they are not tracked
+func (u *QuotaUsage) Use(sender common.Address, to *common.Address, gas uint64, counters []int) {
+	if _, ok := u.quotas.sender(sender); ok {
+		u.use(u.senders, sender, gas, counters)
+	}
+	if _, ok := u.quotas.contract(to); ok {
+		u.use(u.contracts, *to, gas, counters)
+	}
+}
+
+func (u *QuotaUsage) use(uses map[common.Address]*quotaUse, addr common.Address, gas uint64, counters []int) {
+	use, ok := uses[addr]
+	if !ok {
+		use = &quotaUse{counters: make([]int, len(u.counterLimits))}
+		uses[addr] = use
+	}
+	use.blockTxs++
+	use.batchGas += gas
+	for k := range use.counters {
+		if k < len(counters) {
+			use.counters[k] += counters[k]
+		}
+	}
+}
+
+// Quotas returns the current throughput quotas, reloaded when their configuration changed through Apollo
+func (p *TxPool) Quotas() *Quotas {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.quotasLocked()
+}
+
+func (p *TxPool) quotasLocked() *Quotas {
+	senders, contracts := p.xlayerCfg.SenderQuotas, p.xlayerCfg.ContractQuotas
+	if p.apolloCfg != nil {
+		senders = p.apolloCfg.GetSenderQuotas(senders)
+		contracts = p.apolloCfg.GetContractQuotas(contracts)
+	}
+	if p.quotas == nil || !maps.Equal(senders, p.quotas.sendersCfg) || !maps.Equal(contracts, p.quotas.contractsCfg) {
+		p.quotas = newQuotas(senders, contracts)
+	}
+	return p.quotas
+}
+
+// quotaUsageLocked returns what the yields on top of a block used of the quotas so far, nil when no quota is
+// configured.  A yield on top of another block starts over.
+func (p *TxPool) quotaUsageLocked(onTopOf uint64) *QuotaUsage {
+	quotas := p.quotasLocked()
+	if p.quotaUsage == nil || p.quotaUsage.quotas != quotas || p.quotaUsageOf != onTopOf {
+		p.quotaUsage, p.quotaUsageOf = quotas.NewUsage(nil), onTopOf
+	}
+	return p.quotaUsage
+}
+
+// StartQuotaBlock resets what the yields used of the quotas per block, the sequencer calls it as it starts a block
+func (p *TxPool) StartQuotaBlock() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.quotaUsage != nil {
+		p.quotaUsage.StartBlock()
+	}
+}
+
+func quotaThrottledCounter(kind, resource string) *metrics.Counter {
+	return metrics.GetOrCreateCounter(fmt.Sprintf(`txpool_quota_throttled{kind=%q,resource=%q}`, kind, resource))
+}
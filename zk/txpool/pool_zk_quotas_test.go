@@ -0,0 +1,110 @@
+package txpool
+
+import (
+	"sync"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+)
+
+func TestQuotaUsage(t *testing.T) {
+	hot, other := common.Address{5}, common.Address{6}
+	quotas := newQuotas(map[string]ethconfig.ThroughputQuota{
+		user.Hex():                {MaxTxsPerBlock: 2},
+		ethconfig.AnyAddressQuota: {MaxCounterShare: 0.5},
+	}, map[string]ethconfig.ThroughputQuota{
+		hot.Hex(): {MaxGasPerBatch: 100},
+	})
+	require.Nil(t, newQuotas(nil, nil).NewUsage(nil))
+
+	usage := quotas.NewUsage([]int{10, 20})
+	usage.StartBlock()
+
+	// the txs of a sender in a block
+	require.False(t, throttled(usage.Throttle(user, &other, 0)))
+	usage.Use(user, &other, 10, []int{1, 1})
+	usage.Use(user, &other, 10, []int{1, 1})
+	kind, resource, held := usage.Throttle(user, &other, 0)
+	require.True(t, held)
+	require.Equal(t, QuotaSender, kind)
+	require.Equal(t, QuotaTxs, resource)
+
+	// the gas of a contract in the batch
+	require.False(t, throttled(usage.Throttle(operator, &hot, 60)))
+	usage.Use(operator, &hot, 60, []int{1, 1})
+	kind, resource, held = usage.Throttle(project, &hot, 60)
+	require.True(t, held)
+	require.Equal(t, QuotaContract, kind)
+	require.Equal(t, QuotaGas, resource)
+	// a sender held back stays held back for the rest of the block
+	require.True(t, throttled(usage.Throttle(project, &other, 0)))
+
+	// the counters of the senders without a quota of their own
+	usage.Use(claimer, nil, 0, []int{4, 1})
+	require.False(t, throttled(usage.Throttle(claimer, nil, 0)))
+	usage.Use(claimer, nil, 0, []int{1, 1})
+	kind, resource, held = usage.Throttle(claimer, nil, 0)
+	require.True(t, held)
+	require.Equal(t, QuotaSender, kind)
+	require.Equal(t, QuotaCounters, resource)
+
+	// the txs per block start over, the gas and counters per batch do not
+	usage.StartBlock()
+	require.False(t, throttled(usage.Throttle(user, &other, 0)))
+	require.False(t, throttled(usage.Throttle(project, &other, 0)))
+	require.True(t, throttled(usage.Throttle(operator, &hot, 41)))
+	require.True(t, throttled(usage.Throttle(claimer, nil, 0)))
+}
+
+func throttled(_, _ string, throttled bool) bool {
+	return throttled
+}
+
+func TestBestQuotasAcrossYields(t *testing.T) {
+	p := &TxPool{
+		byHash:   map[string]*metaTx{},
+		senders:  newSendersCache(nil),
+		pending:  NewPendingSubPool(PendingSubPool, 10),
+		limbo:    newLimbo(),
+		lock:     &sync.Mutex{},
+		flushMtx: &sync.Mutex{},
+		xlayerCfg: XLayerConfig{SenderQuotas: map[string]ethconfig.ThroughputQuota{
+			user.Hex(): {MaxTxsPerBlock: 2},
+		}},
+	}
+	p.senders.senderID2Addr[1] = user
+	p.lastSeenBlock.Store(2)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		mt := &metaTx{Tx: &types.TxSlot{SenderID: 1, Nonce: nonce, Gas: 21_000, To: project, Rlp: []byte{byte(nonce)}}, currentSubPool: PendingSubPool}
+		mt.Tx.IDHash[0] = byte(nonce + 1)
+		p.byHash[string(mt.Tx.IDHash[:])] = mt
+		p.pending.Add(mt)
+	}
+
+	toSkip := mapset.NewSet[[32]byte]()
+	yield := func(n uint16, onTopOf uint64) int {
+		var txs types.TxsRlp
+		ok, count, err := p.best(n, &txs, nil, onTopOf, 1_000_000, 0, toSkip)
+		require.NoError(t, err)
+		require.True(t, ok)
+		return count
+	}
+
+	// the yields of a block share the quotas
+	require.Equal(t, 1, yield(1, 1))
+	require.Equal(t, 1, yield(10, 1))
+	require.Equal(t, 0, yield(10, 1))
+
+	// the next block of the batch is yielded on top of the same block and starts over
+	p.StartQuotaBlock()
+	require.Equal(t, 1, yield(10, 1))
+
+	// as does the next batch
+	toSkip.Clear()
+	require.Equal(t, 2, yield(10, 2))
+}
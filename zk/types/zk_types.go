@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
 
 	"bytes"
 	"encoding/binary"
@@ -168,3 +169,24 @@ type ForkInterval struct {
 	ToBatchNumber   uint64
 	BlockNumber     uint64
 }
+
+// VerificationFailure holds the details of a batch the executor failed to verify, either because of an error or a
+// state root mismatch, or because our counters undershot the ones of the executor
+type VerificationFailure struct {
+	BatchNumber       hexutil.Uint64   `json:"batchNumber"`
+	BlockNumbers      []hexutil.Uint64 `json:"blockNumbers"`
+	ForkId            hexutil.Uint64   `json:"forkId"`
+	ExecutorForkId    hexutil.Uint64   `json:"executorForkId"`
+	OldStateRoot      common.Hash      `json:"oldStateRoot"`
+	ExpectedStateRoot common.Hash      `json:"expectedStateRoot"`
+	ExecutorStateRoot common.Hash      `json:"executorStateRoot"`
+	Counters          map[string]int   `json:"counters"`
+	ExecutorCounters  map[string]int   `json:"executorCounters"`
+	// counters where ours are lower than the ones of the executor
+	CounterUndershoots []string  `json:"counterUndershoots,omitempty"`
+	ExecutorUrl        string    `json:"executorUrl"`
+	ExecutorError      string    `json:"executorError,omitempty"`
+	RomError           string    `json:"romError,omitempty"`
+	Error              string    `json:"error,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
+}
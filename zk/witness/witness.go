@@ -169,7 +169,8 @@ func (g *Generator) generateWitness(tx kv.Tx, ctx context.Context, batchNum uint
 	}()
 
 	areExecutorUrlsEmpty := len(g.zkConfig.ExecutorUrls) == 0 || g.zkConfig.ExecutorUrls[0] == ""
-	shouldGenerateMockWitness := g.zkConfig.MockWitnessGeneration && areExecutorUrlsEmpty
+	// the stateless verification needs the actual witness
+	shouldGenerateMockWitness := g.zkConfig.MockWitnessGeneration && areExecutorUrlsEmpty && !g.zkConfig.UseStatelessVerification()
 	if shouldGenerateMockWitness {
 		return g.generateMockWitness(batchNum, blocks, debug)
 	}